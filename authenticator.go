@@ -26,6 +26,9 @@ type TFAOptions string
 // MessageType describes a classification of a Message
 type MessageType string
 
+// DeliveryStatus describes the progress of a Message's delivery.
+type DeliveryStatus string
+
 const (
 	// OTPEmail allows a user to complete TFA with an OTP
 	// code delivered via email.
@@ -36,9 +39,15 @@ const (
 	// TOTP allows a user to complete TFA with a TOTP
 	// device or application.
 	TOTP = "totp"
+	// HOTP allows a user to complete TFA with a counter based
+	// hardware token.
+	HOTP = "hotp"
 	// FIDODevice allows a user to complete TFA with a Webauthn
 	// compliant device.
 	FIDODevice = "device"
+	// RecoveryCode allows a user to complete TFA with a single
+	// use backup recovery code.
+	RecoveryCode = "recovery_code"
 )
 
 const (
@@ -46,6 +55,8 @@ const (
 	Phone DeliveryMethod = "phone"
 	// Email is a delivery method for email.
 	Email = "email"
+	// Voice is a delivery method for voice calls to a phone number.
+	Voice = "voice"
 )
 
 const (
@@ -55,6 +66,13 @@ const (
 	// JWTAuthorized represents a the state of a user after completing
 	// the final step of login or signup.
 	JWTAuthorized TokenState = "authorized"
+	// JWTResetPreAuthorized represents the state of a user before completing
+	// the TFA step of a password reset.
+	JWTResetPreAuthorized TokenState = "reset_pre_authorized"
+	// JWTResetAuthorized represents the state of a user after completing
+	// the TFA step of a password reset. Tokens in this state may only be
+	// used once to set a new password.
+	JWTResetAuthorized TokenState = "reset_authorized"
 )
 
 const (
@@ -66,6 +84,42 @@ const (
 	OTPLogin MessageType = "otp_login"
 	// OTPSignup is a message containing an OTP code for signup.
 	OTPSignup MessageType = "otp_signup"
+	// OTPReset is a message containing an OTP code for password reset.
+	OTPReset MessageType = "otp_reset"
+	// OTPConfirm is a message containing an OTP code to confirm
+	// a change to account settings.
+	OTPConfirm MessageType = "otp_confirm"
+	// MagicLink is a message containing a one-click link to complete
+	// passwordless login or signup.
+	MagicLink MessageType = "magic_link"
+	// OTPUnlock is a message containing an OTP code to unlock an
+	// account locked after repeated failed login attempts.
+	OTPUnlock MessageType = "otp_unlock"
+)
+
+// MessageTypes are all MessageTypes sent to Users.
+var MessageTypes = []MessageType{
+	OTPAddress,
+	OTPResend,
+	OTPLogin,
+	OTPSignup,
+	OTPReset,
+	OTPConfirm,
+	MagicLink,
+	OTPUnlock,
+}
+
+const (
+	// MessageQueued is a Message waiting to be delivered.
+	MessageQueued DeliveryStatus = "queued"
+	// MessageSent is a Message delivered to a provider.
+	MessageSent DeliveryStatus = "sent"
+	// MessageFailed is a Message which expired after failed delivery
+	// attempts. Failed Messages are moved to a dead-letter store.
+	MessageFailed DeliveryStatus = "failed"
+	// MessageExpired is a Message which expired before a delivery
+	// was attempted.
+	MessageExpired DeliveryStatus = "expired"
 )
 
 // User represents a user who is registered with the service.
@@ -76,10 +130,17 @@ type User struct {
 	Phone sql.NullString
 	// Email is an email address associated with the account.
 	Email sql.NullString
-	// Password is the current User provided password.
+	// Password is the current User provided password. Password
+	// may be empty for Users registered in passwordless mode.
 	Password string
 	// TFASecret is a a secret string used to generate 2FA TOTP codes.
 	TFASecret string
+	// HOTPSecret is the encrypted seed of a HOTP hardware token
+	// enrolled for the user.
+	HOTPSecret string
+	// HOTPCounter is the counter of the next HOTP code expected
+	// from the user's hardware token.
+	HOTPCounter int64
 	// IsPhoneAllowed specifies a user may complete authentication
 	// by verifying an OTP code delivered through SMS.
 	IsPhoneOTPAllowed bool
@@ -89,15 +150,25 @@ type User struct {
 	// IsTOTPAllowed specifies a user may complete authentication
 	// by verifying a TOTP code.
 	IsTOTPAllowed bool
+	// IsHOTPAllowed specifies a user may complete authentication
+	// by verifying a HOTP code from a hardware token.
+	IsHOTPAllowed bool
 	// IsDeviceAllowed specifies a user may complete authentication
 	// by verifying a WebAuthn capable device.
 	IsDeviceAllowed bool
+	// IsRecoveryCodeAllowed specifies a user may complete authentication
+	// by verifying a single use recovery code.
+	IsRecoveryCodeAllowed bool
 	// IsVerified tells us if a user confirmed ownership of
 	// an email or phone number by validating a one time code
 	// after registration.
 	IsVerified bool
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// Locale is the User's preferred language for messages
+	// as a BCP 47 language tag (e.g. en or pt-BR). An empty
+	// Locale uses the default language.
+	Locale    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 // DefaultTFA is the recommended enabled TFA option clients should
@@ -111,6 +182,10 @@ func (u *User) DefaultTFA() TFAOptions {
 		return TOTP
 	}
 
+	if u.IsHOTPAllowed {
+		return HOTP
+	}
+
 	if u.IsEmailOTPAllowed {
 		return OTPEmail
 	}
@@ -130,7 +205,7 @@ func (u *User) DefaultOTPDelivery() DeliveryMethod {
 // CanSendDefaultOTP determines if an OTP code should be sent out
 // to a user immediately as a 2FA option.
 func (u *User) CanSendDefaultOTP() bool {
-	if u.IsDeviceAllowed || u.IsTOTPAllowed {
+	if u.IsDeviceAllowed || u.IsTOTPAllowed || u.IsHOTPAllowed {
 		return false
 	}
 
@@ -138,6 +213,36 @@ func (u *User) CanSendDefaultOTP() bool {
 	return isOTPEnabled
 }
 
+// HOTPSeed is the secret of a HOTP hardware token as provided
+// by a token vendor.
+type HOTPSeed struct {
+	// Serial is the serial number printed on the token.
+	Serial string
+	// Secret is the shared secret used to generate codes.
+	Secret []byte
+	// Counter is the counter of the next code generated by the
+	// token.
+	Counter int64
+	// Digits is the length of codes generated by the token.
+	Digits int
+}
+
+// TFAOption returns the 2FA option for an OTP code
+// delivered through the delivery method.
+func (d DeliveryMethod) TFAOption() TFAOptions {
+	if d == Email {
+		return OTPEmail
+	}
+
+	return OTPPhone
+}
+
+// IsPhone returns true if the delivery method sends messages to
+// a phone number.
+func (d DeliveryMethod) IsPhone() bool {
+	return d == Phone || d == Voice
+}
+
 // DefaultName returns the default name for a user (email or phone).
 func (u *User) DefaultName() string {
 	if u.Email.String != "" {
@@ -187,6 +292,12 @@ type LoginHistory struct {
 	IsRevoked bool
 	// ExpiresAt is the expiry time of the JWT token.
 	ExpiresAt time.Time
+	// IPAddress is the client IP address at login.
+	IPAddress string
+	// UserAgent is the client user agent at login.
+	UserAgent string
+	// TFAMethod is the 2FA option used to complete login.
+	TFAMethod TFAOptions
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -240,6 +351,12 @@ type Token struct {
 
 // Message is a message to be delivered to a user.
 type Message struct {
+	// ID uniquely identifies a Message. It is set when a Message
+	// is first sent and is retained across delivery attempts.
+	ID string
+	// ReceiptID identifies a Message in a MessageRepository. It is
+	// set when a Message is retrieved for delivery.
+	ReceiptID string
 	// Type describes the classification of a Message.
 	Type MessageType
 	// Subject is a human readable subject describe the Message.
@@ -251,22 +368,85 @@ type Message struct {
 	Vars map[string]string
 	// Content of the message.
 	Content string
+	// Locale is the language used to render templated content.
+	Locale string
 	// Delivery address of the user (e.g. phone or email).
 	Address string
 	// ExpiresAt is the latest time we can attempt delivery.
 	ExpiresAt time.Time
 	// DeliveryAttempts is the total amount of delivery attempts made.
 	DeliveryAttempts int
+	// LastError is the error returned by a provider on the
+	// most recent failed delivery attempt.
+	LastError string
+}
+
+// MessageStatus records the delivery status of a Message.
+type MessageStatus struct {
+	// MessageID is the ID of the Message.
+	MessageID string
+	// Type describes the classification of the Message.
+	Type MessageType
+	// Delivery type of the Message (e.g. phone or email).
+	Delivery DeliveryMethod
+	// Status is the current DeliveryStatus of the Message.
+	Status DeliveryStatus
+	// DeliveryAttempts is the total amount of delivery attempts made.
+	DeliveryAttempts int
+	// LastError is the error returned by a provider on the
+	// most recent failed delivery attempt.
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DeadLetter is a Message which failed to be delivered before expiry.
+type DeadLetter struct {
+	// Message is the undelivered Message.
+	Message *Message
+	// LastError is the error returned by a provider on the
+	// final delivery attempt.
+	LastError string
+	CreatedAt time.Time
 }
 
 // MessageRepository represents a local storage for outgoing messages.
 // This service will deliver OTP codes via email or SMS if enabled for the user.
 type MessageRepository interface {
 	// Publish prepares a message for a user. Behind the scenes we write the
-	// message into a channel to be processed by a consumer.
+	// message into a queue to be processed by a consumer. Messages published
+	// after a failed delivery attempt are delayed before they are retried.
 	Publish(ctx context.Context, msg *Message) error
 	// Recent retrieves a list of messages to be delivered.
 	Recent(ctx context.Context) (<-chan *Message, <-chan error)
+	// Ack acknowledges a message retrieved from Recent has been
+	// processed and may be removed from the repository. Messages
+	// that are not acknowledged may be delivered again.
+	Ack(ctx context.Context, msg *Message) error
+}
+
+// MessageStatusRepository represents a local storage for MessageStatus.
+type MessageStatusRepository interface {
+	// ByID retrieves the MessageStatus of a Message by the Message's ID.
+	ByID(ctx context.Context, messageID string) (*MessageStatus, error)
+	// Save creates a MessageStatus or updates an existing one.
+	Save(ctx context.Context, status *MessageStatus) error
+}
+
+// DeadLetterRepository represents a local storage for DeadLetters.
+type DeadLetterRepository interface {
+	// ByID retrieves a DeadLetter by the ID of its Message.
+	ByID(ctx context.Context, messageID string) (*DeadLetter, error)
+	// Recent retrieves DeadLetters ordered from most to least recent.
+	// It supports pagination through a limit or offset value.
+	Recent(ctx context.Context, limit, offset int) ([]*DeadLetter, error)
+	// Create creates a new DeadLetter.
+	Create(ctx context.Context, deadLetter *DeadLetter) error
+	// GetForUpdate retrieves a DeadLetter by the ID of its Message
+	// for updating.
+	GetForUpdate(ctx context.Context, messageID string) (*DeadLetter, error)
+	// Remove removes a DeadLetter by the ID of its Message.
+	Remove(ctx context.Context, messageID string) error
 }
 
 // LoginHistoryRepository represents a local storage for LoginHistory.
@@ -282,6 +462,10 @@ type LoginHistoryRepository interface {
 	GetForUpdate(ctx context.Context, tokenID string) (*LoginHistory, error)
 	// Update updates a LoginHistory.
 	Update(ctx context.Context, login *LoginHistory) error
+	// RevokeByUserID revokes all unexpired LoginHistory associated with
+	// a User's ID, except for an optional token ID. It returns the IDs of
+	// the revoked tokens.
+	RevokeByUserID(ctx context.Context, userID, excludeTokenID string) ([]string, error)
 }
 
 // DeviceRepository represents a local storage for Device.
@@ -303,6 +487,17 @@ type DeviceRepository interface {
 	Remove(ctx context.Context, deviceID, userID string) error
 }
 
+// RecoveryCodeRepository represents a local storage for a User's
+// recovery codes. Codes are stored as hashes.
+type RecoveryCodeRepository interface {
+	// Replace removes all existing recovery codes for a User
+	// and stores a new set.
+	Replace(ctx context.Context, userID string, codes []string) error
+	// Consume marks an unused recovery code as used. An error is
+	// returned if no unused code matches.
+	Consume(ctx context.Context, userID, code string) error
+}
+
 // UserRepository represents a local storage for User.
 type UserRepository interface {
 	// ByIdentity retrieves a User by some whitelisted identity
@@ -323,6 +518,18 @@ type UserRepository interface {
 	DisableOTP(ctx context.Context, userID string, method DeliveryMethod) (*User, error)
 	// RemoveDeliveryMethod removes a phone or email from a User.
 	RemoveDeliveryMethod(ctx context.Context, userID string, method DeliveryMethod) (*User, error)
+	// List retrieves a page of Users ordered by ID, starting after
+	// the User with afterID.
+	List(ctx context.Context, afterID string, limit int) ([]*User, error)
+	// UpdateTFASecret replaces a User's TFA secret if it has not
+	// changed from the current value.
+	UpdateTFASecret(ctx context.Context, userID, current, secret string) (bool, error)
+	// UpdateHOTPCounter replaces a User's HOTP counter if it has not
+	// changed from the current value.
+	UpdateHOTPCounter(ctx context.Context, userID string, current, counter int64) (bool, error)
+	// UpdatePassword replaces a User's password hash if it has not
+	// changed from the current value.
+	UpdatePassword(ctx context.Context, userID, current, hash string) (bool, error)
 }
 
 // RepositoryManager manages repositories stored in storages
@@ -340,13 +547,22 @@ type RepositoryManager interface {
 	Device() DeviceRepository
 	// User returns a UserRepository.
 	User() UserRepository
+	// RecoveryCode returns a RecoveryCodeRepository.
+	RecoveryCode() RecoveryCodeRepository
+	// MessageStatus returns a MessageStatusRepository.
+	MessageStatus() MessageStatusRepository
+	// DeadLetter returns a DeadLetterRepository.
+	DeadLetter() DeadLetterRepository
 }
 
 // TokenConfiguration provides configurable settings for a JWT token.
 type TokenConfiguration struct {
-	DeliveryMethod   DeliveryMethod
-	DeliveryAddress  string
-	RefreshableToken *Token
+	DeliveryMethod     DeliveryMethod
+	DeliveryAddress    string
+	MessageType        MessageType
+	RefreshableToken   *Token
+	RotateRefreshToken bool
+	ExpiresIn          time.Duration
 }
 
 // TokenOption configures a new JWT token.
@@ -364,6 +580,12 @@ type TokenService interface {
 	Validate(ctx context.Context, signedToken string, clientID string) (*Token, error)
 	// Revoke Revokes a token by it's ID.
 	Revoke(ctx context.Context, tokenID string) error
+	// RevokeAll revokes all unexpired tokens for a User, except
+	// for an optional token ID.
+	RevokeAll(ctx context.Context, userID, excludeTokenID string) error
+	// JWKS returns a JSON Web Key Set of public keys used to
+	// verify signed tokens.
+	JWKS(ctx context.Context) ([]byte, error)
 	// Cookies returns secure cookies to accompany a token.
 	Cookies(ctx context.Context, token *Token) []*http.Cookie
 	// Refreshable checks if a provided token can be refreshed.
@@ -391,8 +613,21 @@ type PasswordService interface {
 	// Validate determines if a submitted pasword is valid for a stored
 	// password hash.
 	Validate(user *User, password string) error
+	// NeedsRehash determines if a stored password hash should be
+	// replaced by a hash with the current algorithm and cost.
+	NeedsRehash(hash string) bool
 	// OKForUser checks if a password may be used for a user.
 	OKForUser(password string) error
+	// CheckBreached checks if a new password has been exposed in
+	// a data breach.
+	CheckBreached(ctx context.Context, password string) error
+}
+
+// BreachChecker checks passwords against a corpus of passwords
+// exposed in data breaches.
+type BreachChecker interface {
+	// IsBreached determines if a password appears in the corpus.
+	IsBreached(ctx context.Context, password string) (bool, error)
 }
 
 // OTPService manages the protocol for SMS/Email 2FA codes and TOTP codes.
@@ -401,12 +636,40 @@ type OTPService interface {
 	TOTPQRString(u *User) (string, error)
 	// TOTPSecret creates a TOTP secret for code generation.
 	TOTPSecret(u *User) (string, error)
-	// OTPCode creates a random OTP code and hash.
-	OTPCode(address string, method DeliveryMethod) (code, hash string, err error)
+	// OTPCode creates a random OTP code and hash for a message type.
+	OTPCode(address string, method DeliveryMethod, msgType MessageType) (code, hash string, err error)
 	// ValidateOTP checks if a User email/sms delivered OTP code is valid.
-	ValidateOTP(code, hash string) error
+	// Attempts are tracked by the ID of the token the code was issued with.
+	ValidateOTP(ctx context.Context, id, code, hash string) error
 	// ValidateTOTP checks if a User TOTP code is valid.
 	ValidateTOTP(ctx context.Context, user *User, code string) error
+	// HOTPSecret creates an encrypted secret from the seed of a
+	// HOTP hardware token.
+	HOTPSecret(seed *HOTPSeed) (string, error)
+	// ValidateHOTP checks if a User HOTP code is valid and returns
+	// the counter of the next expected code.
+	ValidateHOTP(ctx context.Context, user *User, code string) (int64, error)
+	// RecoveryCodes creates a set of random single use recovery codes.
+	RecoveryCodes() ([]string, error)
+	// ReencryptTOTPSecret encrypts a TOTP secret with the latest
+	// secret key.
+	ReencryptTOTPSecret(secret string) (string, error)
+}
+
+// LockoutService tracks failed authentication attempts for a User
+// and restricts further attempts after repeated failures.
+type LockoutService interface {
+	// Check returns an error if a User is locked out or must wait
+	// before attempting to authenticate again.
+	Check(ctx context.Context, userID string) error
+	// Fail records a failed authentication attempt for a User.
+	Fail(ctx context.Context, userID string) error
+	// Reset clears all failed attempts and any lockout for a User.
+	Reset(ctx context.Context, userID string) error
+	// UnlockCode creates an OTP code to unlock a locked out User.
+	UnlockCode(ctx context.Context, userID, address string, method DeliveryMethod) (string, error)
+	// Unlock validates an unlock code and clears the User's lockout.
+	Unlock(ctx context.Context, userID, code string) error
 }
 
 // MessagingService sends messages through email or SMS.
@@ -415,6 +678,14 @@ type MessagingService interface {
 	Send(ctx context.Context, msg *Message) error
 }
 
+// MessageThrottle restricts the volume of messages sent to an address,
+// phone number range or country.
+type MessageThrottle interface {
+	// Allow records an outgoing message and returns an error if it
+	// exceeds a send limit or its destination is not permitted.
+	Allow(ctx context.Context, msg *Message) error
+}
+
 // LoginAPI provides HTTP handlers for user authentication.
 type LoginAPI interface {
 	// Login is the initial login step to identify a User.
@@ -430,6 +701,11 @@ type LoginAPI interface {
 	// a TOTP or randomly generated code delivered by SMS/Email.
 	// On success it will return a JWT token in an auhtorized state.
 	VerifyCode(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// Unlock delivers an OTP code to unlock an account locked
+	// after repeated failed login attempts.
+	Unlock(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// VerifyUnlock unlocks an account with a delivered OTP code.
+	VerifyUnlock(w http.ResponseWriter, r *http.Request) (interface{}, error)
 }
 
 // SignUpAPI provides HTTP handlers for user registration.
@@ -498,19 +774,74 @@ type DeviceAPI interface {
 type TokenAPI interface {
 	// Revoke revokes a User's token for a logged in session.
 	Revoke(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// RevokeAll revokes all of a User's tokens, optionally keeping
+	// the current session.
+	RevokeAll(w http.ResponseWriter, r *http.Request) (interface{}, error)
 	// Verify verifies a User's token is authenticated and
 	// valid. A valid token is not expired and not revoked.
 	Verify(w http.ResponseWriter, r *http.Request) (interface{}, error)
 	// Refresh refreshes an expired token with a new expiry time.
 	// Refreshed tokens share a token's original ID and client ID.
 	Refresh(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// JWKS returns the public keys used to verify JWT tokens so other
+	// services may verify tokens without being able to sign them.
+	JWKS(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// List returns a User's recent login sessions.
+	List(w http.ResponseWriter, r *http.Request) (interface{}, error)
+}
+
+// ResetAPI provides HTTP handlers to reset a User's password.
+type ResetAPI interface {
+	// Request is the initial password reset step to identify a User.
+	// On success it will return a JWT token in a reset_pre_authorized state.
+	Request(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// DeviceChallenge retrieves a device challenge to be signed by the client.
+	DeviceChallenge(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// VerifyDevice verifies a User's authenticity by verifying
+	// a signing device owned by the user. On success it will return
+	// a JWT token in a reset_authorized state.
+	VerifyDevice(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// VerifyCode verifies a User's authenticity by verifying
+	// a TOTP or randomly generated code delivered by SMS/Email.
+	// On success it will return a JWT token in a reset_authorized state.
+	VerifyCode(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// Reset sets a new password for a User. All outstanding tokens
+	// for the User are revoked after the password is changed.
+	Reset(w http.ResponseWriter, r *http.Request) (interface{}, error)
 }
 
 // UserAPI proivdes HTTP handlers to configure a registered User's
 // account.
 type UserAPI interface {
-	// UpdatePassword change's a User's password.
+	// UpdatePassword change's a User's password. The User must provide
+	// their current password and complete 2FA.
 	UpdatePassword(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// Profile returns a User's contact addresses and enabled
+	// TFA options.
+	Profile(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// SendCode delivers an OTP code to an enabled address so a User
+	// may complete 2FA before changing account settings.
+	SendCode(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// DeviceChallenge retrieves a device challenge to be signed by the
+	// client so a User may complete 2FA before changing account settings.
+	DeviceChallenge(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// RecoveryCodes generates a new set of recovery codes for a User,
+	// replacing any existing codes. The User must complete 2FA.
+	RecoveryCodes(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// UpdateLocale sets the language a User receives messages in.
+	UpdateLocale(w http.ResponseWriter, r *http.Request) (interface{}, error)
+}
+
+// MessageAPI provides HTTP handlers for administrators to inspect
+// and replay outgoing messages.
+type MessageAPI interface {
+	// Status retrieves the delivery status of a Message.
+	Status(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// DeadLetters retrieves Messages which failed to be delivered.
+	DeadLetters(w http.ResponseWriter, r *http.Request) (interface{}, error)
+	// Replay removes a Message from the dead-letter store and
+	// publishes it for delivery again.
+	Replay(w http.ResponseWriter, r *http.Request) (interface{}, error)
 }
 
 // Emailer exposes an email API.
@@ -524,3 +855,10 @@ type SMSer interface {
 	// SMS sends an SMS to an phone number
 	SMS(ctx context.Context, phoneNumber string, message string) error
 }
+
+// Voicer exposes a voice call API.
+type Voicer interface {
+	// Voice calls a phone number and reads a message aloud. The
+	// locale is the language of the message and may be empty.
+	Voice(ctx context.Context, phoneNumber, message, locale string) error
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/fmitra/authenticator/internal/breachfile"
+)
+
+const (
+	defaultBloomFPRate  = 0.001
+	defaultPrefixLength = 8
+)
+
+// runBreach executes the breach subcommand:
+//
+//	breach bloom <hashes> <output> [false-positive-rate]  Build a Bloom filter from a file of SHA-1 hashes
+//	breach prefix <hashes> <output> [prefix-bytes]        Build a prefix list from a file of sorted SHA-1 hashes
+//
+// Hashes are read in the format published by Have I Been Pwned.
+func runBreach(out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("breach requires a command: bloom, prefix")
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return fmt.Errorf("breach %s requires a hash file and output file", args[0])
+	}
+
+	switch args[0] {
+	case "bloom":
+		fpRate := defaultBloomFPRate
+		if len(args) == 4 {
+			rate, err := strconv.ParseFloat(args[3], 64)
+			if err != nil {
+				return fmt.Errorf("invalid false positive rate: %w", err)
+			}
+			fpRate = rate
+		}
+
+		count, err := buildBloomFilter(args[1], args[2], fpRate)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%v hash(es) added to bloom filter\n", count)
+		return nil
+	case "prefix":
+		length := defaultPrefixLength
+		if len(args) == 4 {
+			l, err := strconv.Atoi(args[3])
+			if err != nil {
+				return fmt.Errorf("invalid prefix length: %w", err)
+			}
+			length = l
+		}
+
+		count, err := buildPrefixList(args[1], args[2], length)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%v prefix(es) added to prefix list\n", count)
+		return nil
+	default:
+		return fmt.Errorf("unknown breach command %s", args[0])
+	}
+}
+
+// buildBloomFilter writes a Bloom filter sized for the number of
+// hashes in a hash file, which is read twice.
+func buildBloomFilter(hashPath, outPath string, fpRate float64) (uint64, error) {
+	var count uint64
+	err := readHashes(hashPath, func(sum [sha1.Size]byte) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	filter, err := breachfile.NewBloomFilter(count, fpRate)
+	if err != nil {
+		return 0, err
+	}
+
+	err = readHashes(hashPath, func(sum [sha1.Size]byte) error {
+		filter.Add(sum)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = writeFile(outPath, func(w io.Writer) error {
+		_, err := filter.WriteTo(w)
+		return err
+	})
+
+	return count, err
+}
+
+func buildPrefixList(hashPath, outPath string, length int) (int, error) {
+	f, err := os.Open(hashPath)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open hash file: %w", err)
+	}
+	defer f.Close()
+
+	var count int
+	err = writeFile(outPath, func(w io.Writer) error {
+		count, err = breachfile.WritePrefixList(w, bufio.NewReader(f), length)
+		return err
+	})
+
+	return count, err
+}
+
+func readHashes(path string, fn func(sum [sha1.Size]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open hash file: %w", err)
+	}
+	defer f.Close()
+
+	return breachfile.ParseHashes(bufio.NewReader(f), fn)
+}
+
+// writeFile creates a file with buffered content from fn. The file is
+// removed if its content cannot be written.
+func writeFile(path string, fn func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create output file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if err = fn(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/otp"
+)
+
+// runHOTP executes the hotp subcommand:
+//
+//	hotp enroll <file> <serial> <identity>  Enroll a User with a hardware token from a PSKC or CSV seed file
+//	hotp disable <identity>                 Remove the hardware token of a User
+//
+// Users are identified by their email address or phone number.
+func runHOTP(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, psk []byte, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("hotp requires a command: enroll, disable")
+	}
+
+	switch args[0] {
+	case "enroll":
+		if len(args) != 4 {
+			return fmt.Errorf("hotp enroll requires a seed file, token serial and user identity")
+		}
+		seed, err := findHOTPSeed(args[1], args[2], psk)
+		if err != nil {
+			return err
+		}
+		if err = enrollHOTP(ctx, repoMngr, otpSvc, seed, args[3]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "token %s enrolled for %s\n", seed.Serial, args[3])
+		return nil
+	case "disable":
+		if len(args) != 2 {
+			return fmt.Errorf("hotp disable requires a user identity")
+		}
+		if err := disableHOTP(ctx, repoMngr, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "token disabled for %s\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown hotp command %s", args[0])
+	}
+}
+
+// findHOTPSeed returns the seed of a token from a vendor's seed file.
+// Files with an .xml or .pskc extension are parsed as PSKC, and all
+// other files as CSV.
+func findHOTPSeed(path, serial string, psk []byte) (*auth.HOTPSeed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open seed file: %w", err)
+	}
+	defer f.Close()
+
+	var seeds []*auth.HOTPSeed
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml", ".pskc":
+		seeds, err = otp.ParsePSKC(f, psk)
+	default:
+		seeds, err = otp.ParseHOTPCSV(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seed := range seeds {
+		if seed.Serial == serial {
+			return seed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("token %s not found in seed file", serial)
+}
+
+func enrollHOTP(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, seed *auth.HOTPSeed, identity string) error {
+	user, err := repoMngr.User().ByIdentity(ctx, identityAttribute(identity), identity)
+	if err != nil {
+		return fmt.Errorf("cannot find user %s: %w", identity, err)
+	}
+
+	secret, err := otpSvc.HOTPSecret(seed)
+	if err != nil {
+		return err
+	}
+
+	client, err := repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.WithAtomic(func() (interface{}, error) {
+		user, err := client.User().GetForUpdate(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		user.HOTPSecret = secret
+		user.HOTPCounter = seed.Counter
+		user.IsHOTPAllowed = true
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot set hotp secret: %w", err)
+		}
+
+		return user, nil
+	})
+	return err
+}
+
+func disableHOTP(ctx context.Context, repoMngr auth.RepositoryManager, identity string) error {
+	user, err := repoMngr.User().ByIdentity(ctx, identityAttribute(identity), identity)
+	if err != nil {
+		return fmt.Errorf("cannot find user %s: %w", identity, err)
+	}
+
+	client, err := repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.WithAtomic(func() (interface{}, error) {
+		user, err := client.User().GetForUpdate(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		isTFADisabled := !user.IsPhoneOTPAllowed && !user.IsEmailOTPAllowed &&
+			!user.IsDeviceAllowed && !user.IsTOTPAllowed
+		if isTFADisabled {
+			return nil, fmt.Errorf("a 2FA option must be enabled to disable hotp")
+		}
+
+		user.HOTPSecret = ""
+		user.HOTPCounter = 0
+		user.IsHOTPAllowed = false
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot remove hotp secret: %w", err)
+		}
+
+		return user, nil
+	})
+	return err
+}
+
+// identityAttribute returns the User attribute of an email
+// address or phone number.
+func identityAttribute(identity string) string {
+	if strings.Contains(identity, "@") {
+		return "Email"
+	}
+	return "Phone"
+}
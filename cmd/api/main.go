@@ -4,7 +4,10 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/smtp"
 	"os"
@@ -21,25 +24,40 @@ import (
 	"github.com/oklog/run"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 
 	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/breachfile"
 	"github.com/fmitra/authenticator/internal/contactapi"
 	"github.com/fmitra/authenticator/internal/deviceapi"
+	"github.com/fmitra/authenticator/internal/failover"
+	"github.com/fmitra/authenticator/internal/hibp"
 	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/lockout"
 	"github.com/fmitra/authenticator/internal/loginapi"
 	"github.com/fmitra/authenticator/internal/mail"
+	"github.com/fmitra/authenticator/internal/mailgun"
+	"github.com/fmitra/authenticator/internal/messageapi"
+	"github.com/fmitra/authenticator/internal/messagebird"
 	"github.com/fmitra/authenticator/internal/msgconsumer"
+	"github.com/fmitra/authenticator/internal/msgoutbox"
 	"github.com/fmitra/authenticator/internal/msgpublisher"
 	"github.com/fmitra/authenticator/internal/msgrepo"
+	"github.com/fmitra/authenticator/internal/msgstream"
+	"github.com/fmitra/authenticator/internal/msgthrottle"
 	"github.com/fmitra/authenticator/internal/otp"
 	"github.com/fmitra/authenticator/internal/password"
 	"github.com/fmitra/authenticator/internal/postgres"
+	"github.com/fmitra/authenticator/internal/resetapi"
 	"github.com/fmitra/authenticator/internal/sendgrid"
+	"github.com/fmitra/authenticator/internal/ses"
 	"github.com/fmitra/authenticator/internal/signupapi"
 	"github.com/fmitra/authenticator/internal/token"
 	"github.com/fmitra/authenticator/internal/tokenapi"
 	"github.com/fmitra/authenticator/internal/totpapi"
 	"github.com/fmitra/authenticator/internal/twilio"
+	"github.com/fmitra/authenticator/internal/userapi"
+	"github.com/fmitra/authenticator/internal/vonage"
 	"github.com/fmitra/authenticator/internal/webauthn"
 )
 
@@ -60,37 +78,116 @@ func main() {
 		fs.Bool("api.debug", false, "Enable debug logging")
 		fs.String("api.http-addr", ":8080", "Address to listen on")
 		fs.String("api.allowed-origins", "*", "Comma separated list of allowed origins")
+		fs.String("api.trusted-proxies", "", "Comma separated list of IP addresses and CIDR ranges of trusted reverse proxies")
 		fs.String("api.cookie-domain", "", "Domain to set HTTP cookie")
 		fs.Int("api.cookie-max-age", 605800, "Max age of cookie, in seconds")
+		fs.String("admin.api-key", "", "API key for admin endpoints. Admin endpoints are disabled if not set")
 		fs.String("pg.conn-string", "", "Postgres connection string")
 		fs.String("redis.conn-string", "", "Redis connection string")
 		fs.Int("password.min-length", 8, "Minimum password length")
 		fs.Int("password.max-length", 1000, "Maximum password length")
+		fs.String("password.algorithm", "argon2id", "Algorithm to hash new passwords, argon2id or bcrypt")
+		fs.Int("password.argon2.memory", 64*1024, "Memory used by argon2id, in KiB")
+		fs.Int("password.argon2.time", 3, "Number of argon2id passes over the memory")
+		fs.Int("password.argon2.parallelism", 4, "Number of threads used by argon2id")
+		fs.Int("password.bcrypt.cost", 10, "Cost of bcrypt hashes")
+		fs.String("password.breach.mode", "off", "Action on breached passwords: off, warn or reject")
+		fs.String("password.breach.file", "", "Bloom filter or prefix list of breached passwords. The Pwned Passwords API is used if not set")
+		fs.String("password.breach.api-url", "", "Pwned Passwords API endpoint")
+		fs.Duration("password.breach.timeout", time.Second*2, "Timeout of Pwned Passwords API requests")
+		fs.Bool("passwordless.enabled", false, "Enable login and signup without a password")
+		fs.String("passwordless.magic-link-url", "", "Client URL to complete login through a magic link")
+		fs.Int("lockout.delay-after", 3, "Failed login attempts before attempts are delayed")
+		fs.Int("lockout.max-attempts", 10, "Failed login attempts before an account is locked")
+		fs.Duration("lockout.base-delay", time.Second, "Delay after the first delayed login attempt")
+		fs.Duration("lockout.max-delay", time.Minute*5, "Maximum delay between login attempts")
+		fs.Duration("lockout.duration", time.Minute*30, "Time an account remains locked")
+		fs.Duration("lockout.window", time.Hour, "Time a failed login attempt is remembered")
 		fs.Int("otp.code-length", 6, "OTP code length")
+		fs.String("otp.code-alphabet", "numeric", "Characters used in OTP codes (numeric, alphanumeric)")
+		fs.Duration("otp.code-expiry", time.Minute*5, "OTP code expiry time")
+		fs.String("otp.type-expiry", "", "Comma separated list of OTP code expiry times by message type (e.g. magic_link=15m)")
+		fs.Int64("otp.max-attempts", 5, "Incorrect submissions of an OTP code before a new code must be requested. 0 disables the limit")
 		fs.String("otp.issuer", "", "TOTP issuer domain")
+		fs.String("otp.totp.algorithm", "SHA1", "Hash algorithm of new TOTP secrets (SHA1, SHA256, SHA512)")
+		fs.Int("otp.totp.digits", 6, "Digits in codes of new TOTP secrets (6, 8)")
+		fs.Duration("otp.totp.period", time.Second*30, "Time a code of a new TOTP secret is valid for")
+		fs.Int("otp.totp.skew", 1, "Time steps before or after the current time in which a TOTP code is accepted")
+		fs.Int("otp.totp.qr-size", 256, "Default width and height in pixels of TOTP QR codes")
+		fs.String("otp.totp.qr-recovery-level", "M", "Error correction level of TOTP QR codes (L, M, Q, H)")
+		fs.Int("otp.hotp.look-ahead", 10, "HOTP codes after the expected code that are accepted")
+		fs.Int("otp.hotp.resync-window", 100, "HOTP codes after the expected code that are accepted when confirmed by the next code")
+		fs.String("otp.hotp.pskc-key", "", "Hex encoded pre-shared key to decrypt PSKC seed files")
 		fs.String("otp.secret.key", "", "Encryption key for TOTP secrets")
 		fs.Int("otp.secret.version", 1, "Current version of encryption key")
 		fs.Int("msgconsumer.workers", 4, "Total number of workers to process outgoing messages")
+		fs.Duration("msgconsumer.drain-timeout", time.Second*10, "Time to finish sending in-flight messages on shutdown")
+		fs.String("msgpublisher.template-dir", "templates", "Directory containing per-locale message templates")
+		fs.String("msgpublisher.default-locale", "en", "Locale used for Users without a supported locale preference")
+		fs.Int64("msgthrottle.address-max", 10, "Maximum messages sent to an email address or phone number per window. 0 disables the limit")
+		fs.Duration("msgthrottle.address-window", time.Hour, "Window of the per address message limit")
+		fs.Int("msgthrottle.prefix-length", 7, "Leading digits of a phone number, including the country code, grouped by the prefix limit")
+		fs.Int64("msgthrottle.prefix-max", 0, "Maximum SMS sent to phone numbers sharing a prefix per window. 0 disables the limit")
+		fs.Duration("msgthrottle.prefix-window", time.Hour, "Window of the phone number prefix limit")
+		fs.Int64("msgthrottle.country-max", 0, "Maximum SMS sent to phone numbers of a country per window. 0 disables the limit")
+		fs.Duration("msgthrottle.country-window", time.Hour, "Window of the per country SMS limit")
+		fs.String("msgthrottle.allowed-countries", "", "Comma separated list of ISO country codes SMS may be sent to. If not set, all countries are allowed")
+		fs.String("msgthrottle.denied-countries", "", "Comma separated list of ISO country codes SMS may not be sent to")
+		fs.Int64("msgthrottle.daily-sms-budget", 0, "Maximum SMS sent to all phone numbers per UTC day. 0 disables the limit")
+		fs.String("msgrepo.backend", "memory", "Storage for outgoing messages (memory, redis or postgres)")
+		fs.String("msgrepo.stream", "auth_messages", "Redis stream for outgoing messages")
+		fs.String("msgrepo.group", "msgconsumer", "Redis consumer group for outgoing messages")
+		fs.String("msgrepo.consumer", "", "Unique name of this process in the consumer group. Defaults to hostname and PID")
+		fs.Duration("msgrepo.lease", time.Minute, "Time an unacknowledged message is held before redelivery")
 		fs.Duration("token.expires-in", time.Minute*20, "JWT token expiry time")
 		fs.Duration("token.refresh-expires-in", time.Hour*24*15, "Refresh token expiry time")
 		fs.String("token.issuer", "authenticator", "JWT token issuer")
 		fs.String("token.secret", "", "JWT token secret")
+		fs.String("token.active-key", "", "ID of the versioned key used to sign JWT tokens")
+		fs.Duration("token.key-grace-period", time.Minute*20, "Time retired keys may verify JWT tokens")
+		fs.Duration("reset.expires-in", time.Minute*5, "Password reset token expiry time")
 		fs.Int("webauthn.max-devices", 5, "Maximum amount of devices for registration")
 		fs.String("webauthn.display-name", "Authenticator", "Webauthn display name")
 		fs.String("webauthn.domain", "authenticator.local", "Public client domain")
 		fs.String("webauthn.request-origin", "authenticator.local", "Origin URL for client requests")
 		fs.String("twilio.account-sid", "", "Account SID from Twilio")
 		fs.String("twilio.token", "", "Authentication token for Twilio API")
-		fs.String("twilio.sms-sender", "", "Origin phone number for outgoing SMS")
+		fs.String("twilio.sms-sender", "", "Origin phone number for outgoing SMS and voice calls")
 		fs.String("mail.server-addr", "", "Outgoing mail server")
 		fs.String("mail.from-addr", "", "Origin email address for outgoing email")
 		fs.String("mail.auth.username", "", "Username for mailing service")
 		fs.String("mail.auth.password", "", "Password for mailing service")
 		fs.String("mail.auth.hostname", "", "Hostname for mailing service")
+		fs.String("mail.from-name", "", "Display name for outgoing email")
+		fs.String("mail.reply-to", "", "Reply-To address for outgoing email")
+		fs.String("mail.tls", "opportunistic", "Mail server connection security (opportunistic, starttls, tls or none)")
+		fs.Int("mail.max-idle", 2, "Maximum number of idle mail server connections kept for reuse")
+		fs.Duration("mail.idle-timeout", time.Second*30, "Time an idle mail server connection is kept for reuse")
 		fs.String("sendgrid.api-key", "", "Sendgrid API Key for mailing services")
 		fs.String("sendgrid.from-addr", "", "Origin email address for outgoing email")
 		fs.String("sendgrid.from-name", "", "Origin name for outgoing email")
 		fs.String("maillib", "", "Email library to use. If not set, it will us net/smtp")
+		fs.String("mailgun.api-key", "", "Mailgun API key for mailing services")
+		fs.String("mailgun.domain", "", "Mailgun sending domain")
+		fs.String("mailgun.base-url", "", "Mailgun API endpoint. If not set, the US region is used")
+		fs.String("mailgun.from-addr", "", "Origin email address for outgoing email")
+		fs.String("mailgun.from-name", "", "Origin name for outgoing email")
+		fs.String("ses.region", "", "AWS region of the Amazon SES sending identity")
+		fs.String("ses.access-key-id", "", "AWS access key ID for Amazon SES")
+		fs.String("ses.secret-access-key", "", "AWS secret access key for Amazon SES")
+		fs.String("ses.session-token", "", "AWS session token for temporary credentials")
+		fs.String("ses.from-addr", "", "Origin email address for outgoing email")
+		fs.String("ses.from-name", "", "Origin name for outgoing email")
+		fs.String("vonage.api-key", "", "API key for Vonage")
+		fs.String("vonage.api-secret", "", "API secret for Vonage")
+		fs.String("vonage.sms-sender", "", "Origin phone number or name for outgoing SMS")
+		fs.String("messagebird.access-key", "", "Access key for MessageBird")
+		fs.String("messagebird.originator", "", "Origin phone number or name for outgoing SMS")
+		fs.String("sms.providers", "twilio", "Comma separated list of SMS providers in order of preference (twilio, vonage, messagebird)")
+		fs.String("voice.providers", "twilio", "Comma separated list of voice call providers in order of preference (twilio)")
+		fs.String("mail.providers", "", "Comma separated list of email providers in order of preference (smtp, sendgrid, mailgun, ses). If not set, maillib is used")
+		fs.Int("failover.max-failures", 3, "Consecutive failures before a message provider is marked unhealthy")
+		fs.Duration("failover.cooldown", time.Minute, "Time an unhealthy message provider is attempted after healthy providers")
 
 		fs.StringVar(&configPath, "config", "", "Path to the config file")
 		err = fs.Parse(os.Args[1:])
@@ -124,10 +221,25 @@ func main() {
 		logger = level.NewFilter(logger, level.AllowInfo())
 	}
 
-	passwordSvc := password.NewPassword(
+	if fs.Arg(0) == "breach" {
+		if err = runBreach(os.Stdout, fs.Args()[1:]); err != nil {
+			logger.Log("message", "breach command failed", "error", err, "source", "cmd/api")
+			os.Exit(1)
+		}
+		return
+	}
+
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		logger.Log("message", "invalid password configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+	passwordSvc := password.NewPassword(append(
+		passwordPolicy,
+		password.WithLogger(logger),
 		password.WithMinLength(viper.GetInt("password.min-length")),
 		password.WithMaxLength(viper.GetInt("password.max-length")),
-	)
+	)...)
 
 	var pgDB *sql.DB
 	{
@@ -155,6 +267,61 @@ func main() {
 		}()
 	}
 
+	// Database migrations are run as a subcommand, e.g.
+	// `api migrate up --config=./config.json`.
+	if fs.Arg(0) == "migrate" {
+		err = runMigrations(ctx, pgDB, logger, os.Stdout, fs.Args()[1:])
+		if err != nil {
+			logger.Log("message", "migration failed", "error", err, "source", "cmd/api")
+			os.Exit(1)
+		}
+		cancel()
+		return
+	}
+
+	otpSecrets, err := loadOTPSecrets()
+	if err != nil {
+		logger.Log("message", "invalid OTP secret configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+
+	// TOTP secrets are re-encrypted with the latest secret key as a
+	// subcommand, e.g. `api totp reencrypt --config=./config.json`.
+	if fs.Arg(0) == "totp" {
+		repoMngr := postgres.NewClient(
+			postgres.WithLogger(logger),
+			postgres.WithDB(pgDB),
+		)
+		err = runTOTP(ctx, repoMngr, otp.NewOTP(otpSecrets...), logger, os.Stdout, fs.Args()[1:])
+		if err != nil {
+			logger.Log("message", "totp command failed", "error", err, "source", "cmd/api")
+			os.Exit(1)
+		}
+		cancel()
+		return
+	}
+
+	// Hardware tokens are enrolled as a subcommand, e.g.
+	// `api hotp enroll ./seeds.xml 1234567 jane@example.com --config=./config.json`.
+	if fs.Arg(0) == "hotp" {
+		repoMngr := postgres.NewClient(
+			postgres.WithLogger(logger),
+			postgres.WithDB(pgDB),
+		)
+		psk, err := hex.DecodeString(viper.GetString("otp.hotp.pskc-key"))
+		if err != nil {
+			logger.Log("message", "invalid HOTP configuration", "error", err, "source", "cmd/api")
+			os.Exit(1)
+		}
+		err = runHOTP(ctx, repoMngr, otp.NewOTP(otpSecrets...), psk, os.Stdout, fs.Args()[1:])
+		if err != nil {
+			logger.Log("message", "hotp command failed", "error", err, "source", "cmd/api")
+			os.Exit(1)
+		}
+		cancel()
+		return
+	}
+
 	var redisDB *redis.Client
 	{
 		redisConf, err := redis.ParseURL(viper.GetString("redis.conn-string"))
@@ -181,27 +348,104 @@ func main() {
 		defer closeRedis()
 	}
 
-	messageRepo := msgrepo.NewService(msgrepo.WithLogger(logger))
+	var messageRepo auth.MessageRepository
+	switch viper.GetString("msgrepo.backend") {
+	case "redis":
+		streamOpts := []msgstream.ConfigOption{
+			msgstream.WithLogger(logger),
+			msgstream.WithDB(redisDB),
+			msgstream.WithStream(viper.GetString("msgrepo.stream")),
+			msgstream.WithGroup(viper.GetString("msgrepo.group")),
+			msgstream.WithClaimIdle(viper.GetDuration("msgrepo.lease")),
+		}
+		if consumer := viper.GetString("msgrepo.consumer"); consumer != "" {
+			streamOpts = append(streamOpts, msgstream.WithConsumer(consumer))
+		}
+		messageRepo = msgstream.NewService(streamOpts...)
+	case "postgres":
+		messageRepo = msgoutbox.NewService(
+			msgoutbox.WithLogger(logger),
+			msgoutbox.WithDB(pgDB),
+			msgoutbox.WithLease(viper.GetDuration("msgrepo.lease")),
+		)
+	default:
+		messageRepo = msgrepo.NewService(msgrepo.WithLogger(logger))
+	}
 
 	repoMngr := postgres.NewClient(
 		postgres.WithLogger(logger),
 		postgres.WithPassword(passwordSvc),
+		postgres.WithPasswordless(viper.GetBool("passwordless.enabled")),
 		postgres.WithDB(pgDB),
 	)
 
-	otpSvc := otp.NewOTP(
+	otpPolicy, err := loadOTPPolicy()
+	if err != nil {
+		logger.Log("message", "invalid OTP configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+	otpSvc := otp.NewOTP(append(
+		append(otpPolicy, otpSecrets...),
 		otp.WithCodeLength(viper.GetInt("otp.code-length")),
+		otp.WithCodeExpiry(viper.GetDuration("otp.code-expiry")),
+		otp.WithMaxAttempts(viper.GetInt64("otp.max-attempts")),
 		otp.WithIssuer(viper.GetString("otp.issuer")),
-		otp.WithSecret(otp.Secret{
-			Key:     viper.GetString("otp.secret.key"),
-			Version: viper.GetInt("otp.secret.version"),
-		}),
 		otp.WithDB(redisDB),
+	)...)
+
+	msgThrottle := msgthrottle.NewService(
+		msgthrottle.WithLogger(logger),
+		msgthrottle.WithDB(redisDB),
+		msgthrottle.WithAddressLimit(
+			viper.GetInt64("msgthrottle.address-max"),
+			viper.GetDuration("msgthrottle.address-window"),
+		),
+		msgthrottle.WithPrefixLimit(
+			viper.GetInt("msgthrottle.prefix-length"),
+			viper.GetInt64("msgthrottle.prefix-max"),
+			viper.GetDuration("msgthrottle.prefix-window"),
+		),
+		msgthrottle.WithCountryLimit(
+			viper.GetInt64("msgthrottle.country-max"),
+			viper.GetDuration("msgthrottle.country-window"),
+		),
+		msgthrottle.WithAllowedCountries(splitList(viper.GetString("msgthrottle.allowed-countries"))),
+		msgthrottle.WithDeniedCountries(splitList(viper.GetString("msgthrottle.denied-countries"))),
+		msgthrottle.WithDailySMSBudget(viper.GetInt64("msgthrottle.daily-sms-budget")),
+	)
+
+	messagingSvc, err := msgpublisher.NewService(
+		messageRepo,
+		msgpublisher.WithLogger(logger),
+		msgpublisher.WithRepoManager(repoMngr),
+		msgpublisher.WithThrottle(msgThrottle),
+		msgpublisher.WithTemplateDir(viper.GetString("msgpublisher.template-dir")),
+		msgpublisher.WithDefaultLocale(viper.GetString("msgpublisher.default-locale")),
+	)
+	if err != nil {
+		logger.Log("message", "failed to load message templates", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+
+	lockoutSvc := lockout.NewService(
+		lockout.WithLogger(logger),
+		lockout.WithDB(redisDB),
+		lockout.WithOTP(otpSvc),
+		lockout.WithDelayAfter(viper.GetInt("lockout.delay-after")),
+		lockout.WithMaxAttempts(viper.GetInt("lockout.max-attempts")),
+		lockout.WithBaseDelay(viper.GetDuration("lockout.base-delay")),
+		lockout.WithMaxDelay(viper.GetDuration("lockout.max-delay")),
+		lockout.WithLockoutDuration(viper.GetDuration("lockout.duration")),
+		lockout.WithWindow(viper.GetDuration("lockout.window")),
 	)
 
-	messagingSvc := msgpublisher.NewService(messageRepo, msgpublisher.WithLogger(logger))
+	signingKeys, err := loadSigningKeys()
+	if err != nil {
+		logger.Log("message", "failed to load signing keys", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
 
-	tokenSvc := token.NewService(
+	tokenOptions := []token.ConfigOption{
 		token.WithLogger(logger),
 		token.WithDB(redisDB),
 		token.WithTokenExpiry(viper.GetDuration("token.expires-in")),
@@ -212,7 +456,13 @@ func main() {
 		token.WithCookieMaxAge(viper.GetInt("api.cookie-max-age")),
 		token.WithCookieDomain(viper.GetString("api.cookie-domain")),
 		token.WithRepoManager(repoMngr),
-	)
+		token.WithActiveKey(viper.GetString("token.active-key")),
+		token.WithKeyGracePeriod(viper.GetDuration("token.key-grace-period")),
+	}
+	for _, key := range signingKeys {
+		tokenOptions = append(tokenOptions, token.WithSigningKey(key))
+	}
+	tokenSvc := token.NewService(tokenOptions...)
 
 	webauthnSvc, err := webauthn.NewService(
 		webauthn.WithDB(redisDB),
@@ -235,6 +485,9 @@ func main() {
 		loginapi.WithOTP(otpSvc),
 		loginapi.WithMessaging(messagingSvc),
 		loginapi.WithPassword(passwordSvc),
+		loginapi.WithPasswordless(viper.GetBool("passwordless.enabled")),
+		loginapi.WithMagicLinkURL(viper.GetString("passwordless.magic-link-url")),
+		loginapi.WithLockout(lockoutSvc),
 	)
 
 	signupAPI := signupapi.NewService(
@@ -243,6 +496,21 @@ func main() {
 		signupapi.WithRepoManager(repoMngr),
 		signupapi.WithMessaging(messagingSvc),
 		signupapi.WithOTP(otpSvc),
+		signupapi.WithPassword(passwordSvc),
+		signupapi.WithPasswordless(viper.GetBool("passwordless.enabled")),
+		signupapi.WithMagicLinkURL(viper.GetString("passwordless.magic-link-url")),
+	)
+
+	resetAPI := resetapi.NewService(
+		resetapi.WithLogger(logger),
+		resetapi.WithTokenService(tokenSvc),
+		resetapi.WithRepoManager(repoMngr),
+		resetapi.WithWebAuthn(webauthnSvc),
+		resetapi.WithOTP(otpSvc),
+		resetapi.WithMessaging(messagingSvc),
+		resetapi.WithPassword(passwordSvc),
+		resetapi.WithLockout(lockoutSvc),
+		resetapi.WithTokenExpiry(viper.GetDuration("reset.expires-in")),
 	)
 
 	deviceAPI := deviceapi.NewService(
@@ -260,11 +528,23 @@ func main() {
 		contactapi.WithTokenService(tokenSvc),
 	)
 
+	qrLevel, err := totpapi.ParseRecoveryLevel(viper.GetString("otp.totp.qr-recovery-level"))
+	if err != nil {
+		logger.Log("message", "invalid TOTP configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+	if viper.GetInt("otp.totp.qr-size") < 128 {
+		logger.Log("message", "invalid TOTP configuration", "error", "QR code size must be at least 128 pixels", "source", "cmd/api")
+		os.Exit(1)
+	}
+
 	totpAPI := totpapi.NewService(
 		totpapi.WithLogger(logger),
 		totpapi.WithOTP(otpSvc),
 		totpapi.WithRepoManager(repoMngr),
 		totpapi.WithTokenService(tokenSvc),
+		totpapi.WithQRSize(viper.GetInt("otp.totp.qr-size")),
+		totpapi.WithQRRecoveryLevel(qrLevel),
 	)
 
 	tokenAPI := tokenapi.NewService(
@@ -273,6 +553,28 @@ func main() {
 		tokenapi.WithRepoManager(repoMngr),
 	)
 
+	userAPI := userapi.NewService(
+		userapi.WithLogger(logger),
+		userapi.WithTokenService(tokenSvc),
+		userapi.WithRepoManager(repoMngr),
+		userapi.WithWebAuthn(webauthnSvc),
+		userapi.WithOTP(otpSvc),
+		userapi.WithMessaging(messagingSvc),
+		userapi.WithPassword(passwordSvc),
+	)
+
+	messageAPI := messageapi.NewService(
+		messageapi.WithLogger(logger),
+		messageapi.WithRepoManager(repoMngr),
+		messageapi.WithMessageRepo(messageRepo),
+	)
+
+	trustedProxies, err := httpapi.ParseTrustedProxies(viper.GetString("api.trusted-proxies"))
+	if err != nil {
+		logger.Log("message", "invalid trusted proxy configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
+	}
+
 	lmt := httpapi.NewRateLimiter(redisDB)
 	router := mux.NewRouter()
 	router.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
@@ -282,10 +584,13 @@ func main() {
 
 	loginapi.SetupHTTPHandler(loginAPI, router, tokenSvc, logger, lmt)
 	signupapi.SetupHTTPHandler(signupAPI, router, tokenSvc, logger, lmt)
+	resetapi.SetupHTTPHandler(resetAPI, router, tokenSvc, logger, lmt)
 	deviceapi.SetupHTTPHandler(deviceAPI, router, tokenSvc, logger, lmt)
 	contactapi.SetupHTTPHandler(contactAPI, router, tokenSvc, logger, lmt)
 	totpapi.SetupHTTPHandler(totpAPI, router, tokenSvc, logger, lmt)
 	tokenapi.SetupHTTPHandler(tokenAPI, router, tokenSvc, logger, lmt)
+	userapi.SetupHTTPHandler(userAPI, router, tokenSvc, logger, lmt)
+	messageapi.SetupHTTPHandler(messageAPI, router, viper.GetString("admin.api-key"), logger, lmt)
 
 	server := http.Server{
 		Addr: viper.GetString("api.http-addr"),
@@ -300,47 +605,33 @@ func main() {
 			}),
 			handlers.AllowCredentials(),
 			handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"}),
-		)(router),
+		)(httpapi.ClientIPMiddleware(router, trustedProxies)),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  30 * time.Second,
 	}
 
-	smsLib := twilio.NewClient(twilio.WithDefaults(
-		viper.GetString("twilio.account-sid"),
-		viper.GetString("twilio.token"),
-		viper.GetString("twilio.sms-sender"),
-	))
-
-	sendGrid := sendgrid.NewClient(
-		viper.GetString("sendgrid.api-key"),
-		viper.GetString("sendgrid.from-addr"),
-		viper.GetString("sendgrid.from-name"),
-	)
-	stdMailer := mail.NewService(mail.WithDefaults(
-		viper.GetString("mail.server-addr"),
-		viper.GetString("mail.from-addr"),
-		smtp.PlainAuth(
-			"",
-			viper.GetString("mail.auth.username"),
-			viper.GetString("mail.auth.password"),
-			viper.GetString("mail.auth.hostname"),
-		),
-	))
-
-	var emailLib auth.Emailer
-	if viper.GetString("maillib") == "sendgrid" {
-		emailLib = sendGrid
-	} else {
-		emailLib = stdMailer
+	providers, err := loadProviders()
+	if err != nil {
+		logger.Log("message", "invalid message provider configuration", "error", err, "source", "cmd/api")
+		os.Exit(1)
 	}
+	deliverer := failover.NewService(append(
+		providers,
+		failover.WithLogger(logger),
+		failover.WithMaxFailures(viper.GetInt("failover.max-failures")),
+		failover.WithCooldown(viper.GetDuration("failover.cooldown")),
+	)...)
 
 	msgd := msgconsumer.NewService(
 		messageRepo,
-		smsLib,
-		emailLib,
+		deliverer,
+		deliverer,
 		msgconsumer.WithWorkers(viper.GetInt("msgconsumer.workers")),
+		msgconsumer.WithDrainTimeout(viper.GetDuration("msgconsumer.drain-timeout")),
+		msgconsumer.WithVoicer(deliverer),
 		msgconsumer.WithLogger(logger),
+		msgconsumer.WithRepoManager(repoMngr),
 	)
 
 	var g run.Group
@@ -394,3 +685,376 @@ func main() {
 	err = g.Run()
 	logger.Log("message", "actors stopped", "error", err, "source", "cmd/api")
 }
+
+// signingKeyConfig is a versioned JWT signing key defined
+// in the config file under `token.keys`.
+type signingKeyConfig struct {
+	ID        string `mapstructure:"id"`
+	Algorithm string `mapstructure:"algorithm"`
+	KeyFile   string `mapstructure:"key-file"`
+	RetiredAt string `mapstructure:"retired-at"`
+}
+
+// loadSigningKeys reads PEM encoded JWT signing keys listed in
+// the config file.
+func loadSigningKeys() ([]*token.SigningKey, error) {
+	var configs []signingKeyConfig
+	if err := viper.UnmarshalKey("token.keys", &configs); err != nil {
+		return nil, fmt.Errorf("invalid key configuration: %w", err)
+	}
+
+	keys := make([]*token.SigningKey, 0, len(configs))
+	for _, c := range configs {
+		b, err := ioutil.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key %s: %w", c.ID, err)
+		}
+
+		key, err := token.ParseSigningKey(c.ID, c.Algorithm, b)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.RetiredAt != "" {
+			key.RetiredAt, err = time.Parse(time.RFC3339, c.RetiredAt)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retirement time for key %s: %w", c.ID, err)
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// loadProviders returns the SMS, voice and email providers listed in
+// the config file in order of preference.
+func loadProviders() ([]failover.ConfigOption, error) {
+	var options []failover.ConfigOption
+
+	for _, name := range splitList(viper.GetString("sms.providers")) {
+		var smsLib auth.SMSer
+		switch name {
+		case "twilio":
+			smsLib = twilio.NewClient(twilio.WithDefaults(
+				viper.GetString("twilio.account-sid"),
+				viper.GetString("twilio.token"),
+				viper.GetString("twilio.sms-sender"),
+			))
+		case "vonage":
+			smsLib = vonage.NewClient(vonage.WithDefaults(
+				viper.GetString("vonage.api-key"),
+				viper.GetString("vonage.api-secret"),
+				viper.GetString("vonage.sms-sender"),
+			))
+		case "messagebird":
+			smsLib = messagebird.NewClient(messagebird.WithDefaults(
+				viper.GetString("messagebird.access-key"),
+				viper.GetString("messagebird.originator"),
+			))
+		default:
+			return nil, fmt.Errorf("unknown SMS provider %q", name)
+		}
+		options = append(options, failover.WithSMSProvider(name, smsLib))
+	}
+
+	for _, name := range splitList(viper.GetString("voice.providers")) {
+		var voiceLib auth.Voicer
+		switch name {
+		case "twilio":
+			voiceLib = twilio.NewClient(twilio.WithDefaults(
+				viper.GetString("twilio.account-sid"),
+				viper.GetString("twilio.token"),
+				viper.GetString("twilio.sms-sender"),
+			))
+		default:
+			return nil, fmt.Errorf("unknown voice provider %q", name)
+		}
+		options = append(options, failover.WithVoiceProvider(name, voiceLib))
+	}
+
+	mailProviders := viper.GetString("mail.providers")
+	if mailProviders == "" {
+		mailProviders = "smtp"
+		if viper.GetString("maillib") == "sendgrid" {
+			mailProviders = "sendgrid"
+		}
+	}
+
+	for _, name := range splitList(mailProviders) {
+		var emailLib auth.Emailer
+		switch name {
+		case "smtp":
+			// Mail servers which do not require authentication, such as a
+			// local relay, are configured without a username.
+			var mailAuth smtp.Auth
+			if viper.GetString("mail.auth.username") != "" {
+				mailAuth = smtp.PlainAuth(
+					"",
+					viper.GetString("mail.auth.username"),
+					viper.GetString("mail.auth.password"),
+					viper.GetString("mail.auth.hostname"),
+				)
+			}
+			emailLib = mail.NewService(
+				mail.WithDefaults(
+					viper.GetString("mail.server-addr"),
+					viper.GetString("mail.from-addr"),
+					mailAuth,
+				),
+				mail.WithFromName(viper.GetString("mail.from-name")),
+				mail.WithReplyTo(viper.GetString("mail.reply-to")),
+				mail.WithTLSMode(mail.TLSMode(viper.GetString("mail.tls"))),
+				mail.WithMaxIdle(viper.GetInt("mail.max-idle")),
+				mail.WithIdleTimeout(viper.GetDuration("mail.idle-timeout")),
+			)
+		case "sendgrid":
+			emailLib = sendgrid.NewClient(
+				viper.GetString("sendgrid.api-key"),
+				viper.GetString("sendgrid.from-addr"),
+				viper.GetString("sendgrid.from-name"),
+			)
+		case "mailgun":
+			emailLib = mailgun.NewClient(mailgun.WithDefaults(
+				viper.GetString("mailgun.base-url"),
+				viper.GetString("mailgun.api-key"),
+				viper.GetString("mailgun.domain"),
+				viper.GetString("mailgun.from-addr"),
+				viper.GetString("mailgun.from-name"),
+			))
+		case "ses":
+			emailLib = ses.NewClient(
+				ses.WithDefaults(
+					viper.GetString("ses.region"),
+					viper.GetString("ses.access-key-id"),
+					viper.GetString("ses.secret-access-key"),
+					viper.GetString("ses.from-addr"),
+					viper.GetString("ses.from-name"),
+				),
+				ses.WithSessionToken(viper.GetString("ses.session-token")),
+			)
+		default:
+			return nil, fmt.Errorf("unknown email provider %q", name)
+		}
+		options = append(options, failover.WithEmailProvider(name, emailLib))
+	}
+
+	return options, nil
+}
+
+// otpSecretConfig is a versioned TOTP secret encryption key
+// defined in the config file under `otp.secrets`.
+type otpSecretConfig struct {
+	Version int    `mapstructure:"version"`
+	Key     string `mapstructure:"key"`
+}
+
+// loadOTPSecrets returns the TOTP secret encryption keys listed in
+// the config file. The key set by `otp.secret.key` is included
+// alongside them.
+func loadOTPSecrets() ([]otp.ConfigOption, error) {
+	var configs []otpSecretConfig
+	if err := viper.UnmarshalKey("otp.secrets", &configs); err != nil {
+		return nil, fmt.Errorf("invalid secret configuration: %w", err)
+	}
+
+	if key := viper.GetString("otp.secret.key"); key != "" {
+		configs = append(configs, otpSecretConfig{
+			Version: viper.GetInt("otp.secret.version"),
+			Key:     key,
+		})
+	}
+
+	options := make([]otp.ConfigOption, 0, len(configs))
+	versions := make(map[int]bool)
+	for _, c := range configs {
+		if c.Key == "" {
+			return nil, fmt.Errorf("secret version %v has no key", c.Version)
+		}
+		if versions[c.Version] {
+			return nil, fmt.Errorf("secret version %v is configured more than once", c.Version)
+		}
+		versions[c.Version] = true
+
+		options = append(options, otp.WithSecret(otp.Secret{
+			Version: c.Version,
+			Key:     c.Key,
+		}))
+	}
+
+	return options, nil
+}
+
+// loadOTPPolicy returns the OTP code alphabet, the code expiry
+// times of message types, the HOTP windows and the TOTP parameters
+// listed in the config file.
+func loadOTPPolicy() ([]otp.ConfigOption, error) {
+	var options []otp.ConfigOption
+
+	switch alphabet := viper.GetString("otp.code-alphabet"); alphabet {
+	case "numeric":
+		options = append(options, otp.WithCodeAlphabet(otp.NumericAlphabet))
+	case "alphanumeric":
+		options = append(options, otp.WithCodeAlphabet(otp.AlphanumericAlphabet))
+	default:
+		return nil, fmt.Errorf("unknown OTP code alphabet %q", alphabet)
+	}
+
+	for _, v := range splitList(viper.GetString("otp.type-expiry")) {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid OTP code expiry %q", v)
+		}
+
+		expiry, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTP code expiry %q: %w", v, err)
+		}
+
+		msgType := auth.MessageType(strings.TrimSpace(parts[0]))
+		if !isMessageType(msgType) {
+			return nil, fmt.Errorf("unknown message type in OTP code expiry %q", v)
+		}
+		options = append(options, otp.WithTypeExpiry(msgType, expiry))
+	}
+
+	algorithm, err := otp.ParseAlgorithm(viper.GetString("otp.totp.algorithm"))
+	if err != nil {
+		return nil, err
+	}
+
+	digits := viper.GetInt("otp.totp.digits")
+	if digits != 6 && digits != 8 {
+		return nil, fmt.Errorf("TOTP digits must be 6 or 8, received %v", digits)
+	}
+
+	period := viper.GetDuration("otp.totp.period")
+	if period < time.Second || period%time.Second != 0 {
+		return nil, fmt.Errorf("TOTP period must be a whole number of seconds, received %v", period)
+	}
+
+	skew := viper.GetInt("otp.totp.skew")
+	if skew < 0 {
+		return nil, fmt.Errorf("TOTP skew must not be negative, received %v", skew)
+	}
+
+	lookAhead := viper.GetInt("otp.hotp.look-ahead")
+	resyncWindow := viper.GetInt("otp.hotp.resync-window")
+	if lookAhead < 0 || resyncWindow < 0 {
+		return nil, fmt.Errorf("HOTP windows must not be negative")
+	}
+
+	options = append(options,
+		otp.WithHOTPLookAhead(uint(lookAhead)),
+		otp.WithHOTPResyncWindow(uint(resyncWindow)),
+		otp.WithTOTPAlgorithm(algorithm),
+		otp.WithTOTPDigits(digits),
+		otp.WithTOTPPeriod(period),
+		otp.WithTOTPSkew(uint(skew)),
+	)
+
+	return options, nil
+}
+
+// loadPasswordPolicy returns the algorithm and cost used to hash
+// new passwords.
+func loadPasswordPolicy() ([]password.ConfigOption, error) {
+	algorithm := viper.GetString("password.algorithm")
+	if algorithm != password.Argon2id && algorithm != password.Bcrypt {
+		return nil, fmt.Errorf("unknown password algorithm %q", algorithm)
+	}
+
+	memory := viper.GetInt("password.argon2.memory")
+	if memory < 8 || int64(memory) > math.MaxUint32 {
+		return nil, fmt.Errorf("argon2 memory must be at least 8 KiB, received %v", memory)
+	}
+
+	passes := viper.GetInt("password.argon2.time")
+	if passes < 1 || int64(passes) > math.MaxUint32 {
+		return nil, fmt.Errorf("argon2 time must be at least 1, received %v", passes)
+	}
+
+	parallelism := viper.GetInt("password.argon2.parallelism")
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return nil, fmt.Errorf("argon2 parallelism must be between 1 and 255, received %v", parallelism)
+	}
+	if memory < 8*parallelism {
+		return nil, fmt.Errorf("argon2 memory must be at least 8 KiB per thread, received %v", memory)
+	}
+
+	cost := viper.GetInt("password.bcrypt.cost")
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %v and %v, received %v", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+
+	options := []password.ConfigOption{
+		password.WithAlgorithm(algorithm),
+		password.WithArgon2Memory(uint32(memory)),
+		password.WithArgon2Time(uint32(passes)),
+		password.WithArgon2Parallelism(uint8(parallelism)),
+		password.WithCost(cost),
+	}
+
+	breachOptions, err := loadBreachChecker()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(options, breachOptions...), nil
+}
+
+// loadBreachChecker returns the corpus new passwords are checked
+// against, loading an offline corpus into memory if one is configured.
+func loadBreachChecker() ([]password.ConfigOption, error) {
+	mode := viper.GetString("password.breach.mode")
+	switch mode {
+	case "off":
+		return nil, nil
+	case "warn", "reject":
+	default:
+		return nil, fmt.Errorf("unknown breached password mode %q", mode)
+	}
+
+	var (
+		checker auth.BreachChecker
+		err     error
+	)
+	if path := viper.GetString("password.breach.file"); path != "" {
+		checker, err = breachfile.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load breached passwords: %w", err)
+		}
+	} else {
+		checker = hibp.NewClient(hibp.WithDefaults(
+			viper.GetString("password.breach.api-url"),
+			viper.GetDuration("password.breach.timeout"),
+		))
+	}
+
+	return []password.ConfigOption{
+		password.WithBreachChecker(checker),
+		password.WithBreachWarning(mode == "warn"),
+	}, nil
+}
+
+// splitList splits a comma separated list, ignoring empty values.
+func splitList(list string) []string {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// isMessageType tells us if a configured message type is sent to Users.
+func isMessageType(msgType auth.MessageType) bool {
+	for _, t := range auth.MessageTypes {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
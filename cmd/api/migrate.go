@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/fmitra/authenticator/internal/migrate"
+)
+
+// runMigrations executes the migrate subcommand:
+//
+//	migrate up            Apply all pending migrations
+//	migrate down [steps]  Revert the last applied migration, or a number of them
+//	migrate status        List migrations and whether they are applied
+func runMigrations(ctx context.Context, db *sql.DB, logger log.Logger, out io.Writer, args []string) error {
+	migrator := migrate.NewMigrator(
+		migrate.WithLogger(logger),
+		migrate.WithDB(db),
+	)
+
+	if len(args) == 0 {
+		return fmt.Errorf("migrate requires a command: up, down, or status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%v migration(s) applied\n", applied)
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%v migration(s) reverted\n", reverted)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.IsApplied {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%v\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %s", args[0])
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultBatchSize is the number of Users re-encrypted in each batch.
+const defaultBatchSize = 100
+
+// runTOTP executes the totp subcommand:
+//
+//	totp reencrypt [batch-size]  Re-encrypt all TOTP and HOTP secrets with the latest secret key
+func runTOTP(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, logger log.Logger, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("totp requires a command: reencrypt")
+	}
+
+	switch args[0] {
+	case "reencrypt":
+		batchSize := defaultBatchSize
+		if len(args) > 1 {
+			var err error
+			batchSize, err = strconv.Atoi(args[1])
+			if err != nil || batchSize < 1 {
+				return fmt.Errorf("batch size must be a positive number")
+			}
+		}
+		updated, err := reencryptSecrets(ctx, repoMngr, otpSvc, logger, batchSize)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%v secret(s) re-encrypted\n", updated)
+		return nil
+	default:
+		return fmt.Errorf("unknown totp command %s", args[0])
+	}
+}
+
+// reencryptSecrets re-encrypts the TOTP and HOTP secrets of every User
+// with the latest secret key, in batches ordered by User ID. A secret
+// changed while the batch is processed, such as by a User configuring
+// TOTP, is already encrypted with the latest key and is left as is.
+func reencryptSecrets(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, logger log.Logger, batchSize int) (int, error) {
+	var (
+		afterID string
+		updated int
+	)
+
+	for {
+		users, err := repoMngr.User().List(ctx, afterID, batchSize)
+		if err != nil {
+			return updated, fmt.Errorf("cannot list users: %w", err)
+		}
+		if len(users) == 0 {
+			return updated, nil
+		}
+
+		for _, user := range users {
+			afterID = user.ID
+
+			isUpdated, err := reencryptTOTPSecret(ctx, repoMngr, otpSvc, user)
+			if err != nil {
+				return updated, err
+			}
+			if isUpdated {
+				updated++
+			}
+
+			isUpdated, err = reencryptHOTPSecret(ctx, repoMngr, otpSvc, user)
+			if err != nil {
+				return updated, err
+			}
+			if isUpdated {
+				updated++
+			}
+		}
+
+		logger.Log(
+			"message", "re-encrypted batch of TOTP and HOTP secrets",
+			"last_user_id", afterID,
+			"updated", updated,
+			"source", "cmd/api",
+		)
+	}
+}
+
+func reencryptTOTPSecret(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, user *auth.User) (bool, error) {
+	if user.TFASecret == "" {
+		return false, nil
+	}
+
+	secret, err := otpSvc.ReencryptTOTPSecret(user.TFASecret)
+	if err != nil {
+		return false, fmt.Errorf("cannot re-encrypt secret of user %s: %w", user.ID, err)
+	}
+	if secret == user.TFASecret {
+		return false, nil
+	}
+
+	isUpdated, err := repoMngr.User().UpdateTFASecret(ctx, user.ID, user.TFASecret, secret)
+	if err != nil {
+		return false, fmt.Errorf("cannot update secret of user %s: %w", user.ID, err)
+	}
+	return isUpdated, nil
+}
+
+// reencryptHOTPSecret re-encrypts the secret of a User's HOTP token.
+// The User is locked while the secret is replaced so that a concurrent
+// login does not have its counter overwritten.
+func reencryptHOTPSecret(ctx context.Context, repoMngr auth.RepositoryManager, otpSvc auth.OTPService, user *auth.User) (bool, error) {
+	if user.HOTPSecret == "" {
+		return false, nil
+	}
+
+	secret, err := otpSvc.ReencryptTOTPSecret(user.HOTPSecret)
+	if err != nil {
+		return false, fmt.Errorf("cannot re-encrypt hotp secret of user %s: %w", user.ID, err)
+	}
+	if secret == user.HOTPSecret {
+		return false, nil
+	}
+
+	client, err := repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	entity, err := client.WithAtomic(func() (interface{}, error) {
+		storedUser, err := client.User().GetForUpdate(ctx, user.ID)
+		if err != nil {
+			return false, err
+		}
+		if storedUser.HOTPSecret != user.HOTPSecret {
+			return false, nil
+		}
+
+		storedUser.HOTPSecret = secret
+		if err = client.User().Update(ctx, storedUser); err != nil {
+			return false, fmt.Errorf("cannot update hotp secret of user %s: %w", user.ID, err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return entity.(bool), nil
+}
@@ -22,6 +22,12 @@ const (
 	EWebAuthn ErrCode = "webauthn"
 	// EThrottle represents a rate limiting error.
 	EThrottle ErrCode = "too_many_requests"
+	// EAccountLocked represents an account locked after
+	// repeated failed authentication attempts.
+	EAccountLocked ErrCode = "account_locked"
+	// ETokenResync represents a hardware token code accepted
+	// as the first of two codes needed to resynchronize it.
+	ETokenResync ErrCode = "token_resync"
 )
 
 // Error represents an error within the authenticator domain.
@@ -85,6 +91,23 @@ func (e ErrThrottle) Code() ErrCode   { return EThrottle }
 func (e ErrThrottle) Error() string   { return fmt.Sprintf("[%s] %s", e.Code(), string(e)) }
 func (e ErrThrottle) Message() string { return string(e) }
 
+// ErrAccountLocked represents an error where an account is locked
+// after repeated failed authentication attempts.
+type ErrAccountLocked string
+
+func (e ErrAccountLocked) Code() ErrCode   { return EAccountLocked }
+func (e ErrAccountLocked) Error() string   { return fmt.Sprintf("[%s] %s", e.Code(), string(e)) }
+func (e ErrAccountLocked) Message() string { return string(e) }
+
+// ErrTokenResync represents an error where a hardware token has drifted
+// ahead of the server and the next code is needed to resynchronize it.
+// It is not an incorrect code and does not count as a failed attempt.
+type ErrTokenResync string
+
+func (e ErrTokenResync) Code() ErrCode   { return ETokenResync }
+func (e ErrTokenResync) Error() string   { return fmt.Sprintf("[%s] %s", e.Code(), string(e)) }
+func (e ErrTokenResync) Message() string { return string(e) }
+
 // DomainError returns a domain error if available.
 func DomainError(err error) Error {
 	if err == nil {
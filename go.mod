@@ -3,6 +3,7 @@ module github.com/fmitra/authenticator
 go 1.13
 
 require (
+	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/duo-labs/webauthn v0.0.0-20200714211715-1daaee874e43
 	github.com/go-kit/kit v0.8.0
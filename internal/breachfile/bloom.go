@@ -0,0 +1,128 @@
+package breachfile
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxHashes limits the number of hash functions of a Bloom filter.
+const maxHashes = 32
+
+// BloomFilter is a probabilistic set of breached password hashes.
+// A password not in the set is never reported as breached, while a
+// password in the set is reported as breached with a false positive
+// rate chosen when the filter is built.
+//
+// Bit positions are derived from the SHA-1 hash of a password by
+// double hashing, so a filter may be built from published hashes
+// without the passwords themselves. Filters are stored as:
+//
+//	"PWBF" | version (1 byte) | hashes (1 byte) | bits (8 bytes) | bit array
+type BloomFilter struct {
+	// hashes is the number of bits set for each password.
+	hashes uint8
+	// size is the number of bits in the filter.
+	size uint64
+	bits []byte
+}
+
+// NewBloomFilter returns an empty Bloom filter sized for a number of
+// passwords at a false positive rate.
+func NewBloomFilter(count uint64, fpRate float64) (*BloomFilter, error) {
+	if count == 0 {
+		return nil, fmt.Errorf("bloom filter must hold at least 1 password")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1, received %v", fpRate)
+	}
+
+	size := uint64(math.Ceil(-float64(count) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	hashes := math.Round(float64(size) / float64(count) * math.Ln2)
+	hashes = math.Max(1, math.Min(maxHashes, hashes))
+
+	return &BloomFilter{
+		hashes: uint8(hashes),
+		size:   size,
+		bits:   make([]byte, (size+7)/8),
+	}, nil
+}
+
+// ReadBloomFilter reads a Bloom filter.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	header := make([]byte, len(bloomMagic)+10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cannot read bloom filter header: %w", err)
+	}
+	if string(header[:len(bloomMagic)]) != bloomMagic || header[len(bloomMagic)] != version {
+		return nil, fmt.Errorf("unsupported bloom filter format")
+	}
+
+	f := BloomFilter{
+		hashes: header[len(bloomMagic)+1],
+		size:   binary.BigEndian.Uint64(header[len(bloomMagic)+2:]),
+	}
+	if f.hashes < 1 || f.hashes > maxHashes || f.size == 0 {
+		return nil, fmt.Errorf("invalid bloom filter parameters")
+	}
+
+	f.bits = make([]byte, (f.size+7)/8)
+	if _, err := io.ReadFull(r, f.bits); err != nil {
+		return nil, fmt.Errorf("cannot read bloom filter: %w", err)
+	}
+
+	return &f, nil
+}
+
+// WriteTo writes the Bloom filter.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+10)
+	copy(header, bloomMagic)
+	header[len(bloomMagic)] = version
+	header[len(bloomMagic)+1] = f.hashes
+	binary.BigEndian.PutUint64(header[len(bloomMagic)+2:], f.size)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	m, err := w.Write(f.bits)
+	return int64(n + m), err
+}
+
+// Add adds the SHA-1 hash of a password to the filter.
+func (f *BloomFilter) Add(sum [sha1.Size]byte) {
+	f.positions(sum, func(pos uint64) bool {
+		f.bits[pos/8] |= 1 << (pos % 8)
+		return true
+	})
+}
+
+// IsBreached determines if a password may be in the filter.
+func (f *BloomFilter) IsBreached(ctx context.Context, password string) (bool, error) {
+	isBreached := true
+	f.positions(sha1.Sum([]byte(password)), func(pos uint64) bool {
+		isBreached = f.bits[pos/8]&(1<<(pos%8)) != 0
+		return isBreached
+	})
+
+	return isBreached, nil
+}
+
+// positions calls fn with each bit position of a hash until fn
+// returns false.
+func (f *BloomFilter) positions(sum [sha1.Size]byte, fn func(pos uint64) bool) {
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	// The step is made odd so that it is never 0.
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		if !fn((h1 + i*h2) % f.size) {
+			return
+		}
+	}
+}
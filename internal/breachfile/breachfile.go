@@ -0,0 +1,80 @@
+// Package breachfile checks passwords against an offline corpus of
+// breached password hashes for deployments without access to the
+// Pwned Passwords API. The corpus is built from the SHA-1 password
+// hashes published by Have I Been Pwned, either as a Bloom filter or
+// as a sorted list of hash prefixes, and loaded into memory at startup.
+package breachfile
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	bloomMagic  = "PWBF"
+	prefixMagic = "PWPF"
+	version     = 1
+)
+
+// Load reads a Bloom filter or prefix list from a file, detected
+// from its header.
+func Load(path string) (auth.BreachChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(len(bloomMagic))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+
+	switch string(magic) {
+	case bloomMagic:
+		return ReadBloomFilter(r)
+	case prefixMagic:
+		return ReadPrefixList(r)
+	default:
+		return nil, fmt.Errorf("unknown breach corpus format")
+	}
+}
+
+// ParseHashes reads SHA-1 password hashes in the format published by
+// Have I Been Pwned, one `<hex hash>:<count>` per line, and calls fn
+// with each hash. The count is optional and ignored.
+func ParseHashes(r io.Reader, fn func(sum [sha1.Size]byte) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+
+		var sum [sha1.Size]byte
+		b, err := hex.DecodeString(text)
+		if err != nil || len(b) != sha1.Size {
+			return fmt.Errorf("line %v: invalid SHA-1 hash", line)
+		}
+		copy(sum[:], b)
+
+		if err = fn(sum); err != nil {
+			return fmt.Errorf("line %v: %w", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,148 @@
+package breachfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// corpus returns a sorted list of hashes in the format published by
+// Have I Been Pwned.
+func corpus(passwords ...string) string {
+	var lines []string
+	for i, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		lines = append(lines, fmt.Sprintf("%s:%v", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestBreachFile_Checkers(t *testing.T) {
+	var breached []string
+	for i := 0; i < 1000; i++ {
+		breached = append(breached, fmt.Sprintf("password%v", i))
+	}
+	hashes := corpus(breached...)
+
+	bloom, err := NewBloomFilter(uint64(len(breached)), 0.0001)
+	if err != nil {
+		t.Fatal("failed to create bloom filter:", err)
+	}
+	err = ParseHashes(strings.NewReader(hashes), func(sum [sha1.Size]byte) error {
+		bloom.Add(sum)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("failed to parse hashes:", err)
+	}
+
+	var bloomFile bytes.Buffer
+	if _, err = bloom.WriteTo(&bloomFile); err != nil {
+		t.Fatal("failed to write bloom filter:", err)
+	}
+
+	var prefixFile bytes.Buffer
+	count, err := WritePrefixList(&prefixFile, strings.NewReader(hashes), 8)
+	if err != nil {
+		t.Fatal("failed to write prefix list:", err)
+	}
+	if count != len(breached) {
+		t.Errorf("incorrect prefix count, want %v got %v", len(breached), count)
+	}
+
+	dir, err := ioutil.TempDir("", "breachfile")
+	if err != nil {
+		t.Fatal("failed to create directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"bloom":  bloomFile.Bytes(),
+		"prefix": prefixFile.Bytes(),
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, content, 0600); err != nil {
+				t.Fatal("failed to write file:", err)
+			}
+
+			checker, err := Load(path)
+			if err != nil {
+				t.Fatal("failed to load file:", err)
+			}
+
+			ctx := context.Background()
+			for _, p := range breached {
+				isBreached, err := checker.IsBreached(ctx, p)
+				if err != nil || !isBreached {
+					t.Fatalf("password %s should be breached: %v", p, err)
+				}
+			}
+
+			if falsePositives := countBreached(t, checker, 1000); falsePositives > 1 {
+				t.Errorf("too many false positives: %v", falsePositives)
+			}
+		})
+	}
+}
+
+func countBreached(t *testing.T, checker auth.BreachChecker, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		isBreached, err := checker.IsBreached(context.Background(), fmt.Sprintf("swordfish%v", i))
+		if err != nil {
+			t.Fatal("failed to check password:", err)
+		}
+		if isBreached {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBreachFile_InvalidInput(t *testing.T) {
+	tt := []struct {
+		name   string
+		hashes string
+		errMsg string
+	}{
+		{
+			name:   "Unsorted hashes",
+			hashes: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1\n0000000000000000000000000000000000000000:1\n",
+			errMsg: "line 2: hashes are not sorted",
+		},
+		{
+			name:   "Invalid hash",
+			hashes: "password:1\n",
+			errMsg: "line 1: invalid SHA-1 hash",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := WritePrefixList(ioutil.Discard, strings.NewReader(tc.hashes), 8)
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("incorrect error, want %s got %v", tc.errMsg, err)
+			}
+		})
+	}
+
+	if _, err := ReadPrefixList(strings.NewReader("PWPF\x01\x08abc")); err == nil {
+		t.Error("truncated prefix list should not be read")
+	}
+	if _, err := ReadBloomFilter(strings.NewReader("PWPF\x01\x08")); err == nil {
+		t.Error("prefix list should not be read as a bloom filter")
+	}
+}
@@ -0,0 +1,111 @@
+package breachfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// PrefixList is a sorted list of breached password hash prefixes.
+// Storing a prefix of each SHA-1 hash reduces the size of the list,
+// at the cost of false positives for passwords sharing a prefix with
+// a breached password. Lists are stored as:
+//
+//	"PWPF" | version (1 byte) | prefix length (1 byte) | sorted prefixes
+type PrefixList struct {
+	length   int
+	prefixes []byte
+}
+
+// ReadPrefixList reads a prefix list.
+func ReadPrefixList(r io.Reader) (*PrefixList, error) {
+	header := make([]byte, len(prefixMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cannot read prefix list header: %w", err)
+	}
+	if string(header[:len(prefixMagic)]) != prefixMagic || header[len(prefixMagic)] != version {
+		return nil, fmt.Errorf("unsupported prefix list format")
+	}
+
+	l := PrefixList{length: int(header[len(prefixMagic)+1])}
+	if l.length < 1 || l.length > sha1.Size {
+		return nil, fmt.Errorf("invalid prefix length %v", l.length)
+	}
+
+	prefixes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read prefix list: %w", err)
+	}
+	if len(prefixes)%l.length != 0 {
+		return nil, fmt.Errorf("prefix list is truncated")
+	}
+	l.prefixes = prefixes
+
+	for i := 1; i < l.count(); i++ {
+		if bytes.Compare(l.prefix(i-1), l.prefix(i)) > 0 {
+			return nil, fmt.Errorf("prefix list is not sorted")
+		}
+	}
+
+	return &l, nil
+}
+
+// WritePrefixList writes a prefix list from SHA-1 password hashes in
+// the format read by ParseHashes. Hashes must be sorted, as in the
+// hash ordered download of Have I Been Pwned. It returns the number
+// of prefixes written.
+func WritePrefixList(w io.Writer, hashes io.Reader, length int) (int, error) {
+	if length < 1 || length > sha1.Size {
+		return 0, fmt.Errorf("prefix length must be between 1 and %v, received %v", sha1.Size, length)
+	}
+
+	if _, err := w.Write(append([]byte(prefixMagic), version, byte(length))); err != nil {
+		return 0, err
+	}
+
+	var (
+		last  []byte
+		count int
+	)
+	err := ParseHashes(hashes, func(sum [sha1.Size]byte) error {
+		prefix := sum[:length]
+		switch cmp := bytes.Compare(last, prefix); {
+		case last != nil && cmp > 0:
+			return fmt.Errorf("hashes are not sorted")
+		case last != nil && cmp == 0:
+			return nil
+		}
+
+		last = append(last[:0], prefix...)
+		count++
+		_, err := w.Write(prefix)
+		return err
+	})
+
+	return count, err
+}
+
+// IsBreached determines if the hash prefix of a password is in
+// the list.
+func (l *PrefixList) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	prefix := sum[:l.length]
+
+	i := sort.Search(l.count(), func(i int) bool {
+		return bytes.Compare(l.prefix(i), prefix) >= 0
+	})
+
+	return i < l.count() && bytes.Equal(l.prefix(i), prefix), nil
+}
+
+func (l *PrefixList) count() int {
+	return len(l.prefixes) / l.length
+}
+
+func (l *PrefixList) prefix(i int) []byte {
+	return l.prefixes[i*l.length : (i+1)*l.length]
+}
@@ -264,7 +264,7 @@ func TestContactAPI_Verify(t *testing.T) {
 		name              string
 		errMessage        string
 		statusCode        int
-		otpValidateFn     func(code, hash string) error
+		otpValidateFn     func(ctx context.Context, id, code, hash string) error
 		tokenValidateFn   func(userID string) func() (*auth.Token, error)
 		tokenCreateFn     func() (*auth.Token, error)
 		tokenSignFn       func() (string, error)
@@ -292,7 +292,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: false,
 			isEmailOTPAllowed: true,
 			reqBody:           []byte(`{"code":"123"}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -334,7 +334,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: false,
 			isEmailOTPAllowed: true,
 			reqBody:           []byte(`{"code":"123"}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -371,7 +371,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: true,
 			isEmailOTPAllowed: true,
 			reqBody:           []byte(`{"code":"123"}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -413,7 +413,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: false,
 			isEmailOTPAllowed: true,
 			reqBody:           []byte(`{"code":"123", "isDisabled":true}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -455,7 +455,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: true,
 			isEmailOTPAllowed: false,
 			reqBody:           []byte(`{"code":"123", "isDisabled":true}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -497,7 +497,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: true,
 			isEmailOTPAllowed: true,
 			reqBody:           []byte(`{"code":"123"}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return nil
 			},
 			tokenSignFn: func() (string, error) {
@@ -539,7 +539,7 @@ func TestContactAPI_Verify(t *testing.T) {
 			isPhoneOTPAllowed: true,
 			isEmailOTPAllowed: false,
 			reqBody:           []byte(`{"code":"123"}`),
-			otpValidateFn: func(code, hash string) error {
+			otpValidateFn: func(ctx context.Context, id, code, hash string) error {
 				return auth.ErrInvalidCode("invalid code")
 			},
 			tokenSignFn: func() (string, error) {
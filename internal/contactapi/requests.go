@@ -46,8 +46,8 @@ func decodeSendRequest(r *http.Request) (*sendRequest, error) {
 		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
 	}
 
-	if req.DeliveryMethod != auth.Phone && req.DeliveryMethod != auth.Email {
-		return nil, auth.ErrInvalidField("deliveryMethod must be `phone` or `email`")
+	if !req.DeliveryMethod.IsPhone() && req.DeliveryMethod != auth.Email {
+		return nil, auth.ErrInvalidField("deliveryMethod must be `phone`, `voice` or `email`")
 	}
 
 	return &req, nil
@@ -71,8 +71,8 @@ func decodeDeliveryRequest(r *http.Request) (*deliveryRequest, error) {
 		return nil, auth.ErrInvalidField("address cannot be empty")
 	}
 
-	if req.DeliveryMethod != auth.Phone && req.DeliveryMethod != auth.Email {
-		return nil, auth.ErrInvalidField("deliveryMethod must be `phone` or `email`")
+	if !req.DeliveryMethod.IsPhone() && req.DeliveryMethod != auth.Email {
+		return nil, auth.ErrInvalidField("deliveryMethod must be `phone`, `voice` or `email`")
 	}
 
 	if !contactchecker.Validator(req.DeliveryMethod)(req.Address) {
@@ -53,6 +53,13 @@ func TestContactAPI_CheckAddressRequest(t *testing.T) {
 			deliveryMethod: auth.Email,
 			hasError:       false,
 		},
+		{
+			name:           "Valid voice request",
+			request:        []byte(`{"address": "+6594867353", "deliveryMethod": "voice"}`),
+			address:        "+6594867353",
+			deliveryMethod: auth.Voice,
+			hasError:       false,
+		},
 		{
 			name:           "Invalid email address format",
 			request:        []byte(`{"address": "not-a-real-email", "deliveryMethod": "email"}`),
@@ -24,7 +24,7 @@ type service struct {
 
 // CheckAddress requests an OTP code to be delivered to the user through a
 // email address or phone number so may we verify the user's ownership of the
-// address.
+// address. Codes for a phone number may be delivered by SMS or voice call.
 func (s *service) CheckAddress(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	req, err := decodeDeliveryRequest(r)
 	if err != nil {
@@ -45,6 +45,7 @@ func (s *service) CheckAddress(w http.ResponseWriter, r *http.Request) (interfac
 		user,
 		auth.JWTAuthorized,
 		tokenLib.WithOTPDeliveryMethod(req.DeliveryMethod),
+		tokenLib.WithOTPMessageType(auth.OTPAddress),
 		tokenLib.WithOTPAddress(req.Address),
 		tokenLib.WithRefreshableToken(token),
 	)
@@ -67,6 +68,7 @@ func (s *service) CheckAddress(w http.ResponseWriter, r *http.Request) (interfac
 		Delivery: h.DeliveryMethod,
 		Vars:     map[string]string{"code": token.Code},
 		Address:  h.Address,
+		Locale:   user.Locale,
 	}
 	if err = s.message.Send(ctx, msg); err != nil {
 		return nil, err
@@ -124,7 +126,7 @@ func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, e
 	userID := httpapi.GetUserID(r)
 	token := httpapi.GetToken(r)
 
-	if err = s.otp.ValidateOTP(req.Code, token.CodeHash); err != nil {
+	if err = s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash); err != nil {
 		return nil, err
 	}
 
@@ -144,7 +146,7 @@ func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, e
 			return nil, err
 		}
 
-		if otpHash.DeliveryMethod == auth.Phone {
+		if otpHash.DeliveryMethod.IsPhone() {
 			user.Phone = sql.NullString{String: otpHash.Address, Valid: true}
 			user.IsPhoneOTPAllowed = req.IsOTPEnabled
 		}
@@ -189,7 +191,8 @@ func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, e
 
 // Remove removes a verified email or phone number from the User's profile. Removed
 // addresses must be re-verified with an OTP code in order to be set back onto the
-// profile.
+// profile. All other sessions are revoked as they may have been established
+// through the removed address.
 func (s *service) Remove(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	req, err := decodeDeactivateRequest(r)
 	if err != nil {
@@ -205,6 +208,10 @@ func (s *service) Remove(w http.ResponseWriter, r *http.Request) (interface{}, e
 	}
 
 	token := httpapi.GetToken(r)
+	if err = s.token.RevokeAll(ctx, userID, token.Id); err != nil {
+		return nil, err
+	}
+
 	token, err = s.token.Create(
 		ctx,
 		user,
@@ -248,6 +255,7 @@ func (s *service) Send(w http.ResponseWriter, r *http.Request) (interface{}, err
 		user,
 		auth.JWTPreAuthorized,
 		tokenLib.WithOTPDeliveryMethod(req.DeliveryMethod),
+		tokenLib.WithOTPMessageType(auth.OTPResend),
 		tokenLib.WithRefreshableToken(token),
 	)
 	if err != nil {
@@ -269,6 +277,7 @@ func (s *service) Send(w http.ResponseWriter, r *http.Request) (interface{}, err
 		Vars:     map[string]string{"code": token.Code},
 		Address:  h.Address,
 		Delivery: h.DeliveryMethod,
+		Locale:   user.Locale,
 	}
 	if err = s.message.Send(ctx, msg); err != nil {
 		return nil, err
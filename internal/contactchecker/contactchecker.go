@@ -35,7 +35,7 @@ func Validator(method auth.DeliveryMethod) func(s string) bool {
 		return IsEmailValid
 	}
 
-	if method == auth.Phone {
+	if method.IsPhone() {
 		return IsPhoneValid
 	}
 
@@ -33,9 +33,11 @@ func TestContactChecker_ValidatesPhone(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			res := Validator(auth.Phone)(tc.in)
-			if res != tc.out {
-				t.Error("phone validation failed", cmp.Diff(res, tc.out))
+			for _, method := range []auth.DeliveryMethod{auth.Phone, auth.Voice} {
+				res := Validator(method)(tc.in)
+				if res != tc.out {
+					t.Errorf("%s validation failed %s", method, cmp.Diff(res, tc.out))
+				}
 			}
 		})
 	}
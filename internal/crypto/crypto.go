@@ -6,6 +6,7 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"strings"
 )
 
@@ -20,20 +21,31 @@ func Bytes(length int) ([]byte, error) {
 }
 
 // BytesFromSample returns securely generated random bytes from a string
-// sample.
+// sample. Each character of the sample is equally likely to be chosen.
 func BytesFromSample(length int, samples ...string) ([]byte, error) {
 	sample := strings.Join(samples, "")
 	if sample == "" {
 		sample = "!\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
 			"[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
 	}
-
-	bytes, err := Bytes(length)
-	if err != nil {
-		return nil, err
+	if len(sample) > 256 {
+		return nil, fmt.Errorf("sample must not exceed 256 characters")
 	}
-	for i, b := range bytes {
-		bytes[i] = sample[b%byte(len(sample))]
+
+	// Random bytes at or above the largest multiple of the sample
+	// length are discarded to avoid favouring the first characters.
+	limit := 256 - 256%len(sample)
+	bytes := make([]byte, 0, length)
+	for len(bytes) < length {
+		random, err := Bytes(length - len(bytes))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range random {
+			if int(b) < limit {
+				bytes = append(bytes, sample[int(b)%len(sample)])
+			}
+		}
 	}
 
 	return bytes, nil
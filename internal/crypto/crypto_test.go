@@ -62,3 +62,22 @@ func TestCrypto_Hash(t *testing.T) {
 		t.Error("hashes do not match", cmp.Diff(hash, hash2))
 	}
 }
+
+func TestCrypto_StringDistribution(t *testing.T) {
+	samples := "0123456789"
+	random, err := String(10000, samples)
+	if err != nil {
+		t.Fatal("failed to generate random string", err)
+	}
+
+	counts := map[rune]int{}
+	for _, v := range random {
+		counts[v]++
+	}
+
+	for _, v := range samples {
+		if counts[v] < 800 || counts[v] > 1200 {
+			t.Errorf("character %s is not evenly distributed, found %v times", string(v), counts[v])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package failover
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	// defaultMaxFailures is the default number of consecutive failures
+	// before a provider is marked unhealthy.
+	defaultMaxFailures = 3
+	// defaultCooldown is the default time an unhealthy provider is
+	// tried after healthy providers.
+	defaultCooldown = time.Minute
+)
+
+// Service delivers SMS, voice calls and email through an ordered
+// chain of providers.
+type Service interface {
+	auth.SMSer
+	auth.Voicer
+	auth.Emailer
+}
+
+// NewService returns a new provider chain. Providers are attempted in
+// the order they are configured.
+func NewService(options ...ConfigOption) Service {
+	s := service{
+		logger:      log.NewNopLogger(),
+		maxFailures: defaultMaxFailures,
+		cooldown:    defaultCooldown,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithMaxFailures sets the number of consecutive failures before
+// a provider is marked unhealthy.
+func WithMaxFailures(n int) ConfigOption {
+	return func(s *service) {
+		s.maxFailures = n
+	}
+}
+
+// WithCooldown sets the time an unhealthy provider is tried after
+// healthy providers.
+func WithCooldown(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.cooldown = d
+	}
+}
+
+// WithSMSProvider appends an SMS provider to the chain.
+func WithSMSProvider(name string, smsLib auth.SMSer) ConfigOption {
+	return func(s *service) {
+		s.smsProviders = append(s.smsProviders, &provider{name: name, smsLib: smsLib})
+	}
+}
+
+// WithVoiceProvider appends a voice call provider to the chain.
+func WithVoiceProvider(name string, voiceLib auth.Voicer) ConfigOption {
+	return func(s *service) {
+		s.voiceProviders = append(s.voiceProviders, &provider{name: name, voiceLib: voiceLib})
+	}
+}
+
+// WithEmailProvider appends an email provider to the chain.
+func WithEmailProvider(name string, emailLib auth.Emailer) ConfigOption {
+	return func(s *service) {
+		s.emailProviders = append(s.emailProviders, &provider{name: name, emailLib: emailLib})
+	}
+}
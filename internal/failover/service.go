@@ -0,0 +1,159 @@
+// Package failover delivers messages through an ordered chain of
+// SMS, voice and email providers.
+package failover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// service is an implementation of auth.SMSer, auth.Voicer and auth.Emailer. A message
+// is delivered by the first provider to succeed. Providers which fail
+// repeatedly are marked unhealthy and only attempted after all healthy
+// providers fail, until they recover after a cooldown period.
+type service struct {
+	logger         log.Logger
+	maxFailures    int
+	cooldown       time.Duration
+	smsProviders   []*provider
+	voiceProviders []*provider
+	emailProviders []*provider
+}
+
+// provider is an SMS, voice or email provider and its health.
+type provider struct {
+	name     string
+	smsLib   auth.SMSer
+	voiceLib auth.Voicer
+	emailLib auth.Emailer
+
+	mu sync.Mutex
+	// failures is the number of consecutive failed deliveries.
+	failures int
+	// unhealthyUntil is the time an unhealthy provider is attempted
+	// before healthy providers again.
+	unhealthyUntil time.Time
+}
+
+// SMS sends an SMS to a phone number.
+func (s *service) SMS(ctx context.Context, phoneNumber, message string) error {
+	return s.deliver(ctx, "sms", s.smsProviders, func(p *provider) error {
+		return p.smsLib.SMS(ctx, phoneNumber, message)
+	})
+}
+
+// Voice calls a phone number and reads a message aloud.
+func (s *service) Voice(ctx context.Context, phoneNumber, message, locale string) error {
+	return s.deliver(ctx, "voice", s.voiceProviders, func(p *provider) error {
+		return p.voiceLib.Voice(ctx, phoneNumber, message, locale)
+	})
+}
+
+// Email sends an email to an email address.
+func (s *service) Email(ctx context.Context, email, subject, message string) error {
+	return s.deliver(ctx, "email", s.emailProviders, func(p *provider) error {
+		return p.emailLib.Email(ctx, email, subject, message)
+	})
+}
+
+// deliver attempts a delivery with each provider until one succeeds.
+func (s *service) deliver(ctx context.Context, method string, providers []*provider, send func(p *provider) error) error {
+	if len(providers) == 0 {
+		return fmt.Errorf("no %s providers configured", method)
+	}
+
+	var errs []string
+	for _, p := range s.order(providers) {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err.Error())
+			break
+		}
+
+		err := send(p)
+		if err == nil {
+			p.succeed()
+			return nil
+		}
+
+		if p.fail(s.maxFailures, s.cooldown) {
+			level.Warn(s.logger).Log(
+				"source", "failover.deliver",
+				"message", "provider marked unhealthy",
+				"provider", p.name,
+				"delivery", method,
+				"cooldown", s.cooldown,
+			)
+		}
+		level.Info(s.logger).Log(
+			"source", "failover.deliver",
+			"message", "provider failed to deliver message",
+			"provider", p.name,
+			"delivery", method,
+			"error", err,
+		)
+		errs = append(errs, fmt.Sprintf("%s: %v", p.name, err))
+	}
+
+	return fmt.Errorf("all %s providers failed: %s", method, strings.Join(errs, "; "))
+}
+
+// order returns healthy providers followed by unhealthy providers,
+// each in their configured order.
+func (s *service) order(providers []*provider) []*provider {
+	var (
+		healthy   []*provider
+		unhealthy []*provider
+		now       = time.Now()
+	)
+
+	for _, p := range providers {
+		if p.isHealthy(now) {
+			healthy = append(healthy, p)
+		} else {
+			unhealthy = append(unhealthy, p)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (p *provider) isHealthy(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !now.Before(p.unhealthyUntil)
+}
+
+func (p *provider) succeed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = 0
+	p.unhealthyUntil = time.Time{}
+}
+
+// fail records a failed delivery and returns true if the provider
+// is newly marked unhealthy.
+func (p *provider) fail(maxFailures int, cooldown time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	wasHealthy := !now.Before(p.unhealthyUntil)
+
+	p.failures++
+	if p.failures < maxFailures {
+		return false
+	}
+
+	// Failures are only reset on success, so a provider which fails
+	// again after its cooldown is immediately marked unhealthy.
+	p.unhealthyUntil = now.Add(cooldown)
+	return wasHealthy
+}
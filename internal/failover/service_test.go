@@ -0,0 +1,165 @@
+package failover
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockProvider struct {
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (m *mockProvider) SMS(ctx context.Context, phoneNumber, message string) error {
+	*m.calls = append(*m.calls, m.name)
+	return m.err
+}
+
+func (m *mockProvider) Voice(ctx context.Context, phoneNumber, message, locale string) error {
+	*m.calls = append(*m.calls, m.name)
+	return m.err
+}
+
+func (m *mockProvider) Email(ctx context.Context, email, subject, message string) error {
+	*m.calls = append(*m.calls, m.name)
+	return m.err
+}
+
+func TestFailover_Deliver(t *testing.T) {
+	tt := []struct {
+		name     string
+		errs     []error
+		calls    []string
+		isFailed bool
+	}{
+		{
+			name:     "Delivers with primary provider",
+			errs:     []error{nil, nil},
+			calls:    []string{"primary"},
+			isFailed: false,
+		},
+		{
+			name:     "Fails over to secondary provider",
+			errs:     []error{fmt.Errorf("whoops"), nil},
+			calls:    []string{"primary", "secondary"},
+			isFailed: false,
+		},
+		{
+			name:     "Fails with all providers",
+			errs:     []error{fmt.Errorf("whoops"), fmt.Errorf("whoops")},
+			calls:    []string{"primary", "secondary"},
+			isFailed: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var smsCalls, voiceCalls, emailCalls []string
+			svc := NewService(
+				WithSMSProvider("primary", &mockProvider{name: "primary", err: tc.errs[0], calls: &smsCalls}),
+				WithSMSProvider("secondary", &mockProvider{name: "secondary", err: tc.errs[1], calls: &smsCalls}),
+				WithVoiceProvider("primary", &mockProvider{name: "primary", err: tc.errs[0], calls: &voiceCalls}),
+				WithVoiceProvider("secondary", &mockProvider{name: "secondary", err: tc.errs[1], calls: &voiceCalls}),
+				WithEmailProvider("primary", &mockProvider{name: "primary", err: tc.errs[0], calls: &emailCalls}),
+				WithEmailProvider("secondary", &mockProvider{name: "secondary", err: tc.errs[1], calls: &emailCalls}),
+			)
+
+			ctx := context.Background()
+			smsErr := svc.SMS(ctx, "+15555555555", "hello")
+			voiceErr := svc.Voice(ctx, "+15555555555", "hello", "en")
+			emailErr := svc.Email(ctx, "jane@example.com", "Hello", "hello")
+
+			for _, err := range []error{smsErr, voiceErr, emailErr} {
+				if err != nil && !tc.isFailed {
+					t.Error("expected nil error, received:", err)
+				}
+				if err == nil && tc.isFailed {
+					t.Error("expected error, received nil")
+				}
+			}
+			if !cmp.Equal(smsCalls, tc.calls) {
+				t.Error("incorrect SMS providers called", cmp.Diff(smsCalls, tc.calls))
+			}
+			if !cmp.Equal(voiceCalls, tc.calls) {
+				t.Error("incorrect voice providers called", cmp.Diff(voiceCalls, tc.calls))
+			}
+			if !cmp.Equal(emailCalls, tc.calls) {
+				t.Error("incorrect email providers called", cmp.Diff(emailCalls, tc.calls))
+			}
+		})
+	}
+}
+
+func TestFailover_HealthTracking(t *testing.T) {
+	var calls []string
+	primary := &mockProvider{name: "primary", err: fmt.Errorf("whoops"), calls: &calls}
+	secondary := &mockProvider{name: "secondary", calls: &calls}
+
+	svc := NewService(
+		WithSMSProvider("primary", primary),
+		WithSMSProvider("secondary", secondary),
+		WithMaxFailures(2),
+		WithCooldown(time.Millisecond*50),
+	)
+	s := svc.(*service)
+
+	ctx := context.Background()
+	send := func() []string {
+		calls = nil
+		if err := svc.SMS(ctx, "+15555555555", "hello"); err != nil {
+			t.Fatal("expected nil error, received:", err)
+		}
+		return calls
+	}
+
+	// The primary provider is attempted until it is marked unhealthy.
+	for i := 0; i < 2; i++ {
+		if got := send(); !cmp.Equal(got, []string{"primary", "secondary"}) {
+			t.Errorf("attempt %v: incorrect providers called %v", i+1, got)
+		}
+	}
+
+	// Unhealthy providers are skipped while a healthy provider succeeds.
+	if got := send(); !cmp.Equal(got, []string{"secondary"}) {
+		t.Errorf("unhealthy provider should be skipped, called %v", got)
+	}
+
+	// Unhealthy providers are still attempted if all healthy providers fail.
+	secondary.err = fmt.Errorf("whoops")
+	calls = nil
+	if err := svc.SMS(ctx, "+15555555555", "hello"); err == nil {
+		t.Error("expected error, received nil")
+	}
+	if !cmp.Equal(calls, []string{"secondary", "primary"}) {
+		t.Errorf("unhealthy provider should be attempted last, called %v", calls)
+	}
+
+	// A recovered provider is restored to its position after a cooldown.
+	primary.err = nil
+	secondary.err = nil
+	time.Sleep(time.Millisecond * 60)
+	if got := send(); !cmp.Equal(got, []string{"primary"}) {
+		t.Errorf("recovered provider should be attempted first, called %v", got)
+	}
+	if !s.smsProviders[0].isHealthy(time.Now()) || s.smsProviders[0].failures != 0 {
+		t.Error("recovered provider should be healthy")
+	}
+}
+
+func TestFailover_NoProviders(t *testing.T) {
+	svc := NewService()
+	if err := svc.SMS(context.Background(), "+15555555555", "hello"); err == nil {
+		t.Error("expected error, received nil")
+	}
+	if err := svc.Voice(context.Background(), "+15555555555", "hello", ""); err == nil {
+		t.Error("expected error, received nil")
+	}
+	if err := svc.Email(context.Background(), "jane@example.com", "Hello", "hello"); err == nil {
+		t.Error("expected error, received nil")
+	}
+}
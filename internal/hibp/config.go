@@ -0,0 +1,50 @@
+package hibp
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultBaseURL is the default Pwned Passwords API endpoint.
+const defaultBaseURL = "https://api.pwnedpasswords.com"
+
+// Config holds configuration options for the Pwned Passwords API.
+type Config struct {
+	baseURL string
+	timeout time.Duration
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*client)
+
+// NewClient returns a Pwned Passwords client.
+func NewClient(configuration ConfigOption) auth.BreachChecker {
+	c := client{}
+	configuration(&c)
+	return &c
+}
+
+// WithConfig configures the service with a Config.
+func WithConfig(config Config) ConfigOption {
+	return func(c *client) {
+		c.baseURL = strings.TrimSuffix(config.baseURL, "/")
+		c.httpClient = &http.Client{Timeout: config.timeout}
+	}
+}
+
+// WithDefaults configures a Pwned Passwords client with an API
+// endpoint and request timeout. The default endpoint is used if
+// baseURL is empty.
+func WithDefaults(baseURL string, timeout time.Duration) ConfigOption {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	return WithConfig(Config{
+		baseURL: baseURL,
+		timeout: timeout,
+	})
+}
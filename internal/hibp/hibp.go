@@ -0,0 +1,74 @@
+// Package hibp exposes the Have I Been Pwned Pwned Passwords API.
+package hibp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// client is a consumer of the Pwned Passwords range API. Only the
+// first 5 characters of a password's SHA-1 hash are sent to the API,
+// which responds with the suffixes of all breached hashes sharing the
+// prefix (k-anonymity).
+type client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// IsBreached determines if a password has been exposed in a data breach.
+func (c *client) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	u := fmt.Sprintf("%s/range/%s", c.baseURL, prefix)
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return false, fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+
+	// Padding hides the number of breached hashes sharing the prefix
+	// from observers of the response size.
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "authenticator")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rBody, _ := ioutil.ReadAll(resp.Body)
+		return false, fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusOK, resp.StatusCode, string(rBody))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], suffix) {
+			continue
+		}
+
+		// Padded entries are returned with a count of 0.
+		count, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return false, fmt.Errorf("cannot decode response count %q: %w", parts[1], err)
+		}
+		return count > 0, nil
+	}
+	if err = scanner.Err(); err != nil {
+		return false, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	return false, nil
+}
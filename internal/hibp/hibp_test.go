@@ -0,0 +1,92 @@
+package hibp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHIBP_IsBreached(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	tt := []struct {
+		name         string
+		password     string
+		responseCode int
+		resp         string
+		isBreached   bool
+		hasError     bool
+	}{
+		{
+			name:         "Breached password",
+			password:     "password",
+			responseCode: http.StatusOK,
+			resp:         "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n",
+			isBreached:   true,
+			hasError:     false,
+		},
+		{
+			name:         "Lower case suffix",
+			password:     "password",
+			responseCode: http.StatusOK,
+			resp:         "1e4c9b93f3f0682250b6cf8331b7ee68fd8:12\r\n",
+			isBreached:   true,
+			hasError:     false,
+		},
+		{
+			name:         "Padded entry",
+			password:     "password",
+			responseCode: http.StatusOK,
+			resp:         "1E4C9B93F3F0682250B6CF8331B7EE68FD8:0\r\n",
+			isBreached:   false,
+			hasError:     false,
+		},
+		{
+			name:         "Password not breached",
+			password:     "password",
+			responseCode: http.StatusOK,
+			resp:         "003D68EB55068C33ACE09247EE4C639306B:3\r\n",
+			isBreached:   false,
+			hasError:     false,
+		},
+		{
+			name:         "Invalid 503",
+			password:     "password",
+			responseCode: http.StatusServiceUnavailable,
+			resp:         "",
+			isBreached:   false,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" || r.URL.Path != "/range/5BAA6" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				if r.Header.Get("Add-Padding") != "true" {
+					t.Error("padding should be requested")
+				}
+
+				w.WriteHeader(tc.responseCode)
+				fmt.Fprint(w, tc.resp)
+			}))
+			defer srv.Close()
+
+			c := NewClient(WithDefaults(srv.URL+"/", time.Second))
+			isBreached, err := c.IsBreached(context.Background(), tc.password)
+			if tc.hasError && err == nil {
+				t.Error("expected error, received nil")
+			}
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error, received:", err)
+			}
+			if isBreached != tc.isBreached {
+				t.Errorf("incorrect breach result, want %v got %v", tc.isBreached, isBreached)
+			}
+		})
+	}
+}
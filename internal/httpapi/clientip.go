@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const clientIPContextKey contextKey = "clientIP"
+
+// ParseTrustedProxies parses a comma separated list of IP addresses
+// and CIDR ranges of trusted reverse proxies.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		proxies = append(proxies, ipNet)
+	}
+
+	return proxies, nil
+}
+
+// ClientIPMiddleware sets the client IP address of a request in context.
+// Forwarding headers are only honoured when the request is received
+// from a trusted proxy, as they may otherwise be set by the client.
+func ClientIPMiddleware(next http.Handler, trustedProxies []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, trustedProxies)
+		ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// clientIP walks the X-Forwarded-For chain from the most recent hop,
+// returning the first address which is not a trusted proxy.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !isTrusted(ip, trustedProxies) {
+		return ip
+	}
+
+	forwardedFor := r.Header[http.CanonicalHeaderKey("X-Forwarded-For")]
+	if len(forwardedFor) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+
+		ip = hop
+		if !isTrusted(ip, trustedProxies) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// remoteIP returns the IP address of the peer of a request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrusted(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, p := range trustedProxies {
+		if p.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
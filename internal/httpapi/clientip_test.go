@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPAPI_ParseTrustedProxies(t *testing.T) {
+	tt := []struct {
+		name    string
+		proxies string
+		count   int
+		hasErr  bool
+	}{
+		{
+			name:    "Parses IP addresses and CIDR ranges",
+			proxies: "10.0.0.0/8, 192.168.1.1,::1",
+			count:   3,
+		},
+		{
+			name:    "Parses empty list",
+			proxies: "",
+			count:   0,
+		},
+		{
+			name:    "Rejects invalid IP address",
+			proxies: "10.0.0.256",
+			hasErr:  true,
+		},
+		{
+			name:    "Rejects invalid CIDR range",
+			proxies: "10.0.0.0/33",
+			hasErr:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			proxies, err := ParseTrustedProxies(tc.proxies)
+			if tc.hasErr != (err != nil) {
+				t.Fatalf("incorrect error result, want %v got %v", tc.hasErr, err)
+			}
+			if len(proxies) != tc.count {
+				t.Error("proxy count does not match", cmp.Diff(len(proxies), tc.count))
+			}
+		})
+	}
+}
+
+func TestHTTPAPI_ClientIPMiddleware(t *testing.T) {
+	tt := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		realIP         string
+		trustedProxies string
+		ip             string
+	}{
+		{
+			name:       "Strips port from remote address",
+			remoteAddr: "203.0.113.7:52311",
+			ip:         "203.0.113.7",
+		},
+		{
+			name:       "Strips port from IPv6 remote address",
+			remoteAddr: "[2001:db8::1]:52311",
+			ip:         "2001:db8::1",
+		},
+		{
+			name:         "Ignores forwarding headers from untrusted peers",
+			remoteAddr:   "203.0.113.7:52311",
+			forwardedFor: []string{"198.51.100.1"},
+			realIP:       "198.51.100.2",
+			ip:           "203.0.113.7",
+		},
+		{
+			name:           "Honours X-Forwarded-For from trusted proxies",
+			remoteAddr:     "10.0.0.2:52311",
+			forwardedFor:   []string{"198.51.100.1"},
+			trustedProxies: "10.0.0.0/8",
+			ip:             "198.51.100.1",
+		},
+		{
+			name:           "Skips spoofed hops added before untrusted peer",
+			remoteAddr:     "10.0.0.2:52311",
+			forwardedFor:   []string{"192.0.2.9, 198.51.100.1", "10.0.0.3"},
+			trustedProxies: "10.0.0.0/8",
+			ip:             "198.51.100.1",
+		},
+		{
+			name:           "Honours X-Real-IP from trusted proxies",
+			remoteAddr:     "10.0.0.2:52311",
+			realIP:         "198.51.100.2",
+			trustedProxies: "10.0.0.0/8",
+			ip:             "198.51.100.2",
+		},
+		{
+			name:           "Stops at invalid X-Forwarded-For hop",
+			remoteAddr:     "10.0.0.2:52311",
+			forwardedFor:   []string{"198.51.100.1, unknown"},
+			trustedProxies: "10.0.0.0/8",
+			ip:             "10.0.0.2",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			trustedProxies, err := ParseTrustedProxies(tc.trustedProxies)
+			if err != nil {
+				t.Fatal("failed to parse trusted proxies:", err)
+			}
+
+			r, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal("failed to create mock request:", err)
+			}
+			r.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+
+			var ip string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = GetIP(r)
+			})
+			ClientIPMiddleware(handler, trustedProxies).ServeHTTP(httptest.NewRecorder(), r)
+
+			if ip != tc.ip {
+				t.Error("client IP does not match", cmp.Diff(ip, tc.ip))
+			}
+		})
+	}
+}
+
+func TestHTTPAPI_GetIPWithoutMiddleware(t *testing.T) {
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal("failed to create mock request:", err)
+	}
+	r.RemoteAddr = "203.0.113.7:52311"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	ip := GetIP(r)
+	if ip != "203.0.113.7" {
+		t.Errorf("incorrect IP, want 203.0.113.7 got '%s'", ip)
+	}
+}
@@ -2,7 +2,9 @@ package httpapi
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -65,6 +67,19 @@ func AuthMiddleware(jsonHandler JSONAPIHandler, tokenSvc auth.TokenService, stat
 	}
 }
 
+// AdminMiddleware validates an Authorization header against an administrator
+// API key. All requests are rejected if no API key is configured.
+func AdminMiddleware(jsonHandler JSONAPIHandler, apiKey string) JSONAPIHandler {
+	return func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+		key := strings.TrimPrefix(r.Header.Get(authorizationHeader), "Bearer ")
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			return nil, auth.ErrInvalidToken("admin key is invalid")
+		}
+
+		return jsonHandler(w, r)
+	}
+}
+
 // RefreshTokenMiddleware sets a refresh token in context.
 func RefreshTokenMiddleware(jsonHandler JSONAPIHandler) JSONAPIHandler {
 	return func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
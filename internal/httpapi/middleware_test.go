@@ -199,3 +199,59 @@ func TestHTTPAPI_AuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPAPI_AdminMiddleware(t *testing.T) {
+	tt := []struct {
+		name          string
+		apiKey        string
+		authorization string
+		errCode       auth.ErrCode
+	}{
+		{
+			name:          "Accepts matching key",
+			apiKey:        "swordfish",
+			authorization: "Bearer swordfish",
+			errCode:       auth.ErrCode(""),
+		},
+		{
+			name:          "Rejects incorrect key",
+			apiKey:        "swordfish",
+			authorization: "Bearer catfish",
+			errCode:       auth.EInvalidToken,
+		},
+		{
+			name:          "Rejects missing key",
+			apiKey:        "swordfish",
+			authorization: "",
+			errCode:       auth.EInvalidToken,
+		},
+		{
+			name:          "Rejects all requests if key is not configured",
+			apiKey:        "",
+			authorization: "Bearer ",
+			errCode:       auth.EInvalidToken,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+				return nil, nil
+			}
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("GET", "", nil)
+			if err != nil {
+				t.Fatal("failed to create mock request:", err)
+			}
+			r.Header.Set("AUTHORIZATION", tc.authorization)
+
+			_, err = AdminMiddleware(handler, tc.apiKey)(w, r)
+			if !cmp.Equal(auth.ErrorCode(err), tc.errCode) {
+				t.Error("error code does not match", cmp.Diff(
+					auth.ErrorCode(err), tc.errCode,
+				))
+			}
+		})
+	}
+}
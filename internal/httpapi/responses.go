@@ -13,6 +13,13 @@ import (
 // JSONAPIHandler is an HTTP handler for a JSON API.
 type JSONAPIHandler func(w http.ResponseWriter, r *http.Request) (interface{}, error)
 
+// ContentResponse is a non-JSON response body, such as an image,
+// returned by a JSONAPIHandler.
+type ContentResponse struct {
+	ContentType string
+	Content     []byte
+}
+
 // ToHandlerFunc adapts a JSONAPIHandler into net/http's HandlerFunc.
 func ToHandlerFunc(jsonHandler JSONAPIHandler, successCode int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -22,6 +29,13 @@ func ToHandlerFunc(jsonHandler JSONAPIHandler, successCode int) http.HandlerFunc
 			return
 		}
 
+		if c, ok := response.(*ContentResponse); ok {
+			w.Header().Set("Content-Type", c.ContentType)
+			w.WriteHeader(successCode)
+			_, _ = w.Write(c.Content)
+			return
+		}
+
 		JSONResponse(w, response, successCode)
 	}
 }
@@ -56,23 +70,14 @@ func GetRefreshToken(r *http.Request) string {
 	return token
 }
 
-// GetIP Retrieves the client IP address
+// GetIP Retrieves the client IP address. Requests which have not
+// passed through ClientIPMiddleware resolve to the address of the peer.
 func GetIP(r *http.Request) string {
-	var ip string
-
-	ip = r.Header.Get("X-Forwarded-For")
-	if ip != "" && strings.Contains(ip, ",") {
-		ip = strings.Split(ip, ",")[0]
-	}
-
-	if ip == "" {
-		ip = r.Header.Get("X-Real-IP")
-	}
-
-	if ip == "" {
-		ip = r.RemoteAddr
+	ctx := r.Context()
+	ip, ok := ctx.Value(clientIPContextKey).(string)
+	if !ok {
+		return remoteIP(r)
 	}
-
 	return ip
 }
 
@@ -115,6 +120,8 @@ func ErrorResponse(w http.ResponseWriter, err error) {
 		statusCode = http.StatusUnauthorized
 	case auth.EThrottle:
 		statusCode = http.StatusTooManyRequests
+	case auth.EAccountLocked:
+		statusCode = http.StatusLocked
 	default:
 		statusCode = http.StatusBadRequest
 	}
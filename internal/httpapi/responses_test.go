@@ -85,6 +85,32 @@ func TestHTTPAPI_JSONResponse(t *testing.T) {
 	}
 }
 
+func TestHTTPAPI_ContentResponse(t *testing.T) {
+	handler := ToHandlerFunc(func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+		return &ContentResponse{ContentType: "image/svg+xml", Content: []byte("<svg/>")}, nil
+	}, http.StatusOK)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("failed to read response body:", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status code, want %v got %v", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("incorrect content type, want image/svg+xml got %s", ct)
+	}
+	if string(body) != "<svg/>" {
+		t.Errorf("incorrect response body, want <svg/> got %s", body)
+	}
+}
+
 func TestHTTPAPI_ErrorResponse(t *testing.T) {
 	tt := []struct {
 		name    string
@@ -0,0 +1,106 @@
+package lockout
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	defaultDelayAfter      = 3
+	defaultMaxAttempts     = 10
+	defaultBaseDelay       = time.Second
+	defaultMaxDelay        = time.Minute * 5
+	defaultLockoutDuration = time.Minute * 30
+	defaultWindow          = time.Hour
+)
+
+// NewService returns a new LockoutService.
+func NewService(options ...ConfigOption) auth.LockoutService {
+	s := service{
+		logger:          log.NewNopLogger(),
+		delayAfter:      defaultDelayAfter,
+		maxAttempts:     defaultMaxAttempts,
+		baseDelay:       defaultBaseDelay,
+		maxDelay:        defaultMaxDelay,
+		lockoutDuration: defaultLockoutDuration,
+		window:          defaultWindow,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithDB configures the service with a redis DB.
+func WithDB(db rediser) ConfigOption {
+	return func(s *service) {
+		s.db = db
+	}
+}
+
+// WithOTP configures the service with an OTP generator.
+func WithOTP(o auth.OTPService) ConfigOption {
+	return func(s *service) {
+		s.otp = o
+	}
+}
+
+// WithDelayAfter configures the number of failed attempts permitted
+// before each subsequent attempt is delayed.
+func WithDelayAfter(n int) ConfigOption {
+	return func(s *service) {
+		s.delayAfter = n
+	}
+}
+
+// WithMaxAttempts configures the number of failed attempts
+// before a User is locked out.
+func WithMaxAttempts(n int) ConfigOption {
+	return func(s *service) {
+		s.maxAttempts = n
+	}
+}
+
+// WithBaseDelay configures the delay enforced after the first
+// delayed attempt. The delay doubles after each further failure.
+func WithBaseDelay(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.baseDelay = d
+	}
+}
+
+// WithMaxDelay configures the longest delay enforced between attempts.
+func WithMaxDelay(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.maxDelay = d
+	}
+}
+
+// WithLockoutDuration configures how long a User remains locked out.
+func WithLockoutDuration(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.lockoutDuration = d
+	}
+}
+
+// WithWindow configures how long a failed attempt is remembered.
+func WithWindow(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.window = d
+	}
+}
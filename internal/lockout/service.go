@@ -0,0 +1,188 @@
+// Package lockout restricts authentication after repeated failures.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// unlockCodeExpiry is the lifetime of an unlock code. It matches the
+// expiry embedded in an OTP code hash.
+const unlockCodeExpiry = time.Minute * 5
+
+// rediser is a minimal interface for go-redis
+type rediser interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	TTL(ctx context.Context, key string) *redis.DurationCmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	Close() error
+}
+
+// service is an implementation of auth.LockoutService backed by redis.
+// Failed attempts are counted per User. After a number of failures, each
+// further attempt is delayed with an exponential back-off until the User
+// is locked out entirely.
+type service struct {
+	logger log.Logger
+	db     rediser
+	otp    auth.OTPService
+	// delayAfter is the number of failed attempts permitted before
+	// each subsequent attempt is delayed.
+	delayAfter int
+	// maxAttempts is the number of failed attempts before a User
+	// is locked out.
+	maxAttempts     int
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	lockoutDuration time.Duration
+	// window is the time a failed attempt is remembered.
+	window time.Duration
+}
+
+// Check returns an error if a User is locked out or must wait
+// before attempting to authenticate again.
+func (s *service) Check(ctx context.Context, userID string) error {
+	ttl, err := s.db.TTL(ctx, lockKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("cannot lookup lockout: %w", err)
+	}
+	if ttl > 0 {
+		return auth.ErrAccountLocked("account is temporarily locked")
+	}
+
+	ttl, err = s.db.TTL(ctx, delayKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("cannot lookup attempt delay: %w", err)
+	}
+	if ttl > 0 {
+		return auth.ErrThrottle(fmt.Sprintf(
+			"too many failed attempts, try again in %v", ttl.Round(time.Second),
+		))
+	}
+
+	return nil
+}
+
+// Fail records a failed authentication attempt for a User.
+func (s *service) Fail(ctx context.Context, userID string) error {
+	var attempts *redis.IntCmd
+	_, err := s.db.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		attempts = pipe.Incr(ctx, attemptsKey(userID))
+		pipe.Expire(ctx, attemptsKey(userID), s.window)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot record failed attempt: %w", err)
+	}
+
+	count := int(attempts.Val())
+	if count >= s.maxAttempts {
+		level.Warn(s.logger).Log(
+			"source", "LockoutService.Fail",
+			"message", "account locked after failed attempts",
+			"user_id", userID,
+			"attempts", count,
+		)
+		return s.db.Set(ctx, lockKey(userID), true, s.lockoutDuration).Err()
+	}
+
+	if count >= s.delayAfter {
+		return s.db.Set(ctx, delayKey(userID), true, s.delay(count)).Err()
+	}
+
+	return nil
+}
+
+// Reset clears all failed attempts and any lockout for a User.
+func (s *service) Reset(ctx context.Context, userID string) error {
+	err := s.db.Del(
+		ctx,
+		attemptsKey(userID),
+		delayKey(userID),
+		lockKey(userID),
+		unlockKey(userID),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("cannot reset failed attempts: %w", err)
+	}
+
+	return nil
+}
+
+// UnlockCode creates an OTP code to unlock a locked out User. The code
+// hash is stored until the code is submitted or expires.
+func (s *service) UnlockCode(ctx context.Context, userID, address string, method auth.DeliveryMethod) (string, error) {
+	code, hash, err := s.otp.OTPCode(address, method, auth.OTPUnlock)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate OTP code: %w", err)
+	}
+
+	if err = s.db.Set(ctx, unlockKey(userID), hash, unlockCodeExpiry).Err(); err != nil {
+		return "", fmt.Errorf("cannot store unlock code: %w", err)
+	}
+
+	return code, nil
+}
+
+// Unlock validates an unlock code and clears the User's lockout.
+// An unlock code may only be submitted once.
+func (s *service) Unlock(ctx context.Context, userID, code string) error {
+	hash, err := s.db.Get(ctx, unlockKey(userID)).Result()
+	if err == redis.Nil {
+		return auth.ErrInvalidCode("no unlock code was requested")
+	}
+	if err != nil {
+		return fmt.Errorf("cannot lookup unlock code: %w", err)
+	}
+
+	if err = s.db.Del(ctx, unlockKey(userID)).Err(); err != nil {
+		return fmt.Errorf("cannot remove unlock code: %w", err)
+	}
+
+	if err = s.otp.ValidateOTP(ctx, userID, code, hash); err != nil {
+		return err
+	}
+
+	return s.Reset(ctx, userID)
+}
+
+// delay returns the time a User must wait after a number of failed
+// attempts. The delay doubles with each failure past delayAfter.
+func (s *service) delay(count int) time.Duration {
+	shift := count - s.delayAfter
+	if shift >= 32 {
+		return s.maxDelay
+	}
+
+	d := s.baseDelay << uint(shift)
+	if d <= 0 || d > s.maxDelay {
+		return s.maxDelay
+	}
+
+	return d
+}
+
+func attemptsKey(userID string) string {
+	return fmt.Sprintf("%s_failed_attempts", userID)
+}
+
+func delayKey(userID string) string {
+	return fmt.Sprintf("%s_attempt_delay", userID)
+}
+
+func lockKey(userID string) string {
+	return fmt.Sprintf("%s_is_locked", userID)
+}
+
+func unlockKey(userID string) string {
+	return fmt.Sprintf("%s_unlock_code", userID)
+}
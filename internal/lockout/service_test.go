@@ -0,0 +1,177 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/otp"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func newUserID() string {
+	return fmt.Sprintf("user-%v", time.Now().UnixNano())
+}
+
+func TestLockoutSvc_Fail(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	userID := newUserID()
+	svc := NewService(
+		WithDB(db),
+		WithDelayAfter(2),
+		WithMaxAttempts(4),
+		WithBaseDelay(time.Minute),
+		WithMaxDelay(time.Minute*10),
+		WithLockoutDuration(time.Minute*30),
+	)
+
+	tt := []struct {
+		errCode auth.ErrCode
+		delay   time.Duration
+	}{
+		{errCode: auth.ErrCode(""), delay: 0},
+		{errCode: auth.EThrottle, delay: time.Minute},
+		{errCode: auth.EThrottle, delay: time.Minute * 2},
+		{errCode: auth.EAccountLocked, delay: time.Minute * 2},
+	}
+
+	for i, tc := range tt {
+		if err = svc.Fail(ctx, userID); err != nil {
+			t.Fatal("failed to record attempt:", err)
+		}
+
+		err = svc.Check(ctx, userID)
+		if !cmp.Equal(auth.ErrorCode(err), tc.errCode) {
+			t.Errorf("attempt %v: error code does not match %s", i+1, cmp.Diff(
+				auth.ErrorCode(err), tc.errCode,
+			))
+		}
+
+		ttl := db.TTL(ctx, delayKey(userID)).Val()
+		if ttl < 0 {
+			ttl = 0
+		}
+		if ttl > tc.delay || tc.delay-ttl > time.Second {
+			t.Errorf("attempt %v: incorrect delay, want %v got %v", i+1, tc.delay, ttl)
+		}
+	}
+
+	if err = svc.Reset(ctx, userID); err != nil {
+		t.Fatal("failed to reset attempts:", err)
+	}
+
+	if err = svc.Check(ctx, userID); err != nil {
+		t.Error("reset user should not be locked:", err)
+	}
+}
+
+func TestLockoutSvc_Unlock(t *testing.T) {
+	tt := []struct {
+		name       string
+		codeFn     func(code string) string
+		errCode    auth.ErrCode
+		isUnlocked bool
+	}{
+		{
+			name: "Unlocks with valid code",
+			codeFn: func(code string) string {
+				return code
+			},
+			errCode:    auth.ErrCode(""),
+			isUnlocked: true,
+		},
+		{
+			name: "Remains locked with invalid code",
+			codeFn: func(code string) string {
+				return "not-" + code
+			},
+			errCode:    auth.EInvalidCode,
+			isUnlocked: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := test.NewRedisDB()
+			if err != nil {
+				t.Fatal("failed to create test database:", err)
+			}
+			defer db.Close()
+
+			ctx := context.Background()
+			userID := newUserID()
+			svc := NewService(
+				WithDB(db),
+				WithOTP(otp.NewOTP()),
+				WithMaxAttempts(1),
+			)
+
+			if err = svc.Fail(ctx, userID); err != nil {
+				t.Fatal("failed to record attempt:", err)
+			}
+
+			code, err := svc.UnlockCode(ctx, userID, "jane@example.com", auth.Email)
+			if err != nil {
+				t.Fatal("failed to create unlock code:", err)
+			}
+
+			err = svc.Unlock(ctx, userID, tc.codeFn(code))
+			if !cmp.Equal(auth.ErrorCode(err), tc.errCode) {
+				t.Error("error code does not match", cmp.Diff(
+					auth.ErrorCode(err), tc.errCode,
+				))
+			}
+
+			err = svc.Check(ctx, userID)
+			if tc.isUnlocked && err != nil {
+				t.Error("user should be unlocked:", err)
+			}
+			if !tc.isUnlocked && auth.ErrorCode(err) != auth.EAccountLocked {
+				t.Error("user should remain locked:", err)
+			}
+
+			// Unlock codes may only be submitted once.
+			err = svc.Unlock(ctx, userID, code)
+			if auth.ErrorCode(err) != auth.EInvalidCode {
+				t.Error("unlock code should not be reusable:", err)
+			}
+		})
+	}
+}
+
+func TestLockoutSvc_Delay(t *testing.T) {
+	s := &service{
+		delayAfter: 3,
+		baseDelay:  time.Second,
+		maxDelay:   time.Second * 10,
+	}
+
+	tt := []struct {
+		count int
+		delay time.Duration
+	}{
+		{count: 3, delay: time.Second},
+		{count: 4, delay: time.Second * 2},
+		{count: 5, delay: time.Second * 4},
+		{count: 6, delay: time.Second * 8},
+		{count: 7, delay: time.Second * 10},
+		{count: 100, delay: time.Second * 10},
+	}
+
+	for _, tc := range tt {
+		if d := s.delay(tc.count); d != tc.delay {
+			t.Errorf("incorrect delay for %v attempts, want %v got %v",
+				tc.count, tc.delay, d)
+		}
+	}
+}
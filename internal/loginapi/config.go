@@ -70,3 +70,30 @@ func WithPassword(p auth.PasswordService) ConfigOption {
 		s.password = p
 	}
 }
+
+// WithPasswordless configures the service to authenticate Users
+// without a password. Users are identified by their email or phone
+// and authenticated through an OTP code, magic link or device.
+func WithPasswordless(isEnabled bool) ConfigOption {
+	return func(s *service) {
+		s.passwordless = isEnabled
+	}
+}
+
+// WithMagicLinkURL configures the client URL a magic link should
+// direct to. The URL will receive `token` and `code` query parameters
+// to be submitted to the verify-code endpoint. Magic links are only
+// available in passwordless mode.
+func WithMagicLinkURL(url string) ConfigOption {
+	return func(s *service) {
+		s.magicLinkURL = url
+	}
+}
+
+// WithLockout configures the service with a LockoutService to restrict
+// login attempts after repeated failures.
+func WithLockout(l auth.LockoutService) ConfigOption {
+	return func(s *service) {
+		s.lockout = l
+	}
+}
@@ -50,4 +50,22 @@ func SetupHTTPHandler(svc auth.LoginAPI, router *mux.Router, tokenSvc auth.Token
 		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
 		router.HandleFunc("/api/v1/login/verify-code", httpHandler).Methods("Post")
 	}
+	{
+		handler = svc.Unlock
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"LoginAPI.Unlock", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/login/unlock", httpHandler).Methods("Post")
+	}
+	{
+		handler = svc.VerifyUnlock
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"LoginAPI.VerifyUnlock", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/login/unlock/verify", httpHandler).Methods("Post")
+	}
 }
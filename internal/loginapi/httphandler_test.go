@@ -2,6 +2,7 @@ package loginapi
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -32,6 +33,9 @@ func TestLoginAPI_Login(t *testing.T) {
 		userFn         func() (*auth.User, error)
 		tokenCreateFn  func() (*auth.Token, error)
 		tokenSignFn    func() (string, error)
+		lockoutCheckFn func() error
+		failCalls      int
+		rehashCalls    int
 	}{
 		{
 			name:       "Non existent user failure",
@@ -63,6 +67,7 @@ func TestLoginAPI_Login(t *testing.T) {
 			}`),
 			messagingCalls: 0,
 			errMessage:     "Invalid username or password",
+			failCalls:      1,
 			userFn: func() (*auth.User, error) {
 				return &auth.User{Password: validPassword}, nil
 			},
@@ -73,6 +78,29 @@ func TestLoginAPI_Login(t *testing.T) {
 				return "jwt-token", nil
 			},
 		},
+		{
+			name:       "Locked account failure",
+			statusCode: http.StatusLocked,
+			reqBody: []byte(`{
+				"type": "email",
+				"password": "swordfish",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "Account is temporarily locked",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{Password: validPassword}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{Code: "123456"}, nil
+			},
+			tokenSignFn: func() (string, error) {
+				return "jwt-token", nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
 		{
 			name:       "User query failure",
 			statusCode: http.StatusInternalServerError,
@@ -130,6 +158,7 @@ func TestLoginAPI_Login(t *testing.T) {
 			}`),
 			messagingCalls: 0,
 			errMessage:     "An internal error occurred",
+			rehashCalls:    1,
 			userFn: func() (*auth.User, error) {
 				return &auth.User{Password: validPassword}, nil
 			},
@@ -150,6 +179,7 @@ func TestLoginAPI_Login(t *testing.T) {
 			}`),
 			messagingCalls: 1,
 			errMessage:     "",
+			rehashCalls:    1,
 			userFn: func() (*auth.User, error) {
 				return &auth.User{
 					Password: validPassword,
@@ -188,6 +218,9 @@ func TestLoginAPI_Login(t *testing.T) {
 				SignFn:   tc.tokenSignFn,
 			}
 			messagingSvc := &test.MessagingService{}
+			lockoutSvc := &test.LockoutService{
+				CheckFn: tc.lockoutCheckFn,
+			}
 			passwordSvc := password.NewPassword()
 			svc := NewService(
 				WithLogger(&test.Logger{}),
@@ -195,6 +228,198 @@ func TestLoginAPI_Login(t *testing.T) {
 				WithRepoManager(repoMngr),
 				WithMessaging(messagingSvc),
 				WithPassword(passwordSvc),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/login",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if messagingSvc.Calls.Send != tc.messagingCalls {
+				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
+					tc.messagingCalls, messagingSvc.Calls.Send)
+			}
+
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if userRepo.Calls.UpdatePassword != tc.rehashCalls {
+				t.Errorf("incorrect UserRepository.UpdatePassword() call count, want %v got %v",
+					tc.rehashCalls, userRepo.Calls.UpdatePassword)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoginAPI_LoginPasswordless(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		reqBody        []byte
+		messagingCalls int
+		errMessage     string
+		passwordless   bool
+		magicLinkURL   string
+		user           *auth.User
+	}{
+		{
+			name:       "Missing password failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "Invalid username or password",
+			passwordless:   false,
+			user: &auth.User{
+				Password:          "$2a$10$zURdae3ekOWKobmadhWdROZLolGAIWrCEzjSfegV6Y/nsxJ1wqM2y", // nolint
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+		{
+			name:       "Magic link not enabled failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com",
+				"magicLink": true
+			}`),
+			messagingCalls: 0,
+			errMessage:     "Magic link login is not enabled",
+			passwordless:   true,
+			magicLinkURL:   "",
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+		{
+			name:       "Magic link with TOTP failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com",
+				"magicLink": true
+			}`),
+			messagingCalls: 0,
+			errMessage:     "Magic link is not available for this account",
+			passwordless:   true,
+			magicLinkURL:   "https://authenticator.local/login/magic",
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				IsTOTPAllowed:     true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+		{
+			name:       "Successful request with OTP",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 1,
+			errMessage:     "",
+			passwordless:   true,
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+		{
+			name:       "Successful request with magic link",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com",
+				"magicLink": true
+			}`),
+			messagingCalls: 1,
+			errMessage:     "",
+			passwordless:   true,
+			magicLinkURL:   "https://authenticator.local/login/magic",
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{
+						CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+						State:    auth.JWTPreAuthorized,
+						Code:     test.OTPCode,
+					}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			messagingSvc := &test.MessagingService{}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(messagingSvc),
+				WithPassword(password.NewPassword()),
+				WithPasswordless(tc.passwordless),
+				WithMagicLinkURL(tc.magicLinkURL),
+				WithLockout(&test.LockoutService{}),
 			)
 
 			req, err := http.NewRequest(
@@ -326,6 +551,7 @@ func TestLoginAPI_DeviceChallenge(t *testing.T) {
 				WithRepoManager(repoMngr),
 				WithMessaging(messagingSvc),
 				WithWebAuthn(webauthnSvc),
+				WithLockout(&test.LockoutService{}),
 			)
 
 			req, err := http.NewRequest("GET", "/api/v1/login/verify-device", nil)
@@ -364,6 +590,8 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 		name              string
 		statusCode        int
 		messagingCalls    int
+		failCalls         int
+		resetCalls        int
 		errMessage        string
 		reqBody           []byte
 		webauthnFn        func() error
@@ -427,6 +655,7 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 			name:           "Webauthn login failure",
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
+			failCalls:      1,
 			errMessage:     "Failed to login",
 			reqBody:        []byte(""),
 			webauthnFn: func() error {
@@ -452,6 +681,7 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 			name:           "Login history persisted failure",
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
+			resetCalls:     1,
 			errMessage:     "Cannot save login",
 			reqBody:        []byte(""),
 			webauthnFn: func() error {
@@ -477,6 +707,7 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 			name:           "Token signing failure",
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
+			resetCalls:     1,
 			errMessage:     "Cannot sign token",
 			reqBody:        []byte(""),
 			webauthnFn: func() error {
@@ -502,6 +733,7 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 			name:           "Successful request",
 			statusCode:     http.StatusOK,
 			messagingCalls: 0,
+			resetCalls:     1,
 			errMessage:     "",
 			reqBody:        []byte(""),
 			webauthnFn: func() error {
@@ -551,12 +783,14 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 			webauthnSvc := &test.WebAuthnService{
 				FinishLoginFn: tc.webauthnFn,
 			}
+			lockoutSvc := &test.LockoutService{}
 			svc := NewService(
 				WithLogger(&test.Logger{}),
 				WithTokenService(tokenSvc),
 				WithRepoManager(repoMngr),
 				WithMessaging(messagingSvc),
 				WithWebAuthn(webauthnSvc),
+				WithLockout(lockoutSvc),
 			)
 
 			req, err := http.NewRequest(
@@ -586,6 +820,16 @@ func TestLoginAPI_VerifyDevice(t *testing.T) {
 					tc.messagingCalls, messagingSvc.Calls.Send)
 			}
 
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if lockoutSvc.Calls.Reset != tc.resetCalls {
+				t.Errorf("incorrect LockoutService.Reset() call count, want %v got %v",
+					tc.resetCalls, lockoutSvc.Calls.Reset)
+			}
+
 			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
 			if err != nil {
 				t.Error(err)
@@ -606,6 +850,10 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		tokenSignFn       func() (string, error)
 		tokenValidationFn func() (*auth.Token, error)
 		loginHistoryFn    func() error
+		recoveryCodeFn    func() error
+		failCalls         int
+		resetCalls        int
+		consumeCalls      int
 	}{
 		{
 			name:           "Invalid token failure",
@@ -661,6 +909,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		},
 		{
 			name:           "Invalid OTP code failure",
+			failCalls:      1,
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
 			errMessage:     "Incorrect code provided",
@@ -687,6 +936,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		},
 		{
 			name:           "Token creation failure",
+			resetCalls:     1,
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
 			errMessage:     "Cannot create token",
@@ -713,6 +963,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		},
 		{
 			name:           "Persist login history failure",
+			resetCalls:     1,
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
 			errMessage:     "Cannot save history",
@@ -739,6 +990,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		},
 		{
 			name:           "Token signing failure",
+			resetCalls:     1,
 			statusCode:     http.StatusBadRequest,
 			messagingCalls: 0,
 			errMessage:     "Cannot sign token",
@@ -765,6 +1017,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 		},
 		{
 			name:           "Successful request",
+			resetCalls:     1,
 			statusCode:     http.StatusOK,
 			messagingCalls: 0,
 			errMessage:     "",
@@ -789,6 +1042,83 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:           "Recovery code not enabled failure",
+			statusCode:     http.StatusBadRequest,
+			messagingCalls: 0,
+			errMessage:     "Recovery codes are not enabled",
+			reqBody:        []byte(`{"recoveryCode": "abcde-12345"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsEmailOTPAllowed: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{}, nil
+			},
+			tokenSignFn: func() (string, error) {
+				return "jwt-token", nil
+			},
+			tokenValidationFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:           "Invalid recovery code failure",
+			failCalls:      1,
+			consumeCalls:   1,
+			statusCode:     http.StatusBadRequest,
+			messagingCalls: 0,
+			errMessage:     "Incorrect code provided",
+			reqBody:        []byte(`{"recoveryCode": "abcde-12345"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsEmailOTPAllowed: true, IsRecoveryCodeAllowed: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{}, nil
+			},
+			tokenSignFn: func() (string, error) {
+				return "jwt-token", nil
+			},
+			tokenValidationFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+			recoveryCodeFn: func() error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+		},
+		{
+			name:           "Successful recovery code request",
+			resetCalls:     1,
+			consumeCalls:   1,
+			statusCode:     http.StatusOK,
+			messagingCalls: 0,
+			errMessage:     "",
+			reqBody:        []byte(`{"recoveryCode": "abcde-12345"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsEmailOTPAllowed: true, IsRecoveryCodeAllowed: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{}, nil
+			},
+			tokenSignFn: func() (string, error) {
+				return "jwt-token", nil
+			},
+			tokenValidationFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTPreAuthorized,
+					Code:     test.OTPCode,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -800,6 +1130,9 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 			loginHistoryRepo := &test.LoginHistoryRepository{
 				CreateFn: tc.loginHistoryFn,
 			}
+			recoveryCodeRepo := &test.RecoveryCodeRepository{
+				ConsumeFn: tc.recoveryCodeFn,
+			}
 			repoMngr := &test.RepositoryManager{
 				UserFn: func() auth.UserRepository {
 					return userRepo
@@ -807,6 +1140,9 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 				LoginHistoryFn: func() auth.LoginHistoryRepository {
 					return loginHistoryRepo
 				},
+				RecoveryCodeFn: func() auth.RecoveryCodeRepository {
+					return recoveryCodeRepo
+				},
 			}
 			tokenSvc := &test.TokenService{
 				ValidateFn: tc.tokenValidationFn,
@@ -814,6 +1150,7 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 				SignFn:     tc.tokenSignFn,
 			}
 			messagingSvc := &test.MessagingService{}
+			lockoutSvc := &test.LockoutService{}
 			otpSvc := otp.NewOTP()
 			svc := NewService(
 				WithLogger(&test.Logger{}),
@@ -821,6 +1158,185 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 				WithRepoManager(repoMngr),
 				WithMessaging(messagingSvc),
 				WithOTP(otpSvc),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/login/verify-code",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if messagingSvc.Calls.Send != tc.messagingCalls {
+				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
+					tc.messagingCalls, messagingSvc.Calls.Send)
+			}
+
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if lockoutSvc.Calls.Reset != tc.resetCalls {
+				t.Errorf("incorrect LockoutService.Reset() call count, want %v got %v",
+					tc.resetCalls, lockoutSvc.Calls.Reset)
+			}
+
+			if recoveryCodeRepo.Calls.Consume != tc.consumeCalls {
+				t.Errorf("incorrect RecoveryCodeRepository.Consume() call count, want %v got %v",
+					tc.consumeCalls, recoveryCodeRepo.Calls.Consume)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoginAPI_VerifyHOTP(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		reqBody        []byte
+		errMessage     string
+		user           *auth.User
+		validateHOTPFn func(ctx context.Context, u *auth.User, code string) (int64, error)
+		isCounterSaved bool
+		counterCalls   int
+		failCalls      int
+		resetCalls     int
+	}{
+		{
+			name:       "HOTP requested by method",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage: "",
+			user: &auth.User{
+				IsTOTPAllowed: true,
+				IsHOTPAllowed: true,
+				HOTPCounter:   5,
+			},
+			isCounterSaved: true,
+			counterCalls:   1,
+			resetCalls:     1,
+		},
+		{
+			name:           "HOTP used if TOTP is not enabled",
+			statusCode:     http.StatusOK,
+			reqBody:        []byte(`{"code": "123456"}`),
+			errMessage:     "",
+			user:           &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			isCounterSaved: true,
+			counterCalls:   1,
+			resetCalls:     1,
+		},
+		{
+			name:           "HOTP not enabled",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage:     "HOTP is not enabled",
+			user:           &auth.User{IsTOTPAllowed: true},
+			isCounterSaved: true,
+			counterCalls:   0,
+		},
+		{
+			name:       "Incorrect HOTP code",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage: "Incorrect code provided",
+			user:       &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			validateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+				return 0, auth.ErrInvalidCode("incorrect code provided")
+			},
+			isCounterSaved: true,
+			counterCalls:   0,
+			failCalls:      1,
+		},
+		{
+			name:       "HOTP token out of sync",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage: "Token is out of sync, submit the next code",
+			user:       &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			validateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+				return 0, auth.ErrTokenResync("token is out of sync, submit the next code")
+			},
+			isCounterSaved: true,
+			counterCalls:   0,
+			failCalls:      0,
+		},
+		{
+			name:           "HOTP counter advanced concurrently",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage:     "Code is no longer valid",
+			user:           &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			isCounterSaved: false,
+			counterCalls:   1,
+			failCalls:      1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+				UpdateHOTPCounterFn: func(userID string, current, counter int64) (bool, error) {
+					if current != 5 || counter != 6 {
+						t.Errorf("incorrect counter update, want 5 to 6 got %v to %v", current, counter)
+					}
+					return tc.isCounterSaved, nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+				LoginHistoryFn: func() auth.LoginHistoryRepository {
+					return &test.LoginHistoryRepository{}
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTPreAuthorized}, nil
+				},
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			lockoutSvc := &test.LockoutService{}
+			otpSvc := &test.OTPService{ValidateHOTPFn: tc.validateHOTPFn}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(&test.MessagingService{}),
+				WithOTP(otpSvc),
+				WithLockout(lockoutSvc),
 			)
 
 			req, err := http.NewRequest(
@@ -845,11 +1361,269 @@ func TestLoginAPI_VerifyCode(t *testing.T) {
 				t.Error(rr.Body.String())
 			}
 
+			if otpSvc.Calls.ValidateTOTP != 0 {
+				t.Error("TOTP should not be validated")
+			}
+
+			if userRepo.Calls.UpdateHOTPCounter != tc.counterCalls {
+				t.Errorf("incorrect UserRepository.UpdateHOTPCounter() call count, want %v got %v",
+					tc.counterCalls, userRepo.Calls.UpdateHOTPCounter)
+			}
+
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if lockoutSvc.Calls.Reset != tc.resetCalls {
+				t.Errorf("incorrect LockoutService.Reset() call count, want %v got %v",
+					tc.resetCalls, lockoutSvc.Calls.Reset)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoginAPI_Unlock(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		reqBody         []byte
+		errMessage      string
+		messagingCalls  int
+		unlockCodeCalls int
+		userFn          func() (*auth.User, error)
+		lockoutCheckFn  func() error
+	}{
+		{
+			name:       "Invalid request failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"identity": "jane@example.com"}`),
+			errMessage: "Identity type must be email or phone",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true}, nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+		{
+			name:       "Non existent user is ignored",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com"}`),
+			userFn: func() (*auth.User, error) {
+				return nil, sql.ErrNoRows
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+		{
+			name:       "Unlocked user is ignored",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true}, nil
+			},
+			lockoutCheckFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:       "Unverified user is ignored",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: false}, nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+		{
+			name:            "Successful request",
+			statusCode:      http.StatusOK,
+			reqBody:         []byte(`{"type": "email", "identity": "jane@example.com"}`),
+			messagingCalls:  1,
+			unlockCodeCalls: 1,
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true}, nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: tc.userFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{}
+			messagingSvc := &test.MessagingService{}
+			lockoutSvc := &test.LockoutService{
+				CheckFn: tc.lockoutCheckFn,
+				UnlockCodeFn: func() (string, error) {
+					return test.OTPCode, nil
+				},
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(messagingSvc),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/login/unlock",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
 			if messagingSvc.Calls.Send != tc.messagingCalls {
 				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
 					tc.messagingCalls, messagingSvc.Calls.Send)
 			}
 
+			if lockoutSvc.Calls.UnlockCode != tc.unlockCodeCalls {
+				t.Errorf("incorrect LockoutService.UnlockCode() call count, want %v got %v",
+					tc.unlockCodeCalls, lockoutSvc.Calls.UnlockCode)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoginAPI_VerifyUnlock(t *testing.T) {
+	tt := []struct {
+		name       string
+		statusCode int
+		reqBody    []byte
+		errMessage string
+		userFn     func() (*auth.User, error)
+		unlockFn   func() error
+	}{
+		{
+			name:       "Missing code failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com"}`),
+			errMessage: "Code is required",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{}, nil
+			},
+			unlockFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:       "Non existent user failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com", "code": "123456"}`),
+			errMessage: "No unlock code was requested",
+			userFn: func() (*auth.User, error) {
+				return nil, sql.ErrNoRows
+			},
+			unlockFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:       "Invalid code failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com", "code": "123456"}`),
+			errMessage: "Incorrect code provided",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{}, nil
+			},
+			unlockFn: func() error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"type": "email", "identity": "jane@example.com", "code": "123456"}`),
+			userFn: func() (*auth.User, error) {
+				return &auth.User{}, nil
+			},
+			unlockFn: func() error {
+				return nil
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: tc.userFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{}
+			lockoutSvc := &test.LockoutService{
+				UnlockFn: tc.unlockFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/login/unlock/verify",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
 			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
 			if err != nil {
 				t.Error(err)
@@ -13,14 +13,65 @@ type loginRequest struct {
 	Password string              `json:"password"`
 	Identity string              `json:"identity"`
 	Type     auth.DeliveryMethod `json:"type"`
+	// MagicLink requests an email containing a one-click login
+	// link instead of an OTP code.
+	MagicLink bool `json:"magicLink"`
+	// Voice requests an OTP code delivered to a phone number
+	// through a voice call instead of SMS.
+	Voice bool `json:"voice"`
 }
 
 type verifyCodeRequest struct {
 	Code string `json:"code"`
+	// Method is the 2FA option the code was generated by. It is
+	// required to submit a HOTP code if the User also has TOTP
+	// enabled.
+	Method auth.TFAOptions `json:"method"`
+	// RecoveryCode is a single use recovery code submitted in
+	// place of an OTP or TOTP code.
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type unlockRequest struct {
+	Identity string              `json:"identity"`
+	Type     auth.DeliveryMethod `json:"type"`
+	// Code is the OTP code delivered to unlock the account. It
+	// is only required to verify the unlock.
+	Code string `json:"code"`
+	// Voice requests the unlock code for a phone number to be
+	// delivered through a voice call instead of SMS.
+	Voice bool `json:"voice"`
 }
 
 func (r *loginRequest) UserAttribute() string {
-	switch r.Type {
+	return userAttribute(r.Type)
+}
+
+func (r *unlockRequest) UserAttribute() string {
+	return userAttribute(r.Type)
+}
+
+// OTPDelivery returns the delivery method of a User's default
+// OTP code.
+func (r *loginRequest) OTPDelivery(user *auth.User) auth.DeliveryMethod {
+	return voiceDelivery(user.DefaultOTPDelivery(), r.Voice)
+}
+
+// Delivery returns the delivery method of an unlock code.
+func (r *unlockRequest) Delivery() auth.DeliveryMethod {
+	return voiceDelivery(r.Type, r.Voice)
+}
+
+// voiceDelivery replaces SMS delivery with a voice call if requested.
+func voiceDelivery(d auth.DeliveryMethod, voice bool) auth.DeliveryMethod {
+	if d == auth.Phone && voice {
+		return auth.Voice
+	}
+	return d
+}
+
+func userAttribute(d auth.DeliveryMethod) string {
+	switch d {
 	case auth.Email:
 		return "Email"
 	case auth.Phone:
@@ -69,6 +120,36 @@ func decodeVerifyCodeRequest(r *http.Request) (*verifyCodeRequest, error) {
 		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
 	}
 
+	req.Code = strings.TrimSpace(req.Code)
+	req.RecoveryCode = strings.TrimSpace(req.RecoveryCode)
+
+	if req.Method != "" && req.Method != auth.TOTP && req.Method != auth.HOTP {
+		return nil, auth.ErrBadRequest("method must be `totp` or `hotp`")
+	}
+
+	return &req, nil
+}
+
+func decodeUnlockRequest(r *http.Request) (*unlockRequest, error) {
+	var (
+		req unlockRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	if req.UserAttribute() == "" {
+		return nil, auth.ErrBadRequest("identity type must be email or phone")
+	}
+
+	req.Identity = strings.TrimSpace(req.Identity)
 	req.Code = strings.TrimSpace(req.Code)
 
 	return &req, nil
@@ -2,10 +2,13 @@ package loginapi
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"reflect"
 	"testing"
+
+	auth "github.com/fmitra/authenticator"
 )
 
 func TestLoginAPI_LoginRequestAttribute(t *testing.T) {
@@ -59,6 +62,54 @@ func TestLoginAPI_LoginRequestAttribute(t *testing.T) {
 	}
 }
 
+func TestLoginAPI_OTPDelivery(t *testing.T) {
+	tt := []struct {
+		name     string
+		user     *auth.User
+		request  []byte
+		delivery auth.DeliveryMethod
+	}{
+		{
+			name:     "Delivers to phone by SMS",
+			user:     &auth.User{Phone: sql.NullString{String: "+1555555555", Valid: true}},
+			request:  []byte(`{"identity": "+1555555555", "type": "phone"}`),
+			delivery: auth.Phone,
+		},
+		{
+			name:     "Delivers to phone by voice",
+			user:     &auth.User{Phone: sql.NullString{String: "+1555555555", Valid: true}},
+			request:  []byte(`{"identity": "+1555555555", "type": "phone", "voice": true}`),
+			delivery: auth.Voice,
+		},
+		{
+			name:     "Delivers to email regardless of voice",
+			user:     &auth.User{Email: sql.NullString{String: "jane@example.com", Valid: true}},
+			request:  []byte(`{"identity": "jane@example.com", "type": "email", "voice": true}`),
+			delivery: auth.Email,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var login loginRequest
+			if err := json.Unmarshal(tc.request, &login); err != nil {
+				t.Fatal("failed to unmarshal data:", err)
+			}
+			if d := login.OTPDelivery(tc.user); d != tc.delivery {
+				t.Errorf("incorrect login delivery method, want %s got %s", tc.delivery, d)
+			}
+
+			var unlock unlockRequest
+			if err := json.Unmarshal(tc.request, &unlock); err != nil {
+				t.Fatal("failed to unmarshal data:", err)
+			}
+			if d := unlock.Delivery(); d != tc.delivery {
+				t.Errorf("incorrect unlock delivery method, want %s got %s", tc.delivery, d)
+			}
+		})
+	}
+}
+
 func TestLoginAPI_LoginRequestDecode(t *testing.T) {
 	tt := []struct {
 		name     string
@@ -139,6 +190,22 @@ func TestLoginAPI_VerifyCodeRequest(t *testing.T) {
 			}`),
 			hasError: false,
 		},
+		{
+			name: "Valid HOTP request",
+			request: []byte(`{
+				"code": "123456",
+				"method": "hotp"
+			}`),
+			hasError: false,
+		},
+		{
+			name: "Unsupported method error",
+			request: []byte(`{
+				"code": "123456",
+				"method": "otp_email"
+			}`),
+			hasError: true,
+		},
 	}
 
 	for _, tc := range tt {
@@ -0,0 +1,6 @@
+package loginapi
+
+// Response is a success response.
+type Response struct {
+	Result string `json:"result"`
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 
 	auth "github.com/fmitra/authenticator"
 	"github.com/fmitra/authenticator/internal/httpapi"
@@ -23,9 +24,16 @@ type service struct {
 	password auth.PasswordService
 	webauthn auth.WebAuthnService
 	message  auth.MessagingService
+	lockout  auth.LockoutService
+	// passwordless enables login without a password.
+	passwordless bool
+	// magicLinkURL is the client URL a magic link directs to.
+	magicLinkURL string
 }
 
-// Login is the initial login step to identify a User.
+// Login is the initial login step to identify a User. In passwordless
+// mode, a User is identified without a password and authenticated in the
+// next step through an OTP code, magic link or device.
 func (s *service) Login(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	ctx := r.Context()
 
@@ -42,8 +50,22 @@ func (s *service) Login(w http.ResponseWriter, r *http.Request) (interface{}, er
 		return nil, err
 	}
 
-	if err = s.password.Validate(user, req.Password); err != nil {
-		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid username or password"))
+	if err = s.lockout.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	if !s.passwordless {
+		if err = s.password.Validate(user, req.Password); err != nil {
+			if err := s.lockout.Fail(ctx, user.ID); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid username or password"))
+		}
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	if req.MagicLink {
+		return s.loginWithMagicLink(ctx, w, user)
 	}
 
 	var jwtToken *auth.Token
@@ -53,7 +75,8 @@ func (s *service) Login(w http.ResponseWriter, r *http.Request) (interface{}, er
 			ctx,
 			user,
 			auth.JWTPreAuthorized,
-			token.WithOTPDeliveryMethod(user.DefaultOTPDelivery()),
+			token.WithOTPDeliveryMethod(req.OTPDelivery(user)),
+			token.WithOTPMessageType(auth.OTPLogin),
 		)
 	} else {
 		jwtToken, err = s.token.Create(ctx, user, auth.JWTPreAuthorized)
@@ -63,7 +86,37 @@ func (s *service) Login(w http.ResponseWriter, r *http.Request) (interface{}, er
 		return nil, err
 	}
 
-	return s.respond(ctx, w, user, jwtToken)
+	return s.respond(ctx, w, user, jwtToken, auth.OTPLogin)
+}
+
+// rehashPassword replaces a User's password hash if it was created with
+// an outdated algorithm or cost. The User is already authenticated, so a
+// failure is logged rather than returned and the hash is upgraded on a
+// later login.
+func (s *service) rehashPassword(ctx context.Context, user *auth.User, password string) {
+	if !s.password.NeedsRehash(user.Password) {
+		return
+	}
+
+	var isUpdated bool
+	passwordHash, err := s.password.Hash(password)
+	if err == nil {
+		isUpdated, err = s.repoMngr.User().UpdatePassword(ctx, user.ID, user.Password, string(passwordHash))
+	}
+	if err != nil {
+		level.Error(s.logger).Log(
+			"source", "loginapi.Login",
+			"message", "failed to rehash password",
+			"user_id", user.ID,
+			"error", err,
+		)
+		return
+	}
+
+	// The password may have been changed since the User was retrieved.
+	if isUpdated {
+		user.Password = string(passwordHash)
+	}
 }
 
 // DeviceChallenge requests a challenge to be signed by the client.
@@ -90,11 +143,24 @@ func (s *service) VerifyDevice(w http.ResponseWriter, r *http.Request) (interfac
 		return nil, err
 	}
 
+	if err = s.lockout.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
 	err = s.webauthn.FinishLogin(ctx, user, r)
+	if auth.ErrorCode(err) == auth.EWebAuthn {
+		if err := s.lockout.Fail(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.lockout.Reset(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
 	jwtToken, err := s.token.Create(ctx, user, auth.JWTAuthorized)
 	if err != nil {
 		return nil, err
@@ -104,16 +170,20 @@ func (s *service) VerifyDevice(w http.ResponseWriter, r *http.Request) (interfac
 		UserID:    userID,
 		TokenID:   jwtToken.Id,
 		ExpiresAt: s.token.RefreshableTill(ctx, jwtToken, jwtToken.RefreshToken),
+		IPAddress: httpapi.GetIP(r),
+		UserAgent: r.UserAgent(),
+		TFAMethod: auth.FIDODevice,
 	}
 	if err = s.repoMngr.LoginHistory().Create(ctx, loginHistory); err != nil {
 		return nil, err
 	}
 
-	return s.respond(ctx, w, user, jwtToken)
+	return s.respond(ctx, w, user, jwtToken, auth.OTPLogin)
 }
 
 // VerifyCode verifies a User's authenticity through a validating TOTP or
-// randomly generated code.
+// randomly generated code. A single use recovery code may be submitted
+// instead if the User has generated recovery codes.
 func (s *service) VerifyCode(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	ctx := r.Context()
 	userID := httpapi.GetUserID(r)
@@ -129,16 +199,43 @@ func (s *service) VerifyCode(w http.ResponseWriter, r *http.Request) (interface{
 		return nil, err
 	}
 
-	if token.CodeHash != "" {
-		err = s.otp.ValidateOTP(req.Code, token.CodeHash)
-	} else {
+	if err = s.lockout.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	var tfaMethod auth.TFAOptions
+
+	switch {
+	case req.RecoveryCode != "":
+		err = s.consumeRecoveryCode(ctx, user, req.RecoveryCode)
+		tfaMethod = auth.RecoveryCode
+	case req.Method == auth.HOTP:
+		err = otp.VerifyHOTP(ctx, s.otp, s.repoMngr.User(), user, req.Code)
+		tfaMethod = auth.HOTP
+	case token.CodeHash != "":
+		err = s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash)
+		tfaMethod = otp.TFAOption(token.CodeHash)
+	case req.Method == "" && user.IsHOTPAllowed && !user.IsTOTPAllowed:
+		err = otp.VerifyHOTP(ctx, s.otp, s.repoMngr.User(), user, req.Code)
+		tfaMethod = auth.HOTP
+	default:
 		err = s.otp.ValidateTOTP(ctx, user, req.Code)
+		tfaMethod = auth.TOTP
 	}
 
+	if auth.ErrorCode(err) == auth.EInvalidCode {
+		if err := s.lockout.Fail(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err = s.lockout.Reset(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
 	jwtToken, err := s.token.Create(ctx, user, auth.JWTAuthorized)
 	if err != nil {
 		return nil, err
@@ -148,16 +245,129 @@ func (s *service) VerifyCode(w http.ResponseWriter, r *http.Request) (interface{
 		UserID:    userID,
 		TokenID:   jwtToken.Id,
 		ExpiresAt: s.token.RefreshableTill(ctx, jwtToken, jwtToken.RefreshToken),
+		IPAddress: httpapi.GetIP(r),
+		UserAgent: r.UserAgent(),
+		TFAMethod: tfaMethod,
 	}
 	if err = s.repoMngr.LoginHistory().Create(ctx, loginHistory); err != nil {
 		return nil, err
 	}
 
-	return s.respond(ctx, w, user, jwtToken)
+	return s.respond(ctx, w, user, jwtToken, auth.OTPLogin)
+}
+
+// Unlock delivers an OTP code to unlock an account locked after repeated
+// failed login attempts. The code is only delivered to the verified address
+// the User identified with. A success response is returned regardless of
+// whether the account exists or is locked.
+func (s *service) Unlock(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+
+	req, err := decodeUnlockRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, req.UserAttribute(), req.Identity)
+	if err == sql.ErrNoRows {
+		return &Response{Result: "success"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.lockout.Check(ctx, user.ID)
+	if auth.ErrorCode(err) == auth.EInternal {
+		return nil, err
+	}
+	if auth.ErrorCode(err) != auth.EAccountLocked || !user.IsVerified {
+		return &Response{Result: "success"}, nil
+	}
+
+	code, err := s.lockout.UnlockCode(ctx, user.ID, req.Identity, req.Delivery())
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &auth.Message{
+		Type:     auth.OTPUnlock,
+		Delivery: req.Delivery(),
+		Vars:     map[string]string{"code": code},
+		Address:  req.Identity,
+		Locale:   user.Locale,
+	}
+	if err = s.message.Send(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return &Response{Result: "success"}, nil
+}
+
+// VerifyUnlock unlocks an account with an OTP code delivered through Unlock.
+func (s *service) VerifyUnlock(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+
+	req, err := decodeUnlockRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code == "" {
+		return nil, auth.ErrBadRequest("code is required")
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, req.UserAttribute(), req.Identity)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrInvalidCode("no unlock code was requested"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.lockout.Unlock(ctx, user.ID, req.Code); err != nil {
+		return nil, err
+	}
+
+	return &Response{Result: "success"}, nil
+}
+
+// consumeRecoveryCode validates a recovery code and marks it as used.
+func (s *service) consumeRecoveryCode(ctx context.Context, user *auth.User, code string) error {
+	if !user.IsRecoveryCodeAllowed {
+		return auth.ErrBadRequest("recovery codes are not enabled")
+	}
+
+	return s.repoMngr.RecoveryCode().Consume(ctx, user.ID, code)
+}
+
+// loginWithMagicLink delivers a one-click login link to a User's email.
+// A magic link is not delivered if the User has configured a stronger
+// 2FA option (TOTP or WebAuthn).
+func (s *service) loginWithMagicLink(ctx context.Context, w http.ResponseWriter, user *auth.User) (*token.Response, error) {
+	if !s.passwordless || s.magicLinkURL == "" {
+		return nil, auth.ErrBadRequest("magic link login is not enabled")
+	}
+
+	if !user.CanSendDefaultOTP() || !user.IsEmailOTPAllowed {
+		return nil, auth.ErrBadRequest("magic link is not available for this account")
+	}
+
+	jwtToken, err := s.token.Create(
+		ctx,
+		user,
+		auth.JWTPreAuthorized,
+		token.WithOTPDeliveryMethod(auth.Email),
+		token.WithOTPMessageType(auth.MagicLink),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.respond(ctx, w, user, jwtToken, auth.MagicLink)
 }
 
 // respond creates a JWT token response.
-func (s *service) respond(ctx context.Context, w http.ResponseWriter, _ *auth.User, jwtToken *auth.Token) (*token.Response, error) {
+func (s *service) respond(ctx context.Context, w http.ResponseWriter, user *auth.User, jwtToken *auth.Token, msgType auth.MessageType) (*token.Response, error) {
 	tokenStr, err := s.token.Sign(ctx, jwtToken)
 	if err != nil {
 		return nil, err
@@ -174,10 +384,18 @@ func (s *service) respond(ctx context.Context, w http.ResponseWriter, _ *auth.Us
 		}
 
 		msg := &auth.Message{
-			Type:     auth.OTPLogin,
+			Type:     msgType,
 			Delivery: h.DeliveryMethod,
 			Vars:     map[string]string{"code": jwtToken.Code},
 			Address:  h.Address,
+			Locale:   user.Locale,
+		}
+		if msgType == auth.MagicLink {
+			link, err := token.MagicLink(s.magicLinkURL, tokenStr, jwtToken.Code)
+			if err != nil {
+				return nil, err
+			}
+			msg.Vars["link"] = link
 		}
 		if err = s.message.Send(ctx, msg); err != nil {
 			return nil, err
@@ -1,46 +1,130 @@
 package mail
 
 import (
+	"crypto/tls"
+	"net"
 	"net/smtp"
+	"time"
 
 	auth "github.com/fmitra/authenticator"
 )
 
+// TLSMode describes how a connection to the mail server is secured.
+type TLSMode string
+
+const (
+	// Opportunistic upgrades a plain text connection to TLS if the
+	// server supports the STARTTLS extension, and continues in plain
+	// text otherwise.
+	Opportunistic TLSMode = "opportunistic"
+	// STARTTLS upgrades a plain text connection to TLS. Delivery fails
+	// if the server does not support the STARTTLS extension.
+	STARTTLS TLSMode = "starttls"
+	// ImplicitTLS connects to the mail server over TLS, typically on
+	// port 465.
+	ImplicitTLS TLSMode = "tls"
+	// NoTLS connects to the mail server in plain text. It should only
+	// be used with a local relay.
+	NoTLS TLSMode = "none"
+)
+
+const (
+	// defaultTimeout is the default time to complete a delivery
+	// if the context has no deadline.
+	defaultTimeout = time.Second * 30
+	// defaultIdleTimeout is the default time an unused connection
+	// is kept open for reuse.
+	defaultIdleTimeout = time.Second * 30
+	// defaultMaxIdle is the default number of unused connections
+	// kept open for reuse.
+	defaultMaxIdle = 2
+)
+
 // NewService returns a new mailing service.
-func NewService(configuration ConfigOption) auth.Emailer {
-	s := service{}
-	configuration(&s)
-	return &s
-}
+func NewService(options ...ConfigOption) auth.Emailer {
+	s := service{
+		tlsMode:      Opportunistic,
+		timeout:      defaultTimeout,
+		idleTimeout:  defaultIdleTimeout,
+		maxIdleConns: defaultMaxIdle,
+		dialer:       &net.Dialer{},
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	if s.maxIdleConns < 0 {
+		s.maxIdleConns = 0
+	}
+	s.idle = make(chan *conn, s.maxIdleConns)
 
-// Config holds configuration options for the service.
-type Config struct {
-	serverAddr string
-	fromAddr   string
-	auth       smtp.Auth
-	mailFn     func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	return &s
 }
 
 // ConfigOption configures the service.
 type ConfigOption func(*service)
 
-// WithConfig configures the service with a Config.
-func WithConfig(config Config) ConfigOption {
-	return func(s *service) {
-		s.serverAddr = config.serverAddr
-		s.fromAddr = config.fromAddr
-		s.auth = config.auth
-		s.mailFn = config.mailFn
-	}
-}
-
-// WithDefaults configures the service with a default
-// mailer (net/smtp)
+// WithDefaults configures the service with a mail server, an
+// origin email address and authentication credentials.
 func WithDefaults(serverAddr, fromAddr string, auth smtp.Auth) ConfigOption {
 	return func(s *service) {
 		s.serverAddr = serverAddr
 		s.fromAddr = fromAddr
 		s.auth = auth
-		s.mailFn = smtp.SendMail
+	}
+}
+
+// WithFromName sets a display name for the origin email address.
+func WithFromName(name string) ConfigOption {
+	return func(s *service) {
+		s.fromName = name
+	}
+}
+
+// WithReplyTo sets an email address for recipients to reply to.
+func WithReplyTo(addr string) ConfigOption {
+	return func(s *service) {
+		s.replyTo = addr
+	}
+}
+
+// WithTLSMode sets how a connection to the mail server is secured.
+func WithTLSMode(mode TLSMode) ConfigOption {
+	return func(s *service) {
+		s.tlsMode = mode
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to the
+// mail server. By default the server certificate is verified against
+// the host of the server address.
+func WithTLSConfig(config *tls.Config) ConfigOption {
+	return func(s *service) {
+		s.tlsConfig = config
+	}
+}
+
+// WithTimeout sets the time to complete a delivery if the context
+// has no deadline.
+func WithTimeout(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.timeout = d
+	}
+}
+
+// WithIdleTimeout sets the time an unused connection is kept
+// open for reuse.
+func WithIdleTimeout(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.idleTimeout = d
+	}
+}
+
+// WithMaxIdle sets the number of unused connections kept open
+// for reuse. Connections are closed after each delivery if set to 0.
+func WithMaxIdle(n int) ConfigOption {
+	return func(s *service) {
+		s.maxIdleConns = n
 	}
 }
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netMail "net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	linkRe      = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	blockRe     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table)>`)
+	tagRe       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLineRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// newMessage creates an RFC 5322 message with multipart/alternative
+// plain text and HTML content.
+func (s *service) newMessage(to, subject, htmlContent string) ([]byte, error) {
+	from := netMail.Address{Name: s.fromName, Address: s.fromAddr}
+	if _, err := netMail.ParseAddress(from.String()); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+
+	if _, err := netMail.ParseAddress(to); err != nil {
+		return nil, fmt.Errorf("invalid to address: %w", err)
+	}
+
+	msgID, err := messageID(s.fromAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buf  bytes.Buffer
+		body bytes.Buffer
+	)
+
+	mw := multipart.NewWriter(&body)
+	if err = writePart(mw, "text/plain", PlainText(htmlContent)); err != nil {
+		return nil, err
+	}
+	if err = writePart(mw, "text/html", htmlContent); err != nil {
+		return nil, err
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", msgID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())},
+	}
+	if s.replyTo != "" {
+		replyTo, err := netMail.ParseAddress(s.replyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply-to address: %w", err)
+		}
+		headers = append(headers, [2]string{"Reply-To", replyTo.String()})
+	}
+
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], h[1])
+	}
+	buf.WriteString("\r\n")
+
+	if _, err = io.Copy(&buf, &body); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writePart adds quoted-printable encoded content to a multipart message.
+func writePart(mw *multipart.Writer, contentType, content string) error {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentType+"; charset=UTF-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	qw := quotedprintable.NewWriter(w)
+	if _, err = qw.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return qw.Close()
+}
+
+// messageID creates a unique Message-ID in the domain of an
+// email address.
+func messageID(addr string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate message ID: %w", err)
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(addr, "@"); i >= 0 && i < len(addr)-1 {
+		domain = addr[i+1:]
+	}
+
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain), nil
+}
+
+// PlainText converts HTML message content to a plain text alternative.
+// Links are kept alongside their text as clients may not render them.
+func PlainText(htmlContent string) string {
+	text := linkRe.ReplaceAllString(htmlContent, "$2 ($1)")
+	text = blockRe.ReplaceAllString(text, "\n")
+	text = tagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	text = blankLineRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,40 @@
+package mail
+
+import "testing"
+
+func TestMail_PlainText(t *testing.T) {
+	tt := []struct {
+		name string
+		html string
+		text string
+	}{
+		{
+			name: "Strips tags",
+			html: "<span>Code: <strong>111</strong></span>\n<p>Enter the code above to login</p>\n",
+			text: "Code: 111\nEnter the code above to login",
+		},
+		{
+			name: "Keeps links",
+			html: `<p><a href="https://example.com/?a=1&amp;b=2">Sign in</a></p>`,
+			text: "Sign in (https://example.com/?a=1&b=2)",
+		},
+		{
+			name: "Breaks block elements",
+			html: "<div>Line one<br/>Line two</div><p>\n\n\n   Line   three</p>",
+			text: "Line one\nLine two\n\nLine three",
+		},
+		{
+			name: "Unescapes entities",
+			html: "<span>Here&#39;s your new code</span>",
+			text: "Here's your new code",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if text := PlainText(tc.html); text != tc.text {
+				t.Errorf("incorrect plain text, want %q got %q", tc.text, text)
+			}
+		})
+	}
+}
@@ -1,26 +1,212 @@
+// Package mail delivers email through an SMTP server.
 package mail
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/smtp"
+	"time"
 )
 
+// service is an implementation of auth.Emailer. Connections to the
+// mail server are kept open after a delivery to be reused by
+// subsequent deliveries.
 type service struct {
-	serverAddr string
-	fromAddr   string
-	auth       smtp.Auth
-	mailFn     func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	serverAddr   string
+	fromAddr     string
+	fromName     string
+	replyTo      string
+	auth         smtp.Auth
+	tlsMode      TLSMode
+	tlsConfig    *tls.Config
+	timeout      time.Duration
+	idleTimeout  time.Duration
+	maxIdleConns int
+	dialer       *net.Dialer
+	idle         chan *conn
 }
 
-// Email delivers an email to an email address.
+// conn is a connection to the mail server.
+type conn struct {
+	netConn net.Conn
+	client  *smtp.Client
+	usedAt  time.Time
+}
+
+// Email delivers an email to an email address. The message is sent
+// as HTML alongside a plain text alternative.
 func (s *service) Email(ctx context.Context, email, subject, message string) error {
-	mimeHeaders := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";"
-	content := []byte(
-		fmt.Sprintf("To: %s\r\n", email) +
-			fmt.Sprintf("Subject: %s\r\n", subject) +
-			fmt.Sprintf("%s\n\n", mimeHeaders) +
-			message,
-	)
-	return s.mailFn(s.serverAddr, s.auth, s.fromAddr, []string{email}, content)
+	msg, err := s.newMessage(email, subject, message)
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.timeout)
+	}
+
+	c, err := s.conn(ctx, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot connect to mail server: %w", err)
+	}
+
+	if err = s.send(c, deadline, email, msg); err != nil {
+		c.client.Close()
+		return fmt.Errorf("cannot deliver email: %w", err)
+	}
+
+	s.release(c)
+	return nil
+}
+
+// send delivers a message over an open connection.
+func (s *service) send(c *conn, deadline time.Time, to string, msg []byte) error {
+	if err := c.netConn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	if err := c.client.Mail(s.fromAddr); err != nil {
+		return err
+	}
+
+	if err := c.client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// conn returns an idle connection or opens a new connection if
+// none are available. Idle connections are reset before reuse
+// as the server may have closed them.
+func (s *service) conn(ctx context.Context, deadline time.Time) (*conn, error) {
+	for {
+		select {
+		case c := <-s.idle:
+			if time.Since(c.usedAt) > s.idleTimeout {
+				c.client.Close()
+				continue
+			}
+
+			if err := c.netConn.SetDeadline(deadline); err != nil {
+				c.client.Close()
+				continue
+			}
+
+			if err := c.client.Reset(); err != nil {
+				c.client.Close()
+				continue
+			}
+
+			return c, nil
+		default:
+			return s.dial(ctx, deadline)
+		}
+	}
+}
+
+// release returns a connection to be reused or closes it if the
+// maximum number of idle connections is reached.
+func (s *service) release(c *conn) {
+	c.usedAt = time.Now()
+	select {
+	case s.idle <- c:
+	default:
+		c.client.Close()
+	}
+}
+
+// dial opens a new connection to the mail server, securing it
+// according to the configured TLSMode before authenticating.
+func (s *service) dial(ctx context.Context, deadline time.Time) (*conn, error) {
+	switch s.tlsMode {
+	case Opportunistic, STARTTLS, ImplicitTLS, NoTLS:
+	default:
+		return nil, fmt.Errorf("unsupported TLS mode %s", s.tlsMode)
+	}
+
+	host, _, err := net.SplitHostPort(s.serverAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address: %w", err)
+	}
+
+	tlsConfig := s.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+
+	netConn, err := s.dialer.DialContext(ctx, "tcp", s.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = netConn.SetDeadline(deadline); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	if s.tlsMode == ImplicitTLS {
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err = tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		netConn = tlsConn
+	}
+
+	client, err := smtp.NewClient(netConn, host)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	if err = s.hello(client, tlsConfig); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &conn{netConn: netConn, client: client}, nil
+}
+
+// hello upgrades a connection with STARTTLS if required or
+// supported and authenticates with the mail server.
+func (s *service) hello(client *smtp.Client, tlsConfig *tls.Config) error {
+	isSupported, _ := client.Extension("STARTTLS")
+	if s.tlsMode == STARTTLS && !isSupported {
+		return fmt.Errorf("mail server does not support STARTTLS")
+	}
+
+	isUpgraded := s.tlsMode == STARTTLS || (s.tlsMode == Opportunistic && isSupported)
+	if isUpgraded {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if s.auth == nil {
+		return nil
+	}
+
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return fmt.Errorf("mail server does not support authentication")
+	}
+
+	if err := client.Auth(s.auth); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return nil
 }
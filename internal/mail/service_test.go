@@ -2,21 +2,526 @@ package mail
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	netMail "net/mail"
 	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestMail_SendsEmail(t *testing.T) {
-	mailSvc := NewService(WithConfig(Config{
-		serverAddr: "localhost:8000",
-		fromAddr:   "test@test.com",
-		auth:       smtp.PlainAuth("identity", "username", "password", "host"),
-		mailFn: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
-			return nil
-		},
-	}))
+	srv := newSMTPServer(t, smtpServerConfig{})
+	defer srv.Close()
+
+	mailSvc := NewService(
+		WithDefaults(
+			srv.Addr(),
+			"noreply@example.com",
+			smtp.PlainAuth("", "username", "password", "127.0.0.1"),
+		),
+		WithFromName("Authenticator"),
+		WithReplyTo("support@example.com"),
+		WithTLSMode(NoTLS),
+	)
+
 	ctx := context.Background()
-	if err := mailSvc.Email(ctx, "jane@example.com", "Hello", "hello world"); err != nil {
-		t.Error("expected nil error, received:", err)
+	err := mailSvc.Email(
+		ctx,
+		"jane@example.com",
+		"Your sign in link",
+		`<p><a href="https://example.com/login?a=1&amp;b=2">Click here</a></p><p>Code: <strong>111</strong></p>`,
+	)
+	if err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+
+	received := srv.Messages()
+	if len(received) != 1 {
+		t.Fatalf("incorrect number of emails received, want 1 got %v", len(received))
 	}
+	if received[0].from != "noreply@example.com" {
+		t.Errorf("incorrect envelope sender: %s", received[0].from)
+	}
+	if received[0].to != "jane@example.com" {
+		t.Errorf("incorrect envelope recipient: %s", received[0].to)
+	}
+	if received[0].auth != "username:password" {
+		t.Errorf("incorrect credentials: %s", received[0].auth)
+	}
+
+	msg, err := netMail.ReadMessage(strings.NewReader(received[0].data))
+	if err != nil {
+		t.Fatal("failed to parse email:", err)
+	}
+
+	headers := map[string]string{
+		"From":         `"Authenticator" <noreply@example.com>`,
+		"To":           "jane@example.com",
+		"Reply-To":     "<support@example.com>",
+		"Subject":      "Your sign in link",
+		"MIME-Version": "1.0",
+	}
+	for k, v := range headers {
+		if msg.Header.Get(k) != v {
+			t.Errorf("incorrect %s header, want %q got %q", k, v, msg.Header.Get(k))
+		}
+	}
+	if _, err = msg.Header.Date(); err != nil {
+		t.Error("invalid Date header:", err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("invalid Message-ID header: %s", msg.Header.Get("Message-ID"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("incorrect content type: %s", msg.Header.Get("Content-Type"))
+	}
+
+	parts := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal("failed to read part:", err)
+		}
+		parts[p.Header.Get("Content-Type")] = string(b)
+	}
+
+	text := parts["text/plain; charset=UTF-8"]
+	wantText := "Click here (https://example.com/login?a=1&b=2)\nCode: 111"
+	if text != wantText {
+		t.Errorf("incorrect plain text part, want %q got %q", wantText, text)
+	}
+	if !strings.Contains(parts["text/html; charset=UTF-8"], "<strong>111</strong>") {
+		t.Errorf("incorrect html part: %q", parts["text/html; charset=UTF-8"])
+	}
+}
+
+func TestMail_TLSModes(t *testing.T) {
+	cert, pool := newCertificate(t)
+
+	tt := []struct {
+		name      string
+		mode      TLSMode
+		serverCfg smtpServerConfig
+		isFailed  bool
+	}{
+		{
+			name: "STARTTLS",
+			mode: STARTTLS,
+			serverCfg: smtpServerConfig{
+				startTLS: &tls.Config{Certificates: []tls.Certificate{cert}},
+			},
+			isFailed: false,
+		},
+		{
+			name:      "STARTTLS not supported failure",
+			mode:      STARTTLS,
+			serverCfg: smtpServerConfig{},
+			isFailed:  true,
+		},
+		{
+			name: "Opportunistic TLS",
+			mode: Opportunistic,
+			serverCfg: smtpServerConfig{
+				startTLS: &tls.Config{Certificates: []tls.Certificate{cert}},
+			},
+			isFailed: false,
+		},
+		{
+			name:      "Opportunistic TLS falls back to plain text",
+			mode:      Opportunistic,
+			serverCfg: smtpServerConfig{},
+			isFailed:  false,
+		},
+		{
+			name: "Implicit TLS",
+			mode: ImplicitTLS,
+			serverCfg: smtpServerConfig{
+				implicitTLS: &tls.Config{Certificates: []tls.Certificate{cert}},
+			},
+			isFailed: false,
+		},
+		{
+			name:      "Implicit TLS not supported failure",
+			mode:      ImplicitTLS,
+			serverCfg: smtpServerConfig{},
+			isFailed:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newSMTPServer(t, tc.serverCfg)
+			defer srv.Close()
+
+			mailSvc := NewService(
+				WithDefaults(srv.Addr(), "noreply@example.com", nil),
+				WithTLSMode(tc.mode),
+				WithTLSConfig(&tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}),
+				WithTimeout(time.Second),
+			)
+
+			err := mailSvc.Email(context.Background(), "jane@example.com", "Hello", "<p>hello</p>")
+			if err != nil && !tc.isFailed {
+				t.Error("expected nil error, received:", err)
+			}
+			if err == nil && tc.isFailed {
+				t.Error("expected error, received nil")
+			}
+
+			want := 1
+			if tc.isFailed {
+				want = 0
+			}
+			if got := len(srv.Messages()); got != want {
+				t.Errorf("incorrect number of emails received, want %v got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestMail_ReusesConnection(t *testing.T) {
+	srv := newSMTPServer(t, smtpServerConfig{})
+	defer srv.Close()
+
+	mailSvc := NewService(
+		WithDefaults(srv.Addr(), "noreply@example.com", nil),
+		WithTLSMode(NoTLS),
+	)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := mailSvc.Email(ctx, "jane@example.com", "Hello", "<p>hello</p>"); err != nil {
+			t.Fatal("expected nil error, received:", err)
+		}
+	}
+	if srv.Connections() != 1 {
+		t.Errorf("connection should be reused, want 1 connection got %v", srv.Connections())
+	}
+
+	// A connection closed by the server is replaced.
+	srv.CloseConnections()
+	if err := mailSvc.Email(ctx, "jane@example.com", "Hello", "<p>hello</p>"); err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+	if srv.Connections() != 2 {
+		t.Errorf("closed connection should be replaced, want 2 connections got %v", srv.Connections())
+	}
+	if len(srv.Messages()) != 4 {
+		t.Errorf("incorrect number of emails received, want 4 got %v", len(srv.Messages()))
+	}
+}
+
+func TestMail_ClosesIdleConnection(t *testing.T) {
+	srv := newSMTPServer(t, smtpServerConfig{})
+	defer srv.Close()
+
+	mailSvc := NewService(
+		WithDefaults(srv.Addr(), "noreply@example.com", nil),
+		WithTLSMode(NoTLS),
+		WithIdleTimeout(time.Millisecond),
+	)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := mailSvc.Email(ctx, "jane@example.com", "Hello", "<p>hello</p>"); err != nil {
+			t.Fatal("expected nil error, received:", err)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	if srv.Connections() != 2 {
+		t.Errorf("idle connection should not be reused, want 2 connections got %v", srv.Connections())
+	}
+}
+
+func TestMail_InvalidAddress(t *testing.T) {
+	tt := []struct {
+		name     string
+		fromAddr string
+		replyTo  string
+		to       string
+	}{
+		{
+			name:     "Invalid from address",
+			fromAddr: "authenticator.local",
+			to:       "jane@example.com",
+		},
+		{
+			name:     "Invalid reply-to address",
+			fromAddr: "noreply@example.com",
+			replyTo:  "support",
+			to:       "jane@example.com",
+		},
+		{
+			name:     "Invalid to address",
+			fromAddr: "noreply@example.com",
+			to:       "jane@example.com\r\nBcc: john@example.com",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newSMTPServer(t, smtpServerConfig{})
+			defer srv.Close()
+
+			mailSvc := NewService(
+				WithDefaults(srv.Addr(), tc.fromAddr, nil),
+				WithReplyTo(tc.replyTo),
+				WithTLSMode(NoTLS),
+			)
+			err := mailSvc.Email(context.Background(), tc.to, "Hello", "<p>hello</p>")
+			if err == nil {
+				t.Error("expected error, received nil")
+			}
+			if srv.Connections() != 0 {
+				t.Error("invalid email should not be delivered")
+			}
+		})
+	}
+}
+
+// smtpServerConfig configures an smtpServer.
+type smtpServerConfig struct {
+	// startTLS enables the STARTTLS extension.
+	startTLS *tls.Config
+	// implicitTLS accepts TLS connections only.
+	implicitTLS *tls.Config
+}
+
+// receivedEmail is an email accepted by an smtpServer.
+type receivedEmail struct {
+	from string
+	to   string
+	auth string
+	data string
+}
+
+// smtpServer is an in-process SMTP server supporting the subset of
+// the protocol used by net/smtp.
+type smtpServer struct {
+	t        *testing.T
+	config   smtpServerConfig
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+	accepted int
+	messages []receivedEmail
+}
+
+func newSMTPServer(t *testing.T, config smtpServerConfig) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("failed to start SMTP server:", err)
+	}
+	if config.implicitTLS != nil {
+		l = tls.NewListener(l, config.implicitTLS)
+	}
+
+	s := &smtpServer{t: t, config: config, listener: l}
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) Close() {
+	s.listener.Close()
+	s.CloseConnections()
+}
+
+// CloseConnections closes all open client connections.
+func (s *smtpServer) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// Connections returns the total number of connections accepted.
+func (s *smtpServer) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+func (s *smtpServer) Messages() []receivedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedEmail(nil), s.messages...)
+}
+
+func (s *smtpServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, c)
+		s.accepted++
+		s.mu.Unlock()
+
+		go s.handle(c)
+	}
+}
+
+func (s *smtpServer) handle(c net.Conn) {
+	defer c.Close()
+
+	tp := textproto.NewConn(c)
+	var email receivedEmail
+	reply := func(format string, args ...interface{}) bool {
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	if !reply("220 localhost ESMTP") {
+		return
+	}
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		arg := strings.TrimSpace(strings.TrimPrefix(line, strings.SplitN(line, " ", 2)[0]))
+
+		switch cmd {
+		case "EHLO", "HELO":
+			_, isTLS := c.(*tls.Conn)
+			ext := []string{"250-localhost", "250-8BITMIME"}
+			if s.config.startTLS != nil && !isTLS {
+				ext = append(ext, "250-STARTTLS")
+			}
+			ext = append(ext, "250 AUTH PLAIN")
+			if !reply(strings.Join(ext, "\r\n")) {
+				return
+			}
+		case "STARTTLS":
+			if s.config.startTLS == nil {
+				reply("502 not supported")
+				continue
+			}
+			if !reply("220 ready") {
+				return
+			}
+			tlsConn := tls.Server(c, s.config.startTLS)
+			if err = tlsConn.Handshake(); err != nil {
+				return
+			}
+			c = tlsConn
+			tp = textproto.NewConn(c)
+		case "AUTH":
+			fields := strings.Fields(arg)
+			if len(fields) != 2 {
+				reply("501 invalid auth")
+				continue
+			}
+			b, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				reply("501 invalid auth")
+				continue
+			}
+			creds := strings.Split(string(b), "\x00")
+			email.auth = strings.Join(creds[1:], ":")
+			reply("235 authenticated")
+		case "MAIL":
+			email.from = pathArg(arg)
+			reply("250 ok")
+		case "RCPT":
+			email.to = pathArg(arg)
+			reply("250 ok")
+		case "DATA":
+			if !reply("354 send data") {
+				return
+			}
+			b, err := ioutil.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			email.data = string(b)
+			s.mu.Lock()
+			s.messages = append(s.messages, email)
+			s.mu.Unlock()
+			email = receivedEmail{auth: email.auth}
+			reply("250 accepted")
+		case "RSET", "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
+
+// pathArg returns the address of a MAIL or RCPT command argument
+// such as FROM:<jane@example.com> BODY=8BITMIME.
+func pathArg(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// newCertificate creates a self-signed certificate for 127.0.0.1
+// and a pool to verify it.
+func newCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate key:", err)
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("failed to create certificate:", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("failed to parse certificate:", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
 }
@@ -0,0 +1,57 @@
+package mailgun
+
+import (
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultBaseURL is the default Mailgun API endpoint. Domains in the
+// EU region must use https://api.eu.mailgun.net.
+const defaultBaseURL = "https://api.mailgun.net"
+
+// Config holds configuration options for Mailgun.
+type Config struct {
+	baseURL  string
+	apiKey   string
+	domain   string
+	fromAddr string
+	fromName string
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*client)
+
+// NewClient returns a Mailgun client.
+func NewClient(configuration ConfigOption) auth.Emailer {
+	c := client{}
+	configuration(&c)
+	return &c
+}
+
+// WithConfig configures the service with a Config.
+func WithConfig(config Config) ConfigOption {
+	return func(c *client) {
+		c.baseURL = strings.TrimSuffix(config.baseURL, "/")
+		c.apiKey = config.apiKey
+		c.domain = config.domain
+		c.fromAddr = config.fromAddr
+		c.fromName = config.fromName
+	}
+}
+
+// WithDefaults configures a Mailgun client with a user's API key,
+// sending domain and origin address. An empty baseURL uses the
+// default API endpoint.
+func WithDefaults(baseURL, apiKey, domain, fromAddr, fromName string) ConfigOption {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return WithConfig(Config{
+		baseURL:  baseURL,
+		apiKey:   apiKey,
+		domain:   domain,
+		fromAddr: fromAddr,
+		fromName: fromName,
+	})
+}
@@ -0,0 +1,61 @@
+// Package mailgun exposes Mailgun's REST API.
+package mailgun
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	netMail "net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/fmitra/authenticator/internal/mail"
+)
+
+// client is a consumer of the Mailgun API.
+type client struct {
+	baseURL  string
+	apiKey   string
+	domain   string
+	fromAddr string
+	fromName string
+}
+
+// Email delivers an email to an email address. The message is sent
+// as HTML alongside a plain text alternative.
+func (c *client) Email(ctx context.Context, email, subject, message string) error {
+	from := netMail.Address{Name: c.fromName, Address: c.fromAddr}
+	form := url.Values{
+		"from":    {from.String()},
+		"to":      {email},
+		"subject": {subject},
+		"html":    {message},
+		"text":    {mail.PlainText(message)},
+	}
+
+	u := fmt.Sprintf("%s/v3/%s/messages", c.baseURL, url.PathEscape(c.domain))
+	req, err := http.NewRequestWithContext(ctx, "POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+
+	req.SetBasicAuth("api", c.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rBody, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusOK, resp.StatusCode, string(rBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package mailgun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMailgun_Email(t *testing.T) {
+	tt := []struct {
+		name         string
+		responseCode int
+		hasError     bool
+	}{
+		{
+			name:         "Success 200",
+			responseCode: http.StatusOK,
+			hasError:     false,
+		},
+		{
+			name:         "Invalid 401",
+			responseCode: http.StatusUnauthorized,
+			hasError:     true,
+		},
+		{
+			name:         "Invalid 500",
+			responseCode: http.StatusInternalServerError,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/v3/mg.example.com/messages" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+
+				user, password, ok := r.BasicAuth()
+				if !ok || user != "api" || password != "api-key" {
+					t.Errorf("incorrect credentials: %s %s", user, password)
+				}
+
+				fields := map[string]string{
+					"from":    `"Authenticator" <noreply@example.com>`,
+					"to":      "jane@example.com",
+					"subject": "Hello",
+					"html":    "<p>hello <strong>world</strong></p>",
+					"text":    "hello world",
+				}
+				for k, v := range fields {
+					if r.FormValue(k) != v {
+						t.Errorf("incorrect %s field, want %q got %q", k, v, r.FormValue(k))
+					}
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.responseCode)
+				w.Write([]byte(`{"id": "<message-id@mg.example.com>", "message": "Queued. Thank you."}`))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			c := NewClient(WithConfig(Config{
+				baseURL:  srv.URL,
+				apiKey:   "api-key",
+				domain:   "mg.example.com",
+				fromAddr: "noreply@example.com",
+				fromName: "Authenticator",
+			}))
+
+			err := c.Email(ctx, "jane@example.com", "Hello", "<p>hello <strong>world</strong></p>")
+			if err != nil && !tc.hasError {
+				t.Error("expected nil error", err)
+			}
+			if err == nil && tc.hasError {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package messageapi
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultReplayExpiry is the default time a replayed message may be
+// retried before it expires.
+const defaultReplayExpiry = time.Minute * 10
+
+// NewService returns a new implementation of auth.MessageAPI.
+func NewService(options ...ConfigOption) auth.MessageAPI {
+	s := service{
+		logger:       log.NewNopLogger(),
+		replayExpiry: defaultReplayExpiry,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithRepoManager configures the service with a new RepositoryManager.
+func WithRepoManager(repoMngr auth.RepositoryManager) ConfigOption {
+	return func(s *service) {
+		s.repoMngr = repoMngr
+	}
+}
+
+// WithMessageRepo configures the service with a MessageRepository
+// to publish replayed messages.
+func WithMessageRepo(r auth.MessageRepository) ConfigOption {
+	return func(s *service) {
+		s.messageRepo = r
+	}
+}
+
+// WithReplayExpiry sets the time a replayed message may be
+// retried before it expires.
+func WithReplayExpiry(t time.Duration) ConfigOption {
+	return func(s *service) {
+		s.replayExpiry = t
+	}
+}
@@ -0,0 +1,44 @@
+package messageapi
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+)
+
+// SetupHTTPHandler converts a service's public methods
+// to http handlers. Handlers require an administrator API key.
+func SetupHTTPHandler(svc auth.MessageAPI, router *mux.Router, apiKey string, logger log.Logger, lmt httpapi.LimiterFactory) {
+	var handler httpapi.JSONAPIHandler
+	{
+		handler = httpapi.AdminMiddleware(svc.Status, apiKey)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"MessageAPI.Status", httpapi.PerMinute, int64(60),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/admin/messages/{messageID}", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AdminMiddleware(svc.DeadLetters, apiKey)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"MessageAPI.DeadLetters", httpapi.PerMinute, int64(60),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/admin/dead-letters", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AdminMiddleware(svc.Replay, apiKey)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"MessageAPI.Replay", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/admin/dead-letters/{messageID}/replay", httpHandler).Methods("Post")
+	}
+}
@@ -0,0 +1,269 @@
+package messageapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+const apiKey = "swordfish"
+
+func TestMessageAPI_Status(t *testing.T) {
+	tt := []struct {
+		name          string
+		authorization string
+		byIDFn        func() (*auth.MessageStatus, error)
+		statusCode    int
+	}{
+		{
+			name:          "Retrieves message status",
+			authorization: "Bearer " + apiKey,
+			byIDFn: func() (*auth.MessageStatus, error) {
+				return &auth.MessageStatus{
+					MessageID: "message-id",
+					Status:    auth.MessageSent,
+				}, nil
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:          "Rejects invalid admin key",
+			authorization: "Bearer catfish",
+			byIDFn: func() (*auth.MessageStatus, error) {
+				return &auth.MessageStatus{}, nil
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "Fails on unknown message",
+			authorization: "Bearer " + apiKey,
+			byIDFn: func() (*auth.MessageStatus, error) {
+				return nil, sql.ErrNoRows
+			},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			statusRepo := &test.MessageStatusRepository{
+				ByIDFn: tc.byIDFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return statusRepo
+				},
+			}
+			svc := NewService(WithRepoManager(repoMngr))
+
+			req, err := http.NewRequest("GET", "/api/v1/admin/messages/message-id", nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+			req.Header.Set("AUTHORIZATION", tc.authorization)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, apiKey, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Error("status code does not match", cmp.Diff(rr.Code, tc.statusCode))
+			}
+		})
+	}
+}
+
+func TestMessageAPI_DeadLetters(t *testing.T) {
+	router := mux.NewRouter()
+	createdAt := time.Now().Round(0).UTC()
+	deadLetterRepo := &test.DeadLetterRepository{
+		RecentFn: func() ([]*auth.DeadLetter, error) {
+			return []*auth.DeadLetter{
+				{
+					Message: &auth.Message{
+						ID:               "message-id",
+						Type:             auth.OTPLogin,
+						Delivery:         auth.Phone,
+						Address:          "+15555555555",
+						Content:          "Your code is 123456",
+						DeliveryAttempts: 3,
+					},
+					LastError: "provider unavailable",
+					CreatedAt: createdAt,
+				},
+			}, nil
+		},
+	}
+	repoMngr := &test.RepositoryManager{
+		DeadLetterFn: func() auth.DeadLetterRepository {
+			return deadLetterRepo
+		},
+	}
+	svc := NewService(WithRepoManager(repoMngr))
+
+	req, err := http.NewRequest("GET", "/api/v1/admin/dead-letters?limit=10", nil)
+	if err != nil {
+		t.Fatal("failed to create request:", err)
+	}
+	req.Header.Set("AUTHORIZATION", "Bearer "+apiKey)
+
+	logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+	SetupHTTPHandler(svc, router, apiKey, logger, &httpapi.MockLimiterFactory{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("status code does not match", cmp.Diff(rr.Code, http.StatusOK))
+	}
+
+	var resp listResponse
+	if err = json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal("failed to decode response:", err)
+	}
+
+	want := []deadLetterResponse{
+		{
+			MessageID:        "message-id",
+			Type:             auth.OTPLogin,
+			Delivery:         auth.Phone,
+			Address:          "+15555555555",
+			DeliveryAttempts: 3,
+			LastError:        "provider unavailable",
+			CreatedAt:        createdAt,
+		},
+	}
+	if !cmp.Equal(resp.DeadLetters, want) {
+		t.Error("response does not match", cmp.Diff(resp.DeadLetters, want))
+	}
+}
+
+func TestMessageAPI_Replay(t *testing.T) {
+	deadLetter := func() (interface{}, error) {
+		return &auth.DeadLetter{
+			Message: &auth.Message{
+				ID:               "message-id",
+				ReceiptID:        "receipt-id",
+				Delivery:         auth.Email,
+				DeliveryAttempts: 3,
+				LastError:        "provider unavailable",
+				ExpiresAt:        time.Now().Add(-time.Minute),
+			},
+			LastError: "provider unavailable",
+		}, nil
+	}
+
+	tt := []struct {
+		name         string
+		withAtomicFn func() (interface{}, error)
+		publishErr   error
+		statusCode   int
+		publishCount int
+		createCount  int
+	}{
+		{
+			name:         "Replays dead letter",
+			withAtomicFn: deadLetter,
+			statusCode:   http.StatusOK,
+			publishCount: 1,
+			createCount:  0,
+		},
+		{
+			name: "Fails on unknown dead letter",
+			withAtomicFn: func() (interface{}, error) {
+				return nil, sql.ErrNoRows
+			},
+			statusCode:   http.StatusBadRequest,
+			publishCount: 0,
+			createCount:  0,
+		},
+		{
+			name:         "Restores dead letter on publish failure",
+			withAtomicFn: deadLetter,
+			publishErr:   fmt.Errorf("whoops"),
+			statusCode:   http.StatusInternalServerError,
+			publishCount: 1,
+			createCount:  1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			deadLetterRepo := &test.DeadLetterRepository{
+				CreateFn: func(deadLetter *auth.DeadLetter) error {
+					if deadLetter.Message.LastError != "provider unavailable" {
+						t.Error("restored message should not be reset")
+					}
+					return nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				WithAtomicFn: tc.withAtomicFn,
+				DeadLetterFn: func() auth.DeadLetterRepository {
+					return deadLetterRepo
+				},
+			}
+			messageRepo := &test.MessageRepository{
+				PublishFn: func(ctx context.Context, msg *auth.Message) error {
+					if msg.DeliveryAttempts != 0 || msg.LastError != "" || msg.ReceiptID != "" {
+						t.Errorf("replayed message was not reset: %+v", msg)
+					}
+					if !msg.ExpiresAt.After(time.Now()) {
+						t.Error("replayed message should not be expired")
+					}
+					return tc.publishErr
+				},
+			}
+			svc := NewService(
+				WithRepoManager(repoMngr),
+				WithMessageRepo(messageRepo),
+			)
+
+			req, err := http.NewRequest("POST", "/api/v1/admin/dead-letters/message-id/replay", nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+			req.Header.Set("AUTHORIZATION", "Bearer "+apiKey)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, apiKey, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Error("status code does not match", cmp.Diff(rr.Code, tc.statusCode))
+			}
+			if repoMngr.Calls.WithAtomic != 1 {
+				t.Errorf("incorrect calls to RepositoryManager.WithAtomic, want 1 got %v",
+					repoMngr.Calls.WithAtomic)
+			}
+			if messageRepo.Calls.Publish != tc.publishCount {
+				t.Errorf("incorrect calls to MessageRepository.Publish, want %v got %v",
+					tc.publishCount, messageRepo.Calls.Publish)
+			}
+			if deadLetterRepo.Calls.Create != tc.createCount {
+				t.Errorf("incorrect calls to DeadLetterRepository.Create, want %v got %v",
+					tc.createCount, deadLetterRepo.Calls.Create)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package messageapi
+
+import (
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+type statusResponse struct {
+	MessageID        string              `json:"messageID"`
+	Type             auth.MessageType    `json:"type"`
+	Delivery         auth.DeliveryMethod `json:"delivery"`
+	Status           auth.DeliveryStatus `json:"status"`
+	DeliveryAttempts int                 `json:"deliveryAttempts"`
+	LastError        string              `json:"lastError"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+}
+
+// Create creates a response from a MessageStatus.
+func (r *statusResponse) Create(status *auth.MessageStatus) {
+	r.MessageID = status.MessageID
+	r.Type = status.Type
+	r.Delivery = status.Delivery
+	r.Status = status.Status
+	r.DeliveryAttempts = status.DeliveryAttempts
+	r.LastError = status.LastError
+	r.CreatedAt = status.CreatedAt
+	r.UpdatedAt = status.UpdatedAt
+}
+
+// deadLetterResponse describes an undelivered message. Message
+// content is omitted as it may contain OTP codes.
+type deadLetterResponse struct {
+	MessageID        string              `json:"messageID"`
+	Type             auth.MessageType    `json:"type"`
+	Delivery         auth.DeliveryMethod `json:"delivery"`
+	Address          string              `json:"address"`
+	DeliveryAttempts int                 `json:"deliveryAttempts"`
+	LastError        string              `json:"lastError"`
+	ExpiresAt        time.Time           `json:"expiresAt"`
+	CreatedAt        time.Time           `json:"createdAt"`
+}
+
+type listResponse struct {
+	DeadLetters []deadLetterResponse `json:"deadLetters"`
+}
+
+// Create creates a list response from DeadLetters.
+func (r *listResponse) Create(deadLetters []*auth.DeadLetter) {
+	r.DeadLetters = make([]deadLetterResponse, 0, len(deadLetters))
+	for _, dl := range deadLetters {
+		r.DeadLetters = append(r.DeadLetters, deadLetterResponse{
+			MessageID:        dl.Message.ID,
+			Type:             dl.Message.Type,
+			Delivery:         dl.Message.Delivery,
+			Address:          dl.Message.Address,
+			DeliveryAttempts: dl.Message.DeliveryAttempts,
+			LastError:        dl.LastError,
+			ExpiresAt:        dl.Message.ExpiresAt,
+			CreatedAt:        dl.CreatedAt,
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package messageapi provides an HTTP API for administrators to
+// inspect and replay outgoing messages.
+package messageapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+type service struct {
+	logger       log.Logger
+	repoMngr     auth.RepositoryManager
+	messageRepo  auth.MessageRepository
+	replayExpiry time.Duration
+}
+
+// Status retrieves the delivery status of a message.
+func (s *service) Status(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	messageID := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/messages/")
+
+	status, err := s.repoMngr.MessageStatus().ByID(ctx, messageID)
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrNotFound("message does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp statusResponse
+	resp.Create(status)
+
+	return &resp, nil
+}
+
+// DeadLetters retrieves messages which failed to be delivered,
+// ordered from most to least recent.
+func (s *service) DeadLetters(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	req, err := decodeListRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	deadLetters, err := s.repoMngr.DeadLetter().Recent(ctx, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp listResponse
+	resp.Create(deadLetters)
+
+	return &resp, nil
+}
+
+// Replay publishes a failed message for delivery again. The message
+// is given a new expiry and its delivery attempts are reset. It is
+// removed from the dead-letter store before it is published, so that
+// concurrent replays publish it once, and restored if publishing fails.
+func (s *service) Replay(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	messageID := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/dead-letters/")
+	messageID = strings.TrimSuffix(messageID, "/replay")
+
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start txn: %w", err)
+	}
+
+	entity, err := client.WithAtomic(func() (interface{}, error) {
+		deadLetter, err := client.DeadLetter().GetForUpdate(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = client.DeadLetter().Remove(ctx, messageID); err != nil {
+			return nil, err
+		}
+
+		return deadLetter, nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrNotFound("dead letter does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetter := entity.(*auth.DeadLetter)
+	msg := *deadLetter.Message
+	msg.ReceiptID = ""
+	msg.LastError = ""
+	msg.DeliveryAttempts = 0
+	msg.ExpiresAt = time.Now().Add(s.replayExpiry)
+
+	status := &auth.MessageStatus{
+		MessageID: msg.ID,
+		Type:      msg.Type,
+		Delivery:  msg.Delivery,
+		Status:    auth.MessageQueued,
+	}
+	if err = s.repoMngr.MessageStatus().Save(ctx, status); err != nil {
+		return nil, s.restore(ctx, deadLetter, err)
+	}
+
+	if err = s.messageRepo.Publish(ctx, &msg); err != nil {
+		err = fmt.Errorf("failed to publish to repository: %w", err)
+		return nil, s.restore(ctx, deadLetter, err)
+	}
+
+	var resp statusResponse
+	resp.Create(status)
+
+	return &resp, nil
+}
+
+// restore returns a dead letter to the dead-letter store after it
+// failed to be replayed.
+func (s *service) restore(ctx context.Context, deadLetter *auth.DeadLetter, err error) error {
+	if restoreErr := s.repoMngr.DeadLetter().Create(ctx, deadLetter); restoreErr != nil {
+		return fmt.Errorf("%v: %w", restoreErr, err)
+	}
+	return err
+}
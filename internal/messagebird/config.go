@@ -0,0 +1,46 @@
+package messagebird
+
+import (
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultBaseURL is the default MessageBird REST API endpoint.
+const defaultBaseURL = "https://rest.messagebird.com"
+
+// Config holds configuration options for MessageBird.
+type Config struct {
+	baseURL    string
+	accessKey  string
+	originator string
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*client)
+
+// NewClient returns a MessageBird client.
+func NewClient(configuration ConfigOption) auth.SMSer {
+	c := client{}
+	configuration(&c)
+	return &c
+}
+
+// WithConfig configures the service with a Config.
+func WithConfig(config Config) ConfigOption {
+	return func(c *client) {
+		c.baseURL = strings.TrimSuffix(config.baseURL, "/")
+		c.accessKey = config.accessKey
+		c.originator = config.originator
+	}
+}
+
+// WithDefaults configures a MessageBird client with a user's access key
+// and originator and configures all other values to default.
+func WithDefaults(accessKey, originator string) ConfigOption {
+	return WithConfig(Config{
+		baseURL:    defaultBaseURL,
+		accessKey:  accessKey,
+		originator: originator,
+	})
+}
@@ -0,0 +1,63 @@
+// Package messagebird exposes the MessageBird SMS API.
+package messagebird
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// client is a consumer of the MessageBird SMS API.
+type client struct {
+	baseURL    string
+	accessKey  string
+	originator string
+}
+
+type messageRequest struct {
+	Originator string   `json:"originator"`
+	Recipients []string `json:"recipients"`
+	Body       string   `json:"body"`
+}
+
+// SMS sends an SMS message to a phone number.
+func (c *client) SMS(ctx context.Context, phoneNumber string, message string) error {
+	body, err := json.Marshal(messageRequest{
+		Originator: c.originator,
+		// Recipients are expected in E.164 format without a leading +.
+		Recipients: []string{strings.TrimPrefix(phoneNumber, "+")},
+		Body:       message,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode request: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/messages", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AccessKey %s", c.accessKey))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		rBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusCreated, resp.StatusCode, string(rBody))
+	}
+
+	return nil
+}
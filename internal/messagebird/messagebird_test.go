@@ -0,0 +1,80 @@
+package messagebird
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessageBird_SMS(t *testing.T) {
+	tt := []struct {
+		name         string
+		responseCode int
+		hasError     bool
+	}{
+		{
+			name:         "Success 201",
+			responseCode: http.StatusCreated,
+			hasError:     false,
+		},
+		{
+			name:         "Invalid 422",
+			responseCode: http.StatusUnprocessableEntity,
+			hasError:     true,
+		},
+		{
+			name:         "Invalid 500",
+			responseCode: http.StatusInternalServerError,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/messages" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+				if auth := r.Header.Get("Authorization"); auth != "AccessKey access-key" {
+					t.Errorf("incorrect authorization header: %s", auth)
+				}
+
+				var req messageRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Error("cannot decode request:", err)
+				}
+				expected := messageRequest{
+					Originator: "Authenticator",
+					Recipients: []string{"17777777777"},
+					Body:       "Your login code is 111",
+				}
+				if !cmp.Equal(req, expected) {
+					t.Error("incorrect request body", cmp.Diff(req, expected))
+				}
+
+				w.WriteHeader(tc.responseCode)
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			c := NewClient(WithConfig(Config{
+				baseURL:    srv.URL,
+				accessKey:  "access-key",
+				originator: "Authenticator",
+			}))
+
+			err := c.SMS(ctx, "+17777777777", "Your login code is 111")
+			if err != nil && !tc.hasError {
+				t.Error("expected nil error", err)
+			}
+			if err == nil && tc.hasError {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"database/sql"
+
+	"github.com/go-kit/kit/log"
+)
+
+// NewMigrator returns a new Migrator configured with the
+// authenticator's migrations.
+func NewMigrator(options ...ConfigOption) *Migrator {
+	m := Migrator{
+		logger:     log.NewNopLogger(),
+		migrations: migrations,
+	}
+
+	for _, opt := range options {
+		opt(&m)
+	}
+
+	return &m
+}
+
+// ConfigOption configures the Migrator.
+type ConfigOption func(*Migrator)
+
+// WithLogger configures the Migrator with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(m *Migrator) {
+		m.logger = l
+	}
+}
+
+// WithDB configures the Migrator with a Postgres DB.
+func WithDB(db *sql.DB) ConfigOption {
+	return func(m *Migrator) {
+		m.db = db
+	}
+}
+
+// WithMigrations replaces the default migrations applied
+// by the Migrator.
+func WithMigrations(migrations []Migration) ConfigOption {
+	return func(m *Migrator) {
+		m.migrations = migrations
+	}
+}
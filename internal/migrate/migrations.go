@@ -0,0 +1,166 @@
+package migrate
+
+// migrations are the versioned schema changes for the authenticator
+// database, applied in order of version. A migration must never be
+// edited after release. Schema changes require a new migration.
+//
+// The initial migration is idempotent so it may be safely applied to
+// databases created from the schema before migrations were introduced.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: `
+			CREATE TABLE IF NOT EXISTS auth_user (
+				id VARCHAR(26) PRIMARY KEY,
+				phone VARCHAR(20) UNIQUE NULL,
+				email VARCHAR(255) UNIQUE NULL,
+				password VARCHAR(60) NULL,
+				tfa_secret VARCHAR(70) NOT NULL,
+				is_sms_otp_allowed BOOLEAN DEFAULT false,
+				is_email_otp_allowed BOOLEAN DEFAULT false,
+				is_totp_allowed BOOLEAN DEFAULT false,
+				is_device_allowed BOOLEAN DEFAULT false,
+				is_verified BOOLEAN DEFAULT false,
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp,
+				updated_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE TABLE IF NOT EXISTS device (
+				id VARCHAR(26) PRIMARY KEY,
+				user_id VARCHAR(26) REFERENCES auth_user(id) NOT NULL,
+				client_id BYTEA NOT NULL,
+				public_key BYTEA NOT NULL,
+				aaguid BYTEA NOT NULL,
+				sign_count INT DEFAULT 0,
+				name VARCHAR(30) NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp,
+				updated_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE TABLE IF NOT EXISTS login_history (
+				token_id VARCHAR(26) PRIMARY KEY,
+				user_id VARCHAR(26) REFERENCES auth_user(id) NOT NULL,
+				is_revoked BOOLEAN DEFAULT false,
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp,
+				updated_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			ALTER TABLE auth_user ALTER COLUMN password DROP NOT NULL;
+			ALTER TABLE login_history ADD COLUMN IF NOT EXISTS ip_address VARCHAR(64) NOT NULL DEFAULT '';
+			ALTER TABLE login_history ADD COLUMN IF NOT EXISTS user_agent VARCHAR(255) NOT NULL DEFAULT '';
+			ALTER TABLE login_history ADD COLUMN IF NOT EXISTS tfa_method VARCHAR(20) NOT NULL DEFAULT '';
+		`,
+		Down: `
+			DROP TABLE IF EXISTS login_history;
+			DROP TABLE IF EXISTS device;
+			DROP TABLE IF EXISTS auth_user;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "recovery_codes",
+		Up: `
+			ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS is_recovery_code_allowed BOOLEAN DEFAULT false;
+			CREATE TABLE IF NOT EXISTS recovery_code (
+				id VARCHAR(26) PRIMARY KEY,
+				user_id VARCHAR(26) REFERENCES auth_user(id) NOT NULL,
+				code_hash VARCHAR(128) NOT NULL,
+				is_used BOOLEAN DEFAULT false,
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp,
+				updated_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE INDEX IF NOT EXISTS recovery_code_user_id_idx ON recovery_code (user_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS recovery_code;
+			ALTER TABLE auth_user DROP COLUMN IF EXISTS is_recovery_code_allowed;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "message_outbox",
+		Up: `
+			CREATE TABLE IF NOT EXISTS message_outbox (
+				id VARCHAR(26) PRIMARY KEY,
+				payload TEXT NOT NULL,
+				available_at TIMESTAMP WITH TIME ZONE NOT NULL,
+				locked_until TIMESTAMP WITH TIME ZONE,
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE INDEX IF NOT EXISTS message_outbox_available_at_idx ON message_outbox (available_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS message_outbox;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "message_delivery",
+		Up: `
+			CREATE TABLE IF NOT EXISTS message_status (
+				message_id VARCHAR(26) PRIMARY KEY,
+				type VARCHAR(20) NOT NULL,
+				delivery VARCHAR(10) NOT NULL,
+				status VARCHAR(10) NOT NULL,
+				delivery_attempts INT NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp,
+				updated_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE TABLE IF NOT EXISTS message_dead_letter (
+				message_id VARCHAR(26) PRIMARY KEY,
+				payload TEXT NOT NULL,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+			);
+			CREATE INDEX IF NOT EXISTS message_dead_letter_created_at_idx ON message_dead_letter (created_at);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS message_dead_letter;
+			DROP TABLE IF EXISTS message_status;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "user_locale",
+		Up: `
+			ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT '';
+		`,
+		Down: `
+			ALTER TABLE auth_user DROP COLUMN IF EXISTS locale;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "tfa_secret_length",
+		Up: `
+			ALTER TABLE auth_user ALTER COLUMN tfa_secret TYPE VARCHAR(255);
+		`,
+		Down: `
+			ALTER TABLE auth_user ALTER COLUMN tfa_secret TYPE VARCHAR(70);
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "hotp_tokens",
+		Up: `
+			ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS hotp_secret VARCHAR(255) NOT NULL DEFAULT '';
+			ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS hotp_counter BIGINT NOT NULL DEFAULT 0;
+			ALTER TABLE auth_user ADD COLUMN IF NOT EXISTS is_hotp_allowed BOOLEAN DEFAULT false;
+		`,
+		Down: `
+			ALTER TABLE auth_user DROP COLUMN IF EXISTS is_hotp_allowed;
+			ALTER TABLE auth_user DROP COLUMN IF EXISTS hotp_counter;
+			ALTER TABLE auth_user DROP COLUMN IF EXISTS hotp_secret;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "password_hash_length",
+		Up: `
+			ALTER TABLE auth_user ALTER COLUMN password TYPE VARCHAR(255);
+		`,
+		Down: `
+			ALTER TABLE auth_user ALTER COLUMN password TYPE VARCHAR(60);
+		`,
+	},
+}
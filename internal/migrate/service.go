@@ -0,0 +1,300 @@
+// Package migrate applies versioned database migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	// pg driver registers itself as being available to the database/sql package.
+	_ "github.com/lib/pq"
+)
+
+// Migration is a versioned change to the database schema.
+type Migration struct {
+	// Version is a unique, increasing number identifying the
+	// order in which a Migration is applied.
+	Version int
+	// Name is a short description of the Migration.
+	Name string
+	// Up contains SQL statements to apply the Migration.
+	Up string
+	// Down contains SQL statements to revert the Migration.
+	Down string
+}
+
+// Status describes whether a Migration has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	IsApplied bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts Migrations. Applied versions are
+// recorded in the schema_migrations table. Each Migration runs in
+// its own transaction alongside its schema_migrations record so a
+// failed Migration leaves no partial changes behind.
+type Migrator struct {
+	logger     log.Logger
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Up applies all pending Migrations in order of version and returns
+// the number of Migrations applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if err := m.validate(); err != nil {
+		return 0, err
+	}
+
+	if err := m.createTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var applied int
+	for _, migration := range m.migrations {
+		migration := migration
+		isApplied, err := m.apply(ctx, &migration)
+		if err != nil {
+			return applied, fmt.Errorf("migration %v (%s) failed: %w",
+				migration.Version, migration.Name, err)
+		}
+		if isApplied {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// Down reverts a number of the most recently applied Migrations
+// and returns the number of Migrations reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.validate(); err != nil {
+		return 0, err
+	}
+
+	if err := m.createTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var reverted int
+	for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		migration := m.migrations[i]
+		isReverted, err := m.revert(ctx, &migration)
+		if err != nil {
+			return reverted, fmt.Errorf("rollback of migration %v (%s) failed: %w",
+				migration.Version, migration.Name, err)
+		}
+		if isReverted {
+			reverted++
+		}
+	}
+
+	return reverted, nil
+}
+
+// Status returns the state of every known Migration.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			t       time.Time
+		)
+		if err = rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = t
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		t, ok := appliedAt[migration.Version]
+		statuses = append(statuses, &Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			IsApplied: ok,
+			AppliedAt: t,
+		})
+	}
+
+	return statuses, nil
+}
+
+// apply runs a Migration if it has not been applied yet.
+func (m *Migrator) apply(ctx context.Context, migration *Migration) (bool, error) {
+	var isApplied bool
+	err := m.withTx(ctx, func(tx *sql.Tx) error {
+		exists, err := isRecorded(ctx, tx, migration.Version)
+		if err != nil || exists {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`,
+			migration.Version,
+			migration.Name,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot record migration: %w", err)
+		}
+
+		isApplied = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if isApplied {
+		level.Info(m.logger).Log(
+			"source", "Migrator.Up",
+			"message", "migration applied",
+			"version", migration.Version,
+			"name", migration.Name,
+		)
+	}
+
+	return isApplied, nil
+}
+
+// revert reverts a Migration if it has been applied.
+func (m *Migrator) revert(ctx context.Context, migration *Migration) (bool, error) {
+	var isReverted bool
+	err := m.withTx(ctx, func(tx *sql.Tx) error {
+		exists, err := isRecorded(ctx, tx, migration.Version)
+		if err != nil || !exists {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`DELETE FROM schema_migrations WHERE version = $1;`,
+			migration.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot remove migration record: %w", err)
+		}
+
+		isReverted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if isReverted {
+		level.Info(m.logger).Log(
+			"source", "Migrator.Down",
+			"message", "migration reverted",
+			"version", migration.Version,
+			"name", migration.Name,
+		)
+	}
+
+	return isReverted, nil
+}
+
+// withTx runs an operation inside a transaction. The schema_migrations
+// table is locked for the duration of the transaction to prevent
+// concurrent Migrators from applying the same Migration.
+func (m *Migrator) withTx(ctx context.Context, operation func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot start transaction: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE schema_migrations IN ACCESS EXCLUSIVE MODE;`)
+	if err == nil {
+		err = operation(tx)
+	}
+
+	if err != nil {
+		if dbErr := tx.Rollback(); dbErr != nil {
+			err = fmt.Errorf("%v: %w", dbErr, err)
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Migrator) createTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT current_timestamp
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("cannot create migrations table: %w", err)
+	}
+
+	return nil
+}
+
+// validate ensures Migrations are ordered by unique, increasing versions.
+func (m *Migrator) validate() error {
+	for i, migration := range m.migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %s has an invalid version %v",
+				migration.Name, migration.Version)
+		}
+		if i > 0 && migration.Version <= m.migrations[i-1].Version {
+			return fmt.Errorf("migration %s must have a version greater than %v",
+				migration.Name, m.migrations[i-1].Version)
+		}
+	}
+
+	return nil
+}
+
+func isRecorded(ctx context.Context, tx *sql.Tx, version int) (bool, error) {
+	var exists bool
+	row := tx.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1);`,
+		version,
+	)
+	if err := row.Scan(&exists); err != nil {
+		return false, fmt.Errorf("cannot lookup migration: %w", err)
+	}
+
+	return exists, nil
+}
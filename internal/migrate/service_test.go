@@ -0,0 +1,162 @@
+// Tests are in an external package as the test database
+// helpers depend on this package to set up tables.
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/fmitra/authenticator/internal/migrate"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestMigrator_Validate(t *testing.T) {
+	tt := []struct {
+		name       string
+		migrations []migrate.Migration
+	}{
+		{
+			name: "Duplicate version",
+			migrations: []migrate.Migration{
+				{Version: 1, Name: "first"},
+				{Version: 1, Name: "second"},
+			},
+		},
+		{
+			name: "Unordered version",
+			migrations: []migrate.Migration{
+				{Version: 2, Name: "first"},
+				{Version: 1, Name: "second"},
+			},
+		},
+		{
+			name: "Invalid version",
+			migrations: []migrate.Migration{
+				{Version: 0, Name: "first"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := migrate.NewMigrator(migrate.WithMigrations(tc.migrations))
+			if _, err := m.Up(context.Background()); err == nil {
+				t.Error("expected invalid migrations to fail")
+			}
+		})
+	}
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	ctx := context.Background()
+	m := migrate.NewMigrator(migrate.WithDB(pgDB.DB))
+
+	// The test database is fully migrated on creation.
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal("failed to apply migrations:", err)
+	}
+	if applied != 0 {
+		t.Errorf("incorrect number of migrations applied, want 0 got %v", applied)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal("failed to retrieve status:", err)
+	}
+	for _, s := range statuses {
+		if !s.IsApplied || s.AppliedAt.IsZero() {
+			t.Errorf("migration %v should be applied", s.Version)
+		}
+	}
+
+	reverted, err := m.Down(ctx, len(statuses))
+	if err != nil {
+		t.Fatal("failed to revert migrations:", err)
+	}
+	if reverted != len(statuses) {
+		t.Errorf("incorrect number of migrations reverted, want %v got %v",
+			len(statuses), reverted)
+	}
+	if isTable(t, pgDB.DB, "auth_user") {
+		t.Error("auth_user table should be removed")
+	}
+
+	applied, err = m.Up(ctx)
+	if err != nil {
+		t.Fatal("failed to apply migrations:", err)
+	}
+	if applied != len(statuses) {
+		t.Errorf("incorrect number of migrations applied, want %v got %v",
+			len(statuses), applied)
+	}
+	if !isTable(t, pgDB.DB, "auth_user") {
+		t.Error("auth_user table should be created")
+	}
+}
+
+func TestMigrator_RollsBackFailedMigration(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	ctx := context.Background()
+	m := migrate.NewMigrator(
+		migrate.WithDB(pgDB.DB),
+		migrate.WithMigrations([]migrate.Migration{
+			{
+				Version: 1000,
+				Name:    "create_ok",
+				Up:      "CREATE TABLE migrate_ok (id INT);",
+				Down:    "DROP TABLE migrate_ok;",
+			},
+			{
+				Version: 1001,
+				Name:    "create_broken",
+				Up:      "CREATE TABLE migrate_broken (id INT); SELECT no_such_function();",
+				Down:    "DROP TABLE migrate_broken;",
+			},
+		}),
+	)
+
+	applied, err := m.Up(ctx)
+	if err == nil {
+		t.Fatal("expected broken migration to fail")
+	}
+	if applied != 1 {
+		t.Errorf("incorrect number of migrations applied, want 1 got %v", applied)
+	}
+
+	if !isTable(t, pgDB.DB, "migrate_ok") {
+		t.Error("successful migration should be committed")
+	}
+	if isTable(t, pgDB.DB, "migrate_broken") {
+		t.Error("failed migration should be rolled back")
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal("failed to retrieve status:", err)
+	}
+	if !statuses[0].IsApplied || statuses[1].IsApplied {
+		t.Error("only the successful migration should be recorded")
+	}
+}
+
+func isTable(t *testing.T, db *sql.DB, name string) bool {
+	var exists bool
+	row := db.QueryRow("SELECT to_regclass($1) IS NOT NULL;", name)
+	if err := row.Scan(&exists); err != nil {
+		t.Fatal("failed to check table:", err)
+	}
+	return exists
+}
@@ -1,19 +1,27 @@
 package msgconsumer
 
 import (
+	"time"
+
 	"github.com/go-kit/kit/log"
 
 	auth "github.com/fmitra/authenticator"
 )
 
-// defaultWorkers represents the default number of workers to process a queue.
-const defaultWorkers = 4
+const (
+	// defaultWorkers represents the default number of workers to process a queue.
+	defaultWorkers = 4
+	// defaultDrainTimeout is the default time workers are given to
+	// finish in-flight deliveries on shutdown.
+	defaultDrainTimeout = time.Second * 10
+)
 
 // NewService returns a new Consumer
 func NewService(r auth.MessageRepository, smsLib auth.SMSer, emailLib auth.Emailer, options ...ConfigOption) Consumer {
 	s := service{
 		logger:       log.NewNopLogger(),
 		totalWorkers: defaultWorkers,
+		drainTimeout: defaultDrainTimeout,
 		messageRepo:  r,
 		smsLib:       smsLib,
 		emailLib:     emailLib,
@@ -43,3 +51,26 @@ func WithWorkers(w int) ConfigOption {
 		s.totalWorkers = w
 	}
 }
+
+// WithVoicer configures the service to deliver voice call messages.
+func WithVoicer(voiceLib auth.Voicer) ConfigOption {
+	return func(s *service) {
+		s.voiceLib = voiceLib
+	}
+}
+
+// WithRepoManager configures the service with a new RepositoryManager
+// to track delivery status and store failed messages.
+func WithRepoManager(repoMngr auth.RepositoryManager) ConfigOption {
+	return func(s *service) {
+		s.repoMngr = repoMngr
+	}
+}
+
+// WithDrainTimeout sets the time workers are given to finish
+// in-flight deliveries after the consumer is stopped.
+func WithDrainTimeout(t time.Duration) ConfigOption {
+	return func(s *service) {
+		s.drainTimeout = t
+	}
+}
@@ -1,8 +1,10 @@
-// Package msgconsumer reads and sends SMS/Email messages from a repository.
+// Package msgconsumer reads and sends SMS/Voice/Email messages from a repository.
 package msgconsumer
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -21,45 +23,117 @@ type Consumer interface {
 type service struct {
 	logger       log.Logger
 	smsLib       auth.SMSer
+	voiceLib     auth.Voicer
 	emailLib     auth.Emailer
 	totalWorkers int
 	messageRepo  auth.MessageRepository
+	repoMngr     auth.RepositoryManager
+	// drainTimeout is the time workers are given to finish
+	// in-flight deliveries after the consumer is stopped.
+	drainTimeout time.Duration
 }
 
 // Run retrieves recent messages from the repository and passes
-// them into a channel to be consumed by goroutines.
+// them into a channel to be consumed by goroutines. When the context
+// is cancelled or the repository fails, workers stop receiving messages
+// and Run waits for them to finish in-flight deliveries before returning.
 func (s *service) Run(ctx context.Context) error {
+	// Deliveries are not bound to ctx so in-flight messages are
+	// not cut off on shutdown. They are cancelled only if workers
+	// fail to finish within the drain timeout.
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+
 	msgc, errc := s.messageRepo.Recent(ctx)
 
-	s.startWorkers(ctx, msgc)
+	stop := make(chan struct{})
+	done := s.startWorkers(workCtx, msgc, stop)
 
-	for {
-		select {
-		case err := <-errc:
-			return err
-		case <-ctx.Done():
-			return ctx.Err()
-		}
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
 	}
+
+	// A repository which fails may leave its channel open, so workers
+	// are told to stop receiving messages instead of waiting for it
+	// to be closed.
+	close(stop)
+	s.drain(done, cancelWork)
+
+	return err
 }
 
 // startWorkers starts a finite number of workers to deliver messages found
-// in the message queue.
-func (s *service) startWorkers(ctx context.Context, msgc <-chan *auth.Message) {
+// in the message queue. The returned channel is closed once the queue or
+// stop channel is closed and all workers have finished.
+func (s *service) startWorkers(ctx context.Context, msgc <-chan *auth.Message, stop <-chan struct{}) <-chan struct{} {
+	var wg sync.WaitGroup
 	for i := 0; i < s.totalWorkers; i++ {
+		wg.Add(1)
 		go func() {
-			for msg := range msgc {
-				s.processMessage(ctx, msg)
+			defer wg.Done()
+			for {
+				select {
+				case msg, ok := <-msgc:
+					if !ok {
+						return
+					}
+					s.processMessage(ctx, msg)
+				case <-stop:
+					return
+				}
 			}
 		}()
 	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+// drain waits for workers to finish in-flight deliveries. Deliveries
+// still running after the drain timeout are cancelled. Cancelled
+// messages are not acknowledged, so durable repositories deliver them
+// again while the in-memory repository reports them as undelivered.
+func (s *service) drain(done <-chan struct{}, cancelWork context.CancelFunc) {
+	logger := log.With(s.logger, "source", "msgconsumer.Run")
+	level.Info(logger).Log("message", "draining in-flight messages")
+
+	select {
+	case <-done:
+		level.Info(logger).Log("message", "in-flight messages drained")
+		return
+	case <-time.After(s.drainTimeout):
+	}
+
+	cancelWork()
+	level.Warn(logger).Log(
+		"message", "drain timeout exceeded, cancelling in-flight messages",
+		"drain_timeout", s.drainTimeout,
+	)
+
+	// Give cancelled deliveries a moment to report their failure.
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		level.Error(logger).Log("message", "workers did not stop after cancellation")
+	}
 }
 
-// processMessage delivers a message through email or SMS.
+// processMessage delivers a message through email or SMS. Messages
+// which expire after failed delivery attempts are moved to the
+// dead-letter store.
 func (s *service) processMessage(ctx context.Context, msg *auth.Message) {
 	logger := log.With(
 		s.logger,
 		"source", "msgconsumer.processMessage",
+		"message_id", msg.ID,
 		"address", msg.Address,
 		"delivery", msg.Delivery,
 		"type", msg.Type,
@@ -68,14 +142,25 @@ func (s *service) processMessage(ctx context.Context, msg *auth.Message) {
 	)
 	isExpired := time.Now().After(msg.ExpiresAt)
 
+	// Messages published without an ID cannot be dead-lettered, nor
+	// can any message without a RepositoryManager to store it.
+	if isExpired && msg.LastError != "" && msg.ID != "" && s.repoMngr != nil {
+		s.fail(ctx, logger, msg)
+		return
+	}
+
 	if isExpired {
 		level.Info(logger).Log("message", "dropping expired message")
+		s.track(ctx, logger, msg, auth.MessageExpired)
+		s.ack(ctx, logger, msg)
 		return
 	}
 
 	var err error
 	if msg.Delivery == auth.Phone {
 		err = s.smsLib.SMS(ctx, msg.Address, msg.Content)
+	} else if msg.Delivery == auth.Voice {
+		err = s.voice(ctx, msg)
 	} else if msg.Delivery == auth.Email {
 		err = s.emailLib.Email(ctx, msg.Address, msg.Subject, msg.Content)
 	}
@@ -87,22 +172,120 @@ func (s *service) processMessage(ctx context.Context, msg *auth.Message) {
 			"content", msg.Content,
 			"message", "message contents",
 		)
+		s.track(ctx, logger, msg, auth.MessageSent)
+		s.ack(ctx, logger, msg)
+		return
+	}
+
+	msg.LastError = err.Error()
+
+	// A message which expired during the attempt is not retried, as
+	// the repository refuses to publish expired messages.
+	if time.Now().After(msg.ExpiresAt) {
+		level.Info(logger).Log("message", "message expired after failed delivery", "error", err)
+		s.fail(ctx, logger, msg)
 		return
 	}
 
 	// Continue to retry the message until expiry.
 	level.Info(logger).Log("message", "retrying message", "error", err)
+	s.track(ctx, logger, msg, auth.MessageQueued)
 
 	if err := s.messageRepo.Publish(ctx, msg); err != nil {
-		level.Info(logger).Log(
-			"message",
-			"failed to retry message",
-			"error",
-			err,
+		level.Error(logger).Log(
+			"message", "failed to retry message",
+			"error", err,
+		)
+		s.fail(ctx, logger, msg)
+		return
+	}
+
+	level.Info(logger).Log(
+		"message", "message sent back to queue",
+	)
+	// Retries are published as a new message so the original
+	// may be acknowledged.
+	s.ack(ctx, logger, msg)
+}
+
+// voice delivers a message through a voice call.
+func (s *service) voice(ctx context.Context, msg *auth.Message) error {
+	if s.voiceLib == nil {
+		return fmt.Errorf("voice delivery is not configured")
+	}
+
+	return s.voiceLib.Voice(ctx, msg.Address, msg.Content, msg.Locale)
+}
+
+// fail moves a message which can no longer be retried to the dead-letter
+// store and acknowledges it. Messages which cannot be dead-lettered are
+// left unacknowledged so that durable repositories deliver them again.
+func (s *service) fail(ctx context.Context, logger log.Logger, msg *auth.Message) {
+	if msg.ID == "" || s.repoMngr == nil {
+		return
+	}
+
+	level.Info(logger).Log("message", "moving failed message to dead-letter store")
+	if err := s.deadLetter(ctx, msg); err != nil {
+		level.Error(logger).Log(
+			"message", "failed to move message to dead-letter store",
+			"error", err,
+		)
+		return
+	}
+
+	s.ack(ctx, logger, msg)
+}
+
+// deadLetter stores a failed message with its last provider error
+// and marks it as failed.
+func (s *service) deadLetter(ctx context.Context, msg *auth.Message) error {
+	err := s.repoMngr.DeadLetter().Create(ctx, &auth.DeadLetter{
+		Message:   msg,
+		LastError: msg.LastError,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repoMngr.MessageStatus().Save(ctx, status(msg, auth.MessageFailed))
+}
+
+// track records the delivery status of a message. Failures are logged
+// and do not interrupt delivery. Messages published without an ID,
+// or processed without a RepositoryManager, are not tracked.
+func (s *service) track(ctx context.Context, logger log.Logger, msg *auth.Message, deliveryStatus auth.DeliveryStatus) {
+	if msg.ID == "" || s.repoMngr == nil {
+		return
+	}
+
+	if err := s.repoMngr.MessageStatus().Save(ctx, status(msg, deliveryStatus)); err != nil {
+		level.Error(logger).Log(
+			"message", "failed to save message status",
+			"status", deliveryStatus,
+			"error", err,
 		)
-	} else {
+	}
+}
+
+// ack acknowledges a processed message. Messages that are not
+// acknowledged may be delivered again by the repository.
+func (s *service) ack(ctx context.Context, logger log.Logger, msg *auth.Message) {
+	if err := s.messageRepo.Ack(ctx, msg); err != nil {
 		level.Info(logger).Log(
-			"message", "message sent back to queue",
+			"message", "failed to acknowledge message",
+			"error", err,
 		)
 	}
 }
+
+func status(msg *auth.Message, deliveryStatus auth.DeliveryStatus) *auth.MessageStatus {
+	return &auth.MessageStatus{
+		MessageID:        msg.ID,
+		Type:             msg.Type,
+		Delivery:         msg.Delivery,
+		Status:           deliveryStatus,
+		DeliveryAttempts: msg.DeliveryAttempts,
+		LastError:        msg.LastError,
+	}
+}
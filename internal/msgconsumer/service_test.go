@@ -22,6 +22,21 @@ type smsMock struct {
 	SMSFn     func(ctx context.Context, phoneNumber, message string) error
 }
 
+type voiceMock struct {
+	callCount int
+	locale    string
+	VoiceFn   func(ctx context.Context, phoneNumber, message, locale string) error
+}
+
+func (m *voiceMock) Voice(ctx context.Context, phoneNumber, message, locale string) error {
+	m.callCount++
+	m.locale = locale
+	if m.VoiceFn != nil {
+		return m.VoiceFn(ctx, phoneNumber, message, locale)
+	}
+	return nil
+}
+
 func (m *emailMock) Email(ctx context.Context, email, subject, message string) error {
 	m.callCount++
 	if m.EmailFn != nil {
@@ -206,9 +221,11 @@ func TestMsgConsumer_ProcessMessage(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			smsChan := make(chan bool)
-			emailChan := make(chan bool)
-			publishChan := make(chan bool)
+			// Channels are buffered as Run waits for workers to
+			// finish processing before it returns.
+			smsChan := make(chan bool, 1)
+			emailChan := make(chan bool, 1)
+			publishChan := make(chan bool, 1)
 
 			defer close(smsChan)
 			defer close(emailChan)
@@ -265,3 +282,519 @@ func TestMsgConsumer_ProcessMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestMsgConsumer_AcksProcessedMessage(t *testing.T) {
+	tt := []struct {
+		name      string
+		expiresAt time.Time
+		emailErr  error
+		publishFn func(ctx context.Context, msg *auth.Message) error
+		ackCount  int
+	}{
+		{
+			name:      "Acks sent message",
+			expiresAt: time.Now().Add(time.Minute),
+			ackCount:  1,
+		},
+		{
+			name:      "Acks expired message",
+			expiresAt: time.Now().Add(time.Minute * -1),
+			ackCount:  1,
+		},
+		{
+			name:      "Acks retried message",
+			expiresAt: time.Now().Add(time.Minute),
+			emailErr:  fmt.Errorf("whoops"),
+			ackCount:  1,
+		},
+		{
+			name:      "Does not ack message that failed to retry",
+			expiresAt: time.Now().Add(time.Minute),
+			emailErr:  fmt.Errorf("whoops"),
+			publishFn: func(ctx context.Context, msg *auth.Message) error {
+				return fmt.Errorf("whoops")
+			},
+			ackCount: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			emailLib := emailMock{
+				EmailFn: func(ctx context.Context, email, subject, message string) error {
+					return tc.emailErr
+				},
+			}
+			messageRepo := test.MessageRepository{
+				PublishFn: tc.publishFn,
+			}
+			svc := &service{
+				logger:      &test.Logger{},
+				smsLib:      &smsMock{},
+				emailLib:    &emailLib,
+				messageRepo: &messageRepo,
+			}
+
+			svc.processMessage(context.Background(), &auth.Message{
+				ReceiptID: "receipt-id",
+				Delivery:  auth.Email,
+				ExpiresAt: tc.expiresAt,
+			})
+
+			if messageRepo.Calls.Ack != tc.ackCount {
+				t.Errorf("incorrect calls to MessageRepository.Ack, want %v got %v",
+					tc.ackCount, messageRepo.Calls.Ack)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_SendsVoice(t *testing.T) {
+	tt := []struct {
+		name         string
+		voiceLib     *voiceMock
+		voiceCount   int
+		publishCount int
+	}{
+		{
+			name:         "Calls phone number",
+			voiceLib:     &voiceMock{},
+			voiceCount:   1,
+			publishCount: 0,
+		},
+		{
+			name: "Retries failed call",
+			voiceLib: &voiceMock{
+				VoiceFn: func(ctx context.Context, phoneNumber, message, locale string) error {
+					return fmt.Errorf("whoops")
+				},
+			},
+			voiceCount:   1,
+			publishCount: 1,
+		},
+		{
+			name:         "Retries without voice library",
+			voiceLib:     nil,
+			voiceCount:   0,
+			publishCount: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			smsLib := smsMock{}
+			messageRepo := test.MessageRepository{}
+			svc := &service{
+				logger:      &test.Logger{},
+				smsLib:      &smsLib,
+				emailLib:    &emailMock{},
+				messageRepo: &messageRepo,
+			}
+			if tc.voiceLib != nil {
+				svc.voiceLib = tc.voiceLib
+			}
+
+			svc.processMessage(context.Background(), &auth.Message{
+				ReceiptID: "receipt-id",
+				Delivery:  auth.Voice,
+				Address:   "+15555555555",
+				Locale:    "pt-BR",
+				ExpiresAt: time.Now().Add(time.Minute),
+			})
+
+			if tc.voiceLib != nil {
+				if tc.voiceLib.callCount != tc.voiceCount {
+					t.Errorf("incorrect calls to voice library, want %v got %v",
+						tc.voiceCount, tc.voiceLib.callCount)
+				}
+				if tc.voiceLib.locale != "pt-BR" {
+					t.Errorf("incorrect voice locale, want pt-BR got %s", tc.voiceLib.locale)
+				}
+			}
+			if smsLib.callCount != 0 {
+				t.Error("voice message should not be sent by SMS")
+			}
+			if messageRepo.Calls.Publish != tc.publishCount {
+				t.Errorf("incorrect calls to MessageRepository.Publish, want %v got %v",
+					tc.publishCount, messageRepo.Calls.Publish)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_TracksDeliveryStatus(t *testing.T) {
+	tt := []struct {
+		name            string
+		expiresAt       time.Time
+		lastError       string
+		emailErr        error
+		createFn        func(deadLetter *auth.DeadLetter) error
+		status          auth.DeliveryStatus
+		deadLetterCount int
+		ackCount        int
+	}{
+		{
+			name:      "Tracks sent message",
+			expiresAt: time.Now().Add(time.Minute),
+			status:    auth.MessageSent,
+			ackCount:  1,
+		},
+		{
+			name:      "Tracks retried message",
+			expiresAt: time.Now().Add(time.Minute),
+			emailErr:  fmt.Errorf("provider unavailable"),
+			status:    auth.MessageQueued,
+			ackCount:  1,
+		},
+		{
+			name:      "Tracks expired message",
+			expiresAt: time.Now().Add(time.Minute * -1),
+			status:    auth.MessageExpired,
+			ackCount:  1,
+		},
+		{
+			name:            "Dead-letters failed message",
+			expiresAt:       time.Now().Add(time.Minute * -1),
+			lastError:       "provider unavailable",
+			status:          auth.MessageFailed,
+			deadLetterCount: 1,
+			ackCount:        1,
+		},
+		{
+			name:      "Does not ack message that failed to dead-letter",
+			expiresAt: time.Now().Add(time.Minute * -1),
+			lastError: "provider unavailable",
+			createFn: func(deadLetter *auth.DeadLetter) error {
+				return fmt.Errorf("whoops")
+			},
+			status:          "",
+			deadLetterCount: 1,
+			ackCount:        0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var status *auth.MessageStatus
+			statusRepo := test.MessageStatusRepository{
+				SaveFn: func(s *auth.MessageStatus) error {
+					status = s
+					return nil
+				},
+			}
+			deadLetterRepo := test.DeadLetterRepository{
+				CreateFn: func(deadLetter *auth.DeadLetter) error {
+					if deadLetter.LastError != "provider unavailable" {
+						t.Error("incorrect dead letter error:", deadLetter.LastError)
+					}
+					if tc.createFn != nil {
+						return tc.createFn(deadLetter)
+					}
+					return nil
+				},
+			}
+			repoMngr := test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return &statusRepo
+				},
+				DeadLetterFn: func() auth.DeadLetterRepository {
+					return &deadLetterRepo
+				},
+			}
+			messageRepo := test.MessageRepository{}
+			emailLib := emailMock{
+				EmailFn: func(ctx context.Context, email, subject, message string) error {
+					return tc.emailErr
+				},
+			}
+			svc := &service{
+				logger:      &test.Logger{},
+				smsLib:      &smsMock{},
+				emailLib:    &emailLib,
+				messageRepo: &messageRepo,
+				repoMngr:    &repoMngr,
+			}
+
+			svc.processMessage(context.Background(), &auth.Message{
+				ID:        "message-id",
+				Delivery:  auth.Email,
+				ExpiresAt: tc.expiresAt,
+				LastError: tc.lastError,
+			})
+
+			var got auth.DeliveryStatus
+			if status != nil {
+				got = status.Status
+			}
+			if got != tc.status {
+				t.Errorf("incorrect status saved, want %s got %s", tc.status, got)
+			}
+			if tc.emailErr != nil && status.LastError != tc.emailErr.Error() {
+				t.Errorf("incorrect last error saved, want %s got %s",
+					tc.emailErr, status.LastError)
+			}
+			if deadLetterRepo.Calls.Create != tc.deadLetterCount {
+				t.Errorf("incorrect calls to DeadLetterRepository.Create, want %v got %v",
+					tc.deadLetterCount, deadLetterRepo.Calls.Create)
+			}
+			if messageRepo.Calls.Ack != tc.ackCount {
+				t.Errorf("incorrect calls to MessageRepository.Ack, want %v got %v",
+					tc.ackCount, messageRepo.Calls.Ack)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_DeadLettersUnretriableMessage(t *testing.T) {
+	tt := []struct {
+		name         string
+		expiresIn    time.Duration
+		emailDelay   time.Duration
+		publishErr   error
+		publishCount int
+	}{
+		{
+			name:         "Message expires during failed delivery",
+			expiresIn:    time.Millisecond * 20,
+			emailDelay:   time.Millisecond * 40,
+			publishCount: 0,
+		},
+		{
+			name:         "Message fails to be published for retry",
+			expiresIn:    time.Minute,
+			publishErr:   fmt.Errorf("cannot publish expired message"),
+			publishCount: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var status *auth.MessageStatus
+			statusRepo := test.MessageStatusRepository{
+				SaveFn: func(s *auth.MessageStatus) error {
+					status = s
+					return nil
+				},
+			}
+			var deadLetter *auth.DeadLetter
+			deadLetterRepo := test.DeadLetterRepository{
+				CreateFn: func(d *auth.DeadLetter) error {
+					deadLetter = d
+					return nil
+				},
+			}
+			repoMngr := test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return &statusRepo
+				},
+				DeadLetterFn: func() auth.DeadLetterRepository {
+					return &deadLetterRepo
+				},
+			}
+			messageRepo := test.MessageRepository{
+				PublishFn: func(ctx context.Context, msg *auth.Message) error {
+					return tc.publishErr
+				},
+			}
+			msg := &auth.Message{
+				ID:        "message-id",
+				Delivery:  auth.Email,
+				ExpiresAt: time.Now().Add(tc.expiresIn),
+			}
+			emailLib := emailMock{
+				EmailFn: func(ctx context.Context, email, subject, message string) error {
+					time.Sleep(tc.emailDelay)
+					return fmt.Errorf("provider unavailable")
+				},
+			}
+			svc := &service{
+				logger:      &test.Logger{},
+				smsLib:      &smsMock{},
+				emailLib:    &emailLib,
+				messageRepo: &messageRepo,
+				repoMngr:    &repoMngr,
+			}
+
+			svc.processMessage(context.Background(), msg)
+
+			if deadLetterRepo.Calls.Create != 1 {
+				t.Fatalf("incorrect calls to DeadLetterRepository.Create, want 1 got %v",
+					deadLetterRepo.Calls.Create)
+			}
+			if deadLetter.LastError != "provider unavailable" {
+				t.Errorf("incorrect dead letter error, want %s got %s",
+					"provider unavailable", deadLetter.LastError)
+			}
+			if status == nil || status.Status != auth.MessageFailed {
+				t.Errorf("incorrect status saved, want %s got %v", auth.MessageFailed, status)
+			}
+			if messageRepo.Calls.Publish != tc.publishCount {
+				t.Errorf("incorrect calls to MessageRepository.Publish, want %v got %v",
+					tc.publishCount, messageRepo.Calls.Publish)
+			}
+			if messageRepo.Calls.Ack != 1 {
+				t.Errorf("incorrect calls to MessageRepository.Ack, want 1 got %v",
+					messageRepo.Calls.Ack)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_ProcessesWithoutRepoManager(t *testing.T) {
+	tt := []struct {
+		name      string
+		expiresAt time.Time
+		lastError string
+	}{
+		{
+			name:      "Sends message",
+			expiresAt: time.Now().Add(time.Minute),
+		},
+		{
+			name:      "Drops failed message",
+			expiresAt: time.Now().Add(time.Minute * -1),
+			lastError: "provider unavailable",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			messageRepo := test.MessageRepository{}
+			svc := &service{
+				logger:      &test.Logger{},
+				smsLib:      &smsMock{},
+				emailLib:    &emailMock{},
+				messageRepo: &messageRepo,
+			}
+
+			svc.processMessage(context.Background(), &auth.Message{
+				ID:        "message-id",
+				Delivery:  auth.Email,
+				ExpiresAt: tc.expiresAt,
+				LastError: tc.lastError,
+			})
+
+			if messageRepo.Calls.Ack != 1 {
+				t.Errorf("incorrect calls to MessageRepository.Ack, want 1 got %v",
+					messageRepo.Calls.Ack)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_DrainsInFlightMessages(t *testing.T) {
+	tt := []struct {
+		name         string
+		drainTimeout time.Duration
+		sendTime     time.Duration
+		isDelivered  bool
+		isCancelled  bool
+	}{
+		{
+			name:         "Finishes in-flight delivery on shutdown",
+			drainTimeout: time.Second,
+			sendTime:     time.Millisecond * 100,
+			isDelivered:  true,
+			isCancelled:  false,
+		},
+		{
+			name:         "Cancels delivery after drain timeout",
+			drainTimeout: time.Millisecond * 50,
+			sendTime:     time.Second * 5,
+			isDelivered:  false,
+			isCancelled:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			sending := make(chan bool)
+
+			var isDelivered, isCancelled bool
+			emailLib := emailMock{
+				EmailFn: func(ctx context.Context, email, subject, message string) error {
+					close(sending)
+					select {
+					case <-time.After(tc.sendTime):
+						isDelivered = true
+						return nil
+					case <-ctx.Done():
+						isCancelled = true
+						return ctx.Err()
+					}
+				},
+			}
+			messageRepo := test.MessageRepository{
+				RecentFn: func(ctx context.Context) (<-chan *auth.Message, <-chan error) {
+					errc := make(chan error, 1)
+					msgc := make(chan *auth.Message)
+					go func() {
+						defer close(errc)
+						defer close(msgc)
+						msgc <- &auth.Message{
+							Delivery:  auth.Email,
+							ExpiresAt: time.Now().Add(time.Minute),
+						}
+						<-ctx.Done()
+						errc <- ctx.Err()
+					}()
+					return msgc, errc
+				},
+			}
+			consumerSvc := NewService(
+				&messageRepo,
+				&smsMock{},
+				&emailLib,
+				WithDrainTimeout(tc.drainTimeout),
+			)
+
+			go func() {
+				<-sending
+				cancel()
+			}()
+
+			if err := consumerSvc.Run(ctx); err != context.Canceled {
+				t.Error("expected context cancelled error, received:", err)
+			}
+
+			if isDelivered != tc.isDelivered {
+				t.Errorf("incorrect delivery state, want %v got %v", tc.isDelivered, isDelivered)
+			}
+			if isCancelled != tc.isCancelled {
+				t.Errorf("incorrect cancellation state, want %v got %v", tc.isCancelled, isCancelled)
+			}
+		})
+	}
+}
+
+func TestMsgConsumer_StopsOnRepositoryFailure(t *testing.T) {
+	messageRepo := test.MessageRepository{
+		RecentFn: func(ctx context.Context) (<-chan *auth.Message, <-chan error) {
+			errc := make(chan error, 1)
+			errc <- fmt.Errorf("whoops")
+			// The message channel is left open by the failed repository.
+			return make(chan *auth.Message), errc
+		},
+	}
+	consumerSvc := NewService(
+		&messageRepo,
+		&smsMock{},
+		&emailMock{},
+		WithDrainTimeout(time.Minute),
+	)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- consumerSvc.Run(context.Background())
+	}()
+
+	select {
+	case err := <-errc:
+		if err == nil || err.Error() != "whoops" {
+			t.Error("expected repository error, received:", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("consumer did not stop after repository failure")
+	}
+}
@@ -0,0 +1,75 @@
+package msgoutbox
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/entropy"
+)
+
+const (
+	defaultBatchSize    = 10
+	defaultPollInterval = time.Second
+	defaultLease        = time.Minute
+)
+
+// NewService returns a new MessageRepository.
+func NewService(options ...ConfigOption) auth.MessageRepository {
+	s := service{
+		logger:       log.NewNopLogger(),
+		entropy:      entropy.New(),
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+		lease:        defaultLease,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithDB configures the service with a Postgres DB.
+func WithDB(db *sql.DB) ConfigOption {
+	return func(s *service) {
+		s.db = db
+	}
+}
+
+// WithBatchSize configures the maximum number of messages
+// claimed from the outbox at a time.
+func WithBatchSize(n int) ConfigOption {
+	return func(s *service) {
+		s.batchSize = n
+	}
+}
+
+// WithPollInterval configures how long to wait before checking
+// the outbox again after it is found empty.
+func WithPollInterval(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.pollInterval = d
+	}
+}
+
+// WithLease configures how long a claimed message is hidden from other
+// consumers. Unacknowledged messages are redelivered once it expires.
+func WithLease(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.lease = d
+	}
+}
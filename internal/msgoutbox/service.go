@@ -0,0 +1,188 @@
+// Package msgoutbox provides durable message storage backed by a Postgres outbox table.
+package msgoutbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid/v2"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/msgrepo"
+)
+
+// service is an implementation of auth.MessageRepository backed by the
+// message_outbox table. Consumers claim messages by leasing them for a
+// period of time. A message is removed once acknowledged, otherwise it is
+// redelivered when its lease expires. Retried messages are stored with a
+// delayed availability.
+type service struct {
+	logger       log.Logger
+	db           *sql.DB
+	entropy      ulid.MonotonicReader
+	batchSize    int
+	pollInterval time.Duration
+	lease        time.Duration
+}
+
+// Publish stores an unsent message in the outbox. Retried
+// messages are not available until their delay elapses.
+func (s *service) Publish(ctx context.Context, msg *auth.Message) error {
+	isExpired := time.Now().After(msg.ExpiresAt)
+	if isExpired {
+		return fmt.Errorf("cannot publish expired message")
+	}
+
+	msg.DeliveryAttempts++
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	availableAt := time.Now()
+	if msg.DeliveryAttempts > 1 {
+		availableAt = availableAt.Add(msgrepo.RetryDelay(msg.DeliveryAttempts))
+	}
+
+	receiptID, err := ulid.New(ulid.Now(), s.entropy)
+	if err != nil {
+		return fmt.Errorf("cannot generate unique receipt ID: %w", err)
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO message_outbox (id, payload, available_at) VALUES ($1, $2, $3);`,
+		receiptID.String(),
+		string(b),
+		availableAt,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot store message: %w", err)
+	}
+
+	return nil
+}
+
+// Recent retrieves recently published unsent messages.
+func (s *service) Recent(ctx context.Context) (<-chan *auth.Message, <-chan error) {
+	msgc := make(chan *auth.Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(msgc)
+		errc <- s.read(ctx, msgc)
+	}()
+
+	return msgc, errc
+}
+
+// Ack removes a message from the outbox once it has been processed.
+func (s *service) Ack(ctx context.Context, msg *auth.Message) error {
+	if msg.ReceiptID == "" {
+		return fmt.Errorf("cannot acknowledge message without a receipt ID")
+	}
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM message_outbox WHERE id = $1;`, msg.ReceiptID)
+	if err != nil {
+		return fmt.Errorf("cannot remove message: %w", err)
+	}
+
+	return nil
+}
+
+// read delivers messages from the outbox until the context is cancelled.
+// The outbox is polled again immediately while messages are available.
+func (s *service) read(ctx context.Context, msgc chan<- *auth.Message) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		msgs, err := s.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			level.Error(s.logger).Log(
+				"source", "MessageRepository.Recent",
+				"message", "failed to claim messages",
+				"error", err,
+			)
+		}
+
+		if len(msgs) == 0 {
+			select {
+			case <-time.After(s.pollInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			select {
+			case msgc <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// claim leases a batch of available messages. Rows locked by another
+// consumer are skipped, so concurrent consumers never claim the same message.
+func (s *service) claim(ctx context.Context) ([]*auth.Message, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`UPDATE message_outbox SET locked_until = $1
+		WHERE id IN (
+			SELECT id FROM message_outbox
+			WHERE available_at <= $2
+			AND (locked_until IS NULL OR locked_until <= $2)
+			ORDER BY available_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload;`,
+		time.Now().Add(s.lease),
+		time.Now(),
+		s.batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*auth.Message
+	for rows.Next() {
+		var (
+			receiptID string
+			payload   string
+		)
+		if err = rows.Scan(&receiptID, &payload); err != nil {
+			return nil, err
+		}
+
+		var msg auth.Message
+		if err = json.Unmarshal([]byte(payload), &msg); err != nil {
+			level.Error(s.logger).Log(
+				"source", "MessageRepository.Recent",
+				"message", "dropping malformed message",
+				"receipt_id", receiptID,
+				"error", err,
+			)
+			s.Ack(ctx, &auth.Message{ReceiptID: receiptID})
+			continue
+		}
+		msg.ReceiptID = receiptID
+		msgs = append(msgs, &msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
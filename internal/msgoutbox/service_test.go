@@ -0,0 +1,98 @@
+package msgoutbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestMsgOutbox_PublishAndAck(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := NewService(WithDB(pgDB.DB), WithPollInterval(time.Millisecond*10))
+
+	msg := &auth.Message{
+		Type:      auth.OTPLogin,
+		Delivery:  auth.Email,
+		Content:   "Your code is 123456",
+		Address:   "jane@example.com",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	if err = svc.Publish(ctx, msg); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	msgc, _ := svc.Recent(ctx)
+
+	var received *auth.Message
+	select {
+	case received = <-msgc:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for message")
+	}
+	if received.ReceiptID == "" || received.Content != msg.Content {
+		t.Errorf("incorrect message received: %+v", received)
+	}
+
+	// A leased message is hidden from other consumers.
+	claimed, err := svc.(*service).claim(ctx)
+	if err != nil {
+		t.Fatal("failed to claim messages:", err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("leased message should not be claimed, found %v", len(claimed))
+	}
+
+	if err = svc.Ack(ctx, received); err != nil {
+		t.Fatal("failed to acknowledge message:", err)
+	}
+
+	var count int
+	row := pgDB.DB.QueryRow("SELECT COUNT(*) FROM message_outbox;")
+	if err = row.Scan(&count); err != nil {
+		t.Fatal("failed to count messages:", err)
+	}
+	if count != 0 {
+		t.Errorf("acknowledged message should be removed, found %v", count)
+	}
+}
+
+func TestMsgOutbox_DelaysRetry(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	ctx := context.Background()
+	svc := NewService(WithDB(pgDB.DB))
+
+	msg := &auth.Message{
+		Type:             auth.OTPLogin,
+		Delivery:         auth.Email,
+		Address:          "jane@example.com",
+		ExpiresAt:        time.Now().Add(time.Minute),
+		DeliveryAttempts: 1,
+	}
+	if err = svc.Publish(ctx, msg); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	claimed, err := svc.(*service).claim(ctx)
+	if err != nil {
+		t.Fatal("failed to claim messages:", err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("retried message should be delayed, found %v", len(claimed))
+	}
+}
@@ -1,31 +1,54 @@
 package msgpublisher
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/go-kit/kit/log"
 
 	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/entropy"
 )
 
-// defaultExpiry is the default expiry time for a message to be published.
-const defaultExpiry = time.Minute * 10
+const (
+	// defaultExpiry is the default expiry time for a message to be published.
+	defaultExpiry = time.Minute * 10
+	// defaultTemplateDir is the default directory of message templates.
+	defaultTemplateDir = "templates"
+	// defaultLocale is the default locale used to render messages.
+	defaultLocale = "en"
+)
 
 // NewService returns a new implementation of auth.MessagingService.
-func NewService(r auth.MessageRepository, options ...ConfigOption) auth.MessagingService {
+// Message templates are loaded on creation and an error is returned
+// if the default locale does not provide a template for every
+// MessageType and delivery method.
+func NewService(r auth.MessageRepository, options ...ConfigOption) (auth.MessagingService, error) {
 	s := service{
-		messageRepo: r,
-		expireAfter: defaultExpiry,
-		logger:      log.NewNopLogger(),
+		messageRepo:   r,
+		expireAfter:   defaultExpiry,
+		logger:        log.NewNopLogger(),
+		entropy:       entropy.New(),
+		templateDir:   defaultTemplateDir,
+		defaultLocale: defaultLocale,
 	}
 
 	for _, opt := range options {
 		opt(&s)
 	}
 
-	s.createTemplates()
+	templates, err := loadTemplates(s.templateDir)
+	if err != nil {
+		return nil, err
+	}
 
-	return &s
+	s.defaultLocale = normalizeLocale(s.defaultLocale)
+	if _, ok := templates[s.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no templates found for default locale %s", s.defaultLocale)
+	}
+	s.templates = templates
+
+	return &s, nil
 }
 
 // ConfigOption configures the service.
@@ -44,3 +67,34 @@ func WithExpiry(t time.Duration) ConfigOption {
 		s.expireAfter = t
 	}
 }
+
+// WithRepoManager configures the service with a new RepositoryManager
+// to track the delivery status of messages.
+func WithRepoManager(repoMngr auth.RepositoryManager) ConfigOption {
+	return func(s *service) {
+		s.repoMngr = repoMngr
+	}
+}
+
+// WithThrottle configures the service to enforce send limits
+// before a message is queued.
+func WithThrottle(t auth.MessageThrottle) ConfigOption {
+	return func(s *service) {
+		s.throttle = t
+	}
+}
+
+// WithTemplateDir sets the directory message templates are loaded from.
+func WithTemplateDir(dir string) ConfigOption {
+	return func(s *service) {
+		s.templateDir = dir
+	}
+}
+
+// WithDefaultLocale sets the locale used to render messages for
+// Users without a supported locale preference.
+func WithDefaultLocale(locale string) ConfigOption {
+	return func(s *service) {
+		s.defaultLocale = locale
+	}
+}
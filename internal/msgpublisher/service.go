@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid/v2"
 
 	auth "github.com/fmitra/authenticator"
 	"github.com/fmitra/authenticator/internal/contactchecker"
@@ -15,32 +16,75 @@ import (
 
 // service is an implementation of auth.MessagingService.
 type service struct {
-	logger         log.Logger
-	messageRepo    auth.MessageRepository
-	expireAfter    time.Duration
-	smsTemplates   map[auth.MessageType]string
-	emailTemplates map[auth.MessageType]string
-	subjects       map[auth.MessageType]string
+	logger        log.Logger
+	messageRepo   auth.MessageRepository
+	repoMngr      auth.RepositoryManager
+	throttle      auth.MessageThrottle
+	entropy       ulid.MonotonicReader
+	expireAfter   time.Duration
+	templateDir   string
+	defaultLocale string
+	templates     map[string]*templateSet
 }
 
 // Send sends a message to a User. Behind the scenes, a message is stored
-// in the MessageRepository to be consumed by a separate service.
+// in the MessageRepository to be consumed by a separate service. Each
+// message is assigned an ID and its delivery status is tracked as queued.
+// Messages exceeding the send limits of a MessageThrottle are rejected
+// before they are queued.
 func (s *service) Send(ctx context.Context, msg *auth.Message) error {
 	if !contactchecker.Validator(msg.Delivery)(msg.Address) {
 		return fmt.Errorf("invalid message delivery method")
 	}
 
+	if s.throttle != nil {
+		if err := s.throttle.Allow(ctx, msg); err != nil {
+			return err
+		}
+	}
+
 	if err := s.setMessageFields(msg); err != nil {
 		return err
 	}
 
-	if err := s.messageRepo.Publish(ctx, msg); err != nil {
+	msgID, err := ulid.New(ulid.Now(), s.entropy)
+	if err != nil {
+		return fmt.Errorf("cannot generate unique message ID: %w", err)
+	}
+	msg.ID = msgID.String()
+
+	status := &auth.MessageStatus{
+		MessageID: msg.ID,
+		Type:      msg.Type,
+		Delivery:  msg.Delivery,
+		Status:    auth.MessageQueued,
+	}
+	if err = s.saveStatus(ctx, status); err != nil {
+		return fmt.Errorf("failed to save message status: %w", err)
+	}
+
+	if err = s.messageRepo.Publish(ctx, msg); err != nil {
+		status.Status = auth.MessageFailed
+		status.LastError = err.Error()
+		if statusErr := s.saveStatus(ctx, status); statusErr != nil {
+			err = fmt.Errorf("%v: %w", statusErr, err)
+		}
 		return fmt.Errorf("failed to publish to repository: %w", err)
 	}
 
 	return nil
 }
 
+// saveStatus records the delivery status of a message. Statuses are
+// not tracked if the service is not configured with a RepositoryManager.
+func (s *service) saveStatus(ctx context.Context, status *auth.MessageStatus) error {
+	if s.repoMngr == nil {
+		return nil
+	}
+
+	return s.repoMngr.MessageStatus().Save(ctx, status)
+}
+
 func (s *service) setMessageFields(msg *auth.Message) error {
 	msg.ExpiresAt = time.Now().Add(s.expireAfter)
 
@@ -49,68 +93,30 @@ func (s *service) setMessageFields(msg *auth.Message) error {
 		return nil
 	}
 
-	template := s.template(msg.Type, msg.Delivery)
-	if template == "" {
-		return fmt.Errorf("no template set for %s", msg.Type)
-	}
-
-	for k, v := range msg.Vars {
-		k = fmt.Sprintf("{{%s}}", k)
-		template = strings.Replace(template, k, v, -1)
-	}
-
-	if strings.Contains(template, "{{") || strings.Contains(template, "}}") {
-		return fmt.Errorf("all variables not set for template: %s", template)
+	content, subject, err := s.templateSet(msg.Locale).render(msg.Type, msg.Delivery, msg.Vars)
+	if err != nil {
+		return err
 	}
 
-	msg.Content = template
-	msg.Subject = s.subjects[msg.Type]
+	msg.Content = content
+	msg.Subject = subject
 	return nil
 }
 
-func (s *service) template(t auth.MessageType, d auth.DeliveryMethod) string {
-	if d == auth.Phone {
-		return s.smsTemplates[t]
-	}
-
-	if d == auth.Email {
-		return s.emailTemplates[t]
-	}
-
-	return ""
-}
-
-func (s *service) createTemplates() {
-	s.smsTemplates = map[auth.MessageType]string{
-		auth.OTPLogin:   "Your login code is {{code}}",
-		auth.OTPSignup:  "Your signup code is {{code}}",
-		auth.OTPResend:  "Youre new code is {{code}}",
-		auth.OTPAddress: "Use the code {{code}} to verify your new contact address",
+// templateSet returns the templates for a locale. A locale without
+// templates falls back to its base language (e.g. pt for pt-BR) and
+// then to the default locale.
+func (s *service) templateSet(locale string) *templateSet {
+	locale = normalizeLocale(locale)
+	if t, ok := s.templates[locale]; ok {
+		return t
 	}
 
-	s.emailTemplates = map[auth.MessageType]string{
-		auth.OTPLogin: `
-			<span>Code: <strong>{{code}}</strong></span>
-			<p>Enter the code above to login</p>
-		`,
-		auth.OTPSignup: `
-			<span>Code: <strong>{{code}}</strong></span>
-			<p>Enter the code above to signup</p>
-		`,
-		auth.OTPResend: `
-			<span>Here's your new code</span>
-			<p>Code: <strong>{{code}}</strong></p>
-		`,
-		auth.OTPAddress: `
-			<span>Code: <strong>{{code}}</strong></span>
-			<p>Enter the code above to verify your new contact address</p>
-		`,
+	if i := strings.Index(locale, "-"); i > 0 {
+		if t, ok := s.templates[locale[:i]]; ok {
+			return t
+		}
 	}
 
-	s.subjects = map[auth.MessageType]string{
-		auth.OTPAddress: "Verify your contact details",
-		auth.OTPLogin:   "Your login verification code",
-		auth.OTPResend:  "You've requested a new verification code",
-		auth.OTPSignup:  "Your signup verification code",
-	}
+	return s.templates[s.defaultLocale]
 }
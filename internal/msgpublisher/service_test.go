@@ -3,13 +3,21 @@ package msgpublisher
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+
 	auth "github.com/fmitra/authenticator"
 	"github.com/fmitra/authenticator/internal/test"
 )
 
+// templateDir contains the message templates shipped with the service.
+const templateDir = "../../templates"
+
 func TestMsgPublisher_Send(t *testing.T) {
 	tt := []struct {
 		name           string
@@ -17,13 +25,18 @@ func TestMsgPublisher_Send(t *testing.T) {
 		deliveryMethod auth.DeliveryMethod
 		publishMock    func(ctx context.Context, msg *auth.Message) error
 		isFailed       bool
+		statuses       []auth.DeliveryStatus
 	}{
 		{
 			name:           "Sends SMS",
 			deliveryMethod: auth.Phone,
 			address:        "+639455189172",
 			isFailed:       false,
+			statuses:       []auth.DeliveryStatus{auth.MessageQueued},
 			publishMock: func(ctx context.Context, msg *auth.Message) error {
+				if msg.ID == "" {
+					t.Error("message ID not set")
+				}
 				if msg.Delivery != auth.Phone {
 					t.Errorf("incorrect delivery method: want %s, got %s", auth.Phone, msg.Delivery)
 				}
@@ -38,6 +51,7 @@ func TestMsgPublisher_Send(t *testing.T) {
 			deliveryMethod: auth.Phone,
 			address:        "94867353",
 			isFailed:       true,
+			statuses:       nil,
 			publishMock: func(ctx context.Context, msg *auth.Message) error {
 				return fmt.Errorf("whoops")
 			},
@@ -47,7 +61,11 @@ func TestMsgPublisher_Send(t *testing.T) {
 			deliveryMethod: auth.Email,
 			address:        "jane@example.com",
 			isFailed:       false,
+			statuses:       []auth.DeliveryStatus{auth.MessageQueued},
 			publishMock: func(ctx context.Context, msg *auth.Message) error {
+				if msg.ID == "" {
+					t.Error("message ID not set")
+				}
 				if msg.Delivery != auth.Email {
 					t.Errorf("incorrect delivery method: want %s, got %s", auth.Email, msg.Delivery)
 				}
@@ -62,6 +80,7 @@ func TestMsgPublisher_Send(t *testing.T) {
 			deliveryMethod: auth.Email,
 			address:        "jane@example.com",
 			isFailed:       true,
+			statuses:       []auth.DeliveryStatus{auth.MessageQueued, auth.MessageFailed},
 			publishMock: func(ctx context.Context, msg *auth.Message) error {
 				return fmt.Errorf("whoops")
 			},
@@ -74,9 +93,29 @@ func TestMsgPublisher_Send(t *testing.T) {
 				PublishFn: tc.publishMock,
 			}
 
+			var statuses []auth.DeliveryStatus
+			statusRepo := test.MessageStatusRepository{
+				SaveFn: func(status *auth.MessageStatus) error {
+					statuses = append(statuses, status.Status)
+					return nil
+				},
+			}
+			repoMngr := test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return &statusRepo
+				},
+			}
+
 			ctx := context.Background()
-			publisherSvc := NewService(&messageRepo)
-			err := publisherSvc.Send(ctx, &auth.Message{
+			publisherSvc, err := NewService(
+				&messageRepo,
+				WithRepoManager(&repoMngr),
+				WithTemplateDir(templateDir),
+			)
+			if err != nil {
+				t.Fatal("failed to create service:", err)
+			}
+			err = publisherSvc.Send(ctx, &auth.Message{
 				Type:     auth.OTPLogin,
 				Delivery: tc.deliveryMethod,
 				Address:  tc.address,
@@ -90,6 +129,380 @@ func TestMsgPublisher_Send(t *testing.T) {
 			if err == nil && tc.isFailed {
 				t.Error("expected error, received nil")
 			}
+			if !cmp.Equal(statuses, tc.statuses) {
+				t.Error("incorrect message statuses saved", cmp.Diff(statuses, tc.statuses))
+			}
+		})
+	}
+}
+
+func TestMsgPublisher_SendWithoutRepoManager(t *testing.T) {
+	messageRepo := test.MessageRepository{}
+	publisherSvc, err := NewService(
+		&messageRepo,
+		WithTemplateDir(templateDir),
+	)
+	if err != nil {
+		t.Fatal("failed to create service:", err)
+	}
+
+	err = publisherSvc.Send(context.Background(), &auth.Message{
+		Type:     auth.OTPLogin,
+		Delivery: auth.Email,
+		Address:  "jane@example.com",
+		Vars: map[string]string{
+			"code": "111",
+		},
+	})
+	if err != nil {
+		t.Error("expected nil error, received:", err)
+	}
+	if messageRepo.Calls.Publish != 1 {
+		t.Errorf("incorrect calls to MessageRepository.Publish, want 1 got %v",
+			messageRepo.Calls.Publish)
+	}
+}
+
+func TestMsgPublisher_Throttle(t *testing.T) {
+	tt := []struct {
+		name     string
+		allowErr error
+		statuses []auth.DeliveryStatus
+		publish  int
+	}{
+		{
+			name:     "Queues allowed message",
+			allowErr: nil,
+			statuses: []auth.DeliveryStatus{auth.MessageQueued},
+			publish:  1,
+		},
+		{
+			name:     "Rejects throttled message",
+			allowErr: auth.ErrThrottle("too many messages sent, try again later"),
+			statuses: nil,
+			publish:  0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			messageRepo := test.MessageRepository{}
+			throttle := test.MessageThrottle{
+				AllowFn: func() error {
+					return tc.allowErr
+				},
+			}
+
+			var statuses []auth.DeliveryStatus
+			statusRepo := test.MessageStatusRepository{
+				SaveFn: func(status *auth.MessageStatus) error {
+					statuses = append(statuses, status.Status)
+					return nil
+				},
+			}
+			repoMngr := test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return &statusRepo
+				},
+			}
+
+			publisherSvc, err := NewService(
+				&messageRepo,
+				WithRepoManager(&repoMngr),
+				WithTemplateDir(templateDir),
+				WithThrottle(&throttle),
+			)
+			if err != nil {
+				t.Fatal("failed to create service:", err)
+			}
+
+			err = publisherSvc.Send(context.Background(), &auth.Message{
+				Type:     auth.OTPLogin,
+				Delivery: auth.Phone,
+				Address:  "+639455189172",
+				Vars: map[string]string{
+					"code": "111",
+				},
+			})
+			if !cmp.Equal(auth.ErrorCode(err), auth.ErrorCode(tc.allowErr)) {
+				t.Error("error code does not match", cmp.Diff(
+					auth.ErrorCode(err), auth.ErrorCode(tc.allowErr),
+				))
+			}
+			if throttle.Calls.Allow != 1 {
+				t.Errorf("incorrect MessageThrottle.Allow() call count, want 1 got %v", throttle.Calls.Allow)
+			}
+			if messageRepo.Calls.Publish != tc.publish {
+				t.Errorf("incorrect MessageRepository.Publish() call count, want %v got %v",
+					tc.publish, messageRepo.Calls.Publish)
+			}
+			if !cmp.Equal(statuses, tc.statuses) {
+				t.Error("incorrect message statuses saved", cmp.Diff(statuses, tc.statuses))
+			}
+		})
+	}
+}
+
+func TestMsgPublisher_RendersLocale(t *testing.T) {
+	dir := newTemplateDir(t, "en", "pt", "ja")
+	defer os.RemoveAll(dir)
+
+	tt := []struct {
+		name     string
+		locale   string
+		delivery auth.DeliveryMethod
+		address  string
+		vars     map[string]string
+		content  string
+		subject  string
+	}{
+		{
+			name:     "Renders default locale",
+			locale:   "",
+			delivery: auth.Phone,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "111"},
+			content:  "en sms 111",
+			subject:  "en subject",
+		},
+		{
+			name:     "Renders exact locale",
+			locale:   "pt",
+			delivery: auth.Phone,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "111"},
+			content:  "pt sms 111",
+			subject:  "pt subject",
+		},
+		{
+			name:     "Falls back to base language",
+			locale:   "pt-BR",
+			delivery: auth.Phone,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "111"},
+			content:  "pt sms 111",
+			subject:  "pt subject",
+		},
+		{
+			name:     "Falls back to default locale",
+			locale:   "fr-CA",
+			delivery: auth.Phone,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "111"},
+			content:  "en sms 111",
+			subject:  "en subject",
+		},
+		{
+			name:     "Matches locale case insensitively",
+			locale:   "JA_jp",
+			delivery: auth.Phone,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "111"},
+			content:  "ja sms 111",
+			subject:  "ja subject",
+		},
+		{
+			name:     "Spells voice variables",
+			locale:   "pt-BR",
+			delivery: auth.Voice,
+			address:  "+639455189172",
+			vars:     map[string]string{"code": "123"},
+			content:  "pt voice 1, 2, 3",
+			subject:  "pt subject",
+		},
+		{
+			name:     "Escapes email variables",
+			locale:   "en",
+			delivery: auth.Email,
+			address:  "jane@example.com",
+			vars:     map[string]string{"code": "<b>111</b>"},
+			content:  "<p>en email &lt;b&gt;111&lt;/b&gt;</p>",
+			subject:  "en subject",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var msg *auth.Message
+			messageRepo := test.MessageRepository{
+				PublishFn: func(ctx context.Context, m *auth.Message) error {
+					msg = m
+					return nil
+				},
+			}
+			repoMngr := test.RepositoryManager{
+				MessageStatusFn: func() auth.MessageStatusRepository {
+					return &test.MessageStatusRepository{}
+				},
+			}
+
+			publisherSvc, err := NewService(
+				&messageRepo,
+				WithRepoManager(&repoMngr),
+				WithTemplateDir(dir),
+			)
+			if err != nil {
+				t.Fatal("failed to create service:", err)
+			}
+
+			err = publisherSvc.Send(context.Background(), &auth.Message{
+				Type:     auth.OTPLogin,
+				Delivery: tc.delivery,
+				Address:  tc.address,
+				Locale:   tc.locale,
+				Vars:     tc.vars,
+			})
+			if err != nil {
+				t.Fatal("failed to send message:", err)
+			}
+			if msg.Content != tc.content {
+				t.Errorf("incorrect content, want %q got %q", tc.content, msg.Content)
+			}
+			if msg.Subject != tc.subject {
+				t.Errorf("incorrect subject, want %q got %q", tc.subject, msg.Subject)
+			}
+		})
+	}
+}
+
+func TestMsgPublisher_MissingVariable(t *testing.T) {
+	repoMngr := test.RepositoryManager{
+		MessageStatusFn: func() auth.MessageStatusRepository {
+			return &test.MessageStatusRepository{}
+		},
+	}
+	publisherSvc, err := NewService(
+		&test.MessageRepository{},
+		WithRepoManager(&repoMngr),
+		WithTemplateDir(templateDir),
+	)
+	if err != nil {
+		t.Fatal("failed to create service:", err)
+	}
+
+	err = publisherSvc.Send(context.Background(), &auth.Message{
+		Type:     auth.MagicLink,
+		Delivery: auth.Email,
+		Address:  "jane@example.com",
+		Vars:     map[string]string{"code": "111"},
+	})
+	if err == nil {
+		t.Error("expected missing template variable to fail")
+	}
+}
+
+func TestMsgPublisher_ValidatesTemplates(t *testing.T) {
+	tt := []struct {
+		name    string
+		setupFn func(dir string) error
+		locale  string
+	}{
+		{
+			name: "Missing template",
+			setupFn: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "pt", "magic_link.sms.txt"))
+			},
+			locale: "en",
+		},
+		{
+			name: "Invalid template",
+			setupFn: func(dir string) error {
+				return ioutil.WriteFile(
+					filepath.Join(dir, "en", "otp_login.email.html"),
+					[]byte("{{.code"),
+					0644,
+				)
+			},
+			locale: "en",
+		},
+		{
+			name: "Missing default locale",
+			setupFn: func(dir string) error {
+				return nil
+			},
+			locale: "fr",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := newTemplateDir(t, "en", "pt")
+			defer os.RemoveAll(dir)
+
+			if err := tc.setupFn(dir); err != nil {
+				t.Fatal("failed to set up templates:", err)
+			}
+
+			_, err := NewService(
+				&test.MessageRepository{},
+				WithTemplateDir(dir),
+				WithDefaultLocale(tc.locale),
+			)
+			if err == nil {
+				t.Error("expected invalid templates to fail")
+			}
 		})
 	}
 }
+
+func TestMsgPublisher_ShippedTemplates(t *testing.T) {
+	templates, err := loadTemplates(templateDir)
+	if err != nil {
+		t.Fatal("failed to load templates:", err)
+	}
+
+	vars := map[string]string{
+		"code": "111",
+		"link": "https://example.com/login",
+	}
+	for locale, set := range templates {
+		for _, msgType := range messageTypes {
+			for _, d := range []auth.DeliveryMethod{auth.Phone, auth.Voice, auth.Email} {
+				content, subject, err := set.render(msgType, d, vars)
+				if err != nil {
+					t.Errorf("%s: failed to render %s %s template: %v", locale, msgType, d, err)
+					continue
+				}
+				code := "111"
+				if d == auth.Voice {
+					code = "1, 1, 1"
+				}
+				if !strings.Contains(content, code) || subject == "" {
+					t.Errorf("%s: incomplete %s %s message: %q %q", locale, msgType, d, content, subject)
+				}
+			}
+		}
+	}
+}
+
+// newTemplateDir creates a directory of templates for a set of locales.
+// Templates render the locale, delivery method and code.
+func newTemplateDir(t *testing.T, locales ...string) string {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal("failed to create template directory:", err)
+	}
+
+	for _, locale := range locales {
+		if err = os.Mkdir(filepath.Join(dir, locale), 0755); err != nil {
+			t.Fatal("failed to create locale directory:", err)
+		}
+
+		files := map[string]string{
+			"%s.sms.txt":     locale + " sms {{.code}}",
+			"%s.voice.txt":   locale + " voice {{spell .code}}",
+			"%s.email.html":  "<p>" + locale + " email {{.code}}</p>",
+			"%s.subject.txt": locale + " subject",
+		}
+		for _, msgType := range messageTypes {
+			for name, content := range files {
+				path := filepath.Join(dir, locale, fmt.Sprintf(name, msgType))
+				if err = ioutil.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+					t.Fatal("failed to write template:", err)
+				}
+			}
+		}
+	}
+
+	return dir
+}
@@ -0,0 +1,175 @@
+package msgpublisher
+
+import (
+	"bytes"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	textTemplate "text/template"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// messageTypes are the MessageTypes a locale must provide templates for.
+var messageTypes = auth.MessageTypes
+
+// voiceFuncs are the functions available to voice templates.
+var voiceFuncs = textTemplate.FuncMap{
+	"spell": spell,
+}
+
+// templateSet contains the parsed message templates for a locale.
+// SMS content, voice scripts and subjects are plain text. Email content
+// is HTML with template variables escaped for the context they appear in.
+type templateSet struct {
+	sms     map[auth.MessageType]*textTemplate.Template
+	voice   map[auth.MessageType]*textTemplate.Template
+	email   map[auth.MessageType]*htmlTemplate.Template
+	subject map[auth.MessageType]*textTemplate.Template
+}
+
+// render executes the templates for a MessageType and returns the
+// message content and subject.
+func (t *templateSet) render(msgType auth.MessageType, d auth.DeliveryMethod, vars map[string]string) (string, string, error) {
+	var (
+		content bytes.Buffer
+		subject bytes.Buffer
+		err     error
+	)
+
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	switch d {
+	case auth.Phone:
+		err = t.sms[msgType].Execute(&content, vars)
+	case auth.Voice:
+		err = t.voice[msgType].Execute(&content, vars)
+	case auth.Email:
+		err = t.email[msgType].Execute(&content, vars)
+	default:
+		return "", "", fmt.Errorf("no template set for delivery method %s", d)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("cannot render %s template: %w", msgType, err)
+	}
+
+	if err = t.subject[msgType].Execute(&subject, vars); err != nil {
+		return "", "", fmt.Errorf("cannot render %s subject: %w", msgType, err)
+	}
+
+	return strings.TrimSpace(content.String()), strings.TrimSpace(subject.String()), nil
+}
+
+// loadTemplates parses message templates from a directory. Each
+// subdirectory is named after a locale and contains a template for
+// every MessageType and delivery method:
+//
+//	<dir>/<locale>/<message_type>.sms.txt
+//	<dir>/<locale>/<message_type>.voice.txt
+//	<dir>/<locale>/<message_type>.email.html
+//	<dir>/<locale>/<message_type>.subject.txt
+//
+// Templates receive a Message's Vars and fail to render if a
+// variable is missing. Voice templates may spell out a variable,
+// such as {{spell .code}}, so each character is read aloud.
+func loadTemplates(dir string) (map[string]*templateSet, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template directory: %w", err)
+	}
+
+	templates := make(map[string]*templateSet)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		locale := normalizeLocale(entry.Name())
+		templates[locale], err = loadTemplateSet(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid templates for locale %s: %w", entry.Name(), err)
+		}
+	}
+
+	return templates, nil
+}
+
+func loadTemplateSet(dir string) (*templateSet, error) {
+	t := templateSet{
+		sms:     make(map[auth.MessageType]*textTemplate.Template),
+		voice:   make(map[auth.MessageType]*textTemplate.Template),
+		email:   make(map[auth.MessageType]*htmlTemplate.Template),
+		subject: make(map[auth.MessageType]*textTemplate.Template),
+	}
+
+	for _, msgType := range messageTypes {
+		name := string(msgType)
+
+		src, err := readTemplate(dir, name+".sms.txt")
+		if err != nil {
+			return nil, err
+		}
+		t.sms[msgType], err = textTemplate.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err = readTemplate(dir, name+".voice.txt")
+		if err != nil {
+			return nil, err
+		}
+		t.voice[msgType], err = textTemplate.New(name).Option("missingkey=error").Funcs(voiceFuncs).Parse(src)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err = readTemplate(dir, name+".email.html")
+		if err != nil {
+			return nil, err
+		}
+		t.email[msgType], err = htmlTemplate.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err = readTemplate(dir, name+".subject.txt")
+		if err != nil {
+			return nil, err
+		}
+		t.subject[msgType], err = textTemplate.New(name).Option("missingkey=error").Parse(src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+func readTemplate(dir, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("missing template %s", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read template %s: %w", name, err)
+	}
+
+	return string(b), nil
+}
+
+// spell separates the characters of a value so that text-to-speech
+// reads each character individually with a short pause.
+func spell(s string) string {
+	return strings.Join(strings.Split(s, ""), ", ")
+}
+
+// normalizeLocale formats a language tag for comparison. Tags are
+// case insensitive and may be written with an underscore separator.
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
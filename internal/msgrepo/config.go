@@ -11,6 +11,7 @@ func NewService(options ...ConfigOption) auth.MessageRepository {
 	s := service{
 		logger:       log.NewNopLogger(),
 		messageQueue: make(chan *auth.Message),
+		done:         make(chan struct{}),
 	}
 
 	for _, opt := range options {
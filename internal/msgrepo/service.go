@@ -5,60 +5,119 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 
 	auth "github.com/fmitra/authenticator"
 )
 
-// service is an implementation of auth.MessageRepository
+// service is an implementation of auth.MessageRepository backed
+// by an in-memory channel. Pending messages are lost on restart and
+// may only be consumed within the same process.
 type service struct {
 	logger       log.Logger
 	messageQueue chan *auth.Message
+	// done is closed when the repository stops accepting messages.
+	done     chan struct{}
+	mu       sync.Mutex
+	isClosed bool
+	// pending tracks published messages waiting to be
+	// written to the channel.
+	pending sync.WaitGroup
 }
 
-// Publish writes an unsent message to a channel.
+// Publish writes an unsent message to a channel. Messages
+// may not be published after the repository is closed.
 func (s *service) Publish(ctx context.Context, msg *auth.Message) error {
 	isExpired := time.Now().After(msg.ExpiresAt)
 	if isExpired {
 		return fmt.Errorf("cannot publish expired message")
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed {
+		return fmt.Errorf("cannot publish to closed repository")
+	}
+
+	s.pending.Add(1)
 	go func() {
+		defer s.pending.Done()
+
 		msg.DeliveryAttempts++
 
-		if msg.DeliveryAttempts == 1 {
-			s.messageQueue <- msg
-			return
+		if msg.DeliveryAttempts > 1 {
+			select {
+			case <-time.After(RetryDelay(msg.DeliveryAttempts)):
+			case <-s.done:
+				s.discard(msg)
+				return
+			}
 		}
 
-		waitTime := delay(msg.DeliveryAttempts)
-		time.Sleep(waitTime)
-
-		s.messageQueue <- msg
+		select {
+		case s.messageQueue <- msg:
+		case <-s.done:
+			s.discard(msg)
+		}
 	}()
 
 	return nil
 }
 
-// Recent retrieves recently published unsent messages.
+// Recent retrieves recently published unsent messages. The repository
+// is closed when the context is cancelled. Messages waiting to be
+// retrieved are discarded and the channel is closed.
 func (s *service) Recent(ctx context.Context) (<-chan *auth.Message, <-chan error) {
 	errc := make(chan error, 1)
 
 	go func() {
 		defer close(errc)
-		defer close(s.messageQueue)
 		<-ctx.Done()
+		s.close()
 		errc <- ctx.Err()
 	}()
 
 	return s.messageQueue, errc
 }
 
-// delay calculates the amount of time to wait before
+// Ack is a no-op. Messages are removed from the channel
+// as soon as they are received.
+func (s *service) Ack(ctx context.Context, msg *auth.Message) error {
+	return nil
+}
+
+// close stops the repository from accepting messages. The channel is
+// only closed once no published messages are waiting to be written to it.
+func (s *service) close() {
+	s.mu.Lock()
+	s.isClosed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	s.pending.Wait()
+	close(s.messageQueue)
+}
+
+// discard reports a message which will not be delivered
+// as the repository was closed.
+func (s *service) discard(msg *auth.Message) {
+	level.Warn(s.logger).Log(
+		"source", "MessageRepository.Recent",
+		"message", "discarding undelivered message on shutdown",
+		"message_id", msg.ID,
+		"type", msg.Type,
+		"delivery", msg.Delivery,
+		"delivery_attempts", msg.DeliveryAttempts,
+	)
+}
+
+// RetryDelay calculates the amount of time to wait before
 // publishing a message back into the queue
-func delay(deliveryAttempts int) time.Duration {
+func RetryDelay(deliveryAttempts int) time.Duration {
 	rand.Seed(time.Now().UnixNano())
 
 	// Maximum 3 second jitter
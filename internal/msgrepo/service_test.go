@@ -66,3 +66,38 @@ func TestMsgRepo_Recent(t *testing.T) {
 		}
 	}
 }
+
+func TestMsgRepo_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := NewService()
+
+	// Retried messages wait before they are written to the queue.
+	msg := auth.Message{
+		ExpiresAt:        time.Now().Add(time.Minute),
+		DeliveryAttempts: 1,
+	}
+	if err := svc.Publish(ctx, &msg); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	msgc, errc := svc.Recent(ctx)
+	cancel()
+
+	select {
+	case m, ok := <-msgc:
+		if ok {
+			t.Error("pending message should be discarded, received:", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queue was not closed")
+	}
+
+	if err := <-errc; err != context.Canceled {
+		t.Error("expected context cancelled error, received:", err)
+	}
+
+	msg = auth.Message{ExpiresAt: time.Now().Add(time.Minute)}
+	if err := svc.Publish(context.Background(), &msg); err == nil {
+		t.Error("closed repository should not accept messages")
+	}
+}
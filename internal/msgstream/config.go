@@ -0,0 +1,95 @@
+package msgstream
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	defaultStream       = "auth_messages"
+	defaultGroup        = "msgconsumer"
+	defaultBatchSize    = 10
+	defaultBlockTimeout = time.Second
+	defaultClaimIdle    = time.Minute
+)
+
+// NewService returns a new MessageRepository.
+func NewService(options ...ConfigOption) auth.MessageRepository {
+	hostname, _ := os.Hostname()
+
+	s := service{
+		logger:       log.NewNopLogger(),
+		stream:       defaultStream,
+		group:        defaultGroup,
+		consumer:     fmt.Sprintf("%s-%v", hostname, os.Getpid()),
+		batchSize:    defaultBatchSize,
+		blockTimeout: defaultBlockTimeout,
+		claimIdle:    defaultClaimIdle,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithDB configures the service with a redis DB.
+func WithDB(db rediser) ConfigOption {
+	return func(s *service) {
+		s.db = db
+	}
+}
+
+// WithStream configures the name of the stream messages are published to.
+func WithStream(name string) ConfigOption {
+	return func(s *service) {
+		s.stream = name
+	}
+}
+
+// WithGroup configures the consumer group messages are read by.
+func WithGroup(name string) ConfigOption {
+	return func(s *service) {
+		s.group = name
+	}
+}
+
+// WithConsumer configures the name identifying this process
+// within the consumer group. Names must be unique per process.
+func WithConsumer(name string) ConfigOption {
+	return func(s *service) {
+		s.consumer = name
+	}
+}
+
+// WithBatchSize configures the maximum number of messages
+// read from the stream at a time.
+func WithBatchSize(n int64) ConfigOption {
+	return func(s *service) {
+		s.batchSize = n
+	}
+}
+
+// WithClaimIdle configures how long a message may remain unacknowledged
+// before it is reclaimed from another consumer.
+func WithClaimIdle(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.claimIdle = d
+	}
+}
@@ -0,0 +1,305 @@
+// Package msgstream provides durable message storage backed by Redis Streams.
+package msgstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/msgrepo"
+)
+
+// payloadField is the stream entry field holding an encoded message.
+const payloadField = "message"
+
+// rediser is a minimal interface for go-redis
+type rediser interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XDel(ctx context.Context, stream string, ids ...string) *redis.IntCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd
+	XPendingExt(ctx context.Context, a *redis.XPendingExtArgs) *redis.XPendingExtCmd
+	XClaim(ctx context.Context, a *redis.XClaimArgs) *redis.XMessageSliceCmd
+	ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	Close() error
+}
+
+// service is an implementation of auth.MessageRepository backed by
+// a Redis Stream. Messages are read through a consumer group and remain
+// pending until acknowledged. Pending messages left idle by a failed
+// consumer are reclaimed by another. Retried messages are held in a
+// sorted set until their delay elapses and are then added back to the stream.
+type service struct {
+	logger       log.Logger
+	db           rediser
+	stream       string
+	group        string
+	consumer     string
+	batchSize    int64
+	blockTimeout time.Duration
+	claimIdle    time.Duration
+}
+
+// Publish adds an unsent message to the stream. Retried
+// messages are delayed before they are added.
+func (s *service) Publish(ctx context.Context, msg *auth.Message) error {
+	isExpired := time.Now().After(msg.ExpiresAt)
+	if isExpired {
+		return fmt.Errorf("cannot publish expired message")
+	}
+
+	msg.DeliveryAttempts++
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	if msg.DeliveryAttempts == 1 {
+		return s.add(ctx, string(b))
+	}
+
+	availableAt := time.Now().Add(msgrepo.RetryDelay(msg.DeliveryAttempts))
+	err = s.db.ZAdd(ctx, s.delayedKey(), &redis.Z{
+		Score:  float64(toMillis(availableAt)),
+		Member: string(b),
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("cannot schedule message: %w", err)
+	}
+
+	return nil
+}
+
+// Recent retrieves recently published unsent messages.
+func (s *service) Recent(ctx context.Context) (<-chan *auth.Message, <-chan error) {
+	msgc := make(chan *auth.Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(msgc)
+		errc <- s.read(ctx, msgc)
+	}()
+
+	return msgc, errc
+}
+
+// Ack removes a message from the stream once it has been processed.
+func (s *service) Ack(ctx context.Context, msg *auth.Message) error {
+	if msg.ReceiptID == "" {
+		return fmt.Errorf("cannot acknowledge message without a receipt ID")
+	}
+
+	if err := s.db.XAck(ctx, s.stream, s.group, msg.ReceiptID).Err(); err != nil {
+		return fmt.Errorf("cannot acknowledge message: %w", err)
+	}
+
+	if err := s.db.XDel(ctx, s.stream, msg.ReceiptID).Err(); err != nil {
+		return fmt.Errorf("cannot remove message: %w", err)
+	}
+
+	return nil
+}
+
+// read delivers messages from the stream until the context is cancelled.
+// Each iteration promotes delayed messages which are due, reclaims
+// messages abandoned by other consumers and reads new messages.
+func (s *service) read(ctx context.Context, msgc chan<- *auth.Message) error {
+	err := s.db.XGroupCreateMkStream(ctx, s.stream, s.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("cannot create consumer group: %w", err)
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = s.promote(ctx); err != nil {
+			s.logError(ctx, "failed to promote delayed messages", err)
+		}
+
+		entries, err := s.claim(ctx)
+		if err != nil {
+			s.logError(ctx, "failed to reclaim pending messages", err)
+		}
+
+		if len(entries) == 0 {
+			entries, err = s.next(ctx)
+			if err != nil {
+				s.logError(ctx, "failed to read messages", err)
+				s.wait(ctx)
+				continue
+			}
+		}
+
+		for _, entry := range entries {
+			msg, err := s.decode(entry)
+			if err != nil {
+				s.logError(ctx, "dropping malformed message", err)
+				s.Ack(ctx, &auth.Message{ReceiptID: entry.ID})
+				continue
+			}
+
+			select {
+			case msgc <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// next reads new messages delivered to this consumer. An empty
+// list is returned if no messages arrive before the block timeout.
+func (s *service) next(ctx context.Context) ([]redis.XMessage, error) {
+	streams, err := s.db.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: s.consumer,
+		Streams:  []string{s.stream, ">"},
+		Count:    s.batchSize,
+		Block:    s.blockTimeout,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []redis.XMessage
+	for _, stream := range streams {
+		entries = append(entries, stream.Messages...)
+	}
+
+	return entries, nil
+}
+
+// claim transfers ownership of messages which have been pending
+// without acknowledgement for longer than claimIdle.
+func (s *service) claim(ctx context.Context) ([]redis.XMessage, error) {
+	pending, err := s.db.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.stream,
+		Group:  s.group,
+		Start:  "-",
+		End:    "+",
+		Count:  s.batchSize,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range pending {
+		if p.Idle >= s.claimIdle {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	return s.db.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   s.stream,
+		Group:    s.group,
+		Consumer: s.consumer,
+		MinIdle:  s.claimIdle,
+		Messages: ids,
+	}).Result()
+}
+
+// promote moves delayed messages which are due into the stream. A message
+// is only added by the consumer which succeeds in removing it from the
+// sorted set, preventing duplicates across consumers.
+func (s *service) promote(ctx context.Context) error {
+	payloads, err := s.db.ZRangeByScore(ctx, s.delayedKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(toMillis(time.Now()), 10),
+		Count: s.batchSize,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range payloads {
+		removed, err := s.db.ZRem(ctx, s.delayedKey(), payload).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			continue
+		}
+		if err = s.add(ctx, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *service) add(ctx context.Context, payload string) error {
+	err := s.db.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{payloadField: payload},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("cannot add message to stream: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) decode(entry redis.XMessage) (*auth.Message, error) {
+	payload, ok := entry.Values[payloadField].(string)
+	if !ok {
+		return nil, fmt.Errorf("message %s has no payload", entry.ID)
+	}
+
+	var msg auth.Message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return nil, fmt.Errorf("cannot decode message %s: %w", entry.ID, err)
+	}
+	msg.ReceiptID = entry.ID
+
+	return &msg, nil
+}
+
+// wait pauses reading after a failure to avoid retrying in a tight loop.
+func (s *service) wait(ctx context.Context) {
+	select {
+	case <-time.After(s.blockTimeout):
+	case <-ctx.Done():
+	}
+}
+
+func (s *service) logError(ctx context.Context, message string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	level.Error(s.logger).Log(
+		"source", "MessageRepository.Recent",
+		"message", message,
+		"error", err,
+	)
+}
+
+func (s *service) delayedKey() string {
+	return fmt.Sprintf("%s_delayed", s.stream)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,163 @@
+package msgstream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func newStream() string {
+	return fmt.Sprintf("messages-%v", time.Now().UnixNano())
+}
+
+func newMessage() *auth.Message {
+	return &auth.Message{
+		Type:      auth.OTPLogin,
+		Delivery:  auth.Email,
+		Content:   "Your code is 123456",
+		Address:   "jane@example.com",
+		ExpiresAt: time.Now().Add(time.Minute).Round(0).UTC(),
+	}
+}
+
+func receive(t *testing.T, msgc <-chan *auth.Message) *auth.Message {
+	select {
+	case msg := <-msgc:
+		return msg
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for message")
+	}
+	return nil
+}
+
+func TestMsgStream_PublishAndAck(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := newStream()
+	svc := NewService(WithDB(db), WithStream(stream))
+
+	msg := newMessage()
+	if err = svc.Publish(ctx, msg); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	msgc, _ := svc.Recent(ctx)
+	received := receive(t, msgc)
+	if received.ReceiptID == "" {
+		t.Error("received message should have a receipt ID")
+	}
+	if !cmp.Equal(received, msg, cmpopts.IgnoreFields(auth.Message{}, "ReceiptID")) {
+		t.Error("received message does not match", cmp.Diff(received, msg))
+	}
+
+	if err = svc.Ack(ctx, received); err != nil {
+		t.Fatal("failed to acknowledge message:", err)
+	}
+
+	if n := db.XLen(ctx, stream).Val(); n != 0 {
+		t.Errorf("acknowledged message should be removed, found %v", n)
+	}
+}
+
+func TestMsgStream_ReclaimsPendingMessage(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	stream := newStream()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	first := NewService(WithDB(db), WithStream(stream), WithConsumer("first"))
+	if err = first.Publish(ctx, newMessage()); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	msgc, _ := first.Recent(ctx)
+	abandoned := receive(t, msgc)
+	cancel()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	second := NewService(
+		WithDB(db),
+		WithStream(stream),
+		WithConsumer("second"),
+		WithClaimIdle(time.Millisecond),
+	)
+	time.Sleep(time.Millisecond * 10)
+
+	msgc, _ = second.Recent(ctx)
+	reclaimed := receive(t, msgc)
+	if reclaimed.ReceiptID != abandoned.ReceiptID {
+		t.Errorf("incorrect message reclaimed, want %s got %s", abandoned.ReceiptID, reclaimed.ReceiptID)
+	}
+}
+
+func TestMsgStream_DelaysRetry(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	stream := newStream()
+	s := &service{db: db, stream: stream, batchSize: defaultBatchSize}
+
+	msg := newMessage()
+	msg.DeliveryAttempts = 1
+	if err = s.Publish(ctx, msg); err != nil {
+		t.Fatal("failed to publish message:", err)
+	}
+
+	if n := db.ZCard(ctx, s.delayedKey()).Val(); n != 1 {
+		t.Errorf("retried message should be delayed, found %v", n)
+	}
+
+	// Delayed messages are promoted only once they are due.
+	if err = s.promote(ctx); err != nil {
+		t.Fatal("failed to promote messages:", err)
+	}
+	if n := db.XLen(ctx, stream).Val(); n != 0 {
+		t.Errorf("message should not be promoted before delay, found %v", n)
+	}
+
+	err = db.ZAdd(ctx, s.delayedKey(), &redis.Z{Score: 0, Member: "{}"}).Err()
+	if err != nil {
+		t.Fatal("failed to schedule message:", err)
+	}
+	if err = s.promote(ctx); err != nil {
+		t.Fatal("failed to promote messages:", err)
+	}
+	if n := db.XLen(ctx, stream).Val(); n != 1 {
+		t.Errorf("due message should be promoted, found %v", n)
+	}
+}
+
+func TestMsgStream_RejectsExpiredMessage(t *testing.T) {
+	svc := NewService()
+	msg := newMessage()
+	msg.ExpiresAt = time.Now().Add(-time.Minute)
+
+	if err := svc.Publish(context.Background(), msg); err == nil {
+		t.Error("expired message should not be published")
+	}
+}
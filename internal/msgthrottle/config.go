@@ -0,0 +1,115 @@
+package msgthrottle
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	defaultAddressMax    = 10
+	defaultAddressWindow = time.Hour
+	defaultPrefixLength  = 7
+	defaultPrefixWindow  = time.Hour
+	defaultCountryWindow = time.Hour
+)
+
+// NewService returns a new MessageThrottle. Only the per address limit
+// is enabled by default.
+func NewService(options ...ConfigOption) auth.MessageThrottle {
+	s := service{
+		logger:       log.NewNopLogger(),
+		addressLimit: limit{max: defaultAddressMax, window: defaultAddressWindow},
+		prefixLength: defaultPrefixLength,
+		prefixLimit:  limit{window: defaultPrefixWindow},
+		countryLimit: limit{window: defaultCountryWindow},
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithDB configures the service with a redis DB.
+func WithDB(db rediser) ConfigOption {
+	return func(s *service) {
+		s.db = db
+	}
+}
+
+// WithAddressLimit configures the number of messages sent to an
+// email address or phone number within a window. A max of 0
+// disables the limit.
+func WithAddressLimit(max int64, window time.Duration) ConfigOption {
+	return func(s *service) {
+		s.addressLimit = limit{max: max, window: window}
+	}
+}
+
+// WithPrefixLimit configures the number of messages sent to phone numbers
+// sharing their first digits, including the country calling code,
+// within a window. A max of 0 disables the limit.
+func WithPrefixLimit(length int, max int64, window time.Duration) ConfigOption {
+	return func(s *service) {
+		s.prefixLength = length
+		s.prefixLimit = limit{max: max, window: window}
+	}
+}
+
+// WithCountryLimit configures the number of messages sent to phone numbers
+// of a single country within a window. A max of 0 disables the limit.
+func WithCountryLimit(max int64, window time.Duration) ConfigOption {
+	return func(s *service) {
+		s.countryLimit = limit{max: max, window: window}
+	}
+}
+
+// WithAllowedCountries restricts messages to phone numbers of the listed
+// ISO 3166-1 alpha-2 country codes. All countries are allowed if the
+// list is empty.
+func WithAllowedCountries(countries []string) ConfigOption {
+	return func(s *service) {
+		s.allowedCountries = countrySet(countries)
+	}
+}
+
+// WithDeniedCountries rejects messages to phone numbers of the listed
+// ISO 3166-1 alpha-2 country codes.
+func WithDeniedCountries(countries []string) ConfigOption {
+	return func(s *service) {
+		s.deniedCountries = countrySet(countries)
+	}
+}
+
+// WithDailySMSBudget configures the number of SMS and voice calls sent
+// to all phone numbers in a UTC day. A budget of 0 disables the limit.
+func WithDailySMSBudget(max int64) ConfigOption {
+	return func(s *service) {
+		s.dailySMSBudget = max
+	}
+}
+
+func countrySet(countries []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, c := range countries {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			set[c] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,171 @@
+// Package msgthrottle limits outgoing messages to protect against
+// abuse such as SMS pumping.
+package msgthrottle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-redis/redis/v8"
+	"github.com/nyaruka/phonenumbers"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// rediser is a minimal interface for go-redis
+type rediser interface {
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+}
+
+// limit is the maximum number of messages sent within a window.
+type limit struct {
+	max    int64
+	window time.Duration
+}
+
+// counter is a limit applied to a single key.
+type counter struct {
+	name  string
+	key   string
+	limit limit
+}
+
+// service is an implementation of auth.MessageThrottle backed by redis.
+// Messages are counted in fixed windows per destination address, and
+// for SMS and voice calls, per phone number prefix and country. Messages
+// to phone numbers may additionally be restricted by country and capped
+// by a daily budget across all numbers.
+type service struct {
+	logger       log.Logger
+	db           rediser
+	addressLimit limit
+	// prefixLength is the number of leading digits of an E.164 phone
+	// number, including the country calling code, used to group
+	// numbers of the same range.
+	prefixLength     int
+	prefixLimit      limit
+	countryLimit     limit
+	allowedCountries map[string]bool
+	deniedCountries  map[string]bool
+	dailySMSBudget   int64
+}
+
+// Allow records an outgoing message and returns an error if it
+// exceeds a send limit or its destination is not permitted.
+func (s *service) Allow(ctx context.Context, msg *auth.Message) error {
+	now := time.Now().UTC()
+
+	if !msg.Delivery.IsPhone() {
+		return s.incr(ctx, now, []counter{
+			{name: "address", key: strings.ToLower(msg.Address), limit: s.addressLimit},
+		})
+	}
+
+	num, err := phonenumbers.Parse(msg.Address, "")
+	if err != nil {
+		return auth.ErrInvalidField("phone number is invalid")
+	}
+
+	country := phonenumbers.GetRegionCodeForNumber(num)
+	if !s.isCountryAllowed(country) {
+		level.Info(s.logger).Log(
+			"source", "msgthrottle.Allow",
+			"message", "phone message rejected for country",
+			"country", country,
+		)
+		return auth.ErrBadRequest("delivery is not supported for this phone number")
+	}
+
+	phone := phonenumbers.Format(num, phonenumbers.E164)
+	prefix := strings.TrimPrefix(phone, "+")
+	if len(prefix) > s.prefixLength {
+		prefix = prefix[:s.prefixLength]
+	}
+
+	err = s.incr(ctx, now, []counter{
+		{name: "address", key: phone, limit: s.addressLimit},
+		{name: "prefix", key: prefix, limit: s.prefixLimit},
+		{name: "country", key: country, limit: s.countryLimit},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The budget is only spent by messages within all other limits
+	// so that a throttled sender cannot exhaust it.
+	if s.dailySMSBudget <= 0 {
+		return nil
+	}
+
+	return s.incr(ctx, now, []counter{{
+		name:  "daily_sms_budget",
+		key:   "sms",
+		limit: limit{max: s.dailySMSBudget, window: time.Hour * 24},
+	}})
+}
+
+// incr increments each enabled counter and returns an error if any
+// counter exceeds its limit.
+func (s *service) incr(ctx context.Context, now time.Time, counters []counter) error {
+	var enabled []counter
+	for _, c := range counters {
+		if c.limit.max > 0 && c.limit.window > 0 {
+			enabled = append(enabled, c)
+		}
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	incrs := make([]*redis.IntCmd, len(enabled))
+	_, err := s.db.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, c := range enabled {
+			key := counterKey(c, now)
+			incrs[i] = pipe.Incr(ctx, key)
+			pipe.Expire(ctx, key, c.limit.window)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to increment counter: %w", err)
+	}
+
+	for i, c := range enabled {
+		if incrs[i].Val() <= c.limit.max {
+			continue
+		}
+
+		level.Warn(s.logger).Log(
+			"source", "msgthrottle.Allow",
+			"message", "message limit exceeded",
+			"limit", c.name,
+			"max", c.limit.max,
+			"window", c.limit.window,
+		)
+		if c.name == "daily_sms_budget" {
+			return auth.ErrThrottle("phone delivery is temporarily unavailable, try again later")
+		}
+		return auth.ErrThrottle("too many messages sent, try again later")
+	}
+
+	return nil
+}
+
+func (s *service) isCountryAllowed(country string) bool {
+	if s.deniedCountries[country] {
+		return false
+	}
+
+	return len(s.allowedCountries) == 0 || s.allowedCountries[country]
+}
+
+// counterKey returns the key of a counter in its current window.
+// Daily windows are aligned to UTC midnight.
+func counterKey(c counter, now time.Time) string {
+	window := now.UnixNano() / int64(c.limit.window)
+	return fmt.Sprintf("msgthrottle:%s:%s:%v", c.name, c.key, window)
+}
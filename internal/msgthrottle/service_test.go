@@ -0,0 +1,283 @@
+package msgthrottle
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+// newPhonePrefix returns a random prefix of a US phone number
+// missing its last digit.
+func newPhonePrefix() string {
+	rand.Seed(time.Now().UnixNano())
+	return fmt.Sprintf("+1212%06d", rand.Intn(1000000))
+}
+
+func newEmail() string {
+	return fmt.Sprintf("jane-%v@example.com", time.Now().UnixNano())
+}
+
+func sms(address string) *auth.Message {
+	return &auth.Message{Delivery: auth.Phone, Address: address}
+}
+
+func TestMsgThrottle_AddressLimit(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	svc := NewService(
+		WithDB(db),
+		WithAddressLimit(2, time.Minute),
+	)
+
+	ctx := context.Background()
+	email := newEmail()
+	phone := newPhonePrefix() + "0"
+
+	tt := []struct {
+		name    string
+		msg     *auth.Message
+		errCode auth.ErrCode
+	}{
+		{
+			name:    "First email",
+			msg:     &auth.Message{Delivery: auth.Email, Address: email},
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Second email with different case",
+			msg:     &auth.Message{Delivery: auth.Email, Address: strings.ToUpper(email)},
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Third email is throttled",
+			msg:     &auth.Message{Delivery: auth.Email, Address: email},
+			errCode: auth.EThrottle,
+		},
+		{
+			name:    "First SMS",
+			msg:     sms(phone),
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Second SMS",
+			msg:     sms(phone),
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Third SMS is throttled",
+			msg:     sms(phone),
+			errCode: auth.EThrottle,
+		},
+		{
+			name:    "Voice call shares phone limit",
+			msg:     &auth.Message{Delivery: auth.Voice, Address: phone},
+			errCode: auth.EThrottle,
+		},
+	}
+
+	for _, tc := range tt {
+		err = svc.Allow(ctx, tc.msg)
+		if !cmp.Equal(auth.ErrorCode(err), tc.errCode) {
+			t.Errorf("%s: error code does not match %s", tc.name, cmp.Diff(
+				auth.ErrorCode(err), tc.errCode,
+			))
+		}
+	}
+}
+
+func TestMsgThrottle_PrefixLimit(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	svc := NewService(
+		WithDB(db),
+		WithPrefixLimit(10, 3, time.Minute),
+	)
+
+	ctx := context.Background()
+	prefix := newPhonePrefix()
+
+	for i := 0; i < 3; i++ {
+		if err = svc.Allow(ctx, sms(fmt.Sprintf("%s%v", prefix, i))); err != nil {
+			t.Fatalf("number %v: expected nil error, received: %v", i, err)
+		}
+	}
+
+	err = svc.Allow(ctx, sms(prefix+"9"))
+	if !cmp.Equal(auth.ErrorCode(err), auth.EThrottle) {
+		t.Error("number range should be throttled, received:", err)
+	}
+
+	// Email is not grouped by prefix.
+	err = svc.Allow(ctx, &auth.Message{Delivery: auth.Email, Address: newEmail()})
+	if err != nil {
+		t.Error("expected nil error, received:", err)
+	}
+}
+
+func TestMsgThrottle_Countries(t *testing.T) {
+	tt := []struct {
+		name    string
+		options []ConfigOption
+		phone   string
+		errCode auth.ErrCode
+	}{
+		{
+			name:    "All countries allowed by default",
+			options: nil,
+			phone:   "+6594867353",
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Allowed country",
+			options: []ConfigOption{WithAllowedCountries([]string{"sg", "US"})},
+			phone:   "+6594867353",
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Country missing from allow list",
+			options: []ConfigOption{WithAllowedCountries([]string{"US"})},
+			phone:   "+6594867353",
+			errCode: auth.EBadRequest,
+		},
+		{
+			name:    "Denied country",
+			options: []ConfigOption{WithDeniedCountries([]string{"SG"})},
+			phone:   "+6594867353",
+			errCode: auth.EBadRequest,
+		},
+		{
+			name: "Denied country takes precedence",
+			options: []ConfigOption{
+				WithAllowedCountries([]string{"SG"}),
+				WithDeniedCountries([]string{"SG"}),
+			},
+			phone:   "+6594867353",
+			errCode: auth.EBadRequest,
+		},
+		{
+			name:    "Invalid phone number",
+			options: nil,
+			phone:   "94867353",
+			errCode: auth.EInvalidField,
+		},
+	}
+
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]ConfigOption{WithDB(db), WithAddressLimit(0, 0)}, tc.options...)
+			svc := NewService(options...)
+
+			err := svc.Allow(context.Background(), sms(tc.phone))
+			if !cmp.Equal(auth.ErrorCode(err), tc.errCode) {
+				t.Error("error code does not match", cmp.Diff(
+					auth.ErrorCode(err), tc.errCode,
+				))
+			}
+		})
+	}
+}
+
+func TestMsgThrottle_CountryLimit(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	c := counter{name: "country", key: "SG", limit: limit{max: 2, window: time.Hour}}
+	if err = db.Del(ctx, counterKey(c, time.Now().UTC())).Err(); err != nil {
+		t.Fatal("failed to reset counter:", err)
+	}
+
+	svc := NewService(
+		WithDB(db),
+		WithAddressLimit(0, 0),
+		WithCountryLimit(2, time.Hour),
+	)
+
+	for i, phone := range []string{"+6594867353", "+6594867354"} {
+		if err = svc.Allow(ctx, sms(phone)); err != nil {
+			t.Fatalf("number %v: expected nil error, received: %v", i, err)
+		}
+	}
+
+	err = svc.Allow(ctx, sms("+6594867355"))
+	if !cmp.Equal(auth.ErrorCode(err), auth.EThrottle) {
+		t.Error("country should be throttled, received:", err)
+	}
+
+	if err = svc.Allow(ctx, sms(newPhonePrefix()+"0")); err != nil {
+		t.Error("other countries should not be throttled, received:", err)
+	}
+}
+
+func TestMsgThrottle_DailySMSBudget(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	c := counter{name: "daily_sms_budget", key: "sms", limit: limit{max: 2, window: time.Hour * 24}}
+	if err = db.Del(ctx, counterKey(c, time.Now().UTC())).Err(); err != nil {
+		t.Fatal("failed to reset counter:", err)
+	}
+
+	svc := NewService(
+		WithDB(db),
+		WithAddressLimit(1, time.Minute),
+		WithDailySMSBudget(2),
+	)
+
+	phone := newPhonePrefix() + "0"
+	if err = svc.Allow(ctx, sms(phone)); err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+
+	// Messages rejected by another limit do not spend the budget.
+	for i := 0; i < 3; i++ {
+		err = svc.Allow(ctx, sms(phone))
+		if !cmp.Equal(auth.ErrorCode(err), auth.EThrottle) {
+			t.Fatal("address should be throttled, received:", err)
+		}
+	}
+
+	if err = svc.Allow(ctx, sms(newPhonePrefix()+"1")); err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+
+	err = svc.Allow(ctx, sms(newPhonePrefix()+"2"))
+	if !cmp.Equal(auth.ErrorCode(err), auth.EThrottle) {
+		t.Error("budget should be exhausted, received:", err)
+	}
+
+	// Email is not counted against the SMS budget.
+	err = svc.Allow(ctx, &auth.Message{Delivery: auth.Email, Address: newEmail()})
+	if err != nil {
+		t.Error("expected nil error, received:", err)
+	}
+}
@@ -1,17 +1,43 @@
 package otp
 
 import (
+	"strings"
+	"time"
+
+	otpLib "github.com/pquerna/otp"
+
 	auth "github.com/fmitra/authenticator"
 )
 
 const (
 	defaultLength = 6
+	defaultExpiry = time.Minute * 5
+)
+
+const (
+	// NumericAlphabet generates codes of digits only.
+	NumericAlphabet = "0123456789"
+	// AlphanumericAlphabet generates codes of digits and uppercase
+	// letters, excluding characters that are easily confused when
+	// read aloud or written down.
+	AlphanumericAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
 )
 
 // NewOTP returns a new OTP validator.
 func NewOTP(options ...ConfigOption) auth.OTPService {
 	s := OTP{
-		codeLength: defaultLength,
+		codeLength:   defaultLength,
+		codeAlphabet: NumericAlphabet,
+		codeExpiry:   defaultExpiry,
+		typeExpiry:   make(map[auth.MessageType]time.Duration),
+		// Defaults match the parameters assumed by most
+		// authenticator apps.
+		totpAlgorithm:    otpLib.AlgorithmSHA1,
+		totpDigits:       otpLib.DigitsSix,
+		totpPeriod:       defaultTOTPPeriod,
+		totpSkew:         defaultTOTPSkew,
+		hotpLookAhead:    defaultHOTPLookAhead,
+		hotpResyncWindow: defaultHOTPResyncWindow,
 	}
 
 	for _, opt := range options {
@@ -32,6 +58,41 @@ func WithCodeLength(length int) ConfigOption {
 	}
 }
 
+// WithCodeAlphabet configures the service with the characters
+// used for random code generation. Codes are case insensitive, so
+// letters in the alphabet are converted to uppercase.
+func WithCodeAlphabet(alphabet string) ConfigOption {
+	return func(s *OTP) {
+		s.codeAlphabet = strings.ToUpper(alphabet)
+	}
+}
+
+// WithCodeExpiry configures the service with a default expiry
+// time for random codes.
+func WithCodeExpiry(expiry time.Duration) ConfigOption {
+	return func(s *OTP) {
+		s.codeExpiry = expiry
+	}
+}
+
+// WithTypeExpiry configures the service with an expiry time for
+// codes delivered in a specific message type, overriding the
+// default expiry.
+func WithTypeExpiry(msgType auth.MessageType, expiry time.Duration) ConfigOption {
+	return func(s *OTP) {
+		s.typeExpiry[msgType] = expiry
+	}
+}
+
+// WithMaxAttempts configures the service with a maximum number of
+// incorrect submissions of a code before it is no longer accepted.
+// Attempts are not limited by default.
+func WithMaxAttempts(max int64) ConfigOption {
+	return func(s *OTP) {
+		s.maxAttempts = max
+	}
+}
+
 // WithIssuer configures the service with a TOTP issuing
 // domain.
 func WithIssuer(issuer string) ConfigOption {
@@ -40,6 +101,59 @@ func WithIssuer(issuer string) ConfigOption {
 	}
 }
 
+// WithTOTPAlgorithm configures the service with the hash algorithm
+// of new TOTP secrets.
+func WithTOTPAlgorithm(algorithm otpLib.Algorithm) ConfigOption {
+	return func(s *OTP) {
+		s.totpAlgorithm = algorithm
+	}
+}
+
+// WithTOTPDigits configures the service with the number of digits
+// in codes of new TOTP secrets.
+func WithTOTPDigits(digits int) ConfigOption {
+	return func(s *OTP) {
+		s.totpDigits = otpLib.Digits(digits)
+	}
+}
+
+// WithTOTPPeriod configures the service with the time a code of a
+// new TOTP secret is valid for. Periods are rounded down to the
+// second.
+func WithTOTPPeriod(period time.Duration) ConfigOption {
+	return func(s *OTP) {
+		s.totpPeriod = uint(period / time.Second)
+	}
+}
+
+// WithTOTPSkew configures the service with the number of time steps
+// before or after the current time in which a TOTP code is accepted,
+// allowing for clock drift on the user's device.
+func WithTOTPSkew(skew uint) ConfigOption {
+	return func(s *OTP) {
+		s.totpSkew = skew
+	}
+}
+
+// WithHOTPLookAhead configures the service with the number of codes
+// after the expected code of a HOTP token that are accepted, allowing
+// for button presses without a login.
+func WithHOTPLookAhead(n uint) ConfigOption {
+	return func(s *OTP) {
+		s.hotpLookAhead = n
+	}
+}
+
+// WithHOTPResyncWindow configures the service with the number of codes
+// after the expected code of a HOTP token that are accepted once the
+// user confirms with the following code. Windows no larger than the
+// look-ahead window disable resynchronization.
+func WithHOTPResyncWindow(n uint) ConfigOption {
+	return func(s *OTP) {
+		s.hotpResyncWindow = n
+	}
+}
+
 // WithSecret sets a new versioned Secret on the client.
 func WithSecret(x Secret) ConfigOption {
 	return func(s *OTP) {
@@ -0,0 +1,184 @@
+package otp
+
+import (
+	"context"
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	otpLib "github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	defaultHOTPLookAhead    = 10
+	defaultHOTPResyncWindow = 100
+	// hotpResyncExpiry is the time a user has to submit the code
+	// following a code outside the look-ahead window.
+	hotpResyncExpiry = time.Minute * 5
+)
+
+// hotpKey is the secret of a HOTP hardware token and the
+// parameters used to generate its codes.
+type hotpKey struct {
+	secret string
+	digits otpLib.Digits
+	serial string
+}
+
+// String encodes the key as its base32 secret followed by its
+// parameters in a query string, e.g. `GEZDGNBVGY3TQOJQ?digits=6&serial=123`.
+func (k *hotpKey) String() string {
+	v := url.Values{}
+	v.Set("digits", k.digits.String())
+	v.Set("serial", k.serial)
+
+	return k.secret + "?" + v.Encode()
+}
+
+// match returns the counter of the first code matching a passcode
+// from a counter up to a number of codes ahead.
+func (k *hotpKey) match(passcode string, from, count uint64) (uint64, bool) {
+	opts := hotp.ValidateOpts{
+		Digits:    k.digits,
+		Algorithm: otpLib.AlgorithmSHA1,
+	}
+
+	for counter := from; counter < from+count; counter++ {
+		ok, err := hotp.ValidateCustom(passcode, counter, k.secret, opts)
+		if err == nil && ok {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+func parseHOTPKey(s string) (*hotpKey, error) {
+	i := strings.Index(s, "?")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid HOTP key")
+	}
+
+	v, err := url.ParseQuery(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid HOTP parameters: %w", err)
+	}
+
+	digits, err := strconv.Atoi(v.Get("digits"))
+	if err != nil || digits < 1 {
+		return nil, fmt.Errorf("invalid HOTP digits %q", v.Get("digits"))
+	}
+
+	return &hotpKey{
+		secret: s[:i],
+		digits: otpLib.Digits(digits),
+		serial: v.Get("serial"),
+	}, nil
+}
+
+// HOTPSecret encrypts the seed of a HOTP hardware token for storage
+// on a User. Tokens are expected to use the SHA1 algorithm defined by
+// RFC 4226.
+func (o *OTP) HOTPSecret(seed *auth.HOTPSeed) (string, error) {
+	if len(seed.Secret) == 0 {
+		return "", fmt.Errorf("HOTP seed %s has no secret", seed.Serial)
+	}
+	if seed.Digits != 6 && seed.Digits != 8 {
+		return "", fmt.Errorf("HOTP seed %s must have 6 or 8 digits", seed.Serial)
+	}
+
+	k := hotpKey{
+		secret: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(seed.Secret),
+		digits: otpLib.Digits(seed.Digits),
+		serial: seed.Serial,
+	}
+	encryptedKey, err := o.encrypt(k.String())
+	if err != nil {
+		return "", fmt.Errorf("cannot encrypt secret: %w", err)
+	}
+	return encryptedKey, nil
+}
+
+// ValidateHOTP checks if a User's HOTP code is valid and returns the
+// counter of the next code expected from the User's token.
+// Tokens generate a new code each time their button is pressed, so a
+// code is accepted within a look-ahead window of the stored counter.
+// A code further ahead, within the resync window, is only accepted
+// after the User submits the code following it, confirming the token
+// has drifted rather than a code being guessed. The caller is expected
+// to store the returned counter, which rejects reuse of earlier codes.
+func (o *OTP) ValidateHOTP(ctx context.Context, user *auth.User, code string) (int64, error) {
+	s, err := o.decrypt(user.HOTPSecret)
+	if err != nil {
+		return 0, fmt.Errorf("cannot decrypt secret: %w", err)
+	}
+	key, err := parseHOTPKey(s)
+	if err != nil {
+		return 0, err
+	}
+
+	current := uint64(user.HOTPCounter)
+	if counter, ok := key.match(code, current, uint64(o.hotpLookAhead)+1); ok {
+		return int64(counter) + 1, nil
+	}
+
+	resyncKey := fmt.Sprintf("%s_hotp_resync", user.ID)
+	pending, err := o.db.Get(ctx, resyncKey).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("cannot get pending resync: %w", err)
+	}
+	if err == nil && pending >= user.HOTPCounter {
+		if _, ok := key.match(code, uint64(pending)+1, 1); ok {
+			if err = o.db.Del(ctx, resyncKey).Err(); err != nil {
+				return 0, fmt.Errorf("cannot clear pending resync: %w", err)
+			}
+			return pending + 2, nil
+		}
+	}
+
+	from := current + uint64(o.hotpLookAhead) + 1
+	if o.hotpResyncWindow > o.hotpLookAhead {
+		count := uint64(o.hotpResyncWindow - o.hotpLookAhead)
+		if counter, ok := key.match(code, from, count); ok {
+			err = o.db.Set(ctx, resyncKey, int64(counter), hotpResyncExpiry).Err()
+			if err != nil {
+				return 0, fmt.Errorf("cannot set pending resync: %w", err)
+			}
+			return 0, auth.ErrTokenResync("token is out of sync, submit the next code")
+		}
+	}
+
+	return 0, auth.ErrInvalidCode("incorrect code provided")
+}
+
+// VerifyHOTP validates a code from a User's HOTP token and advances the
+// stored counter past it. The counter is only advanced if it was not
+// changed by a concurrent request, so that each code is accepted once.
+func VerifyHOTP(ctx context.Context, o auth.OTPService, repo auth.UserRepository, user *auth.User, code string) error {
+	if !user.IsHOTPAllowed {
+		return auth.ErrBadRequest("HOTP is not enabled")
+	}
+
+	counter, err := o.ValidateHOTP(ctx, user, code)
+	if err != nil {
+		return err
+	}
+
+	ok, err := repo.UpdateHOTPCounter(ctx, user.ID, user.HOTPCounter, counter)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return auth.ErrInvalidCode("code is no longer valid")
+	}
+
+	user.HOTPCounter = counter
+	return nil
+}
@@ -0,0 +1,212 @@
+package otp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+// rfc4226Codes are the codes of the RFC 4226 test secret
+// `12345678901234567890` for counters 0 to 9.
+var rfc4226Codes = []string{
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489",
+}
+
+func TestOTPSvc_ValidateHOTP(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	svc := NewOTP(
+		WithDB(db),
+		WithSecret(Secret{Version: 1, Key: "secret-key"}),
+		WithHOTPLookAhead(2),
+		WithHOTPResyncWindow(8),
+	)
+	secret, err := svc.HOTPSecret(&auth.HOTPSeed{
+		Serial: "1234567",
+		Secret: []byte("12345678901234567890"),
+		Digits: 6,
+	})
+	if err != nil {
+		t.Fatal("failed to create secret:", err)
+	}
+
+	type attempt struct {
+		code    string
+		counter int64
+		errCode auth.ErrCode
+		errMsg  string
+	}
+
+	tt := []struct {
+		name     string
+		counter  int64
+		attempts []attempt
+	}{
+		{
+			name:     "Expected code",
+			counter:  0,
+			attempts: []attempt{{code: rfc4226Codes[0], counter: 1}},
+		},
+		{
+			name:     "Code within look-ahead window",
+			counter:  1,
+			attempts: []attempt{{code: rfc4226Codes[3], counter: 4}},
+		},
+		{
+			name:     "Code before counter",
+			counter:  5,
+			attempts: []attempt{{code: rfc4226Codes[4], errCode: auth.EInvalidCode, errMsg: "incorrect code provided"}},
+		},
+		{
+			name:    "Code within resync window",
+			counter: 0,
+			attempts: []attempt{
+				{code: rfc4226Codes[6], errCode: auth.ETokenResync, errMsg: "token is out of sync, submit the next code"},
+				{code: rfc4226Codes[7], counter: 8},
+			},
+		},
+		{
+			name:    "Resync requires consecutive codes",
+			counter: 0,
+			attempts: []attempt{
+				{code: rfc4226Codes[5], errCode: auth.ETokenResync, errMsg: "token is out of sync, submit the next code"},
+				{code: rfc4226Codes[7], errCode: auth.ETokenResync, errMsg: "token is out of sync, submit the next code"},
+				{code: rfc4226Codes[8], counter: 9},
+			},
+		},
+		{
+			name:     "Code outside resync window",
+			counter:  0,
+			attempts: []attempt{{code: rfc4226Codes[9], errCode: auth.EInvalidCode, errMsg: "incorrect code provided"}},
+		},
+	}
+
+	for i, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			user := &auth.User{
+				ID:          fmt.Sprintf("user-%v-%v", time.Now().UnixNano(), i),
+				HOTPSecret:  secret,
+				HOTPCounter: tc.counter,
+			}
+
+			for j, a := range tc.attempts {
+				counter, err := svc.ValidateHOTP(context.Background(), user, a.code)
+				var errMsg string
+				if err != nil {
+					errMsg = auth.DomainError(err).Message()
+				}
+				if errMsg != a.errMsg {
+					t.Fatalf("attempt %v: incorrect error, want %q got %q", j, a.errMsg, errMsg)
+				}
+				if err != nil && auth.ErrorCode(err) != a.errCode {
+					t.Errorf("attempt %v: incorrect error code, want %v got %v", j, a.errCode, auth.ErrorCode(err))
+				}
+				if err == nil {
+					if counter != a.counter {
+						t.Errorf("attempt %v: incorrect counter, want %v got %v", j, a.counter, counter)
+					}
+					user.HOTPCounter = counter
+				}
+			}
+		})
+	}
+}
+
+func TestOTPSvc_VerifyHOTP(t *testing.T) {
+	tt := []struct {
+		name           string
+		user           *auth.User
+		validateErr    error
+		isCounterSaved bool
+		counterCalls   int
+		counter        int64
+		errMsg         string
+	}{
+		{
+			name:         "HOTP not enabled",
+			user:         &auth.User{HOTPCounter: 5},
+			counterCalls: 0,
+			counter:      5,
+			errMsg:       "HOTP is not enabled",
+		},
+		{
+			name:         "Incorrect code",
+			user:         &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			validateErr:  auth.ErrInvalidCode("incorrect code provided"),
+			counterCalls: 0,
+			counter:      5,
+			errMsg:       "incorrect code provided",
+		},
+		{
+			name:           "Counter advanced concurrently",
+			user:           &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			isCounterSaved: false,
+			counterCalls:   1,
+			counter:        5,
+			errMsg:         "code is no longer valid",
+		},
+		{
+			name:           "Counter advanced",
+			user:           &auth.User{IsHOTPAllowed: true, HOTPCounter: 5},
+			isCounterSaved: true,
+			counterCalls:   1,
+			counter:        7,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			otpSvc := &test.OTPService{
+				ValidateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+					return 7, tc.validateErr
+				},
+			}
+			userRepo := &test.UserRepository{
+				UpdateHOTPCounterFn: func(userID string, current, counter int64) (bool, error) {
+					if current != 5 || counter != 7 {
+						t.Errorf("incorrect counter swap, want 5 to 7 got %v to %v", current, counter)
+					}
+					return tc.isCounterSaved, nil
+				},
+			}
+
+			err := VerifyHOTP(context.Background(), otpSvc, userRepo, tc.user, "123456")
+			var errMsg string
+			if err != nil {
+				errMsg = auth.DomainError(err).Message()
+			}
+			if errMsg != tc.errMsg {
+				t.Errorf("incorrect error, want %q got %q", tc.errMsg, errMsg)
+			}
+			if userRepo.Calls.UpdateHOTPCounter != tc.counterCalls {
+				t.Errorf("incorrect UserRepository.UpdateHOTPCounter() call count, want %v got %v",
+					tc.counterCalls, userRepo.Calls.UpdateHOTPCounter)
+			}
+			if tc.user.HOTPCounter != tc.counter {
+				t.Errorf("incorrect user counter, want %v got %v", tc.counter, tc.user.HOTPCounter)
+			}
+		})
+	}
+}
+
+func TestOTPSvc_HOTPSecretDigits(t *testing.T) {
+	svc := NewOTP(WithSecret(Secret{Version: 1, Key: "secret-key"}))
+
+	_, err := svc.HOTPSecret(&auth.HOTPSeed{
+		Serial: "1234567",
+		Secret: []byte("12345678901234567890"),
+		Digits: 7,
+	})
+	if err == nil {
+		t.Error("expected error for unsupported digits, received nil")
+	}
+}
@@ -0,0 +1,268 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const defaultHOTPDigits = 6
+
+// pskcContainer is a Portable Symmetric Key Container (RFC 6030)
+// as provided by hardware token vendors.
+type pskcContainer struct {
+	XMLName   xml.Name `xml:"KeyContainer"`
+	MACMethod *struct {
+		Algorithm string             `xml:"Algorithm,attr"`
+		MACKey    pskcEncryptedValue `xml:"MACKey"`
+	} `xml:"MACMethod"`
+	KeyPackages []struct {
+		SerialNo string  `xml:"DeviceInfo>SerialNo"`
+		Key      pskcKey `xml:"Key"`
+	} `xml:"KeyPackage"`
+}
+
+type pskcKey struct {
+	ID             string `xml:"Id,attr"`
+	Algorithm      string `xml:"Algorithm,attr"`
+	ResponseFormat struct {
+		Length   int    `xml:"Length,attr"`
+		Encoding string `xml:"Encoding,attr"`
+	} `xml:"AlgorithmParameters>ResponseFormat"`
+	Secret  pskcValue `xml:"Data>Secret"`
+	Counter pskcValue `xml:"Data>Counter"`
+}
+
+type pskcValue struct {
+	PlainValue     string              `xml:"PlainValue"`
+	EncryptedValue *pskcEncryptedValue `xml:"EncryptedValue"`
+	ValueMAC       string              `xml:"ValueMAC"`
+}
+
+type pskcEncryptedValue struct {
+	EncryptionMethod struct {
+		Algorithm string `xml:"Algorithm,attr"`
+	} `xml:"EncryptionMethod"`
+	CipherValue string `xml:"CipherData>CipherValue"`
+}
+
+// ParsePSKC returns the seeds of HOTP tokens in a PSKC file. Values
+// encrypted with a pre-shared AES key are decrypted with psk. If the
+// file specifies a MAC method, every encrypted value must carry a MAC.
+func ParsePSKC(r io.Reader, psk []byte) ([]*auth.HOTPSeed, error) {
+	var container pskcContainer
+	if err := xml.NewDecoder(r).Decode(&container); err != nil {
+		return nil, fmt.Errorf("invalid PSKC file: %w", err)
+	}
+
+	var (
+		macKey []byte
+		macFn  func() hash.Hash
+	)
+	if m := container.MACMethod; m != nil {
+		switch m.Algorithm {
+		case "http://www.w3.org/2000/09/xmldsig#hmac-sha1":
+			macFn = sha1.New
+		case "http://www.w3.org/2001/04/xmldsig-more#hmac-sha256":
+			macFn = sha256.New
+		default:
+			return nil, fmt.Errorf("unsupported PSKC MAC algorithm %q", m.Algorithm)
+		}
+
+		var err error
+		macKey, _, err = m.MACKey.decrypt(psk)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt PSKC MAC key: %w", err)
+		}
+	}
+
+	value := func(v pskcValue) ([]byte, error) {
+		if v.EncryptedValue == nil {
+			return base64.StdEncoding.DecodeString(strings.TrimSpace(v.PlainValue))
+		}
+
+		b, cipherValue, err := v.EncryptedValue.decrypt(psk)
+		if err != nil {
+			return nil, err
+		}
+		if macFn == nil {
+			return b, nil
+		}
+		if strings.TrimSpace(v.ValueMAC) == "" {
+			return nil, fmt.Errorf("value MAC is missing")
+		}
+
+		mac := hmac.New(macFn, macKey)
+		_, _ = mac.Write(cipherValue)
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.ValueMAC))
+		if err != nil || !hmac.Equal(mac.Sum(nil), expected) {
+			return nil, fmt.Errorf("value MAC does not match")
+		}
+		return b, nil
+	}
+
+	seeds := make([]*auth.HOTPSeed, 0, len(container.KeyPackages))
+	for _, p := range container.KeyPackages {
+		key := p.Key
+		if !strings.HasSuffix(strings.ToLower(key.Algorithm), "hotp") {
+			return nil, fmt.Errorf("key %s uses unsupported algorithm %q", key.ID, key.Algorithm)
+		}
+
+		seed := &auth.HOTPSeed{
+			Serial: p.SerialNo,
+			Digits: key.ResponseFormat.Length,
+		}
+		if seed.Serial == "" {
+			seed.Serial = key.ID
+		}
+		if seed.Digits == 0 {
+			seed.Digits = defaultHOTPDigits
+		}
+		if e := key.ResponseFormat.Encoding; e != "" && !strings.EqualFold(e, "DECIMAL") {
+			return nil, fmt.Errorf("key %s uses unsupported response encoding %q", key.ID, e)
+		}
+
+		var err error
+		seed.Secret, err = value(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret for key %s: %w", key.ID, err)
+		}
+
+		// Plain counters are decimal while encrypted counters are
+		// 8 byte big endian integers.
+		switch {
+		case key.Counter.EncryptedValue != nil:
+			b, err := value(key.Counter)
+			if err != nil || len(b) != 8 {
+				return nil, fmt.Errorf("invalid counter for key %s", key.ID)
+			}
+			seed.Counter = int64(binary.BigEndian.Uint64(b))
+		case key.Counter.PlainValue != "":
+			seed.Counter, err = strconv.ParseInt(strings.TrimSpace(key.Counter.PlainValue), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counter for key %s: %w", key.ID, err)
+			}
+		}
+
+		seeds = append(seeds, seed)
+	}
+
+	return seeds, nil
+}
+
+// decrypt decrypts a value encrypted with AES-CBC and a pre-shared key.
+// It returns the plain text and the cipher value, including its IV.
+func (v *pskcEncryptedValue) decrypt(psk []byte) ([]byte, []byte, error) {
+	if len(psk) == 0 {
+		return nil, nil, fmt.Errorf("value is encrypted, a pre-shared key is required")
+	}
+
+	var keyLength int
+	switch v.EncryptionMethod.Algorithm {
+	case "http://www.w3.org/2001/04/xmlenc#aes128-cbc":
+		keyLength = 16
+	case "http://www.w3.org/2001/04/xmlenc#aes192-cbc":
+		keyLength = 24
+	case "http://www.w3.org/2001/04/xmlenc#aes256-cbc":
+		keyLength = 32
+	default:
+		return nil, nil, fmt.Errorf("unsupported encryption algorithm %q", v.EncryptionMethod.Algorithm)
+	}
+	if len(psk) != keyLength {
+		return nil, nil, fmt.Errorf("pre-shared key must be %v bytes", keyLength)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.CipherValue))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cipher value: %w", err)
+	}
+	if len(data) < aes.BlockSize*2 || len(data)%aes.BlockSize != 0 {
+		return nil, nil, fmt.Errorf("invalid cipher value length")
+	}
+
+	block, err := aes.NewCipher(psk)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(b, data[aes.BlockSize:])
+
+	padding := int(b[len(b)-1])
+	if padding < 1 || padding > aes.BlockSize {
+		return nil, nil, fmt.Errorf("invalid padding, the pre-shared key may be incorrect")
+	}
+	for _, p := range b[len(b)-padding:] {
+		if int(p) != padding {
+			return nil, nil, fmt.Errorf("invalid padding, the pre-shared key may be incorrect")
+		}
+	}
+
+	return b[:len(b)-padding], data, nil
+}
+
+// ParseHOTPCSV returns the seeds of HOTP tokens in a CSV file. Each
+// row contains a token's serial number, its hex encoded secret and
+// optionally its counter and number of digits, e.g.
+// `1234567,3132333435363738393031323334353637383930,0,6`. A header row
+// starting with `serial` is skipped.
+func ParseHOTPCSV(r io.Reader) ([]*auth.HOTPSeed, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %w", err)
+	}
+
+	seeds := make([]*auth.HOTPSeed, 0, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "serial") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 4 {
+			return nil, fmt.Errorf("row %v must contain a serial, secret, counter and digits", i+1)
+		}
+
+		seed := &auth.HOTPSeed{
+			Serial: strings.TrimSpace(record[0]),
+			Digits: defaultHOTPDigits,
+		}
+		seed.Secret, err = hex.DecodeString(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("row %v has an invalid secret: %w", i+1, err)
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			seed.Counter, err = strconv.ParseInt(strings.TrimSpace(record[2]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %v has an invalid counter: %w", i+1, err)
+			}
+		}
+		if len(record) > 3 && strings.TrimSpace(record[3]) != "" {
+			seed.Digits, err = strconv.Atoi(strings.TrimSpace(record[3]))
+			if err != nil {
+				return nil, fmt.Errorf("row %v has invalid digits: %w", i+1, err)
+			}
+		}
+
+		seeds = append(seeds, seed)
+	}
+
+	return seeds, nil
+}
@@ -0,0 +1,224 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// pskcEncrypt encrypts a value with AES-128-CBC and a fixed IV
+// for use in a PSKC file.
+func pskcEncrypt(t *testing.T, key, value []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal("failed to create cipher:", err)
+	}
+
+	padding := aes.BlockSize - len(value)%aes.BlockSize
+	b := append(append([]byte{}, value...), []byte(strings.Repeat(string(rune(padding)), padding))...)
+
+	data := make([]byte, aes.BlockSize+len(b))
+	copy(data, "0123456789abcdef")
+	cipher.NewCBCEncrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], b)
+
+	return data
+}
+
+func TestOTPSvc_ParsePSKC(t *testing.T) {
+	psk := []byte("1234567890123456")
+	macKey := []byte("mac-key-mac-key-mac!")
+	secret := pskcEncrypt(t, psk, []byte("12345678901234567890"))
+	counter := pskcEncrypt(t, psk, []byte{0, 0, 0, 0, 0, 0, 0, 42})
+
+	valueMAC := func(data []byte) string {
+		mac := hmac.New(sha1.New, macKey)
+		_, _ = mac.Write(data)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	encrypted := func(data []byte) string {
+		return fmt.Sprintf(`<xenc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+			<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData>`,
+			base64.StdEncoding.EncodeToString(data))
+	}
+
+	plainFile := `<?xml version="1.0" encoding="UTF-8"?>
+		<KeyContainer Version="1.0" xmlns="urn:ietf:params:xml:ns:keyprov:pskc">
+			<KeyPackage>
+				<DeviceInfo><Manufacturer>Manufacturer</Manufacturer><SerialNo>987654321</SerialNo></DeviceInfo>
+				<Key Id="12345678" Algorithm="urn:ietf:params:xml:ns:keyprov:pskc:hotp">
+					<AlgorithmParameters><ResponseFormat Length="8" Encoding="DECIMAL"/></AlgorithmParameters>
+					<Data>
+						<Secret><PlainValue>MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=</PlainValue></Secret>
+						<Counter><PlainValue>7</PlainValue></Counter>
+					</Data>
+				</Key>
+			</KeyPackage>
+		</KeyContainer>`
+
+	encryptedFile := func(secretMAC string) string {
+		return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+			<KeyContainer Version="1.0" xmlns="urn:ietf:params:xml:ns:keyprov:pskc"
+				xmlns:xenc="http://www.w3.org/2001/04/xmlenc#">
+				<EncryptionKey><ds:KeyName xmlns:ds="http://www.w3.org/2000/09/xmldsig#">Pre-shared-key</ds:KeyName></EncryptionKey>
+				<MACMethod Algorithm="http://www.w3.org/2000/09/xmldsig#hmac-sha1">
+					<MACKey>%s</MACKey>
+				</MACMethod>
+				<KeyPackage>
+					<Key Id="12345678" Algorithm="urn:ietf:params:xml:ns:keyprov:pskc:hotp">
+						<Data>
+							<Secret><EncryptedValue>%s</EncryptedValue><ValueMAC>%s</ValueMAC></Secret>
+							<Counter><EncryptedValue>%s</EncryptedValue><ValueMAC>%s</ValueMAC></Counter>
+						</Data>
+					</Key>
+				</KeyPackage>
+			</KeyContainer>`,
+			encrypted(pskcEncrypt(t, psk, macKey)),
+			encrypted(secret), secretMAC,
+			encrypted(counter), valueMAC(counter),
+		)
+	}
+
+	tt := []struct {
+		name     string
+		file     string
+		psk      []byte
+		seeds    []*auth.HOTPSeed
+		hasError bool
+	}{
+		{
+			name: "Plain values",
+			file: plainFile,
+			psk:  nil,
+			seeds: []*auth.HOTPSeed{{
+				Serial:  "987654321",
+				Secret:  []byte("12345678901234567890"),
+				Counter: 7,
+				Digits:  8,
+			}},
+			hasError: false,
+		},
+		{
+			name: "Encrypted values",
+			file: encryptedFile(valueMAC(secret)),
+			psk:  psk,
+			seeds: []*auth.HOTPSeed{{
+				Serial:  "12345678",
+				Secret:  []byte("12345678901234567890"),
+				Counter: 42,
+				Digits:  6,
+			}},
+			hasError: false,
+		},
+		{
+			name:     "Missing pre-shared key",
+			file:     encryptedFile(valueMAC(secret)),
+			psk:      nil,
+			seeds:    nil,
+			hasError: true,
+		},
+		{
+			name:     "Incorrect value MAC",
+			file:     encryptedFile(valueMAC(counter)),
+			psk:      psk,
+			seeds:    nil,
+			hasError: true,
+		},
+		{
+			name:     "Missing value MAC",
+			file:     encryptedFile(""),
+			psk:      psk,
+			seeds:    nil,
+			hasError: true,
+		},
+		{
+			name:     "Unsupported algorithm",
+			file:     strings.Replace(plainFile, "pskc:hotp", "pskc:totp", 1),
+			psk:      nil,
+			seeds:    nil,
+			hasError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			seeds, err := ParsePSKC(strings.NewReader(tc.file), tc.psk)
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error, received:", err)
+			}
+			if tc.hasError && err == nil {
+				t.Error("expected error, received nil")
+			}
+			if !cmp.Equal(seeds, tc.seeds) {
+				t.Error("seeds do not match", cmp.Diff(seeds, tc.seeds))
+			}
+		})
+	}
+}
+
+func TestOTPSvc_ParseHOTPCSV(t *testing.T) {
+	tt := []struct {
+		name     string
+		file     string
+		seeds    []*auth.HOTPSeed
+		hasError bool
+	}{
+		{
+			name: "Rows with header and defaults",
+			file: "serial,secret,counter,digits\n" +
+				"# Issued to support staff\n" +
+				"1234567, 3132333435363738393031323334353637383930, 5, 8\n" +
+				"7654321,3132333435363738393031323334353637383930\n",
+			seeds: []*auth.HOTPSeed{
+				{
+					Serial:  "1234567",
+					Secret:  []byte("12345678901234567890"),
+					Counter: 5,
+					Digits:  8,
+				},
+				{
+					Serial:  "7654321",
+					Secret:  []byte("12345678901234567890"),
+					Counter: 0,
+					Digits:  6,
+				},
+			},
+			hasError: false,
+		},
+		{
+			name:     "Invalid secret",
+			file:     "1234567,not-hex\n",
+			seeds:    nil,
+			hasError: true,
+		},
+		{
+			name:     "Missing secret",
+			file:     "1234567\n",
+			seeds:    nil,
+			hasError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			seeds, err := ParseHOTPCSV(strings.NewReader(tc.file))
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error, received:", err)
+			}
+			if tc.hasError && err == nil {
+				t.Error("expected error, received nil")
+			}
+			if !cmp.Equal(seeds, tc.seeds) {
+				t.Error("seeds do not match", cmp.Diff(seeds, tc.seeds))
+			}
+		})
+	}
+}
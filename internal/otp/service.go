@@ -19,15 +19,36 @@ import (
 	"github.com/go-redis/redis/v8"
 	otpLib "github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/hkdf"
 
 	auth "github.com/fmitra/authenticator"
 	"github.com/fmitra/authenticator/internal/crypto"
 )
 
+const (
+	// recoveryCodeCount is the number of recovery codes in a set.
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the length of each half of a recovery
+	// code, e.g. `x7k2m-9qp4r`.
+	recoveryCodeLength = 5
+	// recoveryCodeAlphabet excludes characters that are easily
+	// confused when written down.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	// gcmScheme identifies secrets encrypted with AES-GCM.
+	gcmScheme = "gcm"
+	// gcmKeyInfo binds keys derived from a secret to TOTP
+	// secret encryption.
+	gcmKeyInfo = "authenticator totp secret"
+)
+
 // rediser is a minimal interface for go-redis
 type rediser interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+	ExpireAt(ctx context.Context, key string, tm time.Time) *redis.BoolCmd
 	Close() error
 }
 
@@ -50,19 +71,49 @@ type Hash struct {
 type OTP struct {
 	// codeLength is the length of a randomly generated code.
 	codeLength int
-	totpIssuer string
-	secrets    []Secret
-	db         rediser
+	// codeAlphabet contains the characters of a randomly generated code.
+	codeAlphabet string
+	// codeExpiry is the lifetime of a randomly generated code unless
+	// overridden for its message type in typeExpiry.
+	codeExpiry time.Duration
+	typeExpiry map[auth.MessageType]time.Duration
+	// maxAttempts is the number of times a code may be submitted
+	// before it is no longer accepted. Attempts are not limited
+	// if the value is 0.
+	maxAttempts   int64
+	totpIssuer    string
+	totpAlgorithm otpLib.Algorithm
+	totpDigits    otpLib.Digits
+	// totpPeriod is the number of seconds a TOTP code is valid for.
+	totpPeriod uint
+	// totpSkew is the number of time steps before or after the
+	// current time in which a TOTP code is accepted.
+	totpSkew uint
+	// hotpLookAhead is the number of codes after the expected code
+	// of a HOTP token that are accepted.
+	hotpLookAhead uint
+	// hotpResyncWindow is the number of codes after the expected
+	// code of a HOTP token that are accepted after confirming
+	// with the next code.
+	hotpResyncWindow uint
+	secrets          []Secret
+	db               rediser
 }
 
-// OTPCode creates a random code and hash.
-func (o *OTP) OTPCode(address string, method auth.DeliveryMethod) (code string, hash string, err error) {
-	c, err := crypto.String(o.codeLength, "0123456")
+// OTPCode creates a random code and hash. The code expires according
+// to the type of message it is delivered in.
+func (o *OTP) OTPCode(address string, method auth.DeliveryMethod, msgType auth.MessageType) (code string, hash string, err error) {
+	c, err := crypto.String(o.codeLength, o.codeAlphabet)
 	if err != nil {
 		return "", "", fmt.Errorf("cannot create random string: %w", err)
 	}
 
-	h, err := toOTPHash(c, address, method)
+	expiry, ok := o.typeExpiry[msgType]
+	if !ok {
+		expiry = o.codeExpiry
+	}
+
+	h, err := toOTPHash(c, address, method, expiry)
 	if err != nil {
 		return "", "", fmt.Errorf("cannot hash otp string: %w", err)
 	}
@@ -70,6 +121,22 @@ func (o *OTP) OTPCode(address string, method auth.DeliveryMethod) (code string,
 	return c, h, nil
 }
 
+// RecoveryCodes creates a set of random single use recovery codes.
+// Codes are returned in plain text and are expected to be hashed
+// before storage.
+func (o *OTP) RecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := crypto.String(recoveryCodeLength*2, recoveryCodeAlphabet)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create random string: %w", err)
+		}
+		codes[i] = fmt.Sprintf("%s-%s", c[:recoveryCodeLength], c[recoveryCodeLength:])
+	}
+
+	return codes, nil
+}
+
 // TOTPSecret assigns a TOTP secret for a user for use in code generation.
 // TOTP secrets are encrypted by a preconfigured secret key and decrypted
 // only during validation. Encrypted keys are versioned to assist with migrations
@@ -79,11 +146,23 @@ func (o *OTP) TOTPSecret(u *auth.User) (string, error) {
 	key, err := totp.Generate(totp.GenerateOpts{
 		Issuer:      o.totpIssuer,
 		AccountName: u.DefaultName(),
+		Period:      o.totpPeriod,
+		Digits:      o.totpDigits,
+		Algorithm:   o.totpAlgorithm,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate secret: %w", err)
 	}
-	encryptedKey, err := o.encrypt(key.Secret())
+
+	// Parameters are stored with the secret so that existing
+	// enrollments are unaffected by configuration changes.
+	k := totpKey{
+		secret:    key.Secret(),
+		algorithm: o.totpAlgorithm,
+		digits:    o.totpDigits,
+		period:    o.totpPeriod,
+	}
+	encryptedKey, err := o.encrypt(k.String())
 	if err != nil {
 		return "", fmt.Errorf("cannot encrypt secret: %w", err)
 	}
@@ -94,17 +173,17 @@ func (o *OTP) TOTPSecret(u *auth.User) (string, error) {
 // for TOTP code generation.
 func (o *OTP) TOTPQRString(u *auth.User) (string, error) {
 	// otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
-	secret, err := o.decrypt(u.TFASecret)
+	key, err := o.totpKey(u)
 	if err != nil {
 		return "", fmt.Errorf("failed get secret for QR string: %w", err)
 	}
 
 	v := url.Values{}
-	v.Set("secret", secret)
+	v.Set("secret", key.secret)
 	v.Set("issuer", o.totpIssuer)
-	v.Set("algorithm", otpLib.AlgorithmSHA1.String())
-	v.Set("period", "30")
-	v.Set("digits", "6")
+	v.Set("algorithm", key.algorithm.String())
+	v.Set("period", strconv.FormatUint(uint64(key.period), 10))
+	v.Set("digits", key.digits.String())
 	otpauth := url.URL{
 		Scheme:   "otpauth",
 		Host:     "totp",
@@ -115,8 +194,11 @@ func (o *OTP) TOTPQRString(u *auth.User) (string, error) {
 }
 
 // ValidateOTP checks if a User's OTP code is valid. User's may submit
-// a randomly generated code sent to them through email or SMS.
-func (o *OTP) ValidateOTP(code string, hash string) error {
+// a randomly generated code sent to them through email, SMS or a voice
+// call. Submissions are counted against the ID of the token the code
+// was issued with, and once the maximum number of attempts is reached
+// the code is no longer accepted and a new code must be requested.
+func (o *OTP) ValidateOTP(ctx context.Context, id, code, hash string) error {
 	otp, err := FromOTPHash(hash)
 	if err != nil {
 		return err
@@ -127,49 +209,112 @@ func (o *OTP) ValidateOTP(code string, hash string) error {
 		return auth.ErrInvalidCode("code is expired")
 	}
 
-	h, err := crypto.Hash(code)
+	attempts, err := o.attempt(ctx, id, otp)
+	if err != nil {
+		return err
+	}
+
+	if o.maxAttempts > 0 && attempts > o.maxAttempts {
+		return auth.ErrInvalidCode("too many incorrect attempts, request a new code")
+	}
+
+	h, err := crypto.Hash(strings.ToUpper(code))
 	if err != nil {
 		return auth.ErrInvalidCode("code submission failed")
 	}
 
 	if h != otp.CodeHash {
+		if o.maxAttempts > 0 && attempts == o.maxAttempts {
+			return auth.ErrInvalidCode("too many incorrect attempts, request a new code")
+		}
 		return auth.ErrInvalidCode("incorrect code provided")
 	}
 
 	return nil
 }
 
+// attempt records a submission of an OTP code and returns the number
+// of times the code has been submitted. Attempts are counted before
+// the code is checked so that concurrent submissions cannot exceed the
+// limit.
+func (o *OTP) attempt(ctx context.Context, id string, otp *Hash) (int64, error) {
+	if o.maxAttempts <= 0 {
+		return 0, nil
+	}
+
+	// A token ID may be carried over to tokens with new codes, so
+	// attempts are counted for each code issued with the token.
+	key := fmt.Sprintf("%s_otp_attempts_%s", id, otp.CodeHash)
+	attempts, err := o.db.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("cannot record code attempt: %w", err)
+	}
+
+	if err = o.db.ExpireAt(ctx, key, time.Unix(otp.ExpiresAt, 0)).Err(); err != nil {
+		return 0, fmt.Errorf("cannot set code attempt expiry: %w", err)
+	}
+
+	return attempts, nil
+}
+
 // ValidateTOTP checks if a User's TOTP is valid.
-// We first validate the TOTP against the user's secret key.
-// If the validation passes, we then check if the code has been
-// set in redis, indicating that it has been used in the past 30
-// seconds. Codes that have been validated are cached to prevent
-// immediate reuse.
+// We first validate the TOTP against the user's secret key, accepting
+// codes within the configured number of time steps of the current time.
+// If the validation passes, we then check the time step of the code
+// against the last step accepted for the user. Codes from the same or
+// an earlier step are rejected to prevent reuse.
 func (o *OTP) ValidateTOTP(ctx context.Context, user *auth.User, code string) error {
-	secret, err := o.decrypt(user.TFASecret)
+	key, err := o.totpKey(user)
 	if err != nil {
 		return fmt.Errorf("cannot decrypt secret: %w", err)
 	}
-	if !totp.Validate(code, secret) {
+
+	step, ok := key.step(code, time.Now(), o.totpSkew)
+	if !ok {
 		return auth.ErrInvalidCode("incorrect code provided")
 	}
 
-	key := fmt.Sprintf("%s_%s", user.ID, code)
+	// Steps are recorded by their start time so that they remain
+	// comparable if the user enrolls again with a different period.
+	stepAt := int64(step) * int64(key.period)
+	expiry := time.Duration(key.period) * time.Second * time.Duration(2*o.totpSkew+2)
+	stepKey := fmt.Sprintf("%s_totp_step", user.ID)
+
+	err = o.db.Watch(ctx, func(tx *redis.Tx) error {
+		lastAt, err := tx.Get(ctx, stepKey).Int64()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("cannot get last time step: %w", err)
+		}
+		if err == nil && stepAt <= lastAt {
+			return auth.ErrInvalidCode("code is no longer valid")
+		}
 
-	err = o.db.Get(ctx, key).Err()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, stepKey, stepAt, expiry)
+			return nil
+		})
+		return err
+	}, stepKey)
 
-	// Validated code has previously been used in the past 30 seconds
-	if err == nil {
+	// Another code for the user was accepted concurrently.
+	if err == redis.TxFailedErr {
 		return auth.ErrInvalidCode("code is no longer valid")
 	}
+	if err != nil && auth.DomainError(err) == nil {
+		return fmt.Errorf("failed to validate code: %w", err)
+	}
 
-	// No code found in redis, indicating the code is valid. Set it to the
-	// DB to prevent reuse.
-	if err == redis.Nil {
-		return o.db.Set(ctx, key, true, time.Second*30).Err()
+	return err
+}
+
+// totpKey returns the decrypted TOTP key of a user.
+func (o *OTP) totpKey(u *auth.User) (*totpKey, error) {
+	s, err := o.decrypt(u.TFASecret)
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("failed to vaidated code: %w", err)
+	return parseTOTPKey(s)
 }
 
 func (o *OTP) latestSecret() (Secret, error) {
@@ -203,46 +348,43 @@ func (o *OTP) secretByVersion(version int) (Secret, error) {
 	return secret, nil
 }
 
-// encrypt encrypts a string using the most recent versioned secret key
-// in this service and returns the value as a base64 encoded string
-// with a versioning prefix.
+// encrypt encrypts a string with AES-GCM using the most recent versioned
+// secret key in this service and returns the value as a base64 encoded
+// string prefixed by the key version and encryption scheme,
+// e.g. `2:gcm:<ciphertext>`.
 func (o *OTP) encrypt(s string) (string, error) {
 	secret, err := o.latestSecret()
 	if err != nil {
 		return "", err
 	}
 
-	key := sha256.New()
-	_, err = key.Write([]byte(secret.Key))
+	aead, err := gcmCipher(secret)
 	if err != nil {
-		return "", fmt.Errorf("cannot write secret: %w", err)
+		return "", err
 	}
 
-	block, err := aes.NewCipher(key.Sum(nil))
-	if err != nil {
-		return "", fmt.Errorf("failed create cipher block: %w", err)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to create nonce: %w", err)
 	}
 
-	ciphertext := make([]byte, aes.BlockSize+len(s))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", fmt.Errorf("failed to create cipher text: %w", err)
-	}
+	// The prefix is authenticated with the ciphertext so that a value
+	// cannot be decrypted under a different key version.
+	prefix := fmt.Sprintf("%v:%s", secret.Version, gcmScheme)
+	ciphertext := aead.Seal(nonce, nonce, []byte(s), []byte(prefix))
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(s))
-	return fmt.Sprintf("%v:%s",
-		secret.Version,
+	return fmt.Sprintf("%s:%s",
+		prefix,
 		base64.StdEncoding.EncodeToString(ciphertext),
 	), nil
 }
 
 // decrypt decrypts an encrypted string using a versioned secret.
+// Values encrypted with AES-CFB, which have no scheme in their
+// prefix, are decrypted for backwards compatibility.
 func (o *OTP) decrypt(encryptedTxt string) (string, error) {
-	v := strings.Split(encryptedTxt, ":")[0]
-	encryptedTxt = strings.TrimPrefix(encryptedTxt, fmt.Sprintf("%s:", v))
-
-	version, err := strconv.Atoi(v)
+	parts := strings.Split(encryptedTxt, ":")
+	version, err := strconv.Atoi(parts[0])
 	if err != nil {
 		return "", fmt.Errorf("failed to determine secret version: %w", err)
 	}
@@ -252,8 +394,84 @@ func (o *OTP) decrypt(encryptedTxt string) (string, error) {
 		return "", err
 	}
 
+	switch {
+	case len(parts) == 3 && parts[1] == gcmScheme:
+		return decryptGCM(secret, parts[0]+":"+parts[1], parts[2])
+	case len(parts) == 2:
+		return decryptCFB(secret, parts[1])
+	default:
+		return "", fmt.Errorf("unknown secret encryption format")
+	}
+}
+
+// ReencryptTOTPSecret encrypts a TOTP secret with AES-GCM and the
+// latest secret key. Secrets already encrypted this way are returned
+// unchanged.
+func (o *OTP) ReencryptTOTPSecret(encryptedTxt string) (string, error) {
+	secret, err := o.latestSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(encryptedTxt, fmt.Sprintf("%v:%s:", secret.Version, gcmScheme)) {
+		return encryptedTxt, nil
+	}
+
+	s, err := o.decrypt(encryptedTxt)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret: %w", err)
+	}
+
+	return o.encrypt(s)
+}
+
+// gcmCipher returns an AES-GCM cipher with a 256 bit key derived
+// from a versioned secret.
+func gcmCipher(secret Secret) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, []byte(secret.Key), nil, []byte(gcmKeyInfo))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, fmt.Errorf("cannot derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher block: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func decryptGCM(secret Secret, prefix, encryptedTxt string) (string, error) {
+	aead, err := gcmCipher(secret)
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encryptedTxt)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode base64 encoded secret: %w", err)
+	}
+
+	if len(decoded) < aead.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce := decoded[:aead.NonceSize()]
+	b, err := aead.Open(nil, nonce, decoded[aead.NonceSize():], []byte(prefix))
+	if err != nil {
+		return "", fmt.Errorf("cannot authenticate secret: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// decryptCFB decrypts a string encrypted with AES-CFB and a key
+// hashed from a versioned secret. Secrets were encrypted this way
+// before authenticated encryption was introduced.
+func decryptCFB(secret Secret, encryptedTxt string) (string, error) {
 	key := sha256.New()
-	_, err = key.Write([]byte(secret.Key))
+	_, err := key.Write([]byte(secret.Key))
 	if err != nil {
 		return "", fmt.Errorf("cannot write secret: %w", err)
 	}
@@ -263,29 +481,31 @@ func (o *OTP) decrypt(encryptedTxt string) (string, error) {
 		return "", fmt.Errorf("failed to create cipher block: %w", err)
 	}
 
-	if len(encryptedTxt) < aes.BlockSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-
 	decoded, err := base64.StdEncoding.DecodeString(encryptedTxt)
 	if err != nil {
 		return "", fmt.Errorf("cannot decode base64 encoded secret: %w", err)
 	}
 
+	if len(decoded) < aes.BlockSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
 	iv := decoded[:aes.BlockSize]
 	decoded = decoded[aes.BlockSize:]
+
 	stream := cipher.NewCFBDecrypter(block, iv)
 	stream.XORKeyStream(decoded, decoded)
+
 	return string(decoded), nil
 }
 
-func toOTPHash(code, address string, method auth.DeliveryMethod) (string, error) {
+func toOTPHash(code, address string, method auth.DeliveryMethod, expiry time.Duration) (string, error) {
 	codeHash, err := crypto.Hash(code)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash code: %w", err)
 	}
 
-	expiresAt := time.Now().Add(time.Minute * 5).Unix()
+	expiresAt := time.Now().Add(expiry).Unix()
 
 	hash := &Hash{
 		CodeHash:       codeHash,
@@ -317,3 +537,14 @@ func FromOTPHash(otpHash string) (*Hash, error) {
 
 	return &o, nil
 }
+
+// TFAOption returns the 2FA option of an OTP hash based on
+// its delivery method. An empty value is returned if the hash
+// is invalid.
+func TFAOption(otpHash string) auth.TFAOptions {
+	h, err := FromOTPHash(otpHash)
+	if err != nil {
+		return ""
+	}
+	return h.DeliveryMethod.TFAOption()
+}
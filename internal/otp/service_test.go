@@ -1,19 +1,25 @@
 package otp
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	otpLib "github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
 
 	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
 )
 
 func TestOTPSvc_ValidateOTP(t *testing.T) {
 	codeLength := 10
 	svc := NewOTP(WithCodeLength(codeLength))
-	code, hash, err := svc.OTPCode("jane@example.com", auth.Email)
+	code, hash, err := svc.OTPCode("jane@example.com", auth.Email, auth.OTPLogin)
 	if err != nil {
 		t.Fatal("failed to create code:", err)
 	}
@@ -22,12 +28,170 @@ func TestOTPSvc_ValidateOTP(t *testing.T) {
 		t.Errorf("incorrect code length, want %v got %v", len(code), codeLength)
 	}
 
-	err = svc.ValidateOTP(code, hash)
+	err = svc.ValidateOTP(context.Background(), "token-id", code, hash)
 	if err != nil {
 		t.Error("failed to validate code:", err)
 	}
 }
 
+func TestOTPSvc_OTPCodeAlphabet(t *testing.T) {
+	tt := []struct {
+		name     string
+		options  []ConfigOption
+		alphabet string
+	}{
+		{
+			name:     "Numeric by default",
+			options:  nil,
+			alphabet: NumericAlphabet,
+		},
+		{
+			name:     "Alphanumeric",
+			options:  []ConfigOption{WithCodeAlphabet(AlphanumericAlphabet)},
+			alphabet: AlphanumericAlphabet,
+		},
+		{
+			name:     "Custom alphabet is uppercase",
+			options:  []ConfigOption{WithCodeAlphabet("ab")},
+			alphabet: "AB",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]ConfigOption{WithCodeLength(500)}, tc.options...)
+			svc := NewOTP(options...)
+			code, hash, err := svc.OTPCode("jane@example.com", auth.Email, auth.OTPLogin)
+			if err != nil {
+				t.Fatal("failed to create code:", err)
+			}
+
+			for _, c := range tc.alphabet {
+				if !strings.ContainsRune(code, c) {
+					t.Errorf("code does not use character %s", string(c))
+				}
+			}
+			for _, c := range code {
+				if !strings.ContainsRune(tc.alphabet, c) {
+					t.Errorf("code uses invalid character %s", string(c))
+				}
+			}
+
+			err = svc.ValidateOTP(context.Background(), "token-id", strings.ToLower(code), hash)
+			if err != nil {
+				t.Error("code should be case insensitive, received:", err)
+			}
+		})
+	}
+}
+
+func TestOTPSvc_OTPCodeExpiry(t *testing.T) {
+	svc := NewOTP(
+		WithCodeExpiry(time.Minute*2),
+		WithTypeExpiry(auth.MagicLink, time.Minute*15),
+	)
+
+	tt := []struct {
+		name    string
+		msgType auth.MessageType
+		expiry  time.Duration
+	}{
+		{
+			name:    "Default expiry",
+			msgType: auth.OTPLogin,
+			expiry:  time.Minute * 2,
+		},
+		{
+			name:    "Message type expiry",
+			msgType: auth.MagicLink,
+			expiry:  time.Minute * 15,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, hash, err := svc.OTPCode("jane@example.com", auth.Email, tc.msgType)
+			if err != nil {
+				t.Fatal("failed to create code:", err)
+			}
+
+			otp, err := FromOTPHash(hash)
+			if err != nil {
+				t.Fatal("failed to parse hash:", err)
+			}
+
+			expiry := time.Until(time.Unix(otp.ExpiresAt, 0))
+			if expiry > tc.expiry || expiry < tc.expiry-time.Second*2 {
+				t.Errorf("incorrect expiry, want %v got %v", tc.expiry, expiry)
+			}
+		})
+	}
+}
+
+func TestOTPSvc_ValidateOTPAttempts(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	svc := NewOTP(WithDB(db), WithMaxAttempts(3))
+	ctx := context.Background()
+	tokenID := fmt.Sprintf("token-%v", time.Now().UnixNano())
+
+	code, hash, err := svc.OTPCode("jane@example.com", auth.Email, auth.OTPLogin)
+	if err != nil {
+		t.Fatal("failed to create code:", err)
+	}
+
+	tt := []struct {
+		name    string
+		code    string
+		message string
+	}{
+		{
+			name:    "First incorrect attempt",
+			code:    "wrong",
+			message: "incorrect code provided",
+		},
+		{
+			name:    "Second incorrect attempt",
+			code:    "wrong",
+			message: "incorrect code provided",
+		},
+		{
+			name:    "Last incorrect attempt burns code",
+			code:    "wrong",
+			message: "too many incorrect attempts, request a new code",
+		},
+		{
+			name:    "Correct code is rejected",
+			code:    code,
+			message: "too many incorrect attempts, request a new code",
+		},
+	}
+
+	for _, tc := range tt {
+		err = svc.ValidateOTP(ctx, tokenID, tc.code, hash)
+		if !cmp.Equal(auth.ErrorCode(err), auth.EInvalidCode) {
+			t.Errorf("%s: expected invalid code error, received: %v", tc.name, err)
+			continue
+		}
+		if msg := auth.DomainError(err).Message(); msg != tc.message {
+			t.Errorf("%s: incorrect error message, want %q got %q", tc.name, tc.message, msg)
+		}
+	}
+
+	// Attempts are counted per code issued with a token.
+	code, hash, err = svc.OTPCode("jane@example.com", auth.Email, auth.OTPResend)
+	if err != nil {
+		t.Fatal("failed to create code:", err)
+	}
+	if err = svc.ValidateOTP(ctx, tokenID, code, hash); err != nil {
+		t.Error("new code should be accepted, received:", err)
+	}
+}
+
 func TestOTPSvc_TOTPSecret(t *testing.T) {
 	svc := NewOTP(
 		WithIssuer("authenticator.local"),
@@ -83,6 +247,204 @@ func TestOTPSvc_TOTPQRString(t *testing.T) {
 	}
 }
 
+func TestOTPSvc_TOTPParameters(t *testing.T) {
+	svc := NewOTP(
+		WithIssuer("authenticator.local"),
+		WithSecret(Secret{Version: 0, Key: "secret-key"}),
+		WithTOTPAlgorithm(otpLib.AlgorithmSHA256),
+		WithTOTPDigits(8),
+		WithTOTPPeriod(time.Minute),
+	)
+	user := &auth.User{
+		IsTOTPAllowed: true,
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+
+	secret, err := svc.TOTPSecret(user)
+	if err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+	user.TFASecret = secret
+
+	// Parameters of an enrolled secret are unaffected by changes
+	// to the service configuration.
+	svc = NewOTP(
+		WithIssuer("authenticator.local"),
+		WithSecret(Secret{Version: 0, Key: "secret-key"}),
+	)
+	qrString, err := svc.TOTPQRString(user)
+	if err != nil {
+		t.Fatal("expected nil error, received:", err)
+	}
+
+	for _, param := range []string{"algorithm=SHA256", "digits=8", "period=60"} {
+		if !strings.Contains(qrString, param) {
+			t.Errorf("TOTP QR string %s is missing %s", qrString, param)
+		}
+	}
+}
+
+func TestOTPSvc_ValidateTOTP(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	secret := Secret{Version: 0, Key: "secret-key"}
+	now := time.Now()
+
+	tt := []struct {
+		name    string
+		options []ConfigOption
+		opts    totp.ValidateOpts
+		codeAt  []time.Time
+		errMsgs []string
+	}{
+		{
+			name:    "Default parameters",
+			options: nil,
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now},
+			errMsgs: []string{""},
+		},
+		{
+			name: "Configured parameters",
+			options: []ConfigOption{
+				WithTOTPAlgorithm(otpLib.AlgorithmSHA512),
+				WithTOTPDigits(8),
+				WithTOTPPeriod(time.Minute),
+			},
+			opts:    totp.ValidateOpts{Period: 60, Digits: otpLib.DigitsEight, Algorithm: otpLib.AlgorithmSHA512},
+			codeAt:  []time.Time{now},
+			errMsgs: []string{""},
+		},
+		{
+			name:    "Mismatched parameters",
+			options: []ConfigOption{WithTOTPDigits(8)},
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now},
+			errMsgs: []string{"incorrect code provided"},
+		},
+		{
+			name:    "Code within skew",
+			options: []ConfigOption{WithTOTPSkew(2)},
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now.Add(-time.Minute)},
+			errMsgs: []string{""},
+		},
+		{
+			name:    "Code outside skew",
+			options: []ConfigOption{WithTOTPSkew(0)},
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now.Add(-time.Minute)},
+			errMsgs: []string{"incorrect code provided"},
+		},
+		{
+			name:    "Replayed code",
+			options: nil,
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now, now},
+			errMsgs: []string{"", "code is no longer valid"},
+		},
+		{
+			name:    "Code from earlier time step",
+			options: []ConfigOption{WithTOTPSkew(2)},
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now, now.Add(-time.Minute)},
+			errMsgs: []string{"", "code is no longer valid"},
+		},
+		{
+			name:    "Code from later time step",
+			options: []ConfigOption{WithTOTPSkew(2)},
+			opts:    totp.ValidateOpts{Period: 30, Digits: otpLib.DigitsSix, Algorithm: otpLib.AlgorithmSHA1},
+			codeAt:  []time.Time{now.Add(-time.Minute), now},
+			errMsgs: []string{"", ""},
+		},
+	}
+
+	for i, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]ConfigOption{
+				WithDB(db),
+				WithIssuer("authenticator.local"),
+				WithSecret(secret),
+			}, tc.options...)
+			svc := NewOTP(options...)
+			user := &auth.User{
+				ID:            fmt.Sprintf("user-%v-%v", now.UnixNano(), i),
+				IsTOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			}
+			user.TFASecret, err = svc.TOTPSecret(user)
+			if err != nil {
+				t.Fatal("failed to create secret:", err)
+			}
+
+			o := svc.(*OTP)
+			key, err := o.totpKey(user)
+			if err != nil {
+				t.Fatal("failed to decrypt secret:", err)
+			}
+
+			for j, codeAt := range tc.codeAt {
+				code, err := totp.GenerateCodeCustom(key.secret, codeAt, tc.opts)
+				if err != nil {
+					t.Fatal("failed to generate code:", err)
+				}
+
+				err = svc.ValidateTOTP(context.Background(), user, code)
+				var errMsg string
+				if err != nil {
+					errMsg = auth.DomainError(err).Message()
+				}
+				if errMsg != tc.errMsgs[j] {
+					t.Errorf("code %v: error does not match %s", j, cmp.Diff(errMsg, tc.errMsgs[j]))
+				}
+			}
+		})
+	}
+}
+
+func TestOTPSvc_ValidateTOTPLegacySecret(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer db.Close()
+
+	// Secrets created before TOTP parameters were configurable are
+	// validated with the SHA1 algorithm, 6 digits and a 30 second period.
+	svc := NewOTP(
+		WithDB(db),
+		WithSecret(Secret{
+			Version: 1,
+			Key:     "9f0c6da662f018b58b04a093e2dbb2e1d8d54250",
+		}),
+		WithTOTPAlgorithm(otpLib.AlgorithmSHA256),
+		WithTOTPDigits(8),
+	)
+	user := &auth.User{
+		ID:        fmt.Sprintf("user-%v", time.Now().UnixNano()),
+		TFASecret: "1:usrJIgtKY9j58GgLpKIaoJqNbwylphfzyJcoyRRg1Ow52/7j6KoRpky8tFLZlgrY",
+	}
+
+	code, err := totp.GenerateCode("572JFGKOMDRA6KHE5O3ZV62I6BP352E7", time.Now())
+	if err != nil {
+		t.Fatal("failed to generate code:", err)
+	}
+
+	if err = svc.ValidateTOTP(context.Background(), user, code); err != nil {
+		t.Error("expected nil error, received:", err)
+	}
+}
+
 func TestOTPSvc_EncryptsWithLatestSecret(t *testing.T) {
 	svc := &OTP{
 		secrets: []Secret{
@@ -111,3 +473,109 @@ func TestOTPSvc_EncryptsWithLatestSecret(t *testing.T) {
 		t.Error("value not decrypted")
 	}
 }
+
+func TestOTPSvc_DecryptRejectsTamperedSecret(t *testing.T) {
+	// Versions share a key so that a modified version is only
+	// detected by authenticating the prefix.
+	svc := &OTP{
+		secrets: []Secret{
+			{Version: 1, Key: "key-1"},
+			{Version: 2, Key: "key-1"},
+		},
+	}
+	s, err := svc.encrypt("some-secret-value")
+	if err != nil {
+		t.Fatal("failed to encrypt", err)
+	}
+	if !strings.HasPrefix(s, "2:gcm:") {
+		t.Fatal("value not encrypted with AES-GCM:", s)
+	}
+
+	tt := []struct {
+		name   string
+		secret string
+	}{
+		{
+			name:   "Modified ciphertext",
+			secret: s[:len(s)-4] + "AAAA",
+		},
+		{
+			name:   "Modified key version",
+			secret: "1" + strings.TrimPrefix(s, "2"),
+		},
+		{
+			name:   "Unknown scheme",
+			secret: "2:xyz:" + strings.TrimPrefix(s, "2:gcm:"),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := svc.decrypt(tc.secret); err == nil {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}
+
+func TestOTPSvc_ReencryptTOTPSecret(t *testing.T) {
+	svc := NewOTP(
+		WithSecret(Secret{
+			Version: 1,
+			Key:     "9f0c6da662f018b58b04a093e2dbb2e1d8d54250",
+		}),
+		WithSecret(Secret{Version: 2, Key: "new-secret-key"}),
+	)
+
+	// A secret encrypted with AES-CFB under version 1.
+	legacy := "1:usrJIgtKY9j58GgLpKIaoJqNbwylphfzyJcoyRRg1Ow52/7j6KoRpky8tFLZlgrY"
+	secret, err := svc.ReencryptTOTPSecret(legacy)
+	if err != nil {
+		t.Fatal("failed to re-encrypt secret:", err)
+	}
+	if !strings.HasPrefix(secret, "2:gcm:") {
+		t.Error("secret not encrypted with latest key:", secret)
+	}
+
+	o := svc.(*OTP)
+	value, err := o.decrypt(secret)
+	if err != nil {
+		t.Fatal("failed to decrypt secret:", err)
+	}
+	if value != "572JFGKOMDRA6KHE5O3ZV62I6BP352E7" {
+		t.Error("secret value does not match", cmp.Diff(
+			value, "572JFGKOMDRA6KHE5O3ZV62I6BP352E7",
+		))
+	}
+
+	unchanged, err := svc.ReencryptTOTPSecret(secret)
+	if err != nil {
+		t.Fatal("failed to re-encrypt secret:", err)
+	}
+	if unchanged != secret {
+		t.Error("current secret should not be re-encrypted")
+	}
+}
+
+func TestOTPSvc_RecoveryCodes(t *testing.T) {
+	svc := NewOTP()
+	codes, err := svc.RecoveryCodes()
+	if err != nil {
+		t.Fatal("failed to create recovery codes:", err)
+	}
+
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("incorrect number of codes, want %v got %v", recoveryCodeCount, len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != recoveryCodeLength*2+1 || code[recoveryCodeLength] != '-' {
+			t.Errorf("%s is not a valid recovery code format", code)
+		}
+		if seen[code] {
+			t.Errorf("%s is a duplicate recovery code", code)
+		}
+		seen[code] = true
+	}
+}
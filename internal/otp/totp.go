@@ -0,0 +1,118 @@
+package otp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	otpLib "github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+const (
+	defaultTOTPPeriod = 30
+	defaultTOTPSkew   = 1
+)
+
+// totpKey is a TOTP secret and the parameters used to generate its
+// codes. Parameters are encrypted alongside the secret so that codes
+// from existing authenticator apps remain valid if the configured
+// parameters change.
+type totpKey struct {
+	secret    string
+	algorithm otpLib.Algorithm
+	digits    otpLib.Digits
+	// period is the number of seconds a code is valid for.
+	period uint
+}
+
+// String encodes the key as its secret followed by its parameters
+// in a query string, e.g. `JBSWY3DPEHPK3PXP?algorithm=SHA1&digits=6&period=30`.
+func (k *totpKey) String() string {
+	v := url.Values{}
+	v.Set("algorithm", k.algorithm.String())
+	v.Set("digits", k.digits.String())
+	v.Set("period", strconv.FormatUint(uint64(k.period), 10))
+
+	return k.secret + "?" + v.Encode()
+}
+
+// step returns the time step of a TOTP code matching a passcode
+// within a number of steps before or after the current time.
+func (k *totpKey) step(passcode string, now time.Time, skew uint) (uint64, bool) {
+	current := uint64(now.Unix()) / uint64(k.period)
+	opts := hotp.ValidateOpts{
+		Digits:    k.digits,
+		Algorithm: k.algorithm,
+	}
+
+	for i := uint64(0); i <= uint64(skew); i++ {
+		for _, counter := range []uint64{current + i, current - i} {
+			ok, err := hotp.ValidateCustom(passcode, counter, k.secret, opts)
+			if err == nil && ok {
+				return counter, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// parseTOTPKey parses a decrypted TOTP key. Secrets created before
+// parameters were configurable are stored without them and use the
+// SHA1 algorithm, 6 digits and a 30 second period.
+func parseTOTPKey(s string) (*totpKey, error) {
+	key := &totpKey{
+		secret:    s,
+		algorithm: otpLib.AlgorithmSHA1,
+		digits:    otpLib.DigitsSix,
+		period:    defaultTOTPPeriod,
+	}
+
+	i := strings.Index(s, "?")
+	if i < 0 {
+		return key, nil
+	}
+
+	key.secret = s[:i]
+	v, err := url.ParseQuery(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP parameters: %w", err)
+	}
+
+	key.algorithm, err = ParseAlgorithm(v.Get("algorithm"))
+	if err != nil {
+		return nil, err
+	}
+
+	digits, err := strconv.Atoi(v.Get("digits"))
+	if err != nil || digits < 1 {
+		return nil, fmt.Errorf("invalid TOTP digits %q", v.Get("digits"))
+	}
+	key.digits = otpLib.Digits(digits)
+
+	period, err := strconv.ParseUint(v.Get("period"), 10, 32)
+	if err != nil || period < 1 {
+		return nil, fmt.Errorf("invalid TOTP period %q", v.Get("period"))
+	}
+	key.period = uint(period)
+
+	return key, nil
+}
+
+// ParseAlgorithm returns the TOTP hash algorithm with a name
+// such as `SHA256`.
+func ParseAlgorithm(name string) (otpLib.Algorithm, error) {
+	switch strings.ToUpper(name) {
+	case "SHA1":
+		return otpLib.AlgorithmSHA1, nil
+	case "SHA256":
+		return otpLib.AlgorithmSHA256, nil
+	case "SHA512":
+		return otpLib.AlgorithmSHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported TOTP algorithm %q", name)
+	}
+}
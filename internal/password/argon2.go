@@ -0,0 +1,107 @@
+package password
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/fmitra/authenticator/internal/crypto"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// argon2Params are the argon2id cost parameters of a hash.
+type argon2Params struct {
+	// memory is the memory used in KiB.
+	memory uint32
+	// time is the number of passes over the memory.
+	time uint32
+	// parallelism is the number of threads used.
+	parallelism uint8
+}
+
+// hashArgon2 hashes a password with argon2id and a random salt. The
+// hash is encoded in the PHC string format:
+//
+//	$argon2id$v=19$m=<memory>,t=<time>,p=<parallelism>$<salt>$<key>
+func hashArgon2(password string, params argon2Params) (string, error) {
+	salt, err := crypto.Bytes(argon2SaltLength)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf(
+		"$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id,
+		argon2.Version,
+		params.memory,
+		params.time,
+		params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// compareArgon2 compares an argon2id PHC hash with a password.
+func compareArgon2(hash, password string) error {
+	params, salt, key, err := parseArgon2(hash)
+	if err != nil {
+		return err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return fmt.Errorf("hash does not match password")
+	}
+
+	return nil
+}
+
+// parseArgon2 returns the parameters, salt and key of an argon2id
+// PHC hash.
+func parseArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var (
+		params  argon2Params
+		version int
+	)
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %v", version)
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.parallelism)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.time < 1 || params.parallelism < 1 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
@@ -1,21 +1,33 @@
 package password
 
 import (
+	"github.com/go-kit/kit/log"
 	"golang.org/x/crypto/bcrypt"
 
 	auth "github.com/fmitra/authenticator"
 )
 
 const (
-	defaultCost      = bcrypt.DefaultCost
-	defaultMinLength = 8
-	defaultMaxLength = 1000
+	defaultAlgorithm   = Argon2id
+	defaultCost        = bcrypt.DefaultCost
+	defaultMinLength   = 8
+	defaultMaxLength   = 1000
+	defaultMemory      = 64 * 1024
+	defaultTime        = 3
+	defaultParallelism = 4
 )
 
 // NewPassword returns a new password validator.
 func NewPassword(options ...ConfigOption) auth.PasswordService {
 	s := Password{
+		logger:    log.NewNopLogger(),
+		algorithm: defaultAlgorithm,
 		cost:      defaultCost,
+		argon2: argon2Params{
+			memory:      defaultMemory,
+			time:        defaultTime,
+			parallelism: defaultParallelism,
+		},
 		minLength: defaultMinLength,
 		maxLength: defaultMaxLength,
 	}
@@ -30,13 +42,67 @@ func NewPassword(options ...ConfigOption) auth.PasswordService {
 // ConfigOption configures the validator.
 type ConfigOption func(*Password)
 
-// WithCost configures the service with a cost.
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *Password) {
+		s.logger = l
+	}
+}
+
+// WithBreachChecker configures the service to check new passwords
+// against a corpus of breached passwords.
+func WithBreachChecker(c auth.BreachChecker) ConfigOption {
+	return func(s *Password) {
+		s.breachChecker = c
+	}
+}
+
+// WithBreachWarning configures the service to log breached passwords
+// instead of rejecting them.
+func WithBreachWarning(isEnabled bool) ConfigOption {
+	return func(s *Password) {
+		s.isBreachWarning = isEnabled
+	}
+}
+
+// WithAlgorithm configures the algorithm used to hash new passwords,
+// either Argon2id or Bcrypt.
+func WithAlgorithm(algorithm string) ConfigOption {
+	return func(s *Password) {
+		s.algorithm = algorithm
+	}
+}
+
+// WithCost configures the service with a bcrypt cost.
 func WithCost(cost int) ConfigOption {
 	return func(s *Password) {
 		s.cost = cost
 	}
 }
 
+// WithArgon2Memory configures the memory used by argon2id, in KiB.
+func WithArgon2Memory(memory uint32) ConfigOption {
+	return func(s *Password) {
+		s.argon2.memory = memory
+	}
+}
+
+// WithArgon2Time configures the number of argon2id passes over
+// the memory.
+func WithArgon2Time(time uint32) ConfigOption {
+	return func(s *Password) {
+		s.argon2.time = time
+	}
+}
+
+// WithArgon2Parallelism configures the number of threads used
+// by argon2id.
+func WithArgon2Parallelism(parallelism uint8) ConfigOption {
+	return func(s *Password) {
+		s.argon2.parallelism = parallelism
+	}
+}
+
 // WithMinLength sets a minimum password length.
 func WithMinLength(length int) ConfigOption {
 	return func(s *Password) {
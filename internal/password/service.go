@@ -1,19 +1,47 @@
-// Package password provides password management through bcrypt.
+// Package password provides password management through argon2id and
+// bcrypt.
 package password
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"golang.org/x/crypto/bcrypt"
 
 	auth "github.com/fmitra/authenticator"
 )
 
+const (
+	// Argon2id hashes passwords with argon2id.
+	Argon2id = "argon2id"
+	// Bcrypt hashes passwords with bcrypt. Passwords are truncated
+	// to 72 bytes by bcrypt.
+	Bcrypt = "bcrypt"
+)
+
 // Password is a credential validator for password authentication.
+// Hashes are stored in the PHC string format, or the equivalent
+// modular crypt format of bcrypt, so that hashes of each supported
+// algorithm may be validated regardless of the algorithm used for
+// new passwords.
 type Password struct {
+	logger log.Logger
+	// breachChecker checks new passwords against a corpus of
+	// breached passwords. Passwords are not checked if it is nil.
+	breachChecker auth.BreachChecker
+	// isBreachWarning logs breached passwords instead of
+	// rejecting them.
+	isBreachWarning bool
+	// algorithm is the algorithm used to hash new passwords.
+	algorithm string
 	// cost is the bcrypt hash repetition. Higher cost results
 	// in slower computations.
 	cost int
+	// argon2 are the argon2id cost parameters.
+	argon2 argon2Params
 	// minLength is the minimum length of a password.
 	minLength int
 	// maxLength is the maximum length of a password.
@@ -23,21 +51,50 @@ type Password struct {
 
 // Hash hashes a password for storage.
 func (p *Password) Hash(password string) ([]byte, error) {
-	// bcrypt will manage its own salt
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), p.cost)
+	if p.algorithm == Bcrypt {
+		// bcrypt will manage its own salt
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.cost)
+		if err != nil {
+			return []byte(""), err
+		}
+		return hash, nil
+	}
+
+	hash, err := hashArgon2(password, p.argon2)
 	if err != nil {
 		return []byte(""), err
 	}
 
-	return hash, nil
+	return []byte(hash), nil
 }
 
 // Validate validates if a submitted password is valid for a
 // stored password hash.
 func (p *Password) Validate(user *auth.User, password string) error {
-	bPasswdHash := []byte(user.Password)
-	bPasswd := []byte(password)
-	return bcrypt.CompareHashAndPassword(bPasswdHash, bPasswd)
+	switch algorithm(user.Password) {
+	case Argon2id:
+		return compareArgon2(user.Password, password)
+	case Bcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	default:
+		return fmt.Errorf("unknown password hash format")
+	}
+}
+
+// NeedsRehash tells us if a password hash was created with a different
+// algorithm or cost than the one used for new passwords.
+func (p *Password) NeedsRehash(hash string) bool {
+	if algorithm(hash) != p.algorithm {
+		return true
+	}
+
+	if p.algorithm == Bcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != p.cost
+	}
+
+	params, _, key, err := parseArgon2(hash)
+	return err != nil || params != p.argon2 || len(key) != argon2KeyLength
 }
 
 // OKForUser tells us if a password meets minimum requirements to
@@ -57,3 +114,49 @@ func (p *Password) OKForUser(password string) error {
 
 	return nil
 }
+
+// CheckBreached rejects a new password if it appears in the breach
+// corpus. The check fails open, so a password is accepted if the
+// corpus cannot be queried.
+func (p *Password) CheckBreached(ctx context.Context, password string) error {
+	if p.breachChecker == nil {
+		return nil
+	}
+
+	isBreached, err := p.breachChecker.IsBreached(ctx, password)
+	if err != nil {
+		level.Error(p.logger).Log(
+			"source", "password.CheckBreached",
+			"message", "failed to check breached password",
+			"error", err,
+		)
+		return nil
+	}
+	if !isBreached {
+		return nil
+	}
+
+	if p.isBreachWarning {
+		level.Warn(p.logger).Log(
+			"source", "password.CheckBreached",
+			"message", "breached password accepted",
+		)
+		return nil
+	}
+
+	return auth.ErrInvalidField("password has appeared in a data breach, choose a different password")
+}
+
+// algorithm returns the algorithm of a password hash.
+func algorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$"+Argon2id+"$"):
+		return Argon2id
+	case strings.HasPrefix(hash, "$2a$"),
+		strings.HasPrefix(hash, "$2b$"),
+		strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	default:
+		return ""
+	}
+}
@@ -1,11 +1,15 @@
 package password
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
 
 	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
 )
 
 func TestPasswordSvc_ValidatePasswordRequirement(t *testing.T) {
@@ -71,3 +75,208 @@ func TestPasswordSvc_ValidatePassword(t *testing.T) {
 		t.Error("failed to validate password:", err)
 	}
 }
+
+func TestPasswordSvc_ValidateHashFormats(t *testing.T) {
+	argon2Svc := NewPassword(
+		WithArgon2Memory(1024),
+		WithArgon2Time(1),
+		WithArgon2Parallelism(1),
+	)
+	bcryptSvc := NewPassword(WithAlgorithm(Bcrypt), WithCost(bcrypt.MinCost))
+
+	argon2Hash, err := argon2Svc.Hash("swordfish")
+	if err != nil {
+		t.Fatal("failed to hash password:", err)
+	}
+	if !strings.HasPrefix(string(argon2Hash), "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hash is not in PHC format: %s", argon2Hash)
+	}
+
+	bcryptHash, err := bcryptSvc.Hash("swordfish")
+	if err != nil {
+		t.Fatal("failed to hash password:", err)
+	}
+
+	tt := []struct {
+		name     string
+		hash     string
+		password string
+		isValid  bool
+	}{
+		{
+			name:     "Valid argon2id password",
+			hash:     string(argon2Hash),
+			password: "swordfish",
+			isValid:  true,
+		},
+		{
+			name:     "Invalid argon2id password",
+			hash:     string(argon2Hash),
+			password: "swordfish-2",
+			isValid:  false,
+		},
+		{
+			name:     "Valid bcrypt password",
+			hash:     string(bcryptHash),
+			password: "swordfish",
+			isValid:  true,
+		},
+		{
+			name:     "Invalid bcrypt password",
+			hash:     string(bcryptHash),
+			password: "swordfish-2",
+			isValid:  false,
+		},
+		{
+			name:     "Malformed argon2id hash",
+			hash:     "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+			password: "swordfish",
+			isValid:  false,
+		},
+		{
+			name:     "Unknown hash format",
+			hash:     "swordfish",
+			password: "swordfish",
+			isValid:  false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Hashes of each algorithm are validated by either service.
+			for _, svc := range []auth.PasswordService{argon2Svc, bcryptSvc} {
+				err := svc.Validate(&auth.User{Password: tc.hash}, tc.password)
+				if err != nil && tc.isValid {
+					t.Error("failed to validate password:", err)
+				}
+				if err == nil && !tc.isValid {
+					t.Error("expected password validation failure, not nil")
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordSvc_NeedsRehash(t *testing.T) {
+	svc := NewPassword(
+		WithArgon2Memory(1024),
+		WithArgon2Time(2),
+		WithArgon2Parallelism(1),
+		WithCost(bcrypt.MinCost),
+	)
+
+	hash := func(options ...ConfigOption) string {
+		h, err := NewPassword(options...).Hash("swordfish")
+		if err != nil {
+			t.Fatal("failed to hash password:", err)
+		}
+		return string(h)
+	}
+
+	tt := []struct {
+		name        string
+		hash        string
+		needsRehash bool
+	}{
+		{
+			name:        "Current argon2id parameters",
+			hash:        hash(WithArgon2Memory(1024), WithArgon2Time(2), WithArgon2Parallelism(1)),
+			needsRehash: false,
+		},
+		{
+			name:        "Lower argon2id memory",
+			hash:        hash(WithArgon2Memory(512), WithArgon2Time(2), WithArgon2Parallelism(1)),
+			needsRehash: true,
+		},
+		{
+			name:        "Lower argon2id time",
+			hash:        hash(WithArgon2Memory(1024), WithArgon2Time(1), WithArgon2Parallelism(1)),
+			needsRehash: true,
+		},
+		{
+			name:        "bcrypt hash",
+			hash:        hash(WithAlgorithm(Bcrypt), WithCost(bcrypt.MinCost)),
+			needsRehash: true,
+		},
+		{
+			name:        "Unknown hash format",
+			hash:        "swordfish",
+			needsRehash: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if svc.NeedsRehash(tc.hash) != tc.needsRehash {
+				t.Errorf("incorrect rehash result for %s, want %v", tc.hash, tc.needsRehash)
+			}
+		})
+	}
+
+	bcryptSvc := NewPassword(WithAlgorithm(Bcrypt), WithCost(bcrypt.MinCost+1))
+	if !bcryptSvc.NeedsRehash(hash(WithAlgorithm(Bcrypt), WithCost(bcrypt.MinCost))) {
+		t.Error("lower cost bcrypt hash should be rehashed")
+	}
+	if bcryptSvc.NeedsRehash(hash(WithAlgorithm(Bcrypt), WithCost(bcrypt.MinCost+1))) {
+		t.Error("current cost bcrypt hash should not be rehashed")
+	}
+}
+
+func TestPasswordSvc_CheckBreached(t *testing.T) {
+	tt := []struct {
+		name         string
+		options      []ConfigOption
+		isBreachedFn func() (bool, error)
+		errCode      auth.ErrCode
+	}{
+		{
+			name:    "No breach checker",
+			options: nil,
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name: "Password not breached",
+			isBreachedFn: func() (bool, error) {
+				return false, nil
+			},
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name: "Breached password rejected",
+			isBreachedFn: func() (bool, error) {
+				return true, nil
+			},
+			errCode: auth.EInvalidField,
+		},
+		{
+			name:    "Breached password accepted with warning",
+			options: []ConfigOption{WithBreachWarning(true)},
+			isBreachedFn: func() (bool, error) {
+				return true, nil
+			},
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name: "Breach check failure is accepted",
+			isBreachedFn: func() (bool, error) {
+				return false, fmt.Errorf("whoops")
+			},
+			errCode: auth.ErrCode(""),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			options := tc.options
+			if tc.isBreachedFn != nil {
+				options = append(options, WithBreachChecker(&test.BreachChecker{IsBreachedFn: tc.isBreachedFn}))
+			}
+			svc := NewPassword(options...)
+
+			err := svc.CheckBreached(context.Background(), "swordfish")
+			if auth.ErrorCode(err) != tc.errCode {
+				t.Errorf("incorrect error code, want %s got %s", tc.errCode, auth.ErrorCode(err))
+			}
+		})
+	}
+}
@@ -28,37 +28,60 @@ type Client struct {
 
 	userRepository *UserRepository
 	userQ          map[string]string
+
+	recoveryCodeRepository *RecoveryCodeRepository
+	recoveryCodeQ          map[string]string
+
+	messageStatusRepository *MessageStatusRepository
+	messageStatusQ          map[string]string
+
+	deadLetterRepository *DeadLetterRepository
+	deadLetterQ          map[string]string
 }
 
 func (c *Client) createQueries() {
 	c.loginHistoryQ = map[string]string{
 		"byTokenID": `
-			SELECT user_id, token_id, is_revoked, expires_at, created_at, updated_at
+			SELECT user_id, token_id, is_revoked, expires_at, ip_address, user_agent, tfa_method,
+				created_at, updated_at
 			FROM login_history
 			WHERE token_id = $1;
 		`,
 		"byUserID": `
-			SELECT user_id, token_id, is_revoked, expires_at, created_at, updated_at
+			SELECT user_id, token_id, is_revoked, expires_at, ip_address, user_agent, tfa_method,
+				created_at, updated_at
 			FROM login_history
 			WHERE user_id = $1
+			ORDER BY created_at DESC
 			LIMIT $2
 			OFFSET $3;
 		`,
 		"forUpdate": `
-			SELECT user_id, token_id, is_revoked, expires_at, created_at, updated_at
+			SELECT user_id, token_id, is_revoked, expires_at, ip_address, user_agent, tfa_method,
+				created_at, updated_at
 			FROM login_history
-			WHERE token_id = $1;
+			WHERE token_id = $1
+			FOR UPDATE;
 		`,
 		"update": `
 			UPDATE login_history
-			SET is_revoked=$2, updated_at=$3
+			SET is_revoked=$2, expires_at=$3, updated_at=$4
 			WHERE token_id = $1;
 		`,
+		"revokeByUserID": `
+			UPDATE login_history
+			SET is_revoked=true, updated_at=$3
+			WHERE user_id = $1
+				AND token_id <> $2
+				AND is_revoked = false
+				AND expires_at > $3
+			RETURNING token_id;
+		`,
 		"insert": `
 			INSERT INTO login_history (
-				user_id, token_id, is_revoked, expires_at
+				user_id, token_id, is_revoked, expires_at, ip_address, user_agent, tfa_method
 			)
-			VALUES ($1, $2, $3, $4)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 			RETURNING created_at, updated_at;
 		`,
 	}
@@ -109,46 +132,145 @@ func (c *Client) createQueries() {
 
 	c.userQ = map[string]string{
 		"forUpdate": `
-			SELECT id, phone, email, password, tfa_secret, is_email_otp_allowed, is_sms_otp_allowed,
-				is_totp_allowed, is_device_allowed, is_verified, created_at, updated_at
+			SELECT id, phone, email, COALESCE(password, ''), tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+				is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed, is_recovery_code_allowed,
+				is_verified, locale, created_at, updated_at
 			FROM auth_user
 			WHERE id = $1
 			FOR UPDATE;
 		`,
 		"byPhone": `
-			SELECT id, phone, email, password, tfa_secret, is_email_otp_allowed, is_sms_otp_allowed,
-				is_totp_allowed, is_device_allowed, is_verified, created_at, updated_at
+			SELECT id, phone, email, COALESCE(password, ''), tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+				is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed, is_recovery_code_allowed,
+				is_verified, locale, created_at, updated_at
 			FROM auth_user
 			WHERE phone = $1;
 		`,
 		"byEmail": `
-			SELECT id, phone, email, password, tfa_secret, is_email_otp_allowed, is_sms_otp_allowed,
-				is_totp_allowed, is_device_allowed, is_verified, created_at, updated_at
+			SELECT id, phone, email, COALESCE(password, ''), tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+				is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed, is_recovery_code_allowed,
+				is_verified, locale, created_at, updated_at
 			FROM auth_user
 			WHERE email = $1;
 		`,
 		"byID": `
-			SELECT id, phone, email, password, tfa_secret, is_email_otp_allowed, is_sms_otp_allowed,
-				is_totp_allowed, is_device_allowed, is_verified, created_at, updated_at
+			SELECT id, phone, email, COALESCE(password, ''), tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+				is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed, is_recovery_code_allowed,
+				is_verified, locale, created_at, updated_at
 			FROM auth_user
 			WHERE id = $1;
 		`,
+		"list": `
+			SELECT id, phone, email, COALESCE(password, ''), tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+				is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed, is_recovery_code_allowed,
+				is_verified, locale, created_at, updated_at
+			FROM auth_user
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2;
+		`,
+		"updateTFASecret": `
+			UPDATE auth_user
+			SET tfa_secret=$3, updated_at=$4
+			WHERE id = $1
+				AND tfa_secret = $2;
+		`,
+		"updateHOTPCounter": `
+			UPDATE auth_user
+			SET hotp_counter=$3, updated_at=$4
+			WHERE id = $1
+				AND hotp_counter = $2;
+		`,
+		"updatePassword": `
+			UPDATE auth_user
+			SET password=$3, updated_at=$4
+			WHERE id = $1
+				AND password = $2;
+		`,
 		"update": `
 			UPDATE auth_user
-			SET phone=$2, email=$3, password=$4, tfa_secret=$5,
-				is_email_otp_allowed=$6, is_sms_otp_allowed=$7, is_totp_allowed=$8, is_device_allowed=$9,
-				is_verified=$10, created_at=$11, updated_at=$12, id=$13
+			SET phone=$2, email=$3, password=NULLIF($4, ''), tfa_secret=$5, hotp_secret=$6, hotp_counter=$7,
+				is_email_otp_allowed=$8, is_sms_otp_allowed=$9, is_totp_allowed=$10, is_hotp_allowed=$11,
+				is_device_allowed=$12, is_recovery_code_allowed=$13, is_verified=$14, locale=$15, created_at=$16,
+				updated_at=$17, id=$18
 			WHERE id=$1;
 		`,
 		"insert": `
 			INSERT INTO auth_user (
-				id, phone, email, password, tfa_secret, is_email_otp_allowed, is_sms_otp_allowed,
-					is_totp_allowed, is_device_allowed, is_verified
+				id, phone, email, password, tfa_secret, hotp_secret, hotp_counter, is_email_otp_allowed,
+					is_sms_otp_allowed, is_totp_allowed, is_hotp_allowed, is_device_allowed,
+					is_recovery_code_allowed, is_verified, locale
 			)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 			RETURNING created_at, updated_at
 		`,
 	}
+
+	c.recoveryCodeQ = map[string]string{
+		"deleteByUserID": `
+			DELETE FROM recovery_code WHERE user_id=$1;
+		`,
+		"insert": `
+			INSERT INTO recovery_code (id, user_id, code_hash)
+			VALUES ($1, $2, $3);
+		`,
+		"consume": `
+			UPDATE recovery_code
+			SET is_used=true, updated_at=$3
+			WHERE user_id = $1
+				AND code_hash = $2
+				AND is_used = false;
+		`,
+	}
+
+	c.messageStatusQ = map[string]string{
+		"byID": `
+			SELECT message_id, type, delivery, status, delivery_attempts, last_error,
+				created_at, updated_at
+			FROM message_status
+			WHERE message_id = $1;
+		`,
+		"upsert": `
+			INSERT INTO message_status (
+				message_id, type, delivery, status, delivery_attempts, last_error
+			)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (message_id) DO UPDATE
+			SET status=$4, delivery_attempts=$5, last_error=$6, updated_at=$7
+			RETURNING created_at, updated_at;
+		`,
+	}
+
+	c.deadLetterQ = map[string]string{
+		"byID": `
+			SELECT payload, last_error, created_at
+			FROM message_dead_letter
+			WHERE message_id = $1;
+		`,
+		"forUpdate": `
+			SELECT payload, last_error, created_at
+			FROM message_dead_letter
+			WHERE message_id = $1
+			FOR UPDATE;
+		`,
+		"recent": `
+			SELECT payload, last_error, created_at
+			FROM message_dead_letter
+			ORDER BY created_at DESC
+			LIMIT $1
+			OFFSET $2;
+		`,
+		"insert": `
+			INSERT INTO message_dead_letter (message_id, payload, last_error)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (message_id) DO UPDATE
+			SET payload=$2, last_error=$3, created_at=current_timestamp
+			RETURNING created_at;
+		`,
+		"delete": `
+			DELETE FROM message_dead_letter WHERE message_id=$1;
+		`,
+	}
 }
 
 // NewWithTransaction returns a new client with a transaction. All
@@ -164,6 +286,9 @@ func (c *Client) NewWithTransaction(ctx context.Context) (auth.RepositoryManager
 	newClient.loginHistoryRepository.client = &newClient
 	newClient.userRepository.client = &newClient
 	newClient.deviceRepository.client = &newClient
+	newClient.recoveryCodeRepository.client = &newClient
+	newClient.messageStatusRepository.client = &newClient
+	newClient.deadLetterRepository.client = &newClient
 	return &newClient, nil
 }
 
@@ -210,6 +335,21 @@ func (c *Client) User() auth.UserRepository {
 	return c.userRepository
 }
 
+// RecoveryCode returns a RecoveryCodeRepository.
+func (c *Client) RecoveryCode() auth.RecoveryCodeRepository {
+	return c.recoveryCodeRepository
+}
+
+// MessageStatus returns a MessageStatusRepository.
+func (c *Client) MessageStatus() auth.MessageStatusRepository {
+	return c.messageStatusRepository
+}
+
+// DeadLetter returns a DeadLetterRepository.
+func (c *Client) DeadLetter() auth.DeadLetterRepository {
+	return c.deadLetterRepository
+}
+
 func (c *Client) queryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	if c.tx != nil {
 		return c.tx.QueryRowContext(ctx, query, args...)
@@ -12,10 +12,13 @@ import (
 // NewClient returns a new Postgres client to manage repositories.
 func NewClient(options ...ConfigOption) *Client {
 	c := Client{
-		logger:                 log.NewNopLogger(),
-		loginHistoryRepository: &LoginHistoryRepository{},
-		deviceRepository:       &DeviceRepository{},
-		userRepository:         &UserRepository{},
+		logger:                  log.NewNopLogger(),
+		loginHistoryRepository:  &LoginHistoryRepository{},
+		deviceRepository:        &DeviceRepository{},
+		userRepository:          &UserRepository{},
+		recoveryCodeRepository:  &RecoveryCodeRepository{},
+		messageStatusRepository: &MessageStatusRepository{},
+		deadLetterRepository:    &DeadLetterRepository{},
 	}
 
 	for _, opt := range options {
@@ -31,6 +34,9 @@ func NewClient(options ...ConfigOption) *Client {
 	c.loginHistoryRepository.client = &c
 	c.deviceRepository.client = &c
 	c.userRepository.client = &c
+	c.recoveryCodeRepository.client = &c
+	c.messageStatusRepository.client = &c
+	c.deadLetterRepository.client = &c
 
 	return &c
 }
@@ -52,6 +58,15 @@ func WithPassword(p auth.PasswordService) ConfigOption {
 	}
 }
 
+// WithPasswordless configures the client to accept Users
+// without a password. Passwords provided by a User are still
+// validated and hashed.
+func WithPasswordless(isEnabled bool) ConfigOption {
+	return func(c *Client) {
+		c.userRepository.passwordless = isEnabled
+	}
+}
+
 // WithDB configures the client with a Postgres DB.
 func WithDB(db *sql.DB) ConfigOption {
 	return func(c *Client) {
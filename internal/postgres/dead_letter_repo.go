@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// DeadLetterRepository is an implementation of auth.DeadLetterRepository.
+// Messages are stored as JSON so they may be published again unchanged.
+type DeadLetterRepository struct {
+	client *Client
+}
+
+// ByID retrieves a DeadLetter by the ID of its Message.
+func (r *DeadLetterRepository) ByID(ctx context.Context, messageID string) (*auth.DeadLetter, error) {
+	return r.get(ctx, r.client.deadLetterQ["byID"], messageID)
+}
+
+// GetForUpdate retrieves a DeadLetter by the ID of its Message to be
+// updated or removed.
+func (r *DeadLetterRepository) GetForUpdate(ctx context.Context, messageID string) (*auth.DeadLetter, error) {
+	return r.get(ctx, r.client.deadLetterQ["forUpdate"], messageID)
+}
+
+// Recent retrieves DeadLetters ordered from most to least recent.
+func (r *DeadLetterRepository) Recent(ctx context.Context, limit, offset int) ([]*auth.DeadLetter, error) {
+	rows, err := r.client.queryContext(ctx, r.client.deadLetterQ["recent"], limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := make([]*auth.DeadLetter, 0)
+	for rows.Next() {
+		var (
+			payload    string
+			deadLetter auth.DeadLetter
+		)
+		if err := rows.Scan(&payload, &deadLetter.LastError, &deadLetter.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &deadLetter.Message); err != nil {
+			return nil, fmt.Errorf("cannot decode message: %w", err)
+		}
+		deadLetters = append(deadLetters, &deadLetter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+// Create persists a new DeadLetter to storage. A Message which is
+// dead-lettered again after a replay replaces its previous entry.
+func (r *DeadLetterRepository) Create(ctx context.Context, deadLetter *auth.DeadLetter) error {
+	if deadLetter.Message == nil || deadLetter.Message.ID == "" {
+		return auth.ErrInvalidField("message ID must be provided")
+	}
+
+	payload, err := json.Marshal(deadLetter.Message)
+	if err != nil {
+		return fmt.Errorf("cannot encode message: %w", err)
+	}
+
+	row := r.client.queryRowContext(
+		ctx,
+		r.client.deadLetterQ["insert"],
+		deadLetter.Message.ID,
+		string(payload),
+		deadLetter.LastError,
+	)
+	if err = row.Scan(&deadLetter.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create dead letter: %w", err)
+	}
+
+	return nil
+}
+
+// Remove removes a DeadLetter by the ID of its Message.
+func (r *DeadLetterRepository) Remove(ctx context.Context, messageID string) error {
+	res, err := r.client.execContext(ctx, r.client.deadLetterQ["delete"], messageID)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete: %w", err)
+	}
+
+	removedRows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if removedRows == 0 {
+		return auth.ErrNotFound("dead letter does not exist")
+	}
+
+	return nil
+}
+
+func (r *DeadLetterRepository) get(ctx context.Context, query, messageID string) (*auth.DeadLetter, error) {
+	row := r.client.queryRowContext(ctx, query, messageID)
+
+	var (
+		payload    string
+		deadLetter auth.DeadLetter
+	)
+	if err := row.Scan(&payload, &deadLetter.LastError, &deadLetter.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(payload), &deadLetter.Message); err != nil {
+		return nil, fmt.Errorf("cannot decode message: %w", err)
+	}
+
+	return &deadLetter, nil
+}
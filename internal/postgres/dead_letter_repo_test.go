@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestDeadLetterRepository(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	ctx := context.Background()
+	msg := auth.Message{
+		ID:               "01EAFVC10PRG19DD25FEYAQAZK",
+		Type:             auth.OTPLogin,
+		Delivery:         auth.Phone,
+		Address:          "+15555555555",
+		Content:          "Your code is 123456",
+		ExpiresAt:        time.Now().Round(0).UTC(),
+		DeliveryAttempts: 3,
+	}
+	err = c.DeadLetter().Create(ctx, &auth.DeadLetter{
+		Message:   &msg,
+		LastError: "provider unavailable",
+	})
+	if err != nil {
+		t.Fatal("failed to create dead letter:", err)
+	}
+
+	deadLetter, err := c.DeadLetter().ByID(ctx, msg.ID)
+	if err != nil {
+		t.Fatal("failed to retrieve dead letter:", err)
+	}
+	if !cmp.Equal(deadLetter.Message, &msg) {
+		t.Error("message does not match", cmp.Diff(deadLetter.Message, &msg))
+	}
+
+	deadLetters, err := c.DeadLetter().Recent(ctx, 10, 0)
+	if err != nil {
+		t.Fatal("failed to retrieve dead letters:", err)
+	}
+	if len(deadLetters) != 1 {
+		t.Errorf("incorrect number of dead letters, want 1 got %v", len(deadLetters))
+	}
+
+	client, err := c.NewWithTransaction(ctx)
+	if err != nil {
+		t.Fatal("failed to start transaction:", err)
+	}
+
+	_, err = client.WithAtomic(func() (interface{}, error) {
+		deadLetter, err := client.DeadLetter().GetForUpdate(ctx, msg.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !cmp.Equal(deadLetter.Message, &msg) {
+			t.Error("message does not match", cmp.Diff(deadLetter.Message, &msg))
+		}
+
+		return nil, client.DeadLetter().Remove(ctx, msg.ID)
+	})
+	if err != nil {
+		t.Fatal("failed to remove dead letter:", err)
+	}
+
+	err = c.DeadLetter().Remove(ctx, msg.ID)
+	if auth.ErrorCode(err) != auth.ENotFound {
+		t.Error("removed dead letter should not exist:", err)
+	}
+}
@@ -8,6 +8,13 @@ import (
 	auth "github.com/fmitra/authenticator"
 )
 
+const (
+	// maxIPAddressLen is the maximum length of a stored IP address.
+	maxIPAddressLen = 64
+	// maxUserAgentLen is the maximum length of a stored user agent.
+	maxUserAgentLen = 255
+)
+
 // LoginHistoryRepository is an implementation of auth.LoginHistoryRepository.
 type LoginHistoryRepository struct {
 	client *Client
@@ -19,6 +26,7 @@ func (r *LoginHistoryRepository) ByTokenID(ctx context.Context, tokenID string)
 	row := r.client.queryRowContext(ctx, r.client.loginHistoryQ["byTokenID"], tokenID)
 	err := row.Scan(
 		&login.UserID, &login.TokenID, &login.IsRevoked, &login.ExpiresAt,
+		&login.IPAddress, &login.UserAgent, &login.TFAMethod,
 		&login.CreatedAt, &login.UpdatedAt,
 	)
 	if err != nil {
@@ -28,7 +36,8 @@ func (r *LoginHistoryRepository) ByTokenID(ctx context.Context, tokenID string)
 	return &login, nil
 }
 
-// ByUserID retrieves all LoginHistory records associated with a User.
+// ByUserID retrieves LoginHistory records associated with a User,
+// ordered from most to least recent.
 func (r *LoginHistoryRepository) ByUserID(ctx context.Context, userID string, limit, offset int) ([]*auth.LoginHistory, error) {
 	rows, err := r.client.queryContext(
 		ctx,
@@ -47,6 +56,7 @@ func (r *LoginHistoryRepository) ByUserID(ctx context.Context, userID string, li
 		login := auth.LoginHistory{}
 		err := rows.Scan(
 			&login.UserID, &login.TokenID, &login.IsRevoked, &login.ExpiresAt,
+			&login.IPAddress, &login.UserAgent, &login.TFAMethod,
 			&login.CreatedAt, &login.UpdatedAt,
 		)
 		if err != nil {
@@ -63,6 +73,7 @@ func (r *LoginHistoryRepository) ByUserID(ctx context.Context, userID string, li
 
 // Create persists a new LoginHistory to storage.
 func (r *LoginHistoryRepository) Create(ctx context.Context, login *auth.LoginHistory) error {
+	sanitizeLoginHistory(login)
 	row := r.client.queryRowContext(
 		ctx,
 		r.client.loginHistoryQ["insert"],
@@ -70,6 +81,9 @@ func (r *LoginHistoryRepository) Create(ctx context.Context, login *auth.LoginHi
 		login.TokenID,
 		login.IsRevoked,
 		login.ExpiresAt,
+		login.IPAddress,
+		login.UserAgent,
+		login.TFAMethod,
 	)
 	return row.Scan(
 		&login.CreatedAt,
@@ -87,6 +101,7 @@ func (r *LoginHistoryRepository) Update(ctx context.Context, login *auth.LoginHi
 		r.client.loginHistoryQ["update"],
 		login.TokenID,
 		login.IsRevoked,
+		login.ExpiresAt,
 		login.UpdatedAt,
 	)
 	if err != nil {
@@ -103,12 +118,43 @@ func (r *LoginHistoryRepository) Update(ctx context.Context, login *auth.LoginHi
 	return nil
 }
 
+// RevokeByUserID revokes all unexpired LoginHistory records associated
+// with a User, except for an optional token ID.
+func (r *LoginHistoryRepository) RevokeByUserID(ctx context.Context, userID, excludeTokenID string) ([]string, error) {
+	rows, err := r.client.queryContext(
+		ctx,
+		r.client.loginHistoryQ["revokeByUserID"],
+		userID,
+		excludeTokenID,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute update: %w", err)
+	}
+	defer rows.Close()
+
+	tokenIDs := make([]string, 0)
+	for rows.Next() {
+		var tokenID string
+		if err := rows.Scan(&tokenID); err != nil {
+			return nil, err
+		}
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokenIDs, nil
+}
+
 // GetForUpdate retrieves a LoginHistory to be updated.
 func (r *LoginHistoryRepository) GetForUpdate(ctx context.Context, tokenID string) (*auth.LoginHistory, error) {
 	login := auth.LoginHistory{}
 	row := r.client.queryRowContext(ctx, r.client.loginHistoryQ["forUpdate"], tokenID)
 	err := row.Scan(
 		&login.UserID, &login.TokenID, &login.IsRevoked, &login.ExpiresAt,
+		&login.IPAddress, &login.UserAgent, &login.TFAMethod,
 		&login.CreatedAt, &login.UpdatedAt,
 	)
 	if err != nil {
@@ -117,3 +163,19 @@ func (r *LoginHistoryRepository) GetForUpdate(ctx context.Context, tokenID strin
 
 	return &login, nil
 }
+
+// sanitizeLoginHistory truncates client provided metadata
+// to fit within column limits.
+func sanitizeLoginHistory(login *auth.LoginHistory) {
+	login.IPAddress = truncate(login.IPAddress, maxIPAddressLen)
+	login.UserAgent = truncate(login.UserAgent, maxUserAgentLen)
+}
+
+// truncate shortens a string to a maximum number of characters.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -46,6 +46,9 @@ func TestLoginHistoryRepository_ByTokenID(t *testing.T) {
 		TokenID:   tokenID.String(),
 		IsRevoked: false,
 		ExpiresAt: time.Now().Add(time.Minute * 30),
+		IPAddress: "127.0.0.1",
+		UserAgent: "Mozilla/5.0",
+		TFAMethod: auth.TOTP,
 	}
 	err = c.LoginHistory().Create(ctx, &login)
 	if err != nil {
@@ -62,6 +65,24 @@ func TestLoginHistoryRepository_ByTokenID(t *testing.T) {
 			fetchedLogin.TokenID, login.TokenID,
 		))
 	}
+
+	if !cmp.Equal(fetchedLogin.IPAddress, login.IPAddress) {
+		t.Error("LoginHistory.IPAddress does not match", cmp.Diff(
+			fetchedLogin.IPAddress, login.IPAddress,
+		))
+	}
+
+	if !cmp.Equal(fetchedLogin.UserAgent, login.UserAgent) {
+		t.Error("LoginHistory.UserAgent does not match", cmp.Diff(
+			fetchedLogin.UserAgent, login.UserAgent,
+		))
+	}
+
+	if !cmp.Equal(fetchedLogin.TFAMethod, login.TFAMethod) {
+		t.Error("LoginHistory.TFAMethod does not match", cmp.Diff(
+			fetchedLogin.TFAMethod, login.TFAMethod,
+		))
+	}
 }
 
 func TestLoginHistoryRepository_Create(t *testing.T) {
@@ -227,6 +248,7 @@ func TestLoginHistoryRepository_Update(t *testing.T) {
 		t.Fatal("failed to create loginhistory:", err)
 	}
 
+	expiresAt := time.Now().Add(time.Hour * 24).UTC().Truncate(time.Second)
 	client, err := c.NewWithTransaction(ctx)
 	if err != nil {
 		t.Fatal("failed to start transaction:", err)
@@ -239,6 +261,7 @@ func TestLoginHistoryRepository_Update(t *testing.T) {
 		}
 
 		login.IsRevoked = true
+		login.ExpiresAt = expiresAt
 		err = client.LoginHistory().Update(ctx, login)
 		if err != nil {
 			return nil, err
@@ -258,4 +281,84 @@ func TestLoginHistoryRepository_Update(t *testing.T) {
 		t.Errorf("login IDs do not match: want %s got %s",
 			login.TokenID, updatedLogin.TokenID)
 	}
+
+	storedLogin, err := c.LoginHistory().ByTokenID(ctx, login.TokenID)
+	if err != nil {
+		t.Fatal("failed to retrieve loginhistory:", err)
+	}
+	if !storedLogin.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("login expiry is not updated: want %v got %v",
+			expiresAt, storedLogin.ExpiresAt)
+	}
+}
+
+func TestLoginHistoryRepository_RevokeByUserID(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	c := TestClient(pgDB.DB)
+
+	ctx := context.Background()
+	user := auth.User{
+		Password:  "swordfish",
+		TFASecret: "tfa_secret",
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+	err = c.User().Create(ctx, &user)
+	if err != nil {
+		t.Fatal("failed to create user:", err)
+	}
+
+	logins := []*auth.LoginHistory{
+		{ExpiresAt: time.Now().Add(time.Minute * 30)},
+		{ExpiresAt: time.Now().Add(time.Minute * 30)},
+		{ExpiresAt: time.Now().Add(time.Minute * 30), IsRevoked: true},
+		{ExpiresAt: time.Now().Add(-time.Minute * 30)},
+	}
+	for _, login := range logins {
+		tokenID, err := ulid.New(ulid.Now(), c.entropy)
+		if err != nil {
+			t.Fatal("failed to generate token ID:", err)
+		}
+		login.UserID = user.ID
+		login.TokenID = tokenID.String()
+
+		err = c.LoginHistory().Create(ctx, login)
+		if err != nil {
+			t.Fatal("failed to create loginhistory:", err)
+		}
+	}
+
+	tokenIDs, err := c.LoginHistory().RevokeByUserID(ctx, user.ID, logins[0].TokenID)
+	if err != nil {
+		t.Fatal("failed to revoke loginhistory:", err)
+	}
+
+	if !cmp.Equal(tokenIDs, []string{logins[1].TokenID}) {
+		t.Error("revoked token IDs do not match", cmp.Diff(
+			tokenIDs, []string{logins[1].TokenID},
+		))
+	}
+
+	login, err := c.LoginHistory().ByTokenID(ctx, logins[0].TokenID)
+	if err != nil {
+		t.Fatal("failed to retrieve loginhistory:", err)
+	}
+	if login.IsRevoked {
+		t.Error("excluded login should not be revoked")
+	}
+
+	login, err = c.LoginHistory().ByTokenID(ctx, logins[1].TokenID)
+	if err != nil {
+		t.Fatal("failed to retrieve loginhistory:", err)
+	}
+	if !login.IsRevoked {
+		t.Error("login was not revoked")
+	}
 }
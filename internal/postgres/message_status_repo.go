@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// MessageStatusRepository is an implementation of auth.MessageStatusRepository.
+type MessageStatusRepository struct {
+	client *Client
+}
+
+// ByID retrieves the MessageStatus of a Message.
+func (r *MessageStatusRepository) ByID(ctx context.Context, messageID string) (*auth.MessageStatus, error) {
+	status := auth.MessageStatus{}
+	row := r.client.queryRowContext(ctx, r.client.messageStatusQ["byID"], messageID)
+	err := row.Scan(
+		&status.MessageID, &status.Type, &status.Delivery, &status.Status,
+		&status.DeliveryAttempts, &status.LastError,
+		&status.CreatedAt, &status.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Save persists a MessageStatus. An existing MessageStatus for the
+// same Message is updated in place.
+func (r *MessageStatusRepository) Save(ctx context.Context, status *auth.MessageStatus) error {
+	if status.MessageID == "" {
+		return auth.ErrInvalidField("message ID must be provided")
+	}
+
+	row := r.client.queryRowContext(
+		ctx,
+		r.client.messageStatusQ["upsert"],
+		status.MessageID,
+		status.Type,
+		status.Delivery,
+		status.Status,
+		status.DeliveryAttempts,
+		status.LastError,
+		time.Now().UTC(),
+	)
+	if err := row.Scan(&status.CreatedAt, &status.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save message status: %w", err)
+	}
+
+	return nil
+}
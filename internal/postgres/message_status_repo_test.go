@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestMessageStatusRepository_Save(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	ctx := context.Background()
+	status := auth.MessageStatus{
+		MessageID: "01EAFVC10PRG19DD25FEYAQAZK",
+		Type:      auth.OTPLogin,
+		Delivery:  auth.Email,
+		Status:    auth.MessageQueued,
+	}
+	if err = c.MessageStatus().Save(ctx, &status); err != nil {
+		t.Fatal("failed to create message status:", err)
+	}
+
+	status.Status = auth.MessageSent
+	status.DeliveryAttempts = 2
+	status.LastError = "provider unavailable"
+	if err = c.MessageStatus().Save(ctx, &status); err != nil {
+		t.Fatal("failed to update message status:", err)
+	}
+
+	saved, err := c.MessageStatus().ByID(ctx, status.MessageID)
+	if err != nil {
+		t.Fatal("failed to retrieve message status:", err)
+	}
+	if saved.Status != auth.MessageSent || saved.DeliveryAttempts != 2 {
+		t.Errorf("message status not updated: %+v", saved)
+	}
+	if saved.LastError != status.LastError {
+		t.Errorf("incorrect last error, want %s got %s", status.LastError, saved.LastError)
+	}
+}
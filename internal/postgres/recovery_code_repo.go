@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/crypto"
+)
+
+// RecoveryCodeRepository is an implementation of auth.RecoveryCodeRepository.
+type RecoveryCodeRepository struct {
+	client *Client
+}
+
+// Replace removes all existing recovery codes for a User and stores
+// hashes of a new set. It should be called within a transaction to
+// ensure a User is never left with a partial set of codes.
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID string, codes []string) error {
+	_, err := r.client.execContext(ctx, r.client.recoveryCodeQ["deleteByUserID"], userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove recovery codes: %w", err)
+	}
+
+	for _, code := range codes {
+		codeID, err := ulid.New(ulid.Now(), r.client.entropy)
+		if err != nil {
+			return fmt.Errorf("cannot generate unique recovery code ID: %w", err)
+		}
+
+		codeHash, err := hashRecoveryCode(code)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.client.execContext(
+			ctx,
+			r.client.recoveryCodeQ["insert"],
+			codeID.String(),
+			userID,
+			codeHash,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Consume marks an unused recovery code as used. Codes are matched
+// by their hash, so a code may only be consumed once.
+func (r *RecoveryCodeRepository) Consume(ctx context.Context, userID, code string) error {
+	codeHash, err := hashRecoveryCode(code)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.client.execContext(
+		ctx,
+		r.client.recoveryCodeQ["consume"],
+		userID,
+		codeHash,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if updatedRows == 0 {
+		return auth.ErrInvalidCode("incorrect code provided")
+	}
+
+	return nil
+}
+
+// hashRecoveryCode normalizes a recovery code before hashing so codes
+// may be submitted without separators or in a different case.
+func hashRecoveryCode(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+
+	codeHash, err := crypto.Hash(code)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash recovery code: %w", err)
+	}
+
+	return codeHash, nil
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestRecoveryCodeRepository_Consume(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	ctx := context.Background()
+	user := auth.User{
+		Password:  "swordfish",
+		TFASecret: "tfa_secret",
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+	if err = c.User().Create(ctx, &user); err != nil {
+		t.Fatal("failed to create user:", err)
+	}
+
+	err = c.RecoveryCode().Replace(ctx, user.ID, []string{"abcde-12345", "fghij-67890"})
+	if err != nil {
+		t.Fatal("failed to create recovery codes:", err)
+	}
+
+	tt := []struct {
+		name    string
+		code    string
+		errCode auth.ErrCode
+	}{
+		{
+			name:    "Consumes code",
+			code:    "abcde-12345",
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Rejects used code",
+			code:    "abcde-12345",
+			errCode: auth.EInvalidCode,
+		},
+		{
+			name:    "Consumes normalized code",
+			code:    " FGHIJ67890 ",
+			errCode: auth.ErrCode(""),
+		},
+		{
+			name:    "Rejects unknown code",
+			code:    "klmno-12345",
+			errCode: auth.EInvalidCode,
+		},
+	}
+
+	for _, tc := range tt {
+		err = c.RecoveryCode().Consume(ctx, user.ID, tc.code)
+		if auth.ErrorCode(err) != tc.errCode {
+			t.Errorf("%s: incorrect error code, want %s got %s",
+				tc.name, tc.errCode, auth.ErrorCode(err))
+		}
+	}
+
+	err = c.RecoveryCode().Replace(ctx, user.ID, []string{"abcde-12345"})
+	if err != nil {
+		t.Fatal("failed to replace recovery codes:", err)
+	}
+
+	if err = c.RecoveryCode().Consume(ctx, user.ID, "fghij-67890"); auth.ErrorCode(err) != auth.EInvalidCode {
+		t.Error("replaced recovery codes should not be valid:", err)
+	}
+	if err = c.RecoveryCode().Consume(ctx, user.ID, "abcde-12345"); err != nil {
+		t.Error("new recovery code should be valid:", err)
+	}
+}
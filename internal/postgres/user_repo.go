@@ -17,6 +17,8 @@ import (
 type UserRepository struct {
 	client   *Client
 	password auth.PasswordService
+	// passwordless allows Users to be created without a password.
+	passwordless bool
 }
 
 // ByIdentity retrieves a User by their phone, email, or unique ID.
@@ -39,9 +41,10 @@ func (r *UserRepository) ByIdentity(ctx context.Context, attribute, value string
 
 	row := r.client.queryRowContext(ctx, r.client.userQ[q], value)
 	err := row.Scan(
-		&user.ID, &user.Phone, &user.Email, &user.Password, &user.TFASecret,
-		&user.IsEmailOTPAllowed, &user.IsPhoneOTPAllowed, &user.IsTOTPAllowed, &user.IsDeviceAllowed,
-		&user.IsVerified, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Phone, &user.Email, &user.Password, &user.TFASecret, &user.HOTPSecret, &user.HOTPCounter,
+		&user.IsEmailOTPAllowed, &user.IsPhoneOTPAllowed, &user.IsTOTPAllowed, &user.IsHOTPAllowed,
+		&user.IsDeviceAllowed, &user.IsRecoveryCodeAllowed, &user.IsVerified, &user.Locale,
+		&user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -89,11 +92,16 @@ func (r *UserRepository) Create(ctx context.Context, user *auth.User) error {
 		user.Email,
 		user.Password,
 		user.TFASecret,
+		user.HOTPSecret,
+		user.HOTPCounter,
 		user.IsEmailOTPAllowed,
 		user.IsPhoneOTPAllowed,
 		user.IsTOTPAllowed,
+		user.IsHOTPAllowed,
 		user.IsDeviceAllowed,
+		user.IsRecoveryCodeAllowed,
 		user.IsVerified,
+		user.Locale,
 	)
 	err = row.Scan(
 		&user.CreatedAt,
@@ -143,14 +151,117 @@ func (r *UserRepository) Update(ctx context.Context, user *auth.User) error {
 	return r.update(ctx, user.ID, user)
 }
 
+// List retrieves Users ordered by ID, starting after the User with
+// afterID. An empty afterID starts from the first User.
+func (r *UserRepository) List(ctx context.Context, afterID string, limit int) ([]*auth.User, error) {
+	rows, err := r.client.queryContext(ctx, r.client.userQ["list"], afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*auth.User, 0)
+	for rows.Next() {
+		user := auth.User{}
+		err := rows.Scan(
+			&user.ID, &user.Phone, &user.Email, &user.Password, &user.TFASecret, &user.HOTPSecret, &user.HOTPCounter,
+			&user.IsEmailOTPAllowed, &user.IsPhoneOTPAllowed, &user.IsTOTPAllowed, &user.IsHOTPAllowed,
+			&user.IsDeviceAllowed, &user.IsRecoveryCodeAllowed, &user.IsVerified, &user.Locale,
+			&user.CreatedAt, &user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// UpdateTFASecret replaces a User's TFA secret if it is unchanged
+// from the current value. It returns false if the secret was changed
+// since it was read.
+func (r *UserRepository) UpdateTFASecret(ctx context.Context, userID, current, secret string) (bool, error) {
+	res, err := r.client.execContext(
+		ctx,
+		r.client.userQ["updateTFASecret"],
+		userID,
+		current,
+		secret,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+
+	return updatedRows == 1, nil
+}
+
+// UpdateHOTPCounter replaces a User's HOTP counter if it is unchanged
+// from the current value. It returns false if the counter was changed
+// since it was read, indicating a code was accepted concurrently.
+func (r *UserRepository) UpdateHOTPCounter(ctx context.Context, userID string, current, counter int64) (bool, error) {
+	res, err := r.client.execContext(
+		ctx,
+		r.client.userQ["updateHOTPCounter"],
+		userID,
+		current,
+		counter,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+
+	return updatedRows == 1, nil
+}
+
+// UpdatePassword replaces a User's password hash if it is unchanged
+// from the current value. It returns false if the password was changed
+// since it was read.
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, current, hash string) (bool, error) {
+	res, err := r.client.execContext(
+		ctx,
+		r.client.userQ["updatePassword"],
+		userID,
+		current,
+		hash,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute update: %w", err)
+	}
+
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+
+	return updatedRows == 1, nil
+}
+
 // GetForUpdate retrieves a User to be updated.
 func (r *UserRepository) GetForUpdate(ctx context.Context, userID string) (*auth.User, error) {
 	user := auth.User{}
 	row := r.client.queryRowContext(ctx, r.client.userQ["forUpdate"], userID)
 	err := row.Scan(
-		&user.ID, &user.Phone, &user.Email, &user.Password, &user.TFASecret,
-		&user.IsEmailOTPAllowed, &user.IsPhoneOTPAllowed, &user.IsTOTPAllowed, &user.IsDeviceAllowed,
-		&user.IsVerified, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Phone, &user.Email, &user.Password, &user.TFASecret, &user.HOTPSecret, &user.HOTPCounter,
+		&user.IsEmailOTPAllowed, &user.IsPhoneOTPAllowed, &user.IsTOTPAllowed, &user.IsHOTPAllowed,
+		&user.IsDeviceAllowed, &user.IsRecoveryCodeAllowed, &user.IsVerified, &user.Locale,
+		&user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve record for update: %w", err)
@@ -189,7 +300,7 @@ func (r *UserRepository) RemoveDeliveryMethod(ctx context.Context, userID string
 		}
 
 		isTFADisabled := !user.IsPhoneOTPAllowed && !user.IsEmailOTPAllowed &&
-			!user.IsDeviceAllowed && !user.IsTOTPAllowed
+			!user.IsDeviceAllowed && !user.IsTOTPAllowed && !user.IsHOTPAllowed
 
 		isContactDisabled := !user.Phone.Valid && !user.Email.Valid
 
@@ -241,7 +352,7 @@ func (r *UserRepository) DisableOTP(ctx context.Context, userID string, method a
 		}
 
 		isTFADisabled := !user.IsPhoneOTPAllowed && !user.IsEmailOTPAllowed &&
-			!user.IsDeviceAllowed && !user.IsTOTPAllowed
+			!user.IsDeviceAllowed && !user.IsTOTPAllowed && !user.IsHOTPAllowed
 
 		if isTFADisabled {
 			return nil, auth.ErrInvalidField(
@@ -275,11 +386,16 @@ func (r *UserRepository) update(ctx context.Context, userID string, user *auth.U
 		user.Email,
 		user.Password,
 		user.TFASecret,
+		user.HOTPSecret,
+		user.HOTPCounter,
 		user.IsEmailOTPAllowed,
 		user.IsPhoneOTPAllowed,
 		user.IsTOTPAllowed,
+		user.IsHOTPAllowed,
 		user.IsDeviceAllowed,
+		user.IsRecoveryCodeAllowed,
 		user.IsVerified,
+		user.Locale,
 		// We support updating CreatedAt and ID fields
 		// in order to treat re-registrations
 		// of unverified users as a new user.
@@ -302,6 +418,10 @@ func (r *UserRepository) update(ctx context.Context, userID string, user *auth.U
 }
 
 func (r *UserRepository) hashPassword(user *auth.User) error {
+	if r.passwordless && user.Password == "" {
+		return nil
+	}
+
 	err := r.password.OKForUser(user.Password)
 	if err != nil {
 		return err
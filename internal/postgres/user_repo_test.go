@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/oklog/ulid/v2"
 
 	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/password"
 	"github.com/fmitra/authenticator/internal/test"
 )
 
@@ -126,6 +128,79 @@ func TestUserRepository_Create(t *testing.T) {
 	}
 }
 
+func TestUserRepository_CreatePasswordless(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	tt := []struct {
+		name         string
+		passwordless bool
+		password     string
+		isCreated    bool
+	}{
+		{
+			name:         "No password failure",
+			passwordless: false,
+			password:     "",
+			isCreated:    false,
+		},
+		{
+			name:         "Passwordless success",
+			passwordless: true,
+			password:     "",
+			isCreated:    true,
+		},
+		{
+			name:         "Passwordless with invalid password failure",
+			passwordless: true,
+			password:     "short",
+			isCreated:    false,
+		},
+	}
+
+	for i, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient(
+				WithPassword(password.NewPassword()),
+				WithPasswordless(tc.passwordless),
+				WithDB(pgDB.DB),
+			)
+			user := auth.User{
+				Password:  tc.password,
+				TFASecret: "tfa_secret",
+				Email: sql.NullString{
+					String: fmt.Sprintf("jane-%d@example.com", i),
+					Valid:  true,
+				},
+			}
+			ctx := context.Background()
+			err = c.User().Create(ctx, &user)
+			if tc.isCreated && err != nil {
+				t.Fatal("failed to create user:", err)
+			}
+
+			if !tc.isCreated && auth.DomainError(err) == nil {
+				t.Error("user creation should be blocked by domain error")
+			}
+
+			if !tc.isCreated {
+				return
+			}
+
+			user2, err := c.User().ByIdentity(ctx, "ID", user.ID)
+			if err != nil {
+				t.Fatal("failed to retrieve user:", err)
+			}
+			if user2.Password != "" {
+				t.Errorf("incorrect password, want '' got %s", user2.Password)
+			}
+		})
+	}
+}
+
 func TestUserRepository_ByIdentity(t *testing.T) {
 	pgDB, err := test.NewPGDB()
 	if err != nil {
@@ -244,6 +319,7 @@ func TestUserRepository_Update(t *testing.T) {
 			String: "john@example.com",
 			Valid:  true,
 		}
+		user.Locale = "pt-BR"
 		err = client.User().Update(ctx, user)
 		if err != nil {
 			return nil, err
@@ -263,6 +339,202 @@ func TestUserRepository_Update(t *testing.T) {
 		t.Errorf("user IDs do not match: want %s got %s",
 			user.ID, updatedUser.ID)
 	}
+
+	storedUser, err := c.User().ByIdentity(ctx, "ID", user.ID)
+	if err != nil {
+		t.Fatal("failed to retrieve user:", err)
+	}
+	if storedUser.Locale != "pt-BR" {
+		t.Errorf("user locale is not updated: want %s got %s",
+			"pt-BR", storedUser.Locale)
+	}
+}
+
+func TestUserRepository_List(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	ctx := context.Background()
+	var userIDs []string
+	for i := 0; i < 3; i++ {
+		user := auth.User{
+			Password:  "swordfish",
+			TFASecret: "tfa_secret",
+			Email: sql.NullString{
+				String: fmt.Sprintf("jane-%v@example.com", i),
+				Valid:  true,
+			},
+		}
+		if err = c.User().Create(ctx, &user); err != nil {
+			t.Fatal("failed to create user:", err)
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+
+	var listedIDs []string
+	afterID := ""
+	for {
+		users, err := c.User().List(ctx, afterID, 2)
+		if err != nil {
+			t.Fatal("failed to list users:", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		for _, u := range users {
+			listedIDs = append(listedIDs, u.ID)
+			afterID = u.ID
+		}
+	}
+
+	if !cmp.Equal(listedIDs, userIDs) {
+		t.Error("listed users do not match", cmp.Diff(listedIDs, userIDs))
+	}
+}
+
+func TestUserRepository_UpdateTFASecret(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	user := auth.User{
+		Password:  "swordfish",
+		TFASecret: "tfa_secret",
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+	ctx := context.Background()
+	if err = c.User().Create(ctx, &user); err != nil {
+		t.Fatal("failed to create user:", err)
+	}
+
+	isUpdated, err := c.User().UpdateTFASecret(ctx, user.ID, "stale_secret", "new_secret")
+	if err != nil {
+		t.Fatal("failed to update secret:", err)
+	}
+	if isUpdated {
+		t.Error("secret should not be updated from a stale value")
+	}
+
+	isUpdated, err = c.User().UpdateTFASecret(ctx, user.ID, "tfa_secret", "new_secret")
+	if err != nil {
+		t.Fatal("failed to update secret:", err)
+	}
+	if !isUpdated {
+		t.Error("secret should be updated")
+	}
+
+	storedUser, err := c.User().ByIdentity(ctx, "ID", user.ID)
+	if err != nil {
+		t.Fatal("failed to retrieve user:", err)
+	}
+	if storedUser.TFASecret != "new_secret" {
+		t.Errorf("user secret is not updated: want %s got %s",
+			"new_secret", storedUser.TFASecret)
+	}
+}
+
+func TestUserRepository_UpdateHOTPCounter(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	user := auth.User{
+		Password:      "swordfish",
+		HOTPSecret:    "hotp_secret",
+		HOTPCounter:   5,
+		IsHOTPAllowed: true,
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+	ctx := context.Background()
+	if err = c.User().Create(ctx, &user); err != nil {
+		t.Fatal("failed to create user:", err)
+	}
+
+	isUpdated, err := c.User().UpdateHOTPCounter(ctx, user.ID, 4, 6)
+	if err != nil {
+		t.Fatal("failed to update counter:", err)
+	}
+	if isUpdated {
+		t.Error("counter should not be updated from a stale value")
+	}
+
+	isUpdated, err = c.User().UpdateHOTPCounter(ctx, user.ID, 5, 6)
+	if err != nil {
+		t.Fatal("failed to update counter:", err)
+	}
+	if !isUpdated {
+		t.Error("counter should be updated")
+	}
+
+	storedUser, err := c.User().ByIdentity(ctx, "ID", user.ID)
+	if err != nil {
+		t.Fatal("failed to retrieve user:", err)
+	}
+	if storedUser.HOTPCounter != 6 || !storedUser.IsHOTPAllowed || storedUser.HOTPSecret != "hotp_secret" {
+		t.Errorf("user HOTP token is not stored: got counter %v, allowed %v, secret %s",
+			storedUser.HOTPCounter, storedUser.IsHOTPAllowed, storedUser.HOTPSecret)
+	}
+}
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+	c := TestClient(pgDB.DB)
+
+	user := auth.User{
+		Password: "swordfish",
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+	ctx := context.Background()
+	if err = c.User().Create(ctx, &user); err != nil {
+		t.Fatal("failed to create user:", err)
+	}
+
+	isUpdated, err := c.User().UpdatePassword(ctx, user.ID, "stale_hash", "new_hash")
+	if err != nil {
+		t.Fatal("failed to update password:", err)
+	}
+	if isUpdated {
+		t.Error("password should not be updated from a stale hash")
+	}
+
+	isUpdated, err = c.User().UpdatePassword(ctx, user.ID, user.Password, "new_hash")
+	if err != nil {
+		t.Fatal("failed to update password:", err)
+	}
+	if !isUpdated {
+		t.Error("password should be updated")
+	}
+
+	storedUser, err := c.User().ByIdentity(ctx, "ID", user.ID)
+	if err != nil {
+		t.Fatal("failed to retrieve user:", err)
+	}
+	if storedUser.Password != "new_hash" {
+		t.Errorf("password hash is not stored: got %s", storedUser.Password)
+	}
 }
 
 func TestUserRepository_ReCreateFailure(t *testing.T) {
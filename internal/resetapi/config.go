@@ -0,0 +1,92 @@
+package resetapi
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// NewService returns a new implementation of auth.ResetAPI.
+func NewService(options ...ConfigOption) auth.ResetAPI {
+	s := service{
+		logger:      log.NewNopLogger(),
+		tokenExpiry: time.Minute * 5,
+	}
+
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	return &s
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*service)
+
+// WithLogger configures the service with a logger.
+func WithLogger(l log.Logger) ConfigOption {
+	return func(s *service) {
+		s.logger = l
+	}
+}
+
+// WithTokenService configures the service with a new TokenService.
+func WithTokenService(tokenSvc auth.TokenService) ConfigOption {
+	return func(s *service) {
+		s.token = tokenSvc
+	}
+}
+
+// WithRepoManager configures the service with a new RepositoryManager.
+func WithRepoManager(repoMngr auth.RepositoryManager) ConfigOption {
+	return func(s *service) {
+		s.repoMngr = repoMngr
+	}
+}
+
+// WithWebAuthn configures the service with a WebAuthn library.
+func WithWebAuthn(w auth.WebAuthnService) ConfigOption {
+	return func(s *service) {
+		s.webauthn = w
+	}
+}
+
+// WithOTP configures the service with an OTP validator.
+func WithOTP(o auth.OTPService) ConfigOption {
+	return func(s *service) {
+		s.otp = o
+	}
+}
+
+// WithMessaging configures the service with a MessagingService.
+func WithMessaging(m auth.MessagingService) ConfigOption {
+	return func(s *service) {
+		s.message = m
+	}
+}
+
+// WithPassword configures the service with a PasswordService.
+func WithPassword(p auth.PasswordService) ConfigOption {
+	return func(s *service) {
+		s.password = p
+	}
+}
+
+// WithLockout configures the service with a LockoutService to restrict
+// verification attempts after repeated failures. Failures are shared
+// with login, so an account locked during login cannot be reset.
+func WithLockout(l auth.LockoutService) ConfigOption {
+	return func(s *service) {
+		s.lockout = l
+	}
+}
+
+// WithTokenExpiry configures the lifetime of a reset_authorized token.
+// Defaults to 5 minutes.
+func WithTokenExpiry(d time.Duration) ConfigOption {
+	return func(s *service) {
+		s.tokenExpiry = d
+	}
+}
@@ -0,0 +1,62 @@
+package resetapi
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+)
+
+// SetupHTTPHandler converts a service's public methods
+// to http handlers.
+func SetupHTTPHandler(svc auth.ResetAPI, router *mux.Router, tokenSvc auth.TokenService, logger log.Logger, lmt httpapi.LimiterFactory) {
+	var handler httpapi.JSONAPIHandler
+	{
+		handler = svc.Request
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"ResetAPI.Request", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/reset", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.DeviceChallenge, tokenSvc, auth.JWTResetPreAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"ResetAPI.DeviceChallenge", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/reset/verify-device", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.VerifyDevice, tokenSvc, auth.JWTResetPreAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"ResetAPI.VerifyDevice", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/reset/verify-device", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.VerifyCode, tokenSvc, auth.JWTResetPreAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"ResetAPI.VerifyCode", httpapi.PerMinute, int64(10),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/reset/verify-code", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.Reset, tokenSvc, auth.JWTResetAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"ResetAPI.Reset", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/reset/password", httpHandler).Methods("Post")
+	}
+}
@@ -0,0 +1,896 @@
+package resetapi
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/password"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestResetAPI_Request(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		reqBody        []byte
+		messagingCalls int
+		errMessage     string
+		userFn         func() (*auth.User, error)
+		tokenCreateFn  func() (*auth.Token, error)
+	}{
+		{
+			name:       "Decoy token for non existent user",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "",
+			userFn: func() (*auth.User, error) {
+				return nil, sql.ErrNoRows
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+					Code:     test.OTPCode,
+				}, nil
+			},
+		},
+		{
+			name:       "Decoy token for unverified user",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: false}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+					Code:     test.OTPCode,
+				}, nil
+			},
+		},
+		{
+			name:       "Invalid request failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "Identity type must be email or phone",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{}, nil
+			},
+		},
+		{
+			name:       "Token creation failure",
+			statusCode: http.StatusInternalServerError,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "An internal error occurred",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return nil, fmt.Errorf("can't create token")
+			},
+		},
+		{
+			name:       "Successful request with OTP",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 1,
+			errMessage:     "",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{
+					IsVerified:        true,
+					IsEmailOTPAllowed: true,
+					Email: sql.NullString{
+						String: "jane@example.com",
+						Valid:  true,
+					},
+				}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+					Code:     test.OTPCode,
+				}, nil
+			},
+		},
+		{
+			name:       "Successful request with TOTP",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com"
+			}`),
+			messagingCalls: 0,
+			errMessage:     "",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true, IsTOTPAllowed: true}, nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: tc.userFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				CreateFn: tc.tokenCreateFn,
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			messagingSvc := &test.MessagingService{}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(messagingSvc),
+			)
+
+			req, err := http.NewRequest("POST", "/api/v1/reset", bytes.NewBuffer(tc.reqBody))
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if messagingSvc.Calls.Send != tc.messagingCalls {
+				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
+					tc.messagingCalls, messagingSvc.Calls.Send)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestResetAPI_VerifyDevice(t *testing.T) {
+	tt := []struct {
+		name              string
+		statusCode        int
+		errMessage        string
+		loginHistoryCalls int
+		webauthnFn        func() error
+		tokenValidateFn   func() (*auth.Token, error)
+		lockoutCheckFn    func() error
+		failCalls         int
+		resetCalls        int
+	}{
+		{
+			name:              "Invalid token failure",
+			statusCode:        http.StatusUnauthorized,
+			errMessage:        "Token state is not supported",
+			loginHistoryCalls: 0,
+			webauthnFn: func() error {
+				return nil
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+		},
+		{
+			name:              "Webauthn failure",
+			statusCode:        http.StatusBadRequest,
+			errMessage:        "Failed to login",
+			loginHistoryCalls: 0,
+			webauthnFn: func() error {
+				return auth.ErrWebAuthn("failed to login")
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
+			},
+			failCalls: 1,
+		},
+		{
+			name:              "Locked account failure",
+			statusCode:        http.StatusLocked,
+			errMessage:        "Account is temporarily locked",
+			loginHistoryCalls: 0,
+			webauthnFn: func() error {
+				return nil
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+		{
+			name:              "Successful request",
+			statusCode:        http.StatusOK,
+			errMessage:        "",
+			loginHistoryCalls: 1,
+			webauthnFn: func() error {
+				return nil
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
+			},
+			resetCalls: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return &auth.User{IsDeviceAllowed: true}, nil
+				},
+			}
+			loginHistoryRepo := &test.LoginHistoryRepository{}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+				LoginHistoryFn: func() auth.LoginHistoryRepository {
+					return loginHistoryRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTResetAuthorized}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			webauthnSvc := &test.WebAuthnService{
+				FinishLoginFn: tc.webauthnFn,
+			}
+			lockoutSvc := &test.LockoutService{
+				CheckFn: tc.lockoutCheckFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithWebAuthn(webauthnSvc),
+				WithMessaging(&test.MessagingService{}),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest("POST", "/api/v1/reset/verify-device", nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if loginHistoryRepo.Calls.Create != tc.loginHistoryCalls {
+				t.Errorf("incorrect LoginHistoryRepository.Create() call count, want %v got %v",
+					tc.loginHistoryCalls, loginHistoryRepo.Calls.Create)
+			}
+
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if lockoutSvc.Calls.Reset != tc.resetCalls {
+				t.Errorf("incorrect LockoutService.Reset() call count, want %v got %v",
+					tc.resetCalls, lockoutSvc.Calls.Reset)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestResetAPI_VerifyCode(t *testing.T) {
+	tt := []struct {
+		name              string
+		statusCode        int
+		reqBody           []byte
+		errMessage        string
+		loginHistoryCalls int
+		tokenValidateFn   func() (*auth.Token, error)
+		loginHistoryFn    func() error
+		userErr           error
+		lockoutCheckFn    func() error
+		failCalls         int
+		resetCalls        int
+	}{
+		{
+			name:              "Invalid token failure",
+			statusCode:        http.StatusUnauthorized,
+			reqBody:           []byte(`{"code": "123456"}`),
+			errMessage:        "Token state is not supported",
+			loginHistoryCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:              "Invalid code failure",
+			statusCode:        http.StatusBadRequest,
+			reqBody:           []byte(`{"code": "000000"}`),
+			errMessage:        "OTP code is invalid",
+			loginHistoryCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+			failCalls: 1,
+		},
+		{
+			name:              "Locked account failure",
+			statusCode:        http.StatusLocked,
+			reqBody:           []byte(`{"code": "123456"}`),
+			errMessage:        "Account is temporarily locked",
+			loginHistoryCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+			lockoutCheckFn: func() error {
+				return auth.ErrAccountLocked("account is temporarily locked")
+			},
+		},
+		{
+			name:              "Decoy token failure",
+			statusCode:        http.StatusBadRequest,
+			reqBody:           []byte(`{"code": "123456"}`),
+			errMessage:        "Incorrect code provided",
+			loginHistoryCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+			userErr: sql.ErrNoRows,
+		},
+		{
+			name:              "Persist login history failure",
+			statusCode:        http.StatusBadRequest,
+			reqBody:           []byte(`{"code": "123456"}`),
+			errMessage:        "Cannot save history",
+			loginHistoryCalls: 1,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return auth.ErrBadRequest("cannot save history")
+			},
+			resetCalls: 1,
+		},
+		{
+			name:              "Successful request",
+			statusCode:        http.StatusOK,
+			reqBody:           []byte(`{"code": "123456"}`),
+			errMessage:        "",
+			loginHistoryCalls: 1,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+				}, nil
+			},
+			loginHistoryFn: func() error {
+				return nil
+			},
+			resetCalls: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					if tc.userErr != nil {
+						return nil, tc.userErr
+					}
+					return &auth.User{IsEmailOTPAllowed: true}, nil
+				},
+			}
+			loginHistoryRepo := &test.LoginHistoryRepository{
+				CreateFn: tc.loginHistoryFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+				LoginHistoryFn: func() auth.LoginHistoryRepository {
+					return loginHistoryRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTResetAuthorized}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			otpSvc := &test.OTPService{
+				ValidateOTPFn: func(ctx context.Context, id, code, hash string) error {
+					if code != test.OTPCode {
+						return auth.ErrInvalidCode("OTP code is invalid")
+					}
+					return nil
+				},
+			}
+			lockoutSvc := &test.LockoutService{
+				CheckFn: tc.lockoutCheckFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithOTP(otpSvc),
+				WithMessaging(&test.MessagingService{}),
+				WithLockout(lockoutSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/reset/verify-code",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if loginHistoryRepo.Calls.Create != tc.loginHistoryCalls {
+				t.Errorf("incorrect LoginHistoryRepository.Create() call count, want %v got %v",
+					tc.loginHistoryCalls, loginHistoryRepo.Calls.Create)
+			}
+
+			if lockoutSvc.Calls.Fail != tc.failCalls {
+				t.Errorf("incorrect LockoutService.Fail() call count, want %v got %v",
+					tc.failCalls, lockoutSvc.Calls.Fail)
+			}
+
+			if lockoutSvc.Calls.Reset != tc.resetCalls {
+				t.Errorf("incorrect LockoutService.Reset() call count, want %v got %v",
+					tc.resetCalls, lockoutSvc.Calls.Reset)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestResetAPI_VerifyHOTPCode(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		reqBody        []byte
+		errMessage     string
+		user           *auth.User
+		updateFn       func(userID string, current, counter int64) (bool, error)
+		validateCalls  int
+		totpCalls      int
+		updateCalls    int
+		loginHistories int
+	}{
+		{
+			name:       "Verifies HOTP only user without method",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"code": "123456"}`),
+			user:       &auth.User{IsHOTPAllowed: true, HOTPCounter: 4},
+			updateFn: func(userID string, current, counter int64) (bool, error) {
+				if current != 4 || counter != 5 {
+					return false, fmt.Errorf("incorrect counter update from %v to %v", current, counter)
+				}
+				return true, nil
+			},
+			validateCalls:  1,
+			totpCalls:      0,
+			updateCalls:    1,
+			loginHistories: 1,
+		},
+		{
+			name:       "Verifies HOTP with method",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"code": "123456", "method": "hotp"}`),
+			user:       &auth.User{IsHOTPAllowed: true, IsTOTPAllowed: true, HOTPCounter: 4},
+			updateFn: func(userID string, current, counter int64) (bool, error) {
+				return true, nil
+			},
+			validateCalls:  1,
+			totpCalls:      0,
+			updateCalls:    1,
+			loginHistories: 1,
+		},
+		{
+			name:           "Verifies TOTP for user with TOTP and HOTP",
+			statusCode:     http.StatusOK,
+			reqBody:        []byte(`{"code": "123456"}`),
+			user:           &auth.User{IsHOTPAllowed: true, IsTOTPAllowed: true},
+			validateCalls:  0,
+			totpCalls:      1,
+			updateCalls:    0,
+			loginHistories: 1,
+		},
+		{
+			name:       "Rejects code accepted concurrently",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"code": "123456"}`),
+			errMessage: "Code is no longer valid",
+			user:       &auth.User{IsHOTPAllowed: true, HOTPCounter: 4},
+			updateFn: func(userID string, current, counter int64) (bool, error) {
+				return false, nil
+			},
+			validateCalls:  1,
+			totpCalls:      0,
+			updateCalls:    1,
+			loginHistories: 0,
+		},
+		{
+			name:           "Rejects HOTP method without HOTP enabled",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"code": "123456", "method": "hotp"}`),
+			errMessage:     "HOTP is not enabled",
+			user:           &auth.User{IsTOTPAllowed: true},
+			validateCalls:  0,
+			totpCalls:      0,
+			updateCalls:    0,
+			loginHistories: 0,
+		},
+		{
+			name:           "Rejects unknown method",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"code": "123456", "method": "sms"}`),
+			errMessage:     "Method must be `totp` or `hotp`",
+			user:           &auth.User{IsHOTPAllowed: true},
+			validateCalls:  0,
+			totpCalls:      0,
+			updateCalls:    0,
+			loginHistories: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+				UpdateHOTPCounterFn: tc.updateFn,
+			}
+			loginHistoryRepo := &test.LoginHistoryRepository{}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+				LoginHistoryFn: func() auth.LoginHistoryRepository {
+					return loginHistoryRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
+				},
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTResetAuthorized}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			otpSvc := &test.OTPService{
+				ValidateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+					return u.HOTPCounter + 1, nil
+				},
+				ValidateTOTPFn: func(ctx context.Context, u *auth.User, code string) error {
+					return nil
+				},
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithOTP(otpSvc),
+				WithMessaging(&test.MessagingService{}),
+				WithLockout(&test.LockoutService{}),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/reset/verify-code",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if otpSvc.Calls.ValidateHOTP != tc.validateCalls {
+				t.Errorf("incorrect OTPService.ValidateHOTP() call count, want %v got %v",
+					tc.validateCalls, otpSvc.Calls.ValidateHOTP)
+			}
+
+			if otpSvc.Calls.ValidateTOTP != tc.totpCalls {
+				t.Errorf("incorrect OTPService.ValidateTOTP() call count, want %v got %v",
+					tc.totpCalls, otpSvc.Calls.ValidateTOTP)
+			}
+
+			if userRepo.Calls.UpdateHOTPCounter != tc.updateCalls {
+				t.Errorf("incorrect UserRepository.UpdateHOTPCounter() call count, want %v got %v",
+					tc.updateCalls, userRepo.Calls.UpdateHOTPCounter)
+			}
+
+			if loginHistoryRepo.Calls.Create != tc.loginHistories {
+				t.Errorf("incorrect LoginHistoryRepository.Create() call count, want %v got %v",
+					tc.loginHistories, loginHistoryRepo.Calls.Create)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestResetAPI_Reset(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		reqBody         []byte
+		errMessage      string
+		revokeCalls     int
+		tokenValidateFn func() (*auth.Token, error)
+		withAtomicFn    func() (interface{}, error)
+		revokeAllFn     func() error
+	}{
+		{
+			name:        "Invalid token failure",
+			statusCode:  http.StatusUnauthorized,
+			reqBody:     []byte(`{"password": "swordfish"}`),
+			errMessage:  "Token state is not supported",
+			revokeCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:        "Invalid password failure",
+			statusCode:  http.StatusBadRequest,
+			reqBody:     []byte(`{"password": "abc"}`),
+			errMessage:  "Password must be at least 8 characters long",
+			revokeCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:        "Reused token failure",
+			statusCode:  http.StatusUnauthorized,
+			reqBody:     []byte(`{"password": "swordfish"}`),
+			errMessage:  "Token is revoked",
+			revokeCalls: 0,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return nil, auth.ErrInvalidToken("token is revoked")
+			},
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:        "Token revocation failure",
+			statusCode:  http.StatusInternalServerError,
+			reqBody:     []byte(`{"password": "swordfish"}`),
+			errMessage:  "An internal error occurred",
+			revokeCalls: 1,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllFn: func() error {
+				return fmt.Errorf("redis connection failed")
+			},
+		},
+		{
+			name:        "Successful request",
+			statusCode:  http.StatusOK,
+			reqBody:     []byte(`{"password": "swordfish"}`),
+			errMessage:  "",
+			revokeCalls: 1,
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTResetAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			repoMngr := &test.RepositoryManager{
+				WithAtomicFn: tc.withAtomicFn,
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn:  tc.tokenValidateFn,
+				RevokeAllFn: tc.revokeAllFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithPassword(password.NewPassword(password.WithCost(4))),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/reset/password",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if tokenSvc.Calls.RevokeAll != tc.revokeCalls {
+				t.Errorf("incorrect TokenService.RevokeAll() call count, want %v got %v",
+					tc.revokeCalls, tokenSvc.Calls.RevokeAll)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package resetapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+type resetRequest struct {
+	Identity string              `json:"identity"`
+	Type     auth.DeliveryMethod `json:"type"`
+}
+
+type verifyCodeRequest struct {
+	Code string `json:"code"`
+	// Method is the 2FA option the code was generated by. It is
+	// required to submit a HOTP code if the User also has TOTP
+	// enabled.
+	Method auth.TFAOptions `json:"method"`
+}
+
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+func (r *resetRequest) UserAttribute() string {
+	switch r.Type {
+	case auth.Email:
+		return "Email"
+	case auth.Phone:
+		return "Phone"
+	default:
+		return ""
+	}
+}
+
+func decodeResetRequest(r *http.Request) (*resetRequest, error) {
+	var (
+		req resetRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	if req.UserAttribute() == "" {
+		return nil, auth.ErrBadRequest("identity type must be email or phone")
+	}
+
+	req.Identity = strings.TrimSpace(req.Identity)
+
+	return &req, nil
+}
+
+func decodeVerifyCodeRequest(r *http.Request) (*verifyCodeRequest, error) {
+	var (
+		req verifyCodeRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+
+	if req.Method != "" && req.Method != auth.TOTP && req.Method != auth.HOTP {
+		return nil, auth.ErrBadRequest("method must be `totp` or `hotp`")
+	}
+
+	return &req, nil
+}
+
+func decodePasswordRequest(r *http.Request) (*passwordRequest, error) {
+	var (
+		req passwordRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	return &req, nil
+}
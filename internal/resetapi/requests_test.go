@@ -0,0 +1,111 @@
+package resetapi
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResetAPI_ResetRequestDecode(t *testing.T) {
+	tt := []struct {
+		name     string
+		request  []byte
+		hasError bool
+	}{
+		{
+			name: "Invalid json error",
+			request: []byte(`{
+				"identity": "+15555555555",
+				"type": "phone",
+			}`),
+			hasError: true,
+		},
+		{
+			name: "Invalid attribute error",
+			request: []byte(`{
+				"identity": "janedoe",
+				"type": "username"
+			}`),
+			hasError: true,
+		},
+		{
+			name: "Valid request",
+			request: []byte(`{
+				"identity": " +15555555555 ",
+				"type": "phone"
+			}`),
+			hasError: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "", bytes.NewBuffer(tc.request))
+			if err != nil {
+				t.Fatal("failed to create mock request:", err)
+			}
+
+			req, err := decodeResetRequest(r)
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error:", err)
+			}
+			if tc.hasError && err == nil {
+				t.Error("expected error, not nil")
+			}
+			if tc.hasError && req != nil {
+				t.Error("expected nil response on error")
+			}
+			if reflect.TypeOf(req).String() != "*resetapi.resetRequest" {
+				t.Errorf("incorrect type, want *resetapi.resetRequest, got %s",
+					reflect.TypeOf(req).String())
+			}
+			if !tc.hasError && req.Identity != "+15555555555" {
+				t.Errorf("identity not trimmed, got %q", req.Identity)
+			}
+		})
+	}
+}
+
+func TestResetAPI_PasswordRequestDecode(t *testing.T) {
+	tt := []struct {
+		name     string
+		request  []byte
+		hasError bool
+	}{
+		{
+			name: "Invalid json error",
+			request: []byte(`{
+				"password": "swordfish",
+			}`),
+			hasError: true,
+		},
+		{
+			name: "Valid request",
+			request: []byte(`{
+				"password": "swordfish"
+			}`),
+			hasError: false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "", bytes.NewBuffer(tc.request))
+			if err != nil {
+				t.Fatal("failed to create mock request:", err)
+			}
+
+			req, err := decodePasswordRequest(r)
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error:", err)
+			}
+			if tc.hasError && err == nil {
+				t.Error("expected error, not nil")
+			}
+			if tc.hasError && req != nil {
+				t.Error("expected nil response on error")
+			}
+		})
+	}
+}
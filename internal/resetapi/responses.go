@@ -0,0 +1,6 @@
+package resetapi
+
+// Response is a success response.
+type Response struct {
+	Result string `json:"result"`
+}
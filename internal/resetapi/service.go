@@ -0,0 +1,351 @@
+// Package resetapi provides an HTTP API for password reset.
+package resetapi
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid/v2"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/otp"
+	"github.com/fmitra/authenticator/internal/token"
+)
+
+type service struct {
+	logger      log.Logger
+	token       auth.TokenService
+	repoMngr    auth.RepositoryManager
+	otp         auth.OTPService
+	password    auth.PasswordService
+	webauthn    auth.WebAuthnService
+	message     auth.MessagingService
+	lockout     auth.LockoutService
+	tokenExpiry time.Duration
+}
+
+// Request is the initial password reset step to identify a User.
+// Identities which cannot be reset receive a decoy token in the same
+// shape as a real one, without a message being delivered, so that the
+// response does not reveal which accounts exist.
+func (s *service) Request(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+
+	req, err := decodeResetRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, req.UserAttribute(), req.Identity)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	isDecoy := err == sql.ErrNoRows || !user.IsVerified
+	if isDecoy {
+		user, err = decoyUser(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var jwtToken *auth.Token
+
+	if user.CanSendDefaultOTP() {
+		jwtToken, err = s.token.Create(
+			ctx,
+			user,
+			auth.JWTResetPreAuthorized,
+			token.WithOTPDeliveryMethod(user.DefaultOTPDelivery()),
+			token.WithOTPMessageType(auth.OTPReset),
+		)
+	} else {
+		jwtToken, err = s.token.Create(ctx, user, auth.JWTResetPreAuthorized)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !isDecoy {
+		if err = s.sendCode(ctx, user, jwtToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.respond(ctx, w, jwtToken)
+}
+
+// DeviceChallenge requests a challenge to be signed by the client.
+// This is a pre step in order to verify a User's Device.
+func (s *service) DeviceChallenge(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.webauthn.BeginLogin(ctx, user)
+}
+
+// VerifyDevice verifies a User's authenticity through a signing device.
+func (s *service) VerifyDevice(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.lockout.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	err = s.webauthn.FinishLogin(ctx, user, r)
+	if auth.ErrorCode(err) == auth.EWebAuthn {
+		if err := s.lockout.Fail(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.lockout.Reset(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return s.authorize(ctx, w, r, user, auth.FIDODevice)
+}
+
+// VerifyCode verifies a User's authenticity through a validating TOTP or
+// randomly generated code.
+func (s *service) VerifyCode(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+	token := httpapi.GetToken(r)
+
+	req, err := decodeVerifyCodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err == sql.ErrNoRows && token.CodeHash != "" {
+		// Decoy tokens are validated as usual so that they fail in
+		// the same way as a real token. Their code is never delivered.
+		if err = s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash); err != nil {
+			return nil, err
+		}
+		return nil, auth.ErrInvalidCode("incorrect code provided")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.lockout.Check(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	var tfaMethod auth.TFAOptions
+
+	switch {
+	case req.Method == auth.HOTP:
+		err = otp.VerifyHOTP(ctx, s.otp, s.repoMngr.User(), user, req.Code)
+		tfaMethod = auth.HOTP
+	case token.CodeHash != "":
+		err = s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash)
+		tfaMethod = otp.TFAOption(token.CodeHash)
+	case req.Method == "" && user.IsHOTPAllowed && !user.IsTOTPAllowed:
+		err = otp.VerifyHOTP(ctx, s.otp, s.repoMngr.User(), user, req.Code)
+		tfaMethod = auth.HOTP
+	default:
+		err = s.otp.ValidateTOTP(ctx, user, req.Code)
+		tfaMethod = auth.TOTP
+	}
+
+	if auth.ErrorCode(err) == auth.EInvalidCode {
+		if err := s.lockout.Fail(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.lockout.Reset(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return s.authorize(ctx, w, r, user, tfaMethod)
+}
+
+// Reset sets a new password for a User. A reset_authorized token may
+// only be used once. After the password is updated, every outstanding
+// token for the User is revoked.
+func (s *service) Reset(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+	token := httpapi.GetToken(r)
+
+	req, err := decodePasswordRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.password.OKForUser(req.Password); err != nil {
+		return nil, err
+	}
+
+	if err = s.password.CheckBreached(ctx, req.Password); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := s.password.Hash(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash password: %w", err)
+	}
+
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.WithAtomic(func() (interface{}, error) {
+		lh, err := client.LoginHistory().GetForUpdate(ctx, token.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if lh.IsRevoked {
+			return nil, auth.ErrInvalidToken("token is revoked")
+		}
+
+		user, err := client.User().GetForUpdate(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Password = string(passwordHash)
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot update password: %w", err)
+		}
+
+		lh.IsRevoked = true
+		if err = client.LoginHistory().Update(ctx, lh); err != nil {
+			return nil, fmt.Errorf("cannot revoke reset token: %w", err)
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.token.RevokeAll(ctx, userID, ""); err != nil {
+		return nil, err
+	}
+
+	return &Response{Result: "success"}, nil
+}
+
+// authorize creates a short lived reset_authorized token. A LoginHistory
+// record is kept for the token to ensure it is only used once.
+func (s *service) authorize(ctx context.Context, w http.ResponseWriter, r *http.Request, user *auth.User, tfaMethod auth.TFAOptions) (*token.Response, error) {
+	jwtToken, err := s.token.Create(
+		ctx,
+		user,
+		auth.JWTResetAuthorized,
+		token.WithExpiry(s.tokenExpiry),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	loginHistory := &auth.LoginHistory{
+		UserID:    user.ID,
+		TokenID:   jwtToken.Id,
+		ExpiresAt: time.Unix(jwtToken.ExpiresAt, 0),
+		IPAddress: httpapi.GetIP(r),
+		UserAgent: r.UserAgent(),
+		TFAMethod: tfaMethod,
+	}
+	if err = s.repoMngr.LoginHistory().Create(ctx, loginHistory); err != nil {
+		return nil, err
+	}
+
+	return s.respond(ctx, w, jwtToken)
+}
+
+// respond creates a JWT token response.
+func (s *service) respond(ctx context.Context, w http.ResponseWriter, jwtToken *auth.Token) (*token.Response, error) {
+	tokenStr, err := s.token.Sign(ctx, jwtToken)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cookie := range s.token.Cookies(ctx, jwtToken) {
+		http.SetCookie(w, cookie)
+	}
+
+	resp := token.Response{
+		Token:    tokenStr,
+		ClientID: jwtToken.ClientID,
+	}
+
+	return &resp, nil
+}
+
+// sendCode delivers the OTP code of a token, if it has one.
+func (s *service) sendCode(ctx context.Context, user *auth.User, jwtToken *auth.Token) error {
+	if jwtToken.CodeHash == "" {
+		return nil
+	}
+
+	h, err := otp.FromOTPHash(jwtToken.CodeHash)
+	if err != nil {
+		return fmt.Errorf("invalid OTP created: %w", err)
+	}
+
+	msg := &auth.Message{
+		Type:     auth.OTPReset,
+		Delivery: h.DeliveryMethod,
+		Vars:     map[string]string{"code": jwtToken.Code},
+		Address:  h.Address,
+		Locale:   user.Locale,
+	}
+	return s.message.Send(ctx, msg)
+}
+
+// decoyUser returns a stand-in User for an identity which cannot be
+// reset, with the identity as its only OTP address.
+func decoyUser(req *resetRequest) (*auth.User, error) {
+	userID, err := ulid.New(ulid.Now(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate decoy user ID: %w", err)
+	}
+
+	user := &auth.User{
+		ID:         userID.String(),
+		IsVerified: true,
+	}
+	identity := sql.NullString{String: req.Identity, Valid: true}
+	if req.Type == auth.Email {
+		user.Email = identity
+		user.IsEmailOTPAllowed = true
+	} else {
+		user.Phone = identity
+		user.IsPhoneOTPAllowed = true
+	}
+
+	return user, nil
+}
@@ -0,0 +1,65 @@
+package ses
+
+import (
+	"fmt"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// Config holds configuration options for Amazon SES.
+type Config struct {
+	baseURL         string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	fromAddr        string
+	fromName        string
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*client)
+
+// NewClient returns an Amazon SES client.
+func NewClient(options ...ConfigOption) auth.Emailer {
+	c := client{}
+	for _, opt := range options {
+		opt(&c)
+	}
+	return &c
+}
+
+// WithConfig configures the service with a Config.
+func WithConfig(config Config) ConfigOption {
+	return func(c *client) {
+		c.baseURL = strings.TrimSuffix(config.baseURL, "/")
+		c.region = config.region
+		c.accessKeyID = config.accessKeyID
+		c.secretAccessKey = config.secretAccessKey
+		c.sessionToken = config.sessionToken
+		c.fromAddr = config.fromAddr
+		c.fromName = config.fromName
+	}
+}
+
+// WithDefaults configures an Amazon SES client with the region of
+// a verified sending identity, IAM credentials and an origin address.
+func WithDefaults(region, accessKeyID, secretAccessKey, fromAddr, fromName string) ConfigOption {
+	return WithConfig(Config{
+		baseURL:         fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		fromAddr:        fromAddr,
+		fromName:        fromName,
+	})
+}
+
+// WithSessionToken configures the client with a session token for
+// temporary IAM credentials.
+func WithSessionToken(token string) ConfigOption {
+	return func(c *client) {
+		c.sessionToken = token
+	}
+}
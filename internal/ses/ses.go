@@ -0,0 +1,175 @@
+// Package ses exposes the Amazon SES v2 REST API.
+package ses
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	netMail "net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fmitra/authenticator/internal/mail"
+)
+
+// signingService is the name of the SES service used to sign requests.
+const signingService = "ses"
+
+// client is a consumer of the Amazon SES API.
+type client struct {
+	baseURL         string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	fromAddr        string
+	fromName        string
+}
+
+type content struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+// sendEmailRequest is the request body of the SendEmail operation.
+type sendEmailRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Simple struct {
+			Subject content `json:"Subject"`
+			Body    struct {
+				Text content `json:"Text"`
+				HTML content `json:"Html"`
+			} `json:"Body"`
+		} `json:"Simple"`
+	} `json:"Content"`
+}
+
+// Email delivers an email to an email address. The message is sent
+// as HTML alongside a plain text alternative.
+func (c *client) Email(ctx context.Context, email, subject, message string) error {
+	var body sendEmailRequest
+	from := netMail.Address{Name: c.fromName, Address: c.fromAddr}
+	body.FromEmailAddress = from.String()
+	body.Destination.ToAddresses = []string{email}
+	body.Content.Simple.Subject = content{Data: subject, Charset: "UTF-8"}
+	body.Content.Simple.Body.Text = content{Data: mail.PlainText(message), Charset: "UTF-8"}
+	body.Content.Simple.Body.HTML = content{Data: message, Charset: "UTF-8"}
+
+	b, err := json.Marshal(&body)
+	if err != nil {
+		return fmt.Errorf("cannot encode request: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/v2/email/outbound-emails", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	c.sign(req, b, signingService, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rBody, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusOK, resp.StatusCode, string(rBody))
+	}
+
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a
+// request. The host and all headers set on the request are signed.
+func (c *client) sign(req *http.Request, body []byte, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexHash(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, c.region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexHash([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,124 @@
+package ses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSES_Email(t *testing.T) {
+	tt := []struct {
+		name         string
+		responseCode int
+		hasError     bool
+	}{
+		{
+			name:         "Success 200",
+			responseCode: http.StatusOK,
+			hasError:     false,
+		},
+		{
+			name:         "Invalid 400",
+			responseCode: http.StatusBadRequest,
+			hasError:     true,
+		},
+		{
+			name:         "Invalid 403",
+			responseCode: http.StatusForbidden,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/v2/email/outbound-emails" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+
+				authHeader := r.Header.Get("Authorization")
+				wantPrefix := "AWS4-HMAC-SHA256 Credential=access-key/" +
+					time.Now().UTC().Format("20060102") + "/us-east-1/ses/aws4_request, " +
+					"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature="
+				if !strings.HasPrefix(authHeader, wantPrefix) {
+					t.Errorf("incorrect authorization header: %s", authHeader)
+				}
+				if r.Header.Get("X-Amz-Security-Token") != "session-token" {
+					t.Error("session token not set")
+				}
+
+				var body sendEmailRequest
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatal("failed to decode request:", err)
+				}
+				if body.FromEmailAddress != `"Authenticator" <noreply@example.com>` {
+					t.Errorf("incorrect from address: %s", body.FromEmailAddress)
+				}
+				if len(body.Destination.ToAddresses) != 1 || body.Destination.ToAddresses[0] != "jane@example.com" {
+					t.Errorf("incorrect to address: %v", body.Destination.ToAddresses)
+				}
+				simple := body.Content.Simple
+				if simple.Subject.Data != "Hello" {
+					t.Errorf("incorrect subject: %s", simple.Subject.Data)
+				}
+				if simple.Body.HTML.Data != "<p>hello <strong>world</strong></p>" {
+					t.Errorf("incorrect html body: %s", simple.Body.HTML.Data)
+				}
+				if simple.Body.Text.Data != "hello world" {
+					t.Errorf("incorrect text body: %s", simple.Body.Text.Data)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.responseCode)
+				w.Write([]byte(`{"MessageId": "message-id"}`))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			c := NewClient(WithConfig(Config{
+				baseURL:         srv.URL,
+				region:          "us-east-1",
+				accessKeyID:     "access-key",
+				secretAccessKey: "secret-key",
+				sessionToken:    "session-token",
+				fromAddr:        "noreply@example.com",
+				fromName:        "Authenticator",
+			}))
+
+			err := c.Email(ctx, "jane@example.com", "Hello", "<p>hello <strong>world</strong></p>")
+			if err != nil && !tc.hasError {
+				t.Error("expected nil error", err)
+			}
+			if err == nil && tc.hasError {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}
+
+func TestSES_Sign(t *testing.T) {
+	// Example request from the AWS Signature Version 4 test suite.
+	c := &client{
+		region:          "us-east-1",
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal("failed to create request:", err)
+	}
+
+	c.sign(req, nil, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("incorrect signature\nwant %s\ngot  %s", want, got)
+	}
+}
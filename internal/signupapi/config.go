@@ -56,3 +56,29 @@ func WithOTP(o auth.OTPService) ConfigOption {
 		s.otp = o
 	}
 }
+
+// WithPassword configures the service with a PasswordService to
+// check new passwords against a corpus of breached passwords.
+func WithPassword(p auth.PasswordService) ConfigOption {
+	return func(s *service) {
+		s.password = p
+	}
+}
+
+// WithPasswordless configures the service to register Users
+// without a password.
+func WithPasswordless(isEnabled bool) ConfigOption {
+	return func(s *service) {
+		s.passwordless = isEnabled
+	}
+}
+
+// WithMagicLinkURL configures the client URL a magic link should
+// direct to. The URL will receive `token` and `code` query parameters
+// to be submitted to the verify endpoint. Magic links are only
+// available in passwordless mode.
+func WithMagicLinkURL(url string) ConfigOption {
+	return func(s *service) {
+		s.magicLinkURL = url
+	}
+}
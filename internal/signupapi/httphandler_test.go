@@ -17,6 +17,7 @@ import (
 	auth "github.com/fmitra/authenticator"
 	"github.com/fmitra/authenticator/internal/httpapi"
 	"github.com/fmitra/authenticator/internal/otp"
+	"github.com/fmitra/authenticator/internal/password"
 	"github.com/fmitra/authenticator/internal/postgres"
 	"github.com/fmitra/authenticator/internal/test"
 )
@@ -59,9 +60,37 @@ func TestSignUpAPI_SignUp(t *testing.T) {
 			},
 		},
 		{
-			name:       "User already verified",
-			statusCode: http.StatusBadRequest,
-			errMessage: "Cannot register user",
+			name:       "User already verified starts password reset",
+			statusCode: http.StatusCreated,
+			errMessage: "",
+			reqBody: []byte(`{
+				"type": "email",
+				"password": "swordfish",
+				"identity": "jane@example.com"
+			}`),
+			userCreateCalls: 0,
+			messagingCalls:  1,
+			userGetFn: func() (*auth.User, error) {
+				return &auth.User{IsVerified: true, IsEmailOTPAllowed: true}, nil
+			},
+			userCreateFn: func() error {
+				return nil
+			},
+			tokenCreateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+					State:    auth.JWTResetPreAuthorized,
+					Code:     test.OTPCode,
+				}, nil
+			},
+			tokenSignFn: func() (string, error) {
+				return "jwt-token", nil
+			},
+		},
+		{
+			name:       "User already verified with TOTP starts password reset",
+			statusCode: http.StatusCreated,
+			errMessage: "",
 			reqBody: []byte(`{
 				"type": "email",
 				"password": "swordfish",
@@ -70,13 +99,13 @@ func TestSignUpAPI_SignUp(t *testing.T) {
 			userCreateCalls: 0,
 			messagingCalls:  0,
 			userGetFn: func() (*auth.User, error) {
-				return &auth.User{IsVerified: true}, nil
+				return &auth.User{IsVerified: true, IsTOTPAllowed: true}, nil
 			},
 			userCreateFn: func() error {
 				return nil
 			},
 			tokenCreateFn: func() (*auth.Token, error) {
-				return &auth.Token{Code: "123456"}, nil
+				return &auth.Token{State: auth.JWTResetPreAuthorized}, nil
 			},
 			tokenSignFn: func() (string, error) {
 				return "jwt-token", nil
@@ -279,6 +308,124 @@ func TestSignUpAPI_SignUp(t *testing.T) {
 	}
 }
 
+func TestSignUpAPI_SignUpMagicLink(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		errMessage     string
+		reqBody        []byte
+		messagingCalls int
+		passwordless   bool
+		magicLinkURL   string
+	}{
+		{
+			name:       "Passwordless not enabled failure",
+			statusCode: http.StatusBadRequest,
+			errMessage: "Magic link signup is not enabled",
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com",
+				"magicLink": true
+			}`),
+			messagingCalls: 0,
+			passwordless:   false,
+			magicLinkURL:   "https://authenticator.local/login/magic",
+		},
+		{
+			name:       "Phone delivery failure",
+			statusCode: http.StatusBadRequest,
+			errMessage: "Magic link signup is not enabled",
+			reqBody: []byte(`{
+				"type": "phone",
+				"identity": "+6594867353",
+				"magicLink": true
+			}`),
+			messagingCalls: 0,
+			passwordless:   true,
+			magicLinkURL:   "https://authenticator.local/login/magic",
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusCreated,
+			errMessage: "",
+			reqBody: []byte(`{
+				"type": "email",
+				"identity": "jane@example.com",
+				"magicLink": true
+			}`),
+			messagingCalls: 1,
+			passwordless:   true,
+			magicLinkURL:   "https://authenticator.local/login/magic",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return nil, sql.ErrNoRows
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{
+						CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+						State:    auth.JWTPreAuthorized,
+						Code:     test.OTPCode,
+					}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			messagingSvc := &test.MessagingService{}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(messagingSvc),
+				WithPasswordless(tc.passwordless),
+				WithMagicLinkURL(tc.magicLinkURL),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/signup",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if messagingSvc.Calls.Send != tc.messagingCalls {
+				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
+					tc.messagingCalls, messagingSvc.Calls.Send)
+			}
+		})
+	}
+}
+
 func TestSignUpAPI_SignUpExistingUser(t *testing.T) {
 	pgDB, err := test.NewPGDB()
 	if err != nil {
@@ -359,6 +506,65 @@ func TestSignUpAPI_SignUpExistingUser(t *testing.T) {
 	}
 }
 
+func TestSignUpAPI_SignUpBreachedPassword(t *testing.T) {
+	router := mux.NewRouter()
+	logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+	userRepo := &test.UserRepository{}
+	repoMngr := &test.RepositoryManager{
+		UserFn: func() auth.UserRepository {
+			return userRepo
+		},
+	}
+	tokenSvc := &test.TokenService{}
+	messagingSvc := &test.MessagingService{}
+	breachChecker := &test.BreachChecker{
+		IsBreachedFn: func() (bool, error) {
+			return true, nil
+		},
+	}
+
+	svc := NewService(
+		WithLogger(&test.Logger{}),
+		WithTokenService(tokenSvc),
+		WithRepoManager(repoMngr),
+		WithMessaging(messagingSvc),
+		WithPassword(password.NewPassword(password.WithBreachChecker(breachChecker))),
+	)
+
+	req, err := http.NewRequest("POST", "/api/v1/signup", bytes.NewBuffer([]byte(`{
+		"type": "email",
+		"password": "password",
+		"identity": "jane@example.com"
+	}`)))
+	if err != nil {
+		t.Fatal("failed to create request:", err)
+	}
+
+	SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("incorrect status code, want %v got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	err = test.ValidateErrMessage("Password has appeared in a data breach, choose a different password", rr.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// The identity is not looked up to avoid revealing if it is registered.
+	if userRepo.Calls.ByIdentity != 0 {
+		t.Errorf("incorrect UserRepository.ByIdentity() call count, want 0 got %v",
+			userRepo.Calls.ByIdentity)
+	}
+	if breachChecker.Calls.IsBreached != 1 {
+		t.Errorf("incorrect BreachChecker.IsBreached() call count, want 1 got %v",
+			breachChecker.Calls.IsBreached)
+	}
+}
+
 func TestSignUpAPI_VerifyCode(t *testing.T) {
 	tt := []struct {
 		name            string
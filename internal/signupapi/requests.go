@@ -14,6 +14,9 @@ type signupRequest struct {
 	Password string              `json:"password"`
 	Identity string              `json:"identity"`
 	Type     auth.DeliveryMethod `json:"type"`
+	// MagicLink requests an email containing a one-click verification
+	// link instead of an OTP code.
+	MagicLink bool `json:"magicLink"`
 }
 
 type signupVerifyRequest struct {
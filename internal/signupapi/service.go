@@ -21,6 +21,11 @@ type service struct {
 	repoMngr auth.RepositoryManager
 	message  auth.MessagingService
 	otp      auth.OTPService
+	password auth.PasswordService
+	// passwordless enables registration without a password.
+	passwordless bool
+	// magicLinkURL is the client URL a magic link directs to.
+	magicLinkURL string
 }
 
 // SignUp is the initial registration step to create a new User.
@@ -32,6 +37,19 @@ func (s *service) SignUp(w http.ResponseWriter, r *http.Request) (interface{}, e
 		return nil, err
 	}
 
+	if req.MagicLink && !s.isMagicLinkAllowed(req) {
+		return nil, auth.ErrBadRequest("magic link signup is not enabled")
+	}
+
+	// Passwords are checked before the User is looked up so that a
+	// breached password is rejected whether or not the identity is
+	// already registered.
+	if req.Password != "" && s.password != nil {
+		if err = s.password.CheckBreached(ctx, req.Password); err != nil {
+			return nil, err
+		}
+	}
+
 	newUser := req.ToUser()
 	user, err := s.repoMngr.User().ByIdentity(ctx, req.UserAttribute(), req.Identity)
 
@@ -40,11 +58,10 @@ func (s *service) SignUp(w http.ResponseWriter, r *http.Request) (interface{}, e
 	}
 
 	if isUserVerified(user, err) {
-		// TODO To prevent user enumeration this should trigger
-		// the OTP step for password reset instead of the signup OTP
-		// step. Until password reset has been implemented, we will just
-		// return a general error.
-		return nil, auth.ErrBadRequest("cannot register user")
+		// To prevent user enumeration, registration attempts for
+		// verified users trigger the OTP step for password reset
+		// instead of returning an error.
+		return s.requestReset(ctx, w, user, req)
 	}
 
 	if isUserNotVerified(user, err) {
@@ -59,17 +76,23 @@ func (s *service) SignUp(w http.ResponseWriter, r *http.Request) (interface{}, e
 		return nil, err
 	}
 
+	msgType := auth.OTPSignup
+	if req.MagicLink {
+		msgType = auth.MagicLink
+	}
+
 	jwtToken, err := s.token.Create(
 		ctx,
 		newUser,
 		auth.JWTPreAuthorized,
 		token.WithOTPDeliveryMethod(req.Type),
+		token.WithOTPMessageType(msgType),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.respond(ctx, w, newUser, jwtToken)
+	return s.respond(ctx, w, newUser, jwtToken, msgType)
 }
 
 // Verify is the final registration step to validate a new User's authenticity.
@@ -88,7 +111,7 @@ func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, e
 		return nil, err
 	}
 
-	if err = s.otp.ValidateOTP(req.Code, token.CodeHash); err != nil {
+	if err = s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash); err != nil {
 		return nil, err
 	}
 
@@ -105,12 +128,50 @@ func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, e
 		UserID:    userID,
 		TokenID:   jwtToken.Id,
 		ExpiresAt: s.token.RefreshableTill(ctx, jwtToken, jwtToken.RefreshToken),
+		IPAddress: httpapi.GetIP(r),
+		UserAgent: r.UserAgent(),
+		TFAMethod: otp.TFAOption(token.CodeHash),
 	}
 	if err = s.repoMngr.LoginHistory().Create(ctx, loginHistory); err != nil {
 		return nil, err
 	}
 
-	return s.respond(ctx, w, user, jwtToken)
+	return s.respond(ctx, w, user, jwtToken, auth.OTPSignup)
+}
+
+// requestReset starts the password reset flow for a verified User.
+// An OTP code is only delivered if the User has not configured a
+// stronger 2FA option (TOTP or WebAuthn).
+func (s *service) requestReset(ctx context.Context, w http.ResponseWriter, user *auth.User, req *signupRequest) (*token.Response, error) {
+	var (
+		jwtToken *auth.Token
+		err      error
+	)
+
+	if user.CanSendDefaultOTP() {
+		jwtToken, err = s.token.Create(
+			ctx,
+			user,
+			auth.JWTResetPreAuthorized,
+			token.WithOTPDeliveryMethod(req.Type),
+			token.WithOTPMessageType(auth.OTPReset),
+			token.WithOTPAddress(req.Identity),
+		)
+	} else {
+		jwtToken, err = s.token.Create(ctx, user, auth.JWTResetPreAuthorized)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.respond(ctx, w, user, jwtToken, auth.OTPReset)
+}
+
+// isMagicLinkAllowed determines if a magic link may be delivered
+// for a signup request. Magic links are only delivered by email.
+func (s *service) isMagicLinkAllowed(req *signupRequest) bool {
+	return s.passwordless && s.magicLinkURL != "" && req.Type == auth.Email
 }
 
 // reCreateUser re-creates the account of a non verified user. A user
@@ -159,7 +220,7 @@ func (s *service) createUser(ctx context.Context, newUser *auth.User) error {
 }
 
 // respond creates a JWT token response.
-func (s *service) respond(ctx context.Context, w http.ResponseWriter, _ *auth.User, jwtToken *auth.Token) (*token.Response, error) {
+func (s *service) respond(ctx context.Context, w http.ResponseWriter, user *auth.User, jwtToken *auth.Token, msgType auth.MessageType) (*token.Response, error) {
 	tokenStr, err := s.token.Sign(ctx, jwtToken)
 	if err != nil {
 		return nil, err
@@ -176,10 +237,18 @@ func (s *service) respond(ctx context.Context, w http.ResponseWriter, _ *auth.Us
 		}
 
 		msg := &auth.Message{
-			Type:     auth.OTPSignup,
+			Type:     msgType,
 			Delivery: h.DeliveryMethod,
 			Vars:     map[string]string{"code": jwtToken.Code},
 			Address:  h.Address,
+			Locale:   user.Locale,
+		}
+		if msgType == auth.MagicLink {
+			link, err := token.MagicLink(s.magicLinkURL, tokenStr, jwtToken.Code)
+			if err != nil {
+				return nil, err
+			}
+			msg.Vars["link"] = link
 		}
 		if err = s.message.Send(ctx, msg); err != nil {
 			return nil, err
@@ -53,17 +53,25 @@ func MockTokenHash(a, m string, t int64) string {
 
 // OTPService mocks auth.OTPService interface.
 type OTPService struct {
-	TOTPQRStringFn func(u *auth.User) (string, error)
-	TOTPSecretFn   func(u *auth.User) (string, error)
-	OTPCodeFn      func(address string, method auth.DeliveryMethod) (string, string, error)
-	ValidateOTPFn  func(code, hash string) error
-	ValidateTOTPFn func(ctx context.Context, u *auth.User, code string) error
-	Calls          struct {
-		TOTPQRString int
-		TOTPSecret   int
-		OTPCode      int
-		ValidateOTP  int
-		ValidateTOTP int
+	TOTPQRStringFn        func(u *auth.User) (string, error)
+	TOTPSecretFn          func(u *auth.User) (string, error)
+	OTPCodeFn             func(address string, method auth.DeliveryMethod, msgType auth.MessageType) (string, string, error)
+	ValidateOTPFn         func(ctx context.Context, id, code, hash string) error
+	ValidateTOTPFn        func(ctx context.Context, u *auth.User, code string) error
+	RecoveryCodesFn       func() ([]string, error)
+	ReencryptTOTPSecretFn func(secret string) (string, error)
+	HOTPSecretFn          func(seed *auth.HOTPSeed) (string, error)
+	ValidateHOTPFn        func(ctx context.Context, u *auth.User, code string) (int64, error)
+	Calls                 struct {
+		TOTPQRString        int
+		TOTPSecret          int
+		OTPCode             int
+		ValidateOTP         int
+		ValidateTOTP        int
+		RecoveryCodes       int
+		ReencryptTOTPSecret int
+		HOTPSecret          int
+		ValidateHOTP        int
 	}
 }
 
@@ -71,9 +79,11 @@ type OTPService struct {
 type MessageRepository struct {
 	PublishFn func(ctx context.Context, msg *auth.Message) error
 	RecentFn  func(ctx context.Context) (<-chan *auth.Message, <-chan error)
+	AckFn     func(ctx context.Context, msg *auth.Message) error
 	Calls     struct {
 		Publish int
 		Recent  int
+		Ack     int
 	}
 }
 
@@ -85,6 +95,38 @@ type MessagingService struct {
 	}
 }
 
+// MessageThrottle mocks auth.MessageThrottle interface.
+type MessageThrottle struct {
+	AllowFn func() error
+	Calls   struct {
+		Allow int
+	}
+}
+
+// BreachChecker mocks auth.BreachChecker interface.
+type BreachChecker struct {
+	IsBreachedFn func() (bool, error)
+	Calls        struct {
+		IsBreached int
+	}
+}
+
+// LockoutService mocks auth.LockoutService interface.
+type LockoutService struct {
+	CheckFn      func() error
+	FailFn       func() error
+	ResetFn      func() error
+	UnlockCodeFn func() (string, error)
+	UnlockFn     func() error
+	Calls        struct {
+		Check      int
+		Fail       int
+		Reset      int
+		UnlockCode int
+		Unlock     int
+	}
+}
+
 // TokenService mocks auth.TokenService interface.
 type TokenService struct {
 	RefreshableTillFn func() time.Time
@@ -93,6 +135,8 @@ type TokenService struct {
 	SignFn            func() (string, error)
 	ValidateFn        func() (*auth.Token, error)
 	RevokeFn          func() error
+	RevokeAllFn       func() error
+	JWKSFn            func() ([]byte, error)
 	CookiesFn         func() []*http.Cookie
 	Calls             struct {
 		RefreshableTill int
@@ -101,6 +145,8 @@ type TokenService struct {
 		Sign            int
 		Validate        int
 		Revoke          int
+		RevokeAll       int
+		JWKS            int
 		Cookies         int
 	}
 }
@@ -112,28 +158,36 @@ type RepositoryManager struct {
 	LoginHistoryFn       func() auth.LoginHistoryRepository
 	DeviceFn             func() auth.DeviceRepository
 	UserFn               func() auth.UserRepository
+	RecoveryCodeFn       func() auth.RecoveryCodeRepository
+	MessageStatusFn      func() auth.MessageStatusRepository
+	DeadLetterFn         func() auth.DeadLetterRepository
 	Calls                struct {
 		NewWithTransaction int
 		WithAtomic         int
 		LoginHistory       int
 		Device             int
 		User               int
+		RecoveryCode       int
+		MessageStatus      int
+		DeadLetter         int
 	}
 }
 
 // LoginHistoryRepository mocks auth.LoginHistoryRepository.
 type LoginHistoryRepository struct {
-	ByTokenIDFn    func() (*auth.LoginHistory, error)
-	ByUserIDFn     func() ([]*auth.LoginHistory, error)
-	CreateFn       func() error
-	GetForUpdateFn func() (*auth.LoginHistory, error)
-	UpdateFn       func() error
-	Calls          struct {
-		ByUserID     int
-		Create       int
-		GetForUpdate int
-		Update       int
-		ByTokenID    int
+	ByTokenIDFn      func() (*auth.LoginHistory, error)
+	ByUserIDFn       func() ([]*auth.LoginHistory, error)
+	CreateFn         func() error
+	GetForUpdateFn   func() (*auth.LoginHistory, error)
+	UpdateFn         func() error
+	RevokeByUserIDFn func() ([]string, error)
+	Calls            struct {
+		ByUserID       int
+		Create         int
+		GetForUpdate   int
+		Update         int
+		ByTokenID      int
+		RevokeByUserID int
 	}
 }
 
@@ -157,6 +211,42 @@ type DeviceRepository struct {
 	}
 }
 
+// RecoveryCodeRepository mocks auth.RecoveryCodeRepository.
+type RecoveryCodeRepository struct {
+	ReplaceFn func() error
+	ConsumeFn func() error
+	Calls     struct {
+		Replace int
+		Consume int
+	}
+}
+
+// MessageStatusRepository mocks auth.MessageStatusRepository.
+type MessageStatusRepository struct {
+	ByIDFn func() (*auth.MessageStatus, error)
+	SaveFn func(status *auth.MessageStatus) error
+	Calls  struct {
+		ByID int
+		Save int
+	}
+}
+
+// DeadLetterRepository mocks auth.DeadLetterRepository.
+type DeadLetterRepository struct {
+	ByIDFn         func() (*auth.DeadLetter, error)
+	RecentFn       func() ([]*auth.DeadLetter, error)
+	CreateFn       func(deadLetter *auth.DeadLetter) error
+	GetForUpdateFn func() (*auth.DeadLetter, error)
+	RemoveFn       func() error
+	Calls          struct {
+		ByID         int
+		Recent       int
+		Create       int
+		GetForUpdate int
+		Remove       int
+	}
+}
+
 // UserRepository mocks auth.UserRepository.
 type UserRepository struct {
 	ByIdentityFn           func() (*auth.User, error)
@@ -166,6 +256,10 @@ type UserRepository struct {
 	CreateFn               func() error
 	ReCreateFn             func() error
 	UpdateFn               func() error
+	ListFn                 func() ([]*auth.User, error)
+	UpdateTFASecretFn      func(userID, current, secret string) (bool, error)
+	UpdateHOTPCounterFn    func(userID string, current, counter int64) (bool, error)
+	UpdatePasswordFn       func(userID, current, hash string) (bool, error)
 	Calls                  struct {
 		ByIdentity           int
 		DisableOTP           int
@@ -174,6 +268,10 @@ type UserRepository struct {
 		Create               int
 		ReCreate             int
 		Update               int
+		List                 int
+		UpdateTFASecret      int
+		UpdateHOTPCounter    int
+		UpdatePassword       int
 	}
 }
 
@@ -310,6 +408,114 @@ func (m *RepositoryManager) User() auth.UserRepository {
 	return &UserRepository{}
 }
 
+// RecoveryCode mock.
+func (m *RepositoryManager) RecoveryCode() auth.RecoveryCodeRepository {
+	m.Calls.RecoveryCode++
+	if m.RecoveryCodeFn != nil {
+		return m.RecoveryCodeFn()
+	}
+	return &RecoveryCodeRepository{}
+}
+
+// MessageStatus mock.
+func (m *RepositoryManager) MessageStatus() auth.MessageStatusRepository {
+	m.Calls.MessageStatus++
+	if m.MessageStatusFn != nil {
+		return m.MessageStatusFn()
+	}
+	return &MessageStatusRepository{}
+}
+
+// DeadLetter mock.
+func (m *RepositoryManager) DeadLetter() auth.DeadLetterRepository {
+	m.Calls.DeadLetter++
+	if m.DeadLetterFn != nil {
+		return m.DeadLetterFn()
+	}
+	return &DeadLetterRepository{}
+}
+
+// Replace mock.
+func (m *RecoveryCodeRepository) Replace(ctx context.Context, userID string, codes []string) error {
+	m.Calls.Replace++
+	if m.ReplaceFn != nil {
+		return m.ReplaceFn()
+	}
+	return nil
+}
+
+// Consume mock.
+func (m *RecoveryCodeRepository) Consume(ctx context.Context, userID, code string) error {
+	m.Calls.Consume++
+	if m.ConsumeFn != nil {
+		return m.ConsumeFn()
+	}
+	return nil
+}
+
+// ByID mock.
+func (m *MessageStatusRepository) ByID(ctx context.Context, messageID string) (*auth.MessageStatus, error) {
+	m.Calls.ByID++
+	if m.ByIDFn != nil {
+		return m.ByIDFn()
+	}
+	return &auth.MessageStatus{}, nil
+}
+
+// Save mock.
+func (m *MessageStatusRepository) Save(ctx context.Context, status *auth.MessageStatus) error {
+	m.Calls.Save++
+	if m.SaveFn != nil {
+		return m.SaveFn(status)
+	}
+	return nil
+}
+
+// ByID mock.
+func (m *DeadLetterRepository) ByID(ctx context.Context, messageID string) (*auth.DeadLetter, error) {
+	m.Calls.ByID++
+	if m.ByIDFn != nil {
+		return m.ByIDFn()
+	}
+	return &auth.DeadLetter{Message: &auth.Message{}}, nil
+}
+
+// Recent mock.
+func (m *DeadLetterRepository) Recent(ctx context.Context, limit, offset int) ([]*auth.DeadLetter, error) {
+	m.Calls.Recent++
+	if m.RecentFn != nil {
+		return m.RecentFn()
+	}
+	return []*auth.DeadLetter{}, nil
+}
+
+// Create mock.
+func (m *DeadLetterRepository) Create(ctx context.Context, deadLetter *auth.DeadLetter) error {
+	m.Calls.Create++
+	if m.CreateFn != nil {
+		return m.CreateFn(deadLetter)
+	}
+	return nil
+}
+
+// GetForUpdate mock.
+func (m *DeadLetterRepository) GetForUpdate(ctx context.Context, messageID string) (*auth.DeadLetter, error) {
+	m.Calls.GetForUpdate++
+	if m.GetForUpdateFn != nil {
+		return m.GetForUpdateFn()
+	}
+	return &auth.DeadLetter{Message: &auth.Message{}}, nil
+}
+
+// Remove mock.
+func (m *DeadLetterRepository) Remove(ctx context.Context, messageID string) error {
+	m.Calls.Remove++
+	if m.RemoveFn != nil {
+		return m.RemoveFn()
+	}
+	return nil
+}
+
 // RemoveDeliveryMethod mock.
 func (m *UserRepository) RemoveDeliveryMethod(ctx context.Context, userID string, method auth.DeliveryMethod) (*auth.User, error) {
 	m.Calls.RemoveDeliveryMethod++
@@ -319,6 +525,42 @@ func (m *UserRepository) RemoveDeliveryMethod(ctx context.Context, userID string
 	return &auth.User{}, nil
 }
 
+// List mock.
+func (m *UserRepository) List(ctx context.Context, afterID string, limit int) ([]*auth.User, error) {
+	m.Calls.List++
+	if m.ListFn != nil {
+		return m.ListFn()
+	}
+	return []*auth.User{}, nil
+}
+
+// UpdateTFASecret mock.
+func (m *UserRepository) UpdateTFASecret(ctx context.Context, userID, current, secret string) (bool, error) {
+	m.Calls.UpdateTFASecret++
+	if m.UpdateTFASecretFn != nil {
+		return m.UpdateTFASecretFn(userID, current, secret)
+	}
+	return true, nil
+}
+
+// UpdateHOTPCounter mock.
+func (m *UserRepository) UpdateHOTPCounter(ctx context.Context, userID string, current, counter int64) (bool, error) {
+	m.Calls.UpdateHOTPCounter++
+	if m.UpdateHOTPCounterFn != nil {
+		return m.UpdateHOTPCounterFn(userID, current, counter)
+	}
+	return true, nil
+}
+
+// UpdatePassword mock.
+func (m *UserRepository) UpdatePassword(ctx context.Context, userID, current, hash string) (bool, error) {
+	m.Calls.UpdatePassword++
+	if m.UpdatePasswordFn != nil {
+		return m.UpdatePasswordFn(userID, current, hash)
+	}
+	return true, nil
+}
+
 // DisableOTP mock.
 func (m *UserRepository) DisableOTP(ctx context.Context, userID string, method auth.DeliveryMethod) (*auth.User, error) {
 	m.Calls.DisableOTP++
@@ -485,6 +727,15 @@ func (m *LoginHistoryRepository) Update(ctx context.Context, login *auth.LoginHi
 	return nil
 }
 
+// RevokeByUserID mock.
+func (m *LoginHistoryRepository) RevokeByUserID(ctx context.Context, userID, excludeTokenID string) ([]string, error) {
+	m.Calls.RevokeByUserID++
+	if m.RevokeByUserIDFn != nil {
+		return m.RevokeByUserIDFn()
+	}
+	return []string{}, nil
+}
+
 // RefreshableTill mock.
 func (m *TokenService) RefreshableTill(ctx context.Context, token *auth.Token, refreshToken string) time.Time {
 	m.Calls.RefreshableTill++
@@ -548,6 +799,24 @@ func (m *TokenService) Revoke(ctx context.Context, tokenID string) error {
 	return fmt.Errorf("token revocation failed")
 }
 
+// RevokeAll mock.
+func (m *TokenService) RevokeAll(ctx context.Context, userID, excludeTokenID string) error {
+	m.Calls.RevokeAll++
+	if m.RevokeAllFn != nil {
+		return m.RevokeAllFn()
+	}
+	return nil
+}
+
+// JWKS mock.
+func (m *TokenService) JWKS(ctx context.Context) ([]byte, error) {
+	m.Calls.JWKS++
+	if m.JWKSFn != nil {
+		return m.JWKSFn()
+	}
+	return []byte(`{"keys":[]}`), nil
+}
+
 // BeginSignUp mock.
 func (m *WebAuthnService) BeginSignUp(ctx context.Context, user *auth.User) ([]byte, error) {
 	m.Calls.BeginSignUp++
@@ -634,6 +903,69 @@ func (m *MessagingService) Send(ctx context.Context, msg *auth.Message) error {
 	return nil
 }
 
+// Allow mock.
+func (m *MessageThrottle) Allow(ctx context.Context, msg *auth.Message) error {
+	m.Calls.Allow++
+	if m.AllowFn != nil {
+		return m.AllowFn()
+	}
+	return nil
+}
+
+// IsBreached mock.
+func (m *BreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	m.Calls.IsBreached++
+	if m.IsBreachedFn != nil {
+		return m.IsBreachedFn()
+	}
+	return false, nil
+}
+
+// Check mock.
+func (m *LockoutService) Check(ctx context.Context, userID string) error {
+	m.Calls.Check++
+	if m.CheckFn != nil {
+		return m.CheckFn()
+	}
+	return nil
+}
+
+// Fail mock.
+func (m *LockoutService) Fail(ctx context.Context, userID string) error {
+	m.Calls.Fail++
+	if m.FailFn != nil {
+		return m.FailFn()
+	}
+	return nil
+}
+
+// Reset mock.
+func (m *LockoutService) Reset(ctx context.Context, userID string) error {
+	m.Calls.Reset++
+	if m.ResetFn != nil {
+		return m.ResetFn()
+	}
+	return nil
+}
+
+// UnlockCode mock.
+func (m *LockoutService) UnlockCode(ctx context.Context, userID, address string, method auth.DeliveryMethod) (string, error) {
+	m.Calls.UnlockCode++
+	if m.UnlockCodeFn != nil {
+		return m.UnlockCodeFn()
+	}
+	return "", nil
+}
+
+// Unlock mock.
+func (m *LockoutService) Unlock(ctx context.Context, userID, code string) error {
+	m.Calls.Unlock++
+	if m.UnlockFn != nil {
+		return m.UnlockFn()
+	}
+	return nil
+}
+
 // Publish mock.
 func (m *MessageRepository) Publish(ctx context.Context, msg *auth.Message) error {
 	m.Calls.Publish++
@@ -656,6 +988,15 @@ func (m *MessageRepository) Recent(ctx context.Context) (<-chan *auth.Message, <
 	return msgc, errc
 }
 
+// Ack mock.
+func (m *MessageRepository) Ack(ctx context.Context, msg *auth.Message) error {
+	m.Calls.Ack++
+	if m.AckFn != nil {
+		return m.AckFn(ctx, msg)
+	}
+	return nil
+}
+
 func (s *OTPService) TOTPQRString(u *auth.User) (string, error) {
 	s.Calls.TOTPQRString++
 	if s.TOTPQRStringFn != nil {
@@ -672,18 +1013,18 @@ func (s *OTPService) TOTPSecret(u *auth.User) (string, error) {
 	return "", nil
 }
 
-func (s *OTPService) OTPCode(address string, method auth.DeliveryMethod) (string, string, error) {
+func (s *OTPService) OTPCode(address string, method auth.DeliveryMethod, msgType auth.MessageType) (string, string, error) {
 	s.Calls.OTPCode++
 	if s.OTPCodeFn != nil {
-		return s.OTPCodeFn(address, method)
+		return s.OTPCodeFn(address, method, msgType)
 	}
 	return "", "", nil
 }
 
-func (s *OTPService) ValidateOTP(code, hash string) error {
+func (s *OTPService) ValidateOTP(ctx context.Context, id, code, hash string) error {
 	s.Calls.ValidateOTP++
 	if s.ValidateOTPFn != nil {
-		return s.ValidateOTPFn(code, hash)
+		return s.ValidateOTPFn(ctx, id, code, hash)
 	}
 	return nil
 }
@@ -695,3 +1036,36 @@ func (s *OTPService) ValidateTOTP(ctx context.Context, u *auth.User, code string
 	}
 	return nil
 }
+
+// RecoveryCodes mock.
+func (s *OTPService) RecoveryCodes() ([]string, error) {
+	s.Calls.RecoveryCodes++
+	if s.RecoveryCodesFn != nil {
+		return s.RecoveryCodesFn()
+	}
+	return []string{}, nil
+}
+
+func (s *OTPService) ReencryptTOTPSecret(secret string) (string, error) {
+	s.Calls.ReencryptTOTPSecret++
+	if s.ReencryptTOTPSecretFn != nil {
+		return s.ReencryptTOTPSecretFn(secret)
+	}
+	return secret, nil
+}
+
+func (s *OTPService) HOTPSecret(seed *auth.HOTPSeed) (string, error) {
+	s.Calls.HOTPSecret++
+	if s.HOTPSecretFn != nil {
+		return s.HOTPSecretFn(seed)
+	}
+	return "", nil
+}
+
+func (s *OTPService) ValidateHOTP(ctx context.Context, u *auth.User, code string) (int64, error) {
+	s.Calls.ValidateHOTP++
+	if s.ValidateHOTPFn != nil {
+		return s.ValidateHOTPFn(ctx, u, code)
+	}
+	return u.HOTPCounter + 1, nil
+}
@@ -1,13 +1,14 @@
 package test
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math/rand"
 	"os"
 	"time"
 
-	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/migrate"
 )
 
 // PGClient provies a test database.
@@ -57,7 +58,7 @@ func NewPGDB() (*PGClient, error) {
 		return nil, fmt.Errorf("no response to ping: %w", err)
 	}
 
-	_, err = db.Exec(auth.Schema)
+	_, err = migrate.NewMigrator(migrate.WithDB(db)).Up(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
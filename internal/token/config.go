@@ -30,6 +30,10 @@ func NewService(options ...ConfigOption) auth.TokenService {
 		opt(&s)
 	}
 
+	if s.keyGracePeriod == 0 {
+		s.keyGracePeriod = s.tokenExpiry
+	}
+
 	return &s
 }
 
@@ -74,6 +78,30 @@ func WithSecret(secret string) ConfigOption {
 	}
 }
 
+// WithSigningKey adds a versioned key to sign and verify JWT
+// tokens. Keys that are not active are only used for verification.
+func WithSigningKey(key *SigningKey) ConfigOption {
+	return func(s *service) {
+		s.keys = append(s.keys, key)
+	}
+}
+
+// WithActiveKey sets the ID of the key used to sign new tokens.
+// If no active key is set, tokens are signed with the HS512 secret.
+func WithActiveKey(keyID string) ConfigOption {
+	return func(s *service) {
+		s.activeKeyID = keyID
+	}
+}
+
+// WithKeyGracePeriod defines how long a retired key may verify
+// tokens after its retirement. Defaults to the token expiry time.
+func WithKeyGracePeriod(gracePeriod time.Duration) ConfigOption {
+	return func(s *service) {
+		s.keyGracePeriod = gracePeriod
+	}
+}
+
 // WithIssuer is the issuer identity for the JWT
 // token.
 func WithIssuer(issuer string) ConfigOption {
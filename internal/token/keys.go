@@ -0,0 +1,271 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// RS256 signs tokens with RSASSA-PKCS1-v1_5 using SHA-256.
+	RS256 = "RS256"
+	// ES256 signs tokens with ECDSA using P-256 and SHA-256.
+	ES256 = "ES256"
+	// EdDSA signs tokens with Ed25519.
+	EdDSA = "EdDSA"
+)
+
+// SigningKey is a versioned key used to sign and verify JWT tokens.
+// Keys are identified by the `kid` header of a signed token.
+type SigningKey struct {
+	// ID is the unique identifier of the key. It is set in the
+	// `kid` header of every token signed with this key.
+	ID string
+	// Algorithm is the JWT signing algorithm (RS256, ES256, EdDSA).
+	Algorithm string
+	// PrivateKey signs new tokens. It may be omitted for retired
+	// keys which are only used for verification.
+	PrivateKey crypto.PrivateKey
+	// PublicKey verifies tokens signed by the key.
+	PublicKey crypto.PublicKey
+	// RetiredAt is the time the key stopped signing tokens. Retired
+	// keys continue to verify tokens for a grace period after
+	// retirement.
+	RetiredAt time.Time
+}
+
+// jwk is a JSON Web Key as described in RFC 7517.
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// jwkSet is a JSON Web Key Set as described in RFC 7517.
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// signingMethodEdDSA implements jwt.SigningMethod for Ed25519
+// keys as jwt-go does not support EdDSA.
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(EdDSA, func() jwt.SigningMethod {
+		return &signingMethodEdDSA{}
+	})
+}
+
+// Alg returns the name of the signing method.
+func (m *signingMethodEdDSA) Alg() string {
+	return EdDSA
+}
+
+// Verify verifies a base64 encoded signature with an ed25519.PublicKey.
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return errors.New("ed25519: verification error")
+	}
+
+	return nil
+}
+
+// Sign signs a string with an ed25519.PrivateKey.
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	sig := ed25519.Sign(privateKey, []byte(signingString))
+	return jwt.EncodeSegment(sig), nil
+}
+
+// ParseSigningKey creates a SigningKey from a PEM encoded private or
+// public key. Public keys may only be used to verify tokens.
+func ParseSigningKey(id, algorithm string, pemBytes []byte) (*SigningKey, error) {
+	if id == "" {
+		return nil, fmt.Errorf("signing key ID cannot be empty")
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found for key %s", id)
+	}
+
+	key := SigningKey{ID: id, Algorithm: algorithm}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse public key %s: %w", id, err)
+		}
+		key.PublicKey = publicKey
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key %s: %w", id, err)
+		}
+		key.PrivateKey = privateKey
+	case "EC PRIVATE KEY":
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key %s: %w", id, err)
+		}
+		key.PrivateKey = privateKey
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key %s: %w", id, err)
+		}
+		key.PrivateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s for key %s", block.Type, id)
+	}
+
+	if key.PrivateKey != nil {
+		signer, ok := key.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %s cannot be used for signing", id)
+		}
+		key.PublicKey = signer.Public()
+	}
+
+	if err := key.validate(); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// validate ensures the key type is compatible with the
+// configured signing algorithm.
+func (k *SigningKey) validate() error {
+	var ok bool
+
+	switch k.Algorithm {
+	case RS256:
+		_, ok = k.PublicKey.(*rsa.PublicKey)
+	case ES256:
+		var publicKey *ecdsa.PublicKey
+		publicKey, ok = k.PublicKey.(*ecdsa.PublicKey)
+		ok = ok && publicKey.Curve == elliptic.P256()
+	case EdDSA:
+		_, ok = k.PublicKey.(ed25519.PublicKey)
+	default:
+		return fmt.Errorf("unsupported signing algorithm %s for key %s", k.Algorithm, k.ID)
+	}
+
+	if !ok {
+		return fmt.Errorf("key %s is not a valid %s key", k.ID, k.Algorithm)
+	}
+
+	return nil
+}
+
+// signingMethod returns the jwt-go signing method for the key.
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// isVerifiable checks if the key may still verify tokens. Keys
+// remain verifiable until the grace period after retirement
+// has passed.
+func (k *SigningKey) isVerifiable(gracePeriod time.Duration) bool {
+	if k.RetiredAt.IsZero() {
+		return true
+	}
+
+	return time.Now().Before(k.RetiredAt.Add(gracePeriod))
+}
+
+// toJWK converts the public portion of the key to a JSON Web Key.
+func (k *SigningKey) toJWK() (jwk, error) {
+	key := jwk{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+	}
+
+	switch publicKey := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.KeyType = "RSA"
+		key.N = encodeBigInt(publicKey.N, 0)
+		key.E = encodeBigInt(big.NewInt(int64(publicKey.E)), 0)
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		key.KeyType = "EC"
+		key.Curve = publicKey.Curve.Params().Name
+		key.X = encodeBigInt(publicKey.X, size)
+		key.Y = encodeBigInt(publicKey.Y, size)
+	case ed25519.PublicKey:
+		key.KeyType = "OKP"
+		key.Curve = "Ed25519"
+		key.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return key, fmt.Errorf("unsupported public key type for key %s", k.ID)
+	}
+
+	return key, nil
+}
+
+// encodeBigInt base64 encodes an integer as a big-endian value,
+// left padded with zeros to size bytes.
+func encodeBigInt(i *big.Int, size int) string {
+	b := i.Bytes()
+	if len(b) < size {
+		padded := make([]byte, size)
+		copy(padded[size-len(b):], b)
+		b = padded
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// marshalJWKS creates a JSON Web Key Set from verifiable keys.
+func marshalJWKS(keys []*SigningKey, gracePeriod time.Duration) ([]byte, error) {
+	set := jwkSet{Keys: []jwk{}}
+	for _, k := range keys {
+		if !k.isVerifiable(gracePeriod) {
+			continue
+		}
+
+		key, err := k.toJWK()
+		if err != nil {
+			return nil, err
+		}
+
+		set.Keys = append(set.Keys, key)
+	}
+
+	return json.Marshal(set)
+}
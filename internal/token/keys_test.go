@@ -0,0 +1,214 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSigningKey_ParseSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("failed to generate RSA key:", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate EC key:", err)
+	}
+
+	ecKeyP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate EC key:", err)
+	}
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate Ed25519 key:", err)
+	}
+
+	ecBytes, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal("failed to marshal EC key:", err)
+	}
+
+	ecP384Bytes, err := x509.MarshalECPrivateKey(ecKeyP384)
+	if err != nil {
+		t.Fatal("failed to marshal EC key:", err)
+	}
+
+	edBytes, err := x509.MarshalPKCS8PrivateKey(edPrivateKey)
+	if err != nil {
+		t.Fatal("failed to marshal Ed25519 key:", err)
+	}
+
+	edPublicBytes, err := x509.MarshalPKIXPublicKey(edPublicKey)
+	if err != nil {
+		t.Fatal("failed to marshal Ed25519 public key:", err)
+	}
+
+	tt := []struct {
+		name       string
+		algorithm  string
+		pemType    string
+		pemBytes   []byte
+		hasError   bool
+		canSign    bool
+		isVerified bool
+	}{
+		{
+			name:      "RSA private key",
+			algorithm: RS256,
+			pemType:   "RSA PRIVATE KEY",
+			pemBytes:  x509.MarshalPKCS1PrivateKey(rsaKey),
+			hasError:  false,
+			canSign:   true,
+		},
+		{
+			name:      "EC private key",
+			algorithm: ES256,
+			pemType:   "EC PRIVATE KEY",
+			pemBytes:  ecBytes,
+			hasError:  false,
+			canSign:   true,
+		},
+		{
+			name:      "Ed25519 private key",
+			algorithm: EdDSA,
+			pemType:   "PRIVATE KEY",
+			pemBytes:  edBytes,
+			hasError:  false,
+			canSign:   true,
+		},
+		{
+			name:      "Ed25519 public key",
+			algorithm: EdDSA,
+			pemType:   "PUBLIC KEY",
+			pemBytes:  edPublicBytes,
+			hasError:  false,
+			canSign:   false,
+		},
+		{
+			name:      "Algorithm mismatch failure",
+			algorithm: RS256,
+			pemType:   "PRIVATE KEY",
+			pemBytes:  edBytes,
+			hasError:  true,
+		},
+		{
+			name:      "Curve mismatch failure",
+			algorithm: ES256,
+			pemType:   "EC PRIVATE KEY",
+			pemBytes:  ecP384Bytes,
+			hasError:  true,
+		},
+		{
+			name:      "Unsupported algorithm failure",
+			algorithm: "HS256",
+			pemType:   "PRIVATE KEY",
+			pemBytes:  edBytes,
+			hasError:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := pem.EncodeToMemory(&pem.Block{Type: tc.pemType, Bytes: tc.pemBytes})
+			key, err := ParseSigningKey("key-1", tc.algorithm, b)
+			if tc.hasError && err == nil {
+				t.Fatal("expected error, received nil")
+			}
+			if tc.hasError {
+				return
+			}
+			if err != nil {
+				t.Fatal("expected nil error:", err)
+			}
+
+			if key.ID != "key-1" {
+				t.Error("key ID does not match", cmp.Diff(key.ID, "key-1"))
+			}
+			if key.PublicKey == nil {
+				t.Error("public key not set")
+			}
+			if tc.canSign != (key.PrivateKey != nil) {
+				t.Errorf("private key availability mismatch, want %v", tc.canSign)
+			}
+		})
+	}
+}
+
+func TestSigningKey_MarshalJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("failed to generate RSA key:", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate EC key:", err)
+	}
+
+	edPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate Ed25519 key:", err)
+	}
+
+	keys := []*SigningKey{
+		{ID: "rsa-1", Algorithm: RS256, PublicKey: &rsaKey.PublicKey},
+		{ID: "ec-1", Algorithm: ES256, PublicKey: &ecKey.PublicKey},
+		{ID: "ed-1", Algorithm: EdDSA, PublicKey: edPublicKey},
+		{
+			ID:        "ed-0",
+			Algorithm: EdDSA,
+			PublicKey: edPublicKey,
+			RetiredAt: time.Now().Add(-time.Hour),
+		},
+	}
+
+	b, err := marshalJWKS(keys, time.Minute*20)
+	if err != nil {
+		t.Fatal("failed to marshal JWKS:", err)
+	}
+
+	var set jwkSet
+	if err = json.Unmarshal(b, &set); err != nil {
+		t.Fatal("failed to unmarshal JWKS:", err)
+	}
+
+	expected := []jwk{
+		{KeyType: "RSA", KeyID: "rsa-1", Use: "sig", Algorithm: RS256},
+		{KeyType: "EC", KeyID: "ec-1", Use: "sig", Algorithm: ES256, Curve: "P-256"},
+		{KeyType: "OKP", KeyID: "ed-1", Use: "sig", Algorithm: EdDSA, Curve: "Ed25519"},
+	}
+
+	if len(set.Keys) != len(expected) {
+		t.Fatalf("incorrect number of keys, want %v got %v", len(expected), len(set.Keys))
+	}
+
+	for i, k := range set.Keys {
+		if k.KeyType == "RSA" && (k.N == "" || k.E != "AQAB") {
+			t.Error("invalid RSA key parameters")
+		}
+		if k.KeyType == "EC" && (len(k.X) != 43 || len(k.Y) != 43) {
+			t.Error("invalid EC key parameters")
+		}
+		if k.KeyType == "OKP" && len(k.X) != 43 {
+			t.Error("invalid OKP key parameters")
+		}
+
+		k.N, k.E, k.X, k.Y = "", "", "", ""
+		if !cmp.Equal(k, expected[i]) {
+			t.Error("JWK does not match", cmp.Diff(k, expected[i]))
+		}
+	}
+}
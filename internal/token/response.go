@@ -1,8 +1,30 @@
 package token
 
+import (
+	"fmt"
+	"net/url"
+)
+
 // Response ensures consistent formatting for JSON APIs.
 type Response struct {
 	Token        string `json:"token"`
 	ClientID     string `json:"clientID,omitempty"`
 	RefreshToken string `json:"refreshToken,omitempty"`
 }
+
+// MagicLink returns a link to a client URL with a signed token
+// and OTP code embedded as query parameters. The client is expected
+// to submit both values to complete OTP verification.
+func MagicLink(baseURL, signedToken, code string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid magic link URL: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("token", signedToken)
+	q.Set("code", code)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
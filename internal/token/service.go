@@ -46,6 +46,8 @@ type RefreshToken struct {
 type rediser interface {
 	Get(ctx context.Context, key string) *redislib.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redislib.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redislib.BoolCmd
+	Pipelined(ctx context.Context, fn func(redislib.Pipeliner) error) ([]redislib.Cmder, error)
 	Close() error
 }
 
@@ -57,6 +59,15 @@ func WithOTPDeliveryMethod(method auth.DeliveryMethod) auth.TokenOption {
 	}
 }
 
+// WithOTPMessageType sets the type of message an OTP code related
+// to a JWT token is delivered in. The expiry of the code may vary
+// by message type.
+func WithOTPMessageType(msgType auth.MessageType) auth.TokenOption {
+	return func(conf *auth.TokenConfiguration) {
+		conf.MessageType = msgType
+	}
+}
+
 // WithOTPAddress sets an address to receive a randomly generated
 // OTP code. If a delivery method is configured on the token without
 // a corresponding address, we will deliver the OTP code to the user's
@@ -76,6 +87,24 @@ func WithRefreshableToken(token *auth.Token) auth.TokenOption {
 	}
 }
 
+// WithRefreshTokenRotation issues a new refresh token in place of the
+// refresh token carried over by WithRefreshableToken. The previous refresh
+// token is consumed and may not be used again.
+func WithRefreshTokenRotation() auth.TokenOption {
+	return func(conf *auth.TokenConfiguration) {
+		conf.RotateRefreshToken = true
+	}
+}
+
+// WithExpiry overrides the default expiry time of a JWT token.
+// Tokens with a limited purpose, such as password reset, may
+// be configured with a shorter lifetime.
+func WithExpiry(d time.Duration) auth.TokenOption {
+	return func(conf *auth.TokenConfiguration) {
+		conf.ExpiresIn = d
+	}
+}
+
 // service is an implementation of auth.TokenService
 // backed by redis.
 type service struct {
@@ -90,6 +119,9 @@ type service struct {
 	otp                auth.OTPService
 	cookieMaxAge       int
 	cookieDomain       string
+	keys               []*SigningKey
+	activeKeyID        string
+	keyGracePeriod     time.Duration
 }
 
 // Create creates a new, unsigned JWT token for a User
@@ -110,6 +142,10 @@ func (s *service) Create(ctx context.Context, user *auth.User, state auth.TokenS
 		return nil, err
 	}
 
+	if err = s.consumeRefreshToken(ctx, conf); err != nil {
+		return nil, err
+	}
+
 	code, codeHash, err := s.genOTPAndHash(conf, user)
 	if err != nil {
 		return nil, err
@@ -120,7 +156,12 @@ func (s *service) Create(ctx context.Context, user *auth.User, state auth.TokenS
 		return nil, err
 	}
 
-	expiresAt := time.Now().Add(s.tokenExpiry).Unix()
+	expiresIn := s.tokenExpiry
+	if conf.ExpiresIn > 0 {
+		expiresIn = conf.ExpiresIn
+	}
+
+	expiresAt := time.Now().Add(expiresIn).Unix()
 	tfaOptions := s.genTFAOptions(user)
 
 	token := auth.Token{
@@ -152,9 +193,27 @@ func (s *service) Create(ctx context.Context, user *auth.User, state auth.TokenS
 }
 
 // Sign creates a signed JWT token string from a token struct.
+// Tokens are signed with the active versioned key if one is configured,
+// otherwise they are signed with the HS512 secret.
 func (s *service) Sign(ctx context.Context, token *auth.Token) (string, error) {
-	jwtUnsigned := jwt.NewWithClaims(jwt.SigningMethodHS512, token)
-	jwtSigned, err := jwtUnsigned.SignedString(s.secret)
+	if s.activeKeyID == "" {
+		jwtUnsigned := jwt.NewWithClaims(jwt.SigningMethodHS512, token)
+		jwtSigned, err := jwtUnsigned.SignedString(s.secret)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign JWT token: %w", err)
+		}
+
+		return jwtSigned, nil
+	}
+
+	key := s.keyByID(s.activeKeyID)
+	if key == nil || key.PrivateKey == nil {
+		return "", fmt.Errorf("no private key found for active key %s", s.activeKeyID)
+	}
+
+	jwtUnsigned := jwt.NewWithClaims(key.signingMethod(), token)
+	jwtUnsigned.Header["kid"] = key.ID
+	jwtSigned, err := jwtUnsigned.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign JWT token: %w", err)
 	}
@@ -170,16 +229,8 @@ func (s *service) Validate(ctx context.Context, signedToken string, clientID str
 		return nil, auth.ErrInvalidToken("bearer token expected")
 	}
 
-	tokenParser := func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
-		}
-
-		return s.secret, nil
-	}
-
 	signedToken = strings.TrimPrefix(signedToken, "Bearer ")
-	unpackedToken, err := jwt.Parse(signedToken, tokenParser)
+	unpackedToken, err := jwt.Parse(signedToken, s.verificationKey)
 	if err != nil {
 		return nil, fmt.Errorf("%v: %w", err, auth.ErrInvalidToken("token is invalid"))
 	}
@@ -226,6 +277,17 @@ func (s *service) Validate(ctx context.Context, signedToken string, clientID str
 	return &token, nil
 }
 
+// JWKS returns a JSON Web Key Set containing the public keys
+// used to verify JWT tokens.
+func (s *service) JWKS(ctx context.Context) ([]byte, error) {
+	b, err := marshalJWKS(s.keys, s.keyGracePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create JWKS: %w", err)
+	}
+
+	return b, nil
+}
+
 // Revoke revokes a JWT token by its ID for a specified duration.
 func (s *service) Revoke(ctx context.Context, tokenID string) error {
 	_, err := s.repoMngr.LoginHistory().ByTokenID(ctx, tokenID)
@@ -261,6 +323,31 @@ func (s *service) Revoke(ctx context.Context, tokenID string) error {
 	return s.db.Set(ctx, revocationKey(tokenID), true, s.tokenExpiry).Err()
 }
 
+// RevokeAll revokes all unexpired tokens for a User, except for an
+// optional token ID. Revocation keys are written in a single pipeline.
+func (s *service) RevokeAll(ctx context.Context, userID, excludeTokenID string) error {
+	tokenIDs, err := s.repoMngr.LoginHistory().RevokeByUserID(ctx, userID, excludeTokenID)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate login history records: %w", err)
+	}
+
+	if len(tokenIDs) == 0 {
+		return nil
+	}
+
+	_, err = s.db.Pipelined(ctx, func(pipe redislib.Pipeliner) error {
+		for _, tokenID := range tokenIDs {
+			pipe.Set(ctx, revocationKey(tokenID), true, s.tokenExpiry)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	return nil
+}
+
 // Cookies returns a secure cookies to accompany a token.
 func (s *service) Cookies(ctx context.Context, token *auth.Token) []*http.Cookie {
 	cookies := []*http.Cookie{
@@ -303,6 +390,14 @@ func (s *service) Refreshable(ctx context.Context, token *auth.Token, refreshTok
 		return auth.ErrInvalidToken("token is revoked")
 	}
 
+	err = s.db.Get(ctx, consumptionKey(token.RefreshTokenHash)).Err()
+	if err == nil {
+		return s.revokeFamily(ctx, token)
+	}
+	if err != redislib.Nil {
+		return fmt.Errorf("cannot lookup refresh token consumption: %w", err)
+	}
+
 	return nil
 }
 
@@ -316,6 +411,46 @@ func (s *service) RefreshableTill(ctx context.Context, token *auth.Token, refres
 	return time.Unix(r.ExpiresAt, 0)
 }
 
+// verificationKey returns the key to verify a token's signature. Tokens
+// without a `kid` header are verified with the HS512 secret. Tokens signed
+// by a versioned key must use the key's algorithm and the key must not be
+// retired beyond its grace period.
+func (s *service) verificationKey(token *jwt.Token) (interface{}, error) {
+	keyID, ok := token.Header["kid"].(string)
+	if !ok {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(s.secret) == 0 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		return s.secret, nil
+	}
+
+	key := s.keyByID(keyID)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %s", keyID)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	if !key.isVerifiable(s.keyGracePeriod) {
+		return nil, fmt.Errorf("signing key %s is retired", keyID)
+	}
+
+	return key.PublicKey, nil
+}
+
+func (s *service) keyByID(keyID string) *SigningKey {
+	for _, k := range s.keys {
+		if k.ID == keyID {
+			return k
+		}
+	}
+
+	return nil
+}
+
 func (s *service) genTFAOptions(user *auth.User) []auth.TFAOptions {
 	options := []auth.TFAOptions{}
 
@@ -331,10 +466,18 @@ func (s *service) genTFAOptions(user *auth.User) []auth.TFAOptions {
 		options = append(options, auth.TOTP)
 	}
 
+	if user.IsHOTPAllowed {
+		options = append(options, auth.HOTP)
+	}
+
 	if user.IsDeviceAllowed {
 		options = append(options, auth.FIDODevice)
 	}
 
+	if user.IsRecoveryCodeAllowed {
+		options = append(options, auth.RecoveryCode)
+	}
+
 	return options
 }
 
@@ -378,7 +521,7 @@ func (s *service) genOTPAndHash(conf *auth.TokenConfiguration, user *auth.User)
 	address := conf.DeliveryAddress
 	sendToDefaultAddress := address == ""
 
-	usePhoneNumber := conf.DeliveryMethod == auth.Phone &&
+	usePhoneNumber := conf.DeliveryMethod.IsPhone() &&
 		user.IsPhoneOTPAllowed &&
 		sendToDefaultAddress
 
@@ -398,7 +541,7 @@ func (s *service) genOTPAndHash(conf *auth.TokenConfiguration, user *auth.User)
 		return "", "", auth.ErrInvalidField("delivery address is not valid")
 	}
 
-	code, codeHash, err := s.otp.OTPCode(address, conf.DeliveryMethod)
+	code, codeHash, err := s.otp.OTPCode(address, conf.DeliveryMethod, conf.MessageType)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate OTP code: %w", err)
 	}
@@ -407,7 +550,7 @@ func (s *service) genOTPAndHash(conf *auth.TokenConfiguration, user *auth.User)
 }
 
 func (s *service) genRefreshTokenAndHash(conf *auth.TokenConfiguration) (string, string, error) {
-	if conf.RefreshableToken != nil {
+	if conf.RefreshableToken != nil && !conf.RotateRefreshToken {
 		return "", conf.RefreshableToken.RefreshTokenHash, nil
 	}
 
@@ -436,6 +579,45 @@ func (s *service) genRefreshTokenAndHash(conf *auth.TokenConfiguration) (string,
 	return encodedToken, h, nil
 }
 
+// consumeRefreshToken marks the refresh token of a token being rotated
+// as consumed. Consumption is atomic so that concurrent attempts to
+// rotate the same refresh token are treated as reuse.
+func (s *service) consumeRefreshToken(ctx context.Context, conf *auth.TokenConfiguration) error {
+	if conf.RefreshableToken == nil || !conf.RotateRefreshToken {
+		return nil
+	}
+
+	token := conf.RefreshableToken
+	key := consumptionKey(token.RefreshTokenHash)
+	ok, err := s.db.SetNX(ctx, key, true, s.refreshTokenExpiry).Result()
+	if err != nil {
+		return fmt.Errorf("cannot consume refresh token: %w", err)
+	}
+	if !ok {
+		return s.revokeFamily(ctx, token)
+	}
+
+	return nil
+}
+
+// revokeFamily revokes a token after its consumed refresh token was
+// presented again. Tokens refreshed from the same login share a token ID,
+// so revoking it invalidates every token issued from that login.
+func (s *service) revokeFamily(ctx context.Context, token *auth.Token) error {
+	level.Warn(s.logger).Log(
+		"source", "TokenService.revokeFamily",
+		"message", "refresh token reuse detected",
+		"user_id", token.UserID,
+		"token_id", token.Id,
+	)
+
+	if err := s.Revoke(ctx, token.Id); err != nil {
+		return fmt.Errorf("cannot revoke token family: %w", err)
+	}
+
+	return auth.ErrInvalidToken("refresh token has been used")
+}
+
 func (s *service) invalidateOldTokens(ctx context.Context, conf *auth.TokenConfiguration, token *auth.Token) error {
 	proceed := conf.RefreshableToken != nil &&
 		conf.DeliveryMethod != "" &&
@@ -501,6 +683,10 @@ func invalidationKey(tokenID string) string {
 	return fmt.Sprintf("%s_invalid_after", tokenID)
 }
 
+func consumptionKey(refreshTokenHash string) string {
+	return fmt.Sprintf("%s_is_consumed", refreshTokenHash)
+}
+
 func revocationKey(tokenID string) string {
 	return fmt.Sprintf("%s_is_revoked", tokenID)
 }
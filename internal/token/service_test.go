@@ -2,6 +2,11 @@ package token
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
@@ -145,6 +150,28 @@ func TestTokenSvc_CreatePreAuthorized(t *testing.T) {
 	}
 }
 
+func TestTokenSvc_CreateWithExpiry(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	user := &auth.User{ID: "user_id", IsEmailOTPAllowed: true}
+	tokenSvc := NewTestTokenSvc(db, &test.RepositoryManager{})
+
+	token, err := tokenSvc.Create(ctx, user, auth.JWTResetAuthorized, WithExpiry(time.Second*2))
+	if err != nil {
+		t.Fatal("failed to create token:", err)
+	}
+
+	expiresIn := token.ExpiresAt - token.IssuedAt
+	if expiresIn > 2 {
+		t.Errorf("token expiry exceeds configured duration, want 2 got %v", expiresIn)
+	}
+}
+
 func TestTokenSvc_CreateWithTFAOptions(t *testing.T) {
 	tt := []struct {
 		name       string
@@ -268,6 +295,102 @@ func TestTokenSvc_CreateWithOTP(t *testing.T) {
 	}
 }
 
+func TestTokenSvc_CreateWithVoiceOTP(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	user := &auth.User{
+		ID:                "user_id",
+		IsPhoneOTPAllowed: true,
+		Phone: sql.NullString{
+			String: "+15555555555",
+			Valid:  true,
+		},
+	}
+	tokenSvc := NewTestTokenSvc(db, &test.RepositoryManager{})
+
+	token, err := tokenSvc.Create(
+		ctx,
+		user,
+		auth.JWTPreAuthorized,
+		WithOTPDeliveryMethod(auth.Voice),
+	)
+	if err != nil {
+		t.Fatal("failed to create token:", err)
+	}
+
+	o, err := otp.FromOTPHash(token.CodeHash)
+	if err != nil {
+		t.Fatal("failed to decode code hash:", err)
+	}
+
+	if o.DeliveryMethod != auth.Voice {
+		t.Error("otp delivery does not match", cmp.Diff(
+			o.DeliveryMethod, auth.Voice,
+		))
+	}
+	if o.Address != "+15555555555" {
+		t.Error("otp address does not match", cmp.Diff(
+			o.Address, "+15555555555",
+		))
+	}
+}
+
+func TestTokenSvc_CreateWithOTPMessageType(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	var msgType auth.MessageType
+	otpSvc := &test.OTPService{
+		OTPCodeFn: func(address string, method auth.DeliveryMethod, mt auth.MessageType) (string, string, error) {
+			msgType = mt
+			return "123456", "code-hash", nil
+		},
+	}
+	tokenSvc := NewService(
+		WithLogger(log.NewNopLogger()),
+		WithDB(db),
+		WithTokenExpiry(time.Second*10),
+		WithSecret("my-signing-secret"),
+		WithIssuer("authenticator"),
+		WithOTP(otpSvc),
+		WithRepoManager(&test.RepositoryManager{}),
+	)
+
+	user := &auth.User{
+		ID:                "user_id",
+		IsEmailOTPAllowed: true,
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+	}
+
+	_, err = tokenSvc.Create(
+		context.Background(),
+		user,
+		auth.JWTPreAuthorized,
+		WithOTPDeliveryMethod(auth.Email),
+		WithOTPMessageType(auth.MagicLink),
+	)
+	if err != nil {
+		t.Fatal("failed to create token:", err)
+	}
+
+	if msgType != auth.MagicLink {
+		t.Error("otp message type does not match", cmp.Diff(
+			msgType, auth.MagicLink,
+		))
+	}
+}
+
 func TestTokenSvc_CreateWithOTPAndAddress(t *testing.T) {
 	db, err := test.NewRedisDB()
 	if err != nil {
@@ -400,6 +523,96 @@ func TestTokenSvc_InvalidateAfterRevocation(t *testing.T) {
 	}
 }
 
+func TestTokenSvc_InvalidateAfterRevokeAll(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	repoMngr := postgres.TestClient(pgDB.DB)
+	ctx := context.Background()
+	user := &auth.User{
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+		Password: "swordfish",
+	}
+	err = repoMngr.User().Create(ctx, user)
+	if err != nil {
+		t.Fatal("failed to create test user", err)
+	}
+
+	tokenSvc := NewTestTokenSvc(db, repoMngr)
+
+	var tokens []*auth.Token
+	var jwtTokens []string
+	for i := 0; i < 3; i++ {
+		token, err := tokenSvc.Create(ctx, user, auth.JWTAuthorized)
+		if err != nil {
+			t.Fatal("failed to create token:", err)
+		}
+
+		err = repoMngr.LoginHistory().Create(ctx, &auth.LoginHistory{
+			TokenID:   token.Id,
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(time.Minute),
+		})
+		if err != nil {
+			t.Fatal("failed to create login history", err)
+		}
+
+		jwtToken, err := tokenSvc.Sign(ctx, token)
+		if err != nil {
+			t.Fatal("failed to sign token:", err)
+		}
+
+		tokens = append(tokens, token)
+		jwtTokens = append(jwtTokens, fmt.Sprintf("Bearer %s", jwtToken))
+	}
+
+	currentToken := tokens[0]
+	err = tokenSvc.RevokeAll(ctx, user.ID, currentToken.Id)
+	if err != nil {
+		t.Fatal("failed to revoke tokens:", err)
+	}
+
+	_, err = tokenSvc.Validate(ctx, jwtTokens[0], currentToken.ClientID)
+	if err != nil {
+		t.Error("excluded token should remain valid:", err)
+	}
+
+	for i, token := range tokens[1:] {
+		_, err = tokenSvc.Validate(ctx, jwtTokens[i+1], token.ClientID)
+		if err == nil {
+			t.Error("revoked token should return error")
+			continue
+		}
+
+		code := auth.ErrorCode(err)
+		if code != auth.EInvalidToken {
+			t.Errorf("incorrect error code: want %s got %s",
+				auth.EInvalidToken, code)
+		}
+
+		loginHistory, err := repoMngr.LoginHistory().ByTokenID(ctx, token.Id)
+		if err != nil {
+			t.Fatal("no login history record found", err)
+		}
+
+		if !loginHistory.IsRevoked {
+			t.Error("login history was not revoked")
+		}
+	}
+}
+
 func TestTokenSvc_InvalidateAfterExpiry(t *testing.T) {
 	db, err := test.NewRedisDB()
 	if err != nil {
@@ -582,6 +795,12 @@ func TestTokenSvc_Refreshable(t *testing.T) {
 			}
 			defer pgDB.DropDB()
 
+			db, err := test.NewRedisDB()
+			if err != nil {
+				t.Fatal("failed to create test database:", err)
+			}
+			defer db.Close()
+
 			repoMngr := postgres.TestClient(pgDB.DB)
 			ctx := context.Background()
 
@@ -600,6 +819,7 @@ func TestTokenSvc_Refreshable(t *testing.T) {
 			tokenSvc := &service{
 				refreshTokenExpiry: tc.refreshTokenExpiry,
 				repoMngr:           repoMngr,
+				db:                 db,
 			}
 			token := &auth.Token{
 				StandardClaims: jwt.StandardClaims{
@@ -633,6 +853,172 @@ func TestTokenSvc_Refreshable(t *testing.T) {
 	}
 }
 
+func TestTokenSvc_RefreshTokenRotation(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	repoMngr := postgres.TestClient(pgDB.DB)
+	ctx := context.Background()
+	user := &auth.User{
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+		Password: "swordfish",
+	}
+	err = repoMngr.User().Create(ctx, user)
+	if err != nil {
+		t.Fatal("failed to create test user", err)
+	}
+
+	tokenSvc := NewTestTokenSvc(db, repoMngr)
+
+	token, err := tokenSvc.Create(ctx, user, auth.JWTAuthorized)
+	if err != nil {
+		t.Fatal("failed to create token:", err)
+	}
+
+	err = repoMngr.LoginHistory().Create(ctx, &auth.LoginHistory{
+		TokenID:   token.Id,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal("failed to create login history", err)
+	}
+
+	err = tokenSvc.Refreshable(ctx, token, token.RefreshToken)
+	if err != nil {
+		t.Fatal("token should be refreshable:", err)
+	}
+
+	refreshedToken, err := tokenSvc.Create(
+		ctx,
+		user,
+		auth.JWTAuthorized,
+		WithRefreshableToken(token),
+		WithRefreshTokenRotation(),
+	)
+	if err != nil {
+		t.Fatal("failed to refresh token:", err)
+	}
+
+	if refreshedToken.Id != token.Id {
+		t.Error("token ID does not match", cmp.Diff(refreshedToken.Id, token.Id))
+	}
+	if refreshedToken.RefreshToken == "" || refreshedToken.RefreshToken == token.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if refreshedToken.RefreshTokenHash == token.RefreshTokenHash {
+		t.Error("refresh token hash was not rotated")
+	}
+
+	err = tokenSvc.Refreshable(ctx, refreshedToken, refreshedToken.RefreshToken)
+	if err != nil {
+		t.Fatal("rotated token should be refreshable:", err)
+	}
+
+	err = tokenSvc.Refreshable(ctx, token, token.RefreshToken)
+	if !cmp.Equal(auth.ErrorCode(err), auth.EInvalidToken) {
+		t.Error("error code does not match", cmp.Diff(
+			auth.ErrorCode(err), auth.EInvalidToken,
+		))
+	}
+
+	jwtToken, err := tokenSvc.Sign(ctx, refreshedToken)
+	if err != nil {
+		t.Fatal("failed to sign token:", err)
+	}
+
+	_, err = tokenSvc.Validate(ctx, fmt.Sprintf("Bearer %s", jwtToken), token.ClientID)
+	if !cmp.Equal(auth.ErrorCode(err), auth.EInvalidToken) {
+		t.Error("token family should be revoked after reuse", cmp.Diff(
+			auth.ErrorCode(err), auth.EInvalidToken,
+		))
+	}
+}
+
+func TestTokenSvc_RotationRejectsConsumedRefreshToken(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	pgDB, err := test.NewPGDB()
+	if err != nil {
+		t.Fatal("failed to create test database:", err)
+	}
+	defer pgDB.DropDB()
+
+	repoMngr := postgres.TestClient(pgDB.DB)
+	ctx := context.Background()
+	user := &auth.User{
+		Email: sql.NullString{
+			String: "jane@example.com",
+			Valid:  true,
+		},
+		Password: "swordfish",
+	}
+	err = repoMngr.User().Create(ctx, user)
+	if err != nil {
+		t.Fatal("failed to create test user", err)
+	}
+
+	tokenSvc := NewTestTokenSvc(db, repoMngr)
+
+	token, err := tokenSvc.Create(ctx, user, auth.JWTAuthorized)
+	if err != nil {
+		t.Fatal("failed to create token:", err)
+	}
+
+	err = repoMngr.LoginHistory().Create(ctx, &auth.LoginHistory{
+		TokenID:   token.Id,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal("failed to create login history", err)
+	}
+
+	// Concurrent refresh requests may both pass Refreshable before
+	// either consumes the refresh token.
+	_, err = tokenSvc.Create(ctx, user, auth.JWTAuthorized,
+		WithRefreshableToken(token),
+		WithRefreshTokenRotation(),
+	)
+	if err != nil {
+		t.Fatal("failed to refresh token:", err)
+	}
+
+	_, err = tokenSvc.Create(ctx, user, auth.JWTAuthorized,
+		WithRefreshableToken(token),
+		WithRefreshTokenRotation(),
+	)
+	if !cmp.Equal(auth.ErrorCode(err), auth.EInvalidToken) {
+		t.Error("error code does not match", cmp.Diff(
+			auth.ErrorCode(err), auth.EInvalidToken,
+		))
+	}
+
+	lh, err := repoMngr.LoginHistory().ByTokenID(ctx, token.Id)
+	if err != nil {
+		t.Fatal("no login history record found", err)
+	}
+	if !lh.IsRevoked {
+		t.Error("login history was not revoked")
+	}
+}
+
 func TestTokenSvc_InvalidatesOldTokensWithOTP(t *testing.T) {
 	db, err := test.NewRedisDB()
 	if err != nil {
@@ -664,3 +1050,190 @@ func TestTokenSvc_InvalidatesOldTokensWithOTP(t *testing.T) {
 		))
 	}
 }
+
+func TestTokenSvc_SignWithVersionedKeys(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("failed to generate RSA key:", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate EC key:", err)
+	}
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate Ed25519 key:", err)
+	}
+
+	keys := []*SigningKey{
+		{ID: "rsa-1", Algorithm: RS256, PrivateKey: rsaKey, PublicKey: &rsaKey.PublicKey},
+		{ID: "ec-1", Algorithm: ES256, PrivateKey: ecKey, PublicKey: &ecKey.PublicKey},
+		{ID: "ed-1", Algorithm: EdDSA, PrivateKey: edPrivateKey, PublicKey: edPublicKey},
+	}
+
+	for _, key := range keys {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			ctx := context.Background()
+			user := &auth.User{ID: "user_id"}
+			options := []ConfigOption{
+				WithDB(db),
+				WithIssuer("authenticator"),
+				WithActiveKey(key.ID),
+			}
+			for _, k := range keys {
+				options = append(options, WithSigningKey(k))
+			}
+			tokenSvc := NewService(options...)
+
+			token, err := tokenSvc.Create(ctx, user, auth.JWTAuthorized)
+			if err != nil {
+				t.Fatal("failed to create token:", err)
+			}
+
+			jwtToken, err := tokenSvc.Sign(ctx, token)
+			if err != nil {
+				t.Fatal("failed to sign token:", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(jwtToken, jwt.MapClaims{})
+			if err != nil {
+				t.Fatal("failed to parse token:", err)
+			}
+			if parsed.Header["kid"] != key.ID {
+				t.Error("kid header does not match", cmp.Diff(parsed.Header["kid"], key.ID))
+			}
+			if parsed.Header["alg"] != key.Algorithm {
+				t.Error("alg header does not match", cmp.Diff(parsed.Header["alg"], key.Algorithm))
+			}
+
+			_, err = tokenSvc.Validate(ctx, fmt.Sprintf("Bearer %s", jwtToken), token.ClientID)
+			if err != nil {
+				t.Error("failed to validate token:", err)
+			}
+		})
+	}
+}
+
+func TestTokenSvc_ValidateRetiredKey(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	tt := []struct {
+		name      string
+		retiredAt time.Time
+		isValid   bool
+	}{
+		{
+			name:      "Active key",
+			retiredAt: time.Time{},
+			isValid:   true,
+		},
+		{
+			name:      "Retired key within grace period",
+			retiredAt: time.Now().Add(-time.Minute),
+			isValid:   true,
+		},
+		{
+			name:      "Retired key after grace period",
+			retiredAt: time.Now().Add(-time.Hour),
+			isValid:   false,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal("failed to generate Ed25519 key:", err)
+			}
+
+			ctx := context.Background()
+			user := &auth.User{ID: "user_id"}
+			key := &SigningKey{
+				ID:         "ed-1",
+				Algorithm:  EdDSA,
+				PrivateKey: privateKey,
+				PublicKey:  publicKey,
+			}
+			signer := NewService(
+				WithDB(db),
+				WithSigningKey(key),
+				WithActiveKey(key.ID),
+			)
+			verifier := NewService(
+				WithDB(db),
+				WithSigningKey(&SigningKey{
+					ID:        key.ID,
+					Algorithm: key.Algorithm,
+					PublicKey: key.PublicKey,
+					RetiredAt: tc.retiredAt,
+				}),
+				WithKeyGracePeriod(time.Minute*20),
+			)
+
+			token, err := signer.Create(ctx, user, auth.JWTAuthorized)
+			if err != nil {
+				t.Fatal("failed to create token:", err)
+			}
+
+			jwtToken, err := signer.Sign(ctx, token)
+			if err != nil {
+				t.Fatal("failed to sign token:", err)
+			}
+
+			_, err = verifier.Validate(ctx, fmt.Sprintf("Bearer %s", jwtToken), token.ClientID)
+			if tc.isValid && err != nil {
+				t.Error("expected nil error:", err)
+			}
+			if !tc.isValid && auth.ErrorCode(err) != auth.EInvalidToken {
+				t.Errorf("incorrect error code: want %s got %s",
+					auth.EInvalidToken, auth.ErrorCode(err))
+			}
+		})
+	}
+}
+
+func TestTokenSvc_ValidateAlgorithmMismatch(t *testing.T) {
+	db, err := test.NewRedisDB()
+	if err != nil {
+		t.Fatal("faliled to create test database:", err)
+	}
+	defer db.Close()
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate Ed25519 key:", err)
+	}
+
+	ctx := context.Background()
+	tokenSvc := NewService(
+		WithDB(db),
+		WithSecret("my-signing-secret"),
+		WithSigningKey(&SigningKey{ID: "ed-1", Algorithm: EdDSA, PublicKey: publicKey}),
+	)
+
+	// A token signed with HMAC cannot claim to be signed by an asymmetric key.
+	jwtUnsigned := jwt.NewWithClaims(jwt.SigningMethodHS512, &auth.Token{UserID: "user_id"})
+	jwtUnsigned.Header["kid"] = "ed-1"
+	jwtToken, err := jwtUnsigned.SignedString([]byte("my-signing-secret"))
+	if err != nil {
+		t.Fatal("failed to sign token:", err)
+	}
+
+	_, err = tokenSvc.Validate(ctx, fmt.Sprintf("Bearer %s", jwtToken), "client-id")
+	if auth.ErrorCode(err) != auth.EInvalidToken {
+		t.Errorf("incorrect error code: want %s got %s",
+			auth.EInvalidToken, auth.ErrorCode(err))
+	}
+}
@@ -42,4 +42,31 @@ func SetupHTTPHandler(svc auth.TokenAPI, router *mux.Router, tokenSvc auth.Token
 		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
 		router.HandleFunc("/api/v1/token/refresh", httpHandler).Methods("Post")
 	}
+	{
+		handler = httpapi.AuthMiddleware(svc.List, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"Token.List", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/token", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.RevokeAll, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"Token.RevokeAll", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/token", httpHandler).Methods("Delete")
+	}
+	{
+		handler = svc.JWKS
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"Token.JWKS", httpapi.PerSecond, int64(10),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/.well-known/jwks.json", httpHandler).Methods("Get")
+	}
 }
@@ -2,11 +2,17 @@ package tokenapi
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/google/go-cmp/cmp"
@@ -16,6 +22,7 @@ import (
 	"github.com/fmitra/authenticator/internal/httpapi"
 	"github.com/fmitra/authenticator/internal/postgres"
 	"github.com/fmitra/authenticator/internal/test"
+	"github.com/fmitra/authenticator/internal/token"
 )
 
 func TestTokenAPI_Verify(t *testing.T) {
@@ -113,6 +120,93 @@ func TestTokenAPI_Revoke(t *testing.T) {
 	}
 }
 
+func TestTokenAPI_RevokeAll(t *testing.T) {
+	tt := []struct {
+		name          string
+		path          string
+		statusCode    int
+		revokeAllCall int
+		revokeAllFn   func() error
+	}{
+		{
+			name:          "Revokes all sessions",
+			path:          "/api/v1/token",
+			statusCode:    http.StatusOK,
+			revokeAllCall: 1,
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:          "Revokes all sessions except current",
+			path:          "/api/v1/token?keepCurrent=true",
+			statusCode:    http.StatusOK,
+			revokeAllCall: 1,
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:          "Invalid keepCurrent value",
+			path:          "/api/v1/token?keepCurrent=maybe",
+			statusCode:    http.StatusBadRequest,
+			revokeAllCall: 0,
+			revokeAllFn: func() error {
+				return nil
+			},
+		},
+		{
+			name:          "Revocation failure",
+			path:          "/api/v1/token",
+			statusCode:    http.StatusInternalServerError,
+			revokeAllCall: 1,
+			revokeAllFn: func() error {
+				return errors.New("whoops")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			tokenSvc := &test.TokenService{
+				ValidateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTAuthorized}, nil
+				},
+				RevokeAllFn: tc.revokeAllFn,
+			}
+			repoMngr := &test.RepositoryManager{}
+			svc := NewService(
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+			)
+
+			req, err := http.NewRequest("DELETE", tc.path, nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Error("status code does not match", cmp.Diff(rr.Code, tc.statusCode))
+			}
+
+			if tokenSvc.Calls.RevokeAll != tc.revokeAllCall {
+				t.Error("TokenService.RevokeAll call count mismatch", cmp.Diff(
+					tokenSvc.Calls.RevokeAll, tc.revokeAllCall,
+				))
+			}
+		})
+	}
+}
+
 func TestTokenAPI_Refresh(t *testing.T) {
 	router := mux.NewRouter()
 	ctx := context.Background()
@@ -136,12 +230,28 @@ func TestTokenAPI_Refresh(t *testing.T) {
 		t.Fatal("failed to create user:", err)
 	}
 
+	login := &auth.LoginHistory{
+		UserID:    user.ID,
+		TokenID:   "01DQ8SXZ3G0VEWBG8BHRTE3FVD",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	err = repoMngr.LoginHistory().Create(ctx, login)
+	if err != nil {
+		t.Fatal("failed to create login history:", err)
+	}
+
+	refreshableTill := time.Now().Add(time.Hour * 24 * 14).UTC().Truncate(time.Second)
 	tokenSvc := &test.TokenService{
 		RefreshableFn: func() error {
 			return nil
 		},
+		RefreshableTillFn: func() time.Time {
+			return refreshableTill
+		},
 		ValidateFn: func() (*auth.Token, error) {
-			return &auth.Token{UserID: user.ID, State: auth.JWTAuthorized}, nil
+			token := &auth.Token{UserID: user.ID, State: auth.JWTAuthorized}
+			token.Id = login.TokenID
+			return token, nil
 		},
 		CreateFn: func() (*auth.Token, error) {
 			return &auth.Token{}, nil
@@ -172,6 +282,7 @@ func TestTokenAPI_Refresh(t *testing.T) {
 		refreshableCallCount int = 1
 		createCallCount          = 1
 		signCallCount            = 1
+		cookiesCallCount         = 1
 		statusCode               = http.StatusOK
 	)
 
@@ -196,4 +307,216 @@ func TestTokenAPI_Refresh(t *testing.T) {
 			tokenSvc.Calls.Sign, signCallCount,
 		))
 	}
+	if tokenSvc.Calls.Cookies != cookiesCallCount {
+		t.Error("TokenService.Cookies call count does not match", cmp.Diff(
+			tokenSvc.Calls.Cookies, cookiesCallCount,
+		))
+	}
+
+	login, err = repoMngr.LoginHistory().ByTokenID(ctx, login.TokenID)
+	if err != nil {
+		t.Fatal("failed to retrieve login history:", err)
+	}
+	if !login.ExpiresAt.Equal(refreshableTill) {
+		t.Error("login history expiry does not match", cmp.Diff(login.ExpiresAt, refreshableTill))
+	}
+}
+
+func TestTokenAPI_List(t *testing.T) {
+	tt := []struct {
+		name         string
+		statusCode   int
+		path         string
+		errMessage   string
+		byUserIDFn   func() ([]*auth.LoginHistory, error)
+		sessionCount int
+	}{
+		{
+			name:       "Invalid limit failure",
+			statusCode: http.StatusBadRequest,
+			path:       "/api/v1/token?limit=1000",
+			errMessage: "Limit must be between 1 and 100",
+			byUserIDFn: func() ([]*auth.LoginHistory, error) {
+				return []*auth.LoginHistory{}, nil
+			},
+			sessionCount: 0,
+		},
+		{
+			name:       "Invalid offset failure",
+			statusCode: http.StatusBadRequest,
+			path:       "/api/v1/token?offset=-1",
+			errMessage: "Offset must be a positive number",
+			byUserIDFn: func() ([]*auth.LoginHistory, error) {
+				return []*auth.LoginHistory{}, nil
+			},
+			sessionCount: 0,
+		},
+		{
+			name:       "Repository failure",
+			statusCode: http.StatusInternalServerError,
+			path:       "/api/v1/token",
+			errMessage: "An internal error occurred",
+			byUserIDFn: func() ([]*auth.LoginHistory, error) {
+				return nil, sql.ErrConnDone
+			},
+			sessionCount: 0,
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusOK,
+			path:       "/api/v1/token?limit=10&offset=0",
+			errMessage: "",
+			byUserIDFn: func() ([]*auth.LoginHistory, error) {
+				return []*auth.LoginHistory{
+					{
+						TokenID:   "token-id",
+						IPAddress: "127.0.0.1",
+						UserAgent: "Mozilla/5.0",
+						TFAMethod: auth.TOTP,
+						ExpiresAt: time.Now().Add(time.Hour),
+					},
+					{
+						TokenID:   "revoked-token-id",
+						IsRevoked: true,
+						TFAMethod: auth.OTPEmail,
+						ExpiresAt: time.Now().Add(time.Hour),
+					},
+				}, nil
+			},
+			sessionCount: 2,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			tokenSvc := &test.TokenService{
+				ValidateFn: func() (*auth.Token, error) {
+					token := &auth.Token{State: auth.JWTAuthorized}
+					token.Id = "token-id"
+					return token, nil
+				},
+			}
+			loginHistoryRepo := &test.LoginHistoryRepository{
+				ByUserIDFn: tc.byUserIDFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				LoginHistoryFn: func() auth.LoginHistoryRepository {
+					return loginHistoryRepo
+				},
+			}
+			svc := NewService(
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+			)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Error("status code does not match", cmp.Diff(rr.Code, tc.statusCode))
+			}
+
+			if tc.errMessage != "" {
+				err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+
+			var resp listResponse
+			if err = json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal("failed to decode response:", err)
+			}
+
+			if len(resp.Sessions) != tc.sessionCount {
+				t.Error("session count does not match", cmp.Diff(len(resp.Sessions), tc.sessionCount))
+			}
+
+			if !resp.Sessions[0].IsCurrent || resp.Sessions[1].IsCurrent {
+				t.Error("current session is not flagged correctly")
+			}
+		})
+	}
+}
+
+func TestTokenAPI_JWKS(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate Ed25519 key:", err)
+	}
+
+	router := mux.NewRouter()
+	tokenSvc := token.NewService(
+		token.WithSigningKey(&token.SigningKey{
+			ID:         "key-1",
+			Algorithm:  token.EdDSA,
+			PrivateKey: privateKey,
+			PublicKey:  publicKey,
+		}),
+		token.WithActiveKey("key-1"),
+	)
+	svc := NewService(
+		WithTokenService(tokenSvc),
+		WithRepoManager(&test.RepositoryManager{}),
+	)
+
+	req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatal("failed to create request:", err)
+	}
+
+	logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+	SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Error("status code does not match", cmp.Diff(rr.Code, http.StatusOK))
+	}
+
+	contentType := rr.Header().Get("Content-Type")
+	if contentType != "application/json; charset=utf-8" {
+		t.Error("content type does not match", cmp.Diff(contentType, "application/json; charset=utf-8"))
+	}
+
+	cacheControl := rr.Header().Get("Cache-Control")
+	if cacheControl != "public, max-age=300" {
+		t.Error("cache control does not match", cmp.Diff(cacheControl, "public, max-age=300"))
+	}
+
+	var resp struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err = json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal("failed to decode response:", err)
+	}
+
+	if len(resp.Keys) != 1 {
+		t.Fatalf("incorrect number of keys, want 1 got %v", len(resp.Keys))
+	}
+
+	expected := map[string]string{
+		"kid": "key-1",
+		"kty": "OKP",
+		"alg": token.EdDSA,
+		"use": "sig",
+		"crv": "Ed25519",
+		"x":   base64.RawURLEncoding.EncodeToString(publicKey),
+	}
+	if !cmp.Equal(resp.Keys[0], expected) {
+		t.Error("key does not match", cmp.Diff(resp.Keys[0], expected))
+	}
 }
@@ -0,0 +1,61 @@
+package tokenapi
+
+import (
+	"net/http"
+	"strconv"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+const (
+	// defaultListLimit is the default number of sessions returned.
+	defaultListLimit = 20
+	// maxListLimit is the maximum number of sessions returned.
+	maxListLimit = 100
+)
+
+type listRequest struct {
+	Limit  int
+	Offset int
+}
+
+func decodeListRequest(r *http.Request) (*listRequest, error) {
+	req := listRequest{Limit: defaultListLimit}
+	q := r.URL.Query()
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, auth.ErrInvalidField("limit must be between 1 and 100")
+		}
+		req.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, auth.ErrInvalidField("offset must be a positive number")
+		}
+		req.Offset = offset
+	}
+
+	return &req, nil
+}
+
+type revokeAllRequest struct {
+	KeepCurrent bool
+}
+
+func decodeRevokeAllRequest(r *http.Request) (*revokeAllRequest, error) {
+	var req revokeAllRequest
+
+	if v := r.URL.Query().Get("keepCurrent"); v != "" {
+		keepCurrent, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, auth.ErrInvalidField("keepCurrent must be a boolean")
+		}
+		req.KeepCurrent = keepCurrent
+	}
+
+	return &req, nil
+}
@@ -1,6 +1,45 @@
 package tokenapi
 
+import (
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+)
+
 // Response is a success response.
 type Response struct {
 	Result string `json:"result"`
 }
+
+type sessionResponse struct {
+	TokenID   string          `json:"tokenID"`
+	IsRevoked bool            `json:"isRevoked"`
+	IsCurrent bool            `json:"isCurrent"`
+	IPAddress string          `json:"ipAddress"`
+	UserAgent string          `json:"userAgent"`
+	TFAMethod auth.TFAOptions `json:"tfaMethod"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+}
+
+type listResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// Create creates a list response from a User's LoginHistory. The
+// session associated with the current token is flagged.
+func (r *listResponse) Create(logins []*auth.LoginHistory, currentTokenID string) {
+	r.Sessions = make([]sessionResponse, 0, len(logins))
+	for _, lh := range logins {
+		r.Sessions = append(r.Sessions, sessionResponse{
+			TokenID:   lh.TokenID,
+			IsRevoked: lh.IsRevoked,
+			IsCurrent: lh.TokenID == currentTokenID,
+			IPAddress: lh.IPAddress,
+			UserAgent: lh.UserAgent,
+			TFAMethod: lh.TFAMethod,
+			CreatedAt: lh.CreatedAt,
+			ExpiresAt: lh.ExpiresAt,
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package tokenapi
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -30,13 +31,37 @@ func (s *service) Revoke(w http.ResponseWriter, r *http.Request) (interface{}, e
 	return &Response{Result: "success"}, nil
 }
 
+// RevokeAll revokes every active session belonging to a User. The
+// current session is preserved if requested with `keepCurrent=true`.
+func (s *service) RevokeAll(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	req, err := decodeRevokeAllRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	var excludeTokenID string
+	if req.KeepCurrent {
+		excludeTokenID = httpapi.GetToken(r).Id
+	}
+
+	if err = s.token.RevokeAll(ctx, userID, excludeTokenID); err != nil {
+		return nil, err
+	}
+
+	return &Response{Result: "success"}, nil
+}
+
 // Verify check's if a User's header credentials (token and matching client ID) are valid.
 func (s *service) Verify(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	return &Response{Result: "success"}, nil
 }
 
 // Refresh refreshes an expired token with a new expiry time. Refresh tokens share
-// a token's original ID and client ID.
+// a token's original ID and client ID. Each refresh issues a new refresh token and
+// consumes the previous one. Presenting a consumed refresh token revokes the token.
 func (s *service) Refresh(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	ctx := r.Context()
 	token := httpapi.GetToken(r)
@@ -51,20 +76,97 @@ func (s *service) Refresh(w http.ResponseWriter, r *http.Request) (interface{},
 		return nil, err
 	}
 
-	token, err = s.token.Create(
-		ctx,
-		user,
-		auth.JWTAuthorized,
-		tokenLib.WithRefreshableToken(token),
-	)
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start txn: %w", err)
+	}
+
+	// The login record is locked while the refresh token is rotated so
+	// that its expiry is extended with the token and a concurrent revocation
+	// of the session is not missed.
+	entity, err := client.WithAtomic(func() (interface{}, error) {
+		login, err := client.LoginHistory().GetForUpdate(ctx, token.Id)
+		if err != nil {
+			return nil, fmt.Errorf("cannot retrieve login history: %w", err)
+		}
+		if login.IsRevoked {
+			return nil, auth.ErrInvalidToken("token is revoked")
+		}
+
+		newToken, err := s.token.Create(
+			ctx,
+			user,
+			auth.JWTAuthorized,
+			tokenLib.WithRefreshableToken(token),
+			tokenLib.WithRefreshTokenRotation(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		login.ExpiresAt = s.token.RefreshableTill(ctx, newToken, newToken.RefreshToken)
+		if err = client.LoginHistory().Update(ctx, login); err != nil {
+			return nil, fmt.Errorf("cannot update login history: %w", err)
+		}
+
+		return newToken, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	token = entity.(*auth.Token)
 	signedToken, err := s.token.Sign(ctx, token)
 	if err != nil {
 		return nil, err
 	}
 
-	return &tokenLib.Response{Token: signedToken}, nil
+	// The client ID is carried over from the original token and
+	// its cookie is left untouched.
+	for _, cookie := range s.token.Cookies(ctx, token) {
+		if cookie.Name == tokenLib.RefreshTokenCookie {
+			http.SetCookie(w, cookie)
+		}
+	}
+
+	return &tokenLib.Response{
+		Token:        signedToken,
+		RefreshToken: token.RefreshToken,
+	}, nil
+}
+
+// List returns a User's recent login sessions, ordered from most to least
+// recent. Sessions may be revoked through Revoke.
+func (s *service) List(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	req, err := decodeListRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+	token := httpapi.GetToken(r)
+
+	logins, err := s.repoMngr.LoginHistory().ByUserID(ctx, userID, req.Limit, req.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp listResponse
+	resp.Create(logins, token.Id)
+
+	return &resp, nil
+}
+
+// JWKS returns a JSON Web Key Set of public keys used to verify JWT tokens.
+func (s *service) JWKS(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	jwks, err := s.token.JWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	return jwks, nil
 }
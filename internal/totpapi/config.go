@@ -1,15 +1,20 @@
 package totpapi
 
 import (
+	"github.com/boombuler/barcode/qr"
 	"github.com/go-kit/kit/log"
 
 	auth "github.com/fmitra/authenticator"
 )
 
+const defaultQRSize = 256
+
 // NewService returns a new implementation of auth.TOTPAPI.
 func NewService(options ...ConfigOption) auth.TOTPAPI {
 	s := service{
-		logger: log.NewNopLogger(),
+		logger:  log.NewNopLogger(),
+		qrSize:  defaultQRSize,
+		qrLevel: qr.M,
 	}
 
 	for _, opt := range options {
@@ -49,3 +54,19 @@ func WithTokenService(t auth.TokenService) ConfigOption {
 		s.token = t
 	}
 }
+
+// WithQRSize configures the service with the default width and
+// height in pixels of rendered QR codes.
+func WithQRSize(size int) ConfigOption {
+	return func(s *service) {
+		s.qrSize = size
+	}
+}
+
+// WithQRRecoveryLevel configures the service with the error
+// correction level of rendered QR codes.
+func WithQRRecoveryLevel(level qr.ErrorCorrectionLevel) ConfigOption {
+	return func(s *service) {
+		s.qrLevel = level
+	}
+}
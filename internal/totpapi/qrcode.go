@@ -0,0 +1,126 @@
+package totpapi
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+const (
+	// pngFormat renders QR codes as PNG images.
+	pngFormat = "png"
+	// svgFormat renders QR codes as SVG images.
+	svgFormat = "svg"
+	// quietZone is the number of blank modules surrounding a QR
+	// code, as required by the QR code specification.
+	quietZone = 4
+)
+
+// contentTypes maps QR code formats to their media types.
+var contentTypes = map[string]string{
+	pngFormat: "image/png",
+	svgFormat: "image/svg+xml",
+}
+
+// ParseRecoveryLevel returns the QR code error correction level
+// with a name of `L`, `M`, `Q` or `H`, recovering 7%, 15%, 25% and
+// 30% of data respectively.
+func ParseRecoveryLevel(name string) (qr.ErrorCorrectionLevel, error) {
+	switch strings.ToUpper(name) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	default:
+		return 0, fmt.Errorf("unknown QR code error correction level %q", name)
+	}
+}
+
+// qrCode renders content as a square QR code image of a size in
+// pixels. Modules are scaled to a whole number of pixels so that
+// scanners are not affected by blurred edges, leaving any remaining
+// space as additional margin.
+func qrCode(content, format string, size int, level qr.ErrorCorrectionLevel) ([]byte, error) {
+	code, err := qr.Encode(content, level, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode QR code: %w", err)
+	}
+
+	modules := code.Bounds().Dx()
+	isDark := func(x, y int) bool {
+		return code.At(x, y) == color.Black
+	}
+
+	switch format {
+	case pngFormat:
+		return qrPNG(modules, isDark, size)
+	case svgFormat:
+		return qrSVG(modules, isDark, size), nil
+	default:
+		return nil, fmt.Errorf("unknown QR code format %q", format)
+	}
+}
+
+func qrPNG(modules int, isDark func(x, y int) bool, size int) ([]byte, error) {
+	scale := size / (modules + quietZone*2)
+	if scale < 1 {
+		return nil, fmt.Errorf("QR code requires a size of at least %v pixels", modules+quietZone*2)
+	}
+	offset := (size - modules*scale) / 2
+
+	img := image.NewPaletted(
+		image.Rect(0, 0, size, size),
+		color.Palette{color.White, color.Black},
+	)
+	for y := 0; y < modules; y++ {
+		for x := 0; x < modules; x++ {
+			if !isDark(x, y) {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("cannot encode PNG: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// qrSVG renders a QR code as an SVG image. Dark modules are drawn
+// as a single path in a view box measured in modules.
+func qrSVG(modules int, isDark func(x, y int) bool, size int) []byte {
+	var path strings.Builder
+	for y := 0; y < modules; y++ {
+		for x := 0; x < modules; x++ {
+			if isDark(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	view := modules + quietZone*2
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, view, view)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`, view, view)
+	fmt.Fprintf(&buf, `<path d="%s" fill="#000"/>`, path.String())
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,55 @@
+package totpapi
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+func TestTOTPAPI_QRCode(t *testing.T) {
+	uri := "otpauth://totp/authenticator.local:jane@example.com?algorithm=SHA1&" +
+		"digits=6&issuer=authenticator.local&period=30&secret=572JFGKOMDRA6KHE5O3ZV62I6BP352E7"
+
+	b, err := qrCode(uri, pngFormat, 256, qr.M)
+	if err != nil {
+		t.Fatal("failed to render PNG:", err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal("failed to decode PNG:", err)
+	}
+	if img.Bounds().Dx() != 256 || img.Bounds().Dy() != 256 {
+		t.Error("incorrect PNG size:", img.Bounds())
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Error("PNG quiet zone should be white")
+	}
+
+	b, err = qrCode(uri, svgFormat, 256, qr.H)
+	if err != nil {
+		t.Fatal("failed to render SVG:", err)
+	}
+	svg := string(b)
+	if !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, `width="256" height="256"`) {
+		t.Error("incorrect SVG:", svg)
+	}
+
+	// Higher error correction levels require more modules.
+	low, err := qrCode(uri, svgFormat, 256, qr.L)
+	if err != nil {
+		t.Fatal("failed to render SVG:", err)
+	}
+	if len(low) >= len(b) {
+		t.Error("error correction level not applied")
+	}
+
+	if _, err = qrCode(uri, pngFormat, 32, qr.M); err == nil {
+		t.Error("expected error for size smaller than QR code, received nil")
+	}
+	if _, err = qrCode(uri, "gif", 256, qr.M); err == nil {
+		t.Error("expected error for unknown format, received nil")
+	}
+}
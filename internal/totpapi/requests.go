@@ -3,12 +3,19 @@ package totpapi
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 
 	auth "github.com/fmitra/authenticator"
 )
 
+const (
+	minQRSize = 128
+	maxQRSize = 1024
+)
+
 type totpRequest struct {
 	Code string `json:"code"`
 }
@@ -35,3 +42,75 @@ func decodeTOTPRequest(r *http.Request) (*totpRequest, error) {
 
 	return &req, nil
 }
+
+// secretRequest describes an optional QR code rendering of a
+// TOTP URI.
+type secretRequest struct {
+	// QRFormat is the image format of a QR code. No QR code
+	// is rendered if empty.
+	QRFormat string
+	// QRSize is the width and height of a QR code in pixels. The
+	// configured size is used if 0.
+	QRSize int
+	// IsImage indicates the QR code is returned as the response
+	// body instead of being embedded in a JSON response.
+	IsImage bool
+}
+
+// decodeSecretRequest reads the QR code format from the `qr` query
+// parameter, or from an image media type in the Accept header. Media
+// types in the Accept header are considered in order and quality
+// values are ignored.
+func decodeSecretRequest(r *http.Request) (*secretRequest, error) {
+	var req secretRequest
+
+	if r == nil {
+		return &req, nil
+	}
+
+	query := r.URL.Query()
+	if format := strings.ToLower(query.Get("qr")); format != "" {
+		if _, ok := contentTypes[format]; !ok {
+			return nil, auth.ErrBadRequest("qr must be `png` or `svg`")
+		}
+		req.QRFormat = format
+	} else {
+		req.QRFormat, req.IsImage = acceptedImage(r.Header.Get("Accept"))
+	}
+
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			return nil, auth.ErrBadRequest(fmt.Sprintf(
+				"size must be between %v and %v", minQRSize, maxQRSize,
+			))
+		}
+		req.QRSize = n
+	}
+
+	return &req, nil
+}
+
+// acceptedImage returns the QR code format of the first image media
+// type in an Accept header, unless JSON is accepted first.
+func acceptedImage(accept string) (string, bool) {
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case "application/json", "*/*":
+			return "", false
+		}
+
+		for format, contentType := range contentTypes {
+			if mediaType == contentType {
+				return format, true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -61,3 +61,77 @@ func TestTOTPAPI_TOTPRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestTOTPAPI_SecretRequest(t *testing.T) {
+	tt := []struct {
+		name     string
+		url      string
+		accept   string
+		req      *secretRequest
+		hasError bool
+	}{
+		{
+			name:     "No QR code requested",
+			url:      "/api/v1/totp",
+			accept:   "application/json",
+			req:      &secretRequest{},
+			hasError: false,
+		},
+		{
+			name:     "QR code requested by query",
+			url:      "/api/v1/totp?qr=SVG&size=512",
+			accept:   "application/json",
+			req:      &secretRequest{QRFormat: "svg", QRSize: 512},
+			hasError: false,
+		},
+		{
+			name:     "QR code requested by Accept header",
+			url:      "/api/v1/totp",
+			accept:   "image/webp, image/png;q=0.9, application/json;q=0.8",
+			req:      &secretRequest{QRFormat: "png", IsImage: true},
+			hasError: false,
+		},
+		{
+			name:     "JSON accepted before images",
+			url:      "/api/v1/totp",
+			accept:   "application/json, image/png",
+			req:      &secretRequest{},
+			hasError: false,
+		},
+		{
+			name:     "Unknown QR code format",
+			url:      "/api/v1/totp?qr=gif",
+			accept:   "",
+			req:      nil,
+			hasError: true,
+		},
+		{
+			name:     "QR code size out of range",
+			url:      "/api/v1/totp?qr=png&size=4096",
+			accept:   "",
+			req:      nil,
+			hasError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := http.NewRequest("POST", tc.url, nil)
+			if err != nil {
+				t.Fatal("failed to create mock request:", err)
+			}
+			r.Header.Set("Accept", tc.accept)
+
+			req, err := decodeSecretRequest(r)
+			if !tc.hasError && err != nil {
+				t.Error("expected nil error:", err)
+			}
+			if tc.hasError && err == nil {
+				t.Error("expected error, not nil")
+			}
+			if !cmp.Equal(req, tc.req) {
+				t.Error(cmp.Diff(req, tc.req))
+			}
+		})
+	}
+}
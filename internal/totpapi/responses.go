@@ -1,6 +1,8 @@
 package totpapi
 
-// Response is a success response with an embedded TOTP string.
+// Response is a success response with an embedded TOTP string
+// and optionally a QR code of the string as a data URI.
 type Response struct {
-	TOTP string `json:"totp"`
+	TOTP   string `json:"totp"`
+	QRCode string `json:"qrCode,omitempty"`
 }
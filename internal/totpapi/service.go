@@ -3,9 +3,11 @@ package totpapi
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 
+	"github.com/boombuler/barcode/qr"
 	"github.com/go-kit/kit/log"
 
 	auth "github.com/fmitra/authenticator"
@@ -18,15 +20,25 @@ type service struct {
 	otp      auth.OTPService
 	repoMngr auth.RepositoryManager
 	token    auth.TokenService
+	// qrSize is the width and height in pixels of QR codes
+	// unless requested otherwise.
+	qrSize  int
+	qrLevel qr.ErrorCorrectionLevel
 }
 
 // Secret sets a new TOTP secret on a User's profile and delivers it back to the user
 // in the format of a TOTP URI string that is compatible with TOTP generators such as
-// Authy and Google Authenticator.
+// Authy and Google Authenticator. Clients may request the URI rendered as a PNG or
+// SVG QR code, embedded in the response as a data URI or returned as an image.
 func (s *service) Secret(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	ctx := r.Context()
 	userID := httpapi.GetUserID(r)
 
+	req, err := decodeSecretRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
 	if err != nil {
 		return nil, err
@@ -70,7 +82,29 @@ func (s *service) Secret(w http.ResponseWriter, r *http.Request) (interface{}, e
 		return nil, err
 	}
 
-	return &Response{TOTP: totpQRStr}, nil
+	if req.QRFormat == "" {
+		return &Response{TOTP: totpQRStr}, nil
+	}
+
+	size := req.QRSize
+	if size == 0 {
+		size = s.qrSize
+	}
+
+	img, err := qrCode(totpQRStr, req.QRFormat, size, s.qrLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := contentTypes[req.QRFormat]
+	if req.IsImage {
+		return &httpapi.ContentResponse{ContentType: contentType, Content: img}, nil
+	}
+
+	return &Response{
+		TOTP:   totpQRStr,
+		QRCode: fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(img)),
+	}, nil
 }
 
 // Verify validates a recently generated TOTP code. If a code is valid, TOTP is enabled
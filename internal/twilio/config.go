@@ -14,14 +14,21 @@ type Config struct {
 	baseURL    string
 	accountSID string
 	authToken  string
-	smsSender  string
+	// smsSender is the origin phone number for SMS and voice calls.
+	smsSender string
 }
 
 // ConfigOption configures the service.
 type ConfigOption func(*client)
 
+// Client sends SMS and places voice calls through Twilio.
+type Client interface {
+	auth.SMSer
+	auth.Voicer
+}
+
 // NewClient returns a Twilio client.
-func NewClient(configuration ConfigOption) auth.SMSer {
+func NewClient(configuration ConfigOption) Client {
 	c := client{}
 	configuration(&c)
 	return &c
@@ -4,6 +4,7 @@ package twilio
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -60,6 +61,74 @@ func (c *client) SMS(ctx context.Context, phoneNumber string, message string) er
 	return nil
 }
 
+// Voice calls a phone number and reads a message aloud in the language
+// of a locale. The message is read in Twilio's default language if no
+// locale is set.
+func (c *client) Voice(ctx context.Context, phoneNumber, message, locale string) error {
+	url := fmt.Sprintf(
+		"%s/Accounts/%s/Calls.json",
+		c.baseURL,
+		c.accountSID,
+	)
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	twiml, err := sayTwiML(message, locale)
+	if err != nil {
+		return err
+	}
+
+	callTemplate := map[string]string{
+		"To":    phoneNumber,
+		"From":  c.smsSender,
+		"Twiml": twiml,
+	}
+
+	if err = writeFields(writer, callTemplate); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	resp, err := c.request(ctx, url, body, writer)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		rBody, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusCreated, resp.StatusCode, string(rBody))
+	}
+
+	return nil
+}
+
+// sayTwiML returns TwiML instructions to read a message aloud.
+// Reference: https://www.twilio.com/docs/voice/twiml/say
+func sayTwiML(message, locale string) (string, error) {
+	var text bytes.Buffer
+	if err := xml.EscapeText(&text, []byte(message)); err != nil {
+		return "", fmt.Errorf("cannot encode voice message: %w", err)
+	}
+
+	var lang bytes.Buffer
+	if locale != "" {
+		lang.WriteString(` language="`)
+		if err := xml.EscapeText(&lang, []byte(locale)); err != nil {
+			return "", fmt.Errorf("cannot encode voice language: %w", err)
+		}
+		lang.WriteString(`"`)
+	}
+
+	return fmt.Sprintf("<Response><Say%s>%s</Say></Response>", lang.String(), text.String()), nil
+}
+
 func (c *client) request(ctx context.Context, url string, body io.Reader, writer *multipart.Writer) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
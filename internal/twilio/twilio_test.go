@@ -3,6 +3,7 @@ package twilio
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/fmitra/authenticator/internal/test"
@@ -57,3 +58,87 @@ func TestTwilio_SMS(t *testing.T) {
 		})
 	}
 }
+
+func TestTwilio_Voice(t *testing.T) {
+	tt := []struct {
+		name         string
+		message      string
+		locale       string
+		twiml        string
+		responseCode int
+		hasError     bool
+	}{
+		{
+			name:         "Success 201",
+			message:      "Your code is 1, 1, 1",
+			locale:       "",
+			twiml:        "<Response><Say>Your code is 1, 1, 1</Say></Response>",
+			responseCode: http.StatusCreated,
+			hasError:     false,
+		},
+		{
+			name:         "Success with locale 201",
+			message:      "Seu código é 1, 1, 1",
+			locale:       "pt-BR",
+			twiml:        `<Response><Say language="pt-BR">Seu código é 1, 1, 1</Say></Response>`,
+			responseCode: http.StatusCreated,
+			hasError:     false,
+		},
+		{
+			name:         "Escapes message 201",
+			message:      "<Hangup/> & 1",
+			locale:       "",
+			twiml:        "<Response><Say>&lt;Hangup/&gt; &amp; 1</Say></Response>",
+			responseCode: http.StatusCreated,
+			hasError:     false,
+		},
+		{
+			name:         "Invalid 400",
+			message:      "Your code is 1, 1, 1",
+			locale:       "",
+			twiml:        "<Response><Say>Your code is 1, 1, 1</Say></Response>",
+			responseCode: http.StatusBadRequest,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/Accounts/accountSID/Calls.json" {
+					t.Errorf("unexpected request path: %s", r.URL.Path)
+				}
+
+				fields := map[string]string{
+					"To":    "+17777777777",
+					"From":  "+15555555555",
+					"Twiml": tc.twiml,
+				}
+				for k, v := range fields {
+					if r.FormValue(k) != v {
+						t.Errorf("incorrect %s field, want %q got %q", k, v, r.FormValue(k))
+					}
+				}
+
+				w.WriteHeader(tc.responseCode)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			c := NewClient(WithConfig(Config{
+				baseURL:    srv.URL,
+				accountSID: "accountSID",
+				authToken:  "authToken",
+				smsSender:  "+15555555555",
+			}))
+
+			err := c.Voice(ctx, "+17777777777", tc.message, tc.locale)
+			if err != nil && !tc.hasError {
+				t.Error("expected nil error", err)
+			}
+			if err == nil && tc.hasError {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}
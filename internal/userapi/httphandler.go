@@ -0,0 +1,71 @@
+package userapi
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+)
+
+// SetupHTTPHandler converts a service's public methods
+// to http handlers.
+func SetupHTTPHandler(svc auth.UserAPI, router *mux.Router, tokenSvc auth.TokenService, logger log.Logger, lmt httpapi.LimiterFactory) {
+	var handler httpapi.JSONAPIHandler
+	{
+		handler = httpapi.AuthMiddleware(svc.Profile, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.Profile", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.UpdatePassword, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.UpdatePassword", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user/password", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.SendCode, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.SendCode", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user/code", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.DeviceChallenge, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.DeviceChallenge", httpapi.PerMinute, int64(20),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user/verify-device", httpHandler).Methods("Get")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.RecoveryCodes, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.RecoveryCodes", httpapi.PerMinute, int64(5),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user/recovery-codes", httpHandler).Methods("Post")
+	}
+	{
+		handler = httpapi.AuthMiddleware(svc.UpdateLocale, tokenSvc, auth.JWTAuthorized)
+		handler = httpapi.RateLimitMiddleware(handler, lmt.NewLimiter(
+			"UserAPI.UpdateLocale", httpapi.PerMinute, int64(10),
+		))
+		handler = httpapi.ErrorLoggingMiddleware(handler, logger)
+		httpHandler := httpapi.ToHandlerFunc(handler, http.StatusOK)
+		router.HandleFunc("/api/v1/user/locale", httpHandler).Methods("Post")
+	}
+}
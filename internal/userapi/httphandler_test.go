@@ -0,0 +1,825 @@
+package userapi
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/password"
+	"github.com/fmitra/authenticator/internal/test"
+)
+
+func TestUserAPI_UpdatePassword(t *testing.T) {
+	passwordSvc := password.NewPassword(password.WithCost(4))
+	passwordHash, err := passwordSvc.Hash("swordfish")
+	if err != nil {
+		t.Fatal("failed to hash password:", err)
+	}
+
+	tt := []struct {
+		name            string
+		statusCode      int
+		reqBody         []byte
+		errMessage      string
+		user            *auth.User
+		tokenValidateFn func() (*auth.Token, error)
+		validateOTPFn   func(ctx context.Context, id, code, hash string) error
+		validateTOTPFn  func(ctx context.Context, u *auth.User, code string) error
+		validateHOTPFn  func(ctx context.Context, u *auth.User, code string) (int64, error)
+		webauthnFn      func() error
+		withAtomicFn    func() (interface{}, error)
+		revokeAllCalls  int
+	}{
+		{
+			name:       "Invalid token failure",
+			statusCode: http.StatusUnauthorized,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "Token state is not supported",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+		},
+		{
+			name:       "Missing 2FA failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2"
+			}`),
+			errMessage: "Code or device is required",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Invalid current password failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "not-swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "Current password is invalid",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Invalid OTP code failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "654321"
+			}`),
+			errMessage: "Incorrect code provided",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					State:    auth.JWTAuthorized,
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+				}, nil
+			},
+			validateOTPFn: func(ctx context.Context, id, code, hash string) error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+		},
+		{
+			name:       "No code requested failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "No OTP code was requested",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Device not enabled failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"device": {"id": "credential-id"}
+			}`),
+			errMessage: "Device is not enabled",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Database failure",
+			statusCode: http.StatusInternalServerError,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "An internal error occurred",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					State:    auth.JWTAuthorized,
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+				}, nil
+			},
+			validateOTPFn: func(ctx context.Context, id, code, hash string) error {
+				return nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return nil, fmt.Errorf("whoops")
+			},
+		},
+		{
+			name:       "Successful request with OTP",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "",
+			user:       &auth.User{Password: string(passwordHash)},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					State:    auth.JWTAuthorized,
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+				}, nil
+			},
+			validateOTPFn: func(ctx context.Context, id, code, hash string) error {
+				return nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllCalls: 1,
+		},
+		{
+			name:       "Invalid TOTP and HOTP code failure",
+			statusCode: http.StatusBadRequest,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "654321"
+			}`),
+			errMessage: "Incorrect code provided",
+			user: &auth.User{
+				Password:      string(passwordHash),
+				IsTOTPAllowed: true,
+				IsHOTPAllowed: true,
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			validateTOTPFn: func(ctx context.Context, u *auth.User, code string) error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+			validateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+				return 0, auth.ErrInvalidCode("incorrect code provided")
+			},
+		},
+		{
+			name:       "Successful request with HOTP after TOTP mismatch",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"code": "123456"
+			}`),
+			errMessage: "",
+			user: &auth.User{
+				Password:      string(passwordHash),
+				IsTOTPAllowed: true,
+				IsHOTPAllowed: true,
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			validateTOTPFn: func(ctx context.Context, u *auth.User, code string) error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+			validateHOTPFn: func(ctx context.Context, u *auth.User, code string) (int64, error) {
+				return 1, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllCalls: 1,
+		},
+		{
+			name:       "Successful request with device",
+			statusCode: http.StatusOK,
+			reqBody: []byte(`{
+				"currentPassword": "swordfish",
+				"password": "swordfish-2",
+				"device": {"id": "credential-id"}
+			}`),
+			errMessage: "",
+			user: &auth.User{
+				Password:        string(passwordHash),
+				IsDeviceAllowed: true,
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			webauthnFn: func() error {
+				return nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{}, nil
+			},
+			revokeAllCalls: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+				UpdateHOTPCounterFn: func(userID string, current, counter int64) (bool, error) {
+					return true, nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				WithAtomicFn: tc.withAtomicFn,
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTAuthorized}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			otpSvc := &test.OTPService{
+				ValidateOTPFn:  tc.validateOTPFn,
+				ValidateTOTPFn: tc.validateTOTPFn,
+				ValidateHOTPFn: tc.validateHOTPFn,
+			}
+			webauthnSvc := &test.WebAuthnService{
+				FinishLoginFn: tc.webauthnFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithOTP(otpSvc),
+				WithWebAuthn(webauthnSvc),
+				WithPassword(passwordSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/user/password",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if tokenSvc.Calls.RevokeAll != tc.revokeAllCalls {
+				t.Errorf("incorrect TokenService.RevokeAll() call count, want %v got %v",
+					tc.revokeAllCalls, tokenSvc.Calls.RevokeAll)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUserAPI_Profile(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		errMessage      string
+		userFn          func() (*auth.User, error)
+		tokenValidateFn func() (*auth.Token, error)
+	}{
+		{
+			name:       "Invalid token failure",
+			statusCode: http.StatusUnauthorized,
+			errMessage: "Token state is not supported",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{}, nil
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+		},
+		{
+			name:       "User lookup failure",
+			statusCode: http.StatusInternalServerError,
+			errMessage: "An internal error occurred",
+			userFn: func() (*auth.User, error) {
+				return nil, fmt.Errorf("whoops")
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusOK,
+			errMessage: "",
+			userFn: func() (*auth.User, error) {
+				return &auth.User{
+					ID:                "user-id",
+					IsEmailOTPAllowed: true,
+					IsTOTPAllowed:     true,
+					Email: sql.NullString{
+						String: "jane@example.com",
+						Valid:  true,
+					},
+				}, nil
+			},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: tc.userFn,
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+			)
+
+			req, err := http.NewRequest("GET", "/api/v1/user", nil)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUserAPI_SendCode(t *testing.T) {
+	tt := []struct {
+		name           string
+		statusCode     int
+		reqBody        []byte
+		errMessage     string
+		messagingCalls int
+		user           *auth.User
+	}{
+		{
+			name:           "Invalid delivery method failure",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"deliveryMethod": "carrier-pigeon"}`),
+			errMessage:     "DeliveryMethod must be `phone`, `voice` or `email`",
+			messagingCalls: 0,
+			user:           &auth.User{},
+		},
+		{
+			name:           "Delivery method not enabled failure",
+			statusCode:     http.StatusBadRequest,
+			reqBody:        []byte(`{"deliveryMethod": "phone"}`),
+			errMessage:     "Delivery method is not enabled",
+			messagingCalls: 0,
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+		{
+			name:           "Successful request",
+			statusCode:     http.StatusOK,
+			reqBody:        []byte(`{"deliveryMethod": "email"}`),
+			errMessage:     "",
+			messagingCalls: 1,
+			user: &auth.User{
+				IsEmailOTPAllowed: true,
+				Email: sql.NullString{
+					String: "jane@example.com",
+					Valid:  true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: func() (*auth.Token, error) {
+					return &auth.Token{State: auth.JWTAuthorized}, nil
+				},
+				CreateFn: func() (*auth.Token, error) {
+					return &auth.Token{
+						State:    auth.JWTAuthorized,
+						CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+						Code:     test.OTPCode,
+					}, nil
+				},
+				SignFn: func() (string, error) {
+					return "jwt-token", nil
+				},
+			}
+			messagingSvc := &test.MessagingService{}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithMessaging(messagingSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/user/code",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			if messagingSvc.Calls.Send != tc.messagingCalls {
+				t.Errorf("incorrect MessagingService.Send() call count, want %v got %v",
+					tc.messagingCalls, messagingSvc.Calls.Send)
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUserAPI_RecoveryCodes(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		reqBody         []byte
+		errMessage      string
+		user            *auth.User
+		tokenValidateFn func() (*auth.Token, error)
+		validateOTPFn   func(ctx context.Context, id, code, hash string) error
+		withAtomicFn    func() (interface{}, error)
+		codes           []string
+	}{
+		{
+			name:       "Invalid token failure",
+			statusCode: http.StatusUnauthorized,
+			reqBody:    []byte(`{"code": "123456"}`),
+			errMessage: "Token state is not supported",
+			user:       &auth.User{},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+		},
+		{
+			name:       "Missing 2FA failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{}`),
+			errMessage: "Code or device is required",
+			user:       &auth.User{},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Invalid code failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"code": "123456"}`),
+			errMessage: "Incorrect code provided",
+			user:       &auth.User{},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{
+					State:    auth.JWTAuthorized,
+					CodeHash: test.MockTokenHash("", "", time.Now().Add(time.Minute*5).Unix()),
+				}, nil
+			},
+			validateOTPFn: func(ctx context.Context, id, code, hash string) error {
+				return auth.ErrInvalidCode("incorrect code provided")
+			},
+		},
+		{
+			name:       "Storage failure",
+			statusCode: http.StatusInternalServerError,
+			reqBody:    []byte(`{"code": "123456"}`),
+			errMessage: "An internal error occurred",
+			user:       &auth.User{IsTOTPAllowed: true},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return nil, fmt.Errorf("whoops")
+			},
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"code": "123456"}`),
+			errMessage: "",
+			user:       &auth.User{IsTOTPAllowed: true},
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{IsRecoveryCodeAllowed: true}, nil
+			},
+			codes: []string{"abcde-12345", "fghij-67890"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			userRepo := &test.UserRepository{
+				ByIdentityFn: func() (*auth.User, error) {
+					return tc.user, nil
+				},
+			}
+			repoMngr := &test.RepositoryManager{
+				WithAtomicFn: tc.withAtomicFn,
+				UserFn: func() auth.UserRepository {
+					return userRepo
+				},
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+			}
+			otpSvc := &test.OTPService{
+				ValidateOTPFn: tc.validateOTPFn,
+				RecoveryCodesFn: func() ([]string, error) {
+					return []string{"abcde-12345", "fghij-67890"}, nil
+				},
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+				WithOTP(otpSvc),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/user/recovery-codes",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.codes == nil {
+				return
+			}
+
+			var resp recoveryCodesResponse
+			if err = json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal("failed to decode response:", err)
+			}
+			if !cmp.Equal(resp.Codes, tc.codes) {
+				t.Error("recovery codes do not match", cmp.Diff(resp.Codes, tc.codes))
+			}
+		})
+	}
+}
+
+func TestUserAPI_UpdateLocale(t *testing.T) {
+	tt := []struct {
+		name            string
+		statusCode      int
+		reqBody         []byte
+		errMessage      string
+		tokenValidateFn func() (*auth.Token, error)
+		withAtomicFn    func() (interface{}, error)
+		locale          string
+	}{
+		{
+			name:       "Invalid token failure",
+			statusCode: http.StatusUnauthorized,
+			reqBody:    []byte(`{"locale": "pt-BR"}`),
+			errMessage: "Token state is not supported",
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTPreAuthorized}, nil
+			},
+		},
+		{
+			name:       "Invalid locale failure",
+			statusCode: http.StatusBadRequest,
+			reqBody:    []byte(`{"locale": "<script>"}`),
+			errMessage: "Locale must be a valid language tag",
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+		},
+		{
+			name:       "Storage failure",
+			statusCode: http.StatusInternalServerError,
+			reqBody:    []byte(`{"locale": "pt-BR"}`),
+			errMessage: "An internal error occurred",
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return nil, fmt.Errorf("whoops")
+			},
+		},
+		{
+			name:       "Successful request",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"locale": " pt-BR "}`),
+			errMessage: "",
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{ID: "user-id", Locale: "pt-BR"}, nil
+			},
+			locale: "pt-BR",
+		},
+		{
+			name:       "Successful reset request",
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"locale": ""}`),
+			errMessage: "",
+			tokenValidateFn: func() (*auth.Token, error) {
+				return &auth.Token{State: auth.JWTAuthorized}, nil
+			},
+			withAtomicFn: func() (interface{}, error) {
+				return &auth.User{ID: "user-id"}, nil
+			},
+			locale: "",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			repoMngr := &test.RepositoryManager{
+				WithAtomicFn: tc.withAtomicFn,
+			}
+			tokenSvc := &test.TokenService{
+				ValidateFn: tc.tokenValidateFn,
+			}
+			svc := NewService(
+				WithLogger(&test.Logger{}),
+				WithTokenService(tokenSvc),
+				WithRepoManager(repoMngr),
+			)
+
+			req, err := http.NewRequest(
+				"POST",
+				"/api/v1/user/locale",
+				bytes.NewBuffer(tc.reqBody),
+			)
+			if err != nil {
+				t.Fatal("failed to create request:", err)
+			}
+
+			test.SetAuthHeaders(req)
+
+			logger := log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+			SetupHTTPHandler(svc, router, tokenSvc, logger, &httpapi.MockLimiterFactory{})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.statusCode {
+				t.Errorf("incorrect status code, want %v got %v", tc.statusCode, rr.Code)
+				t.Error(rr.Body.String())
+			}
+
+			err = test.ValidateErrMessage(tc.errMessage, rr.Body)
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tc.statusCode != http.StatusOK {
+				return
+			}
+
+			var resp profileResponse
+			if err = json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal("failed to decode response:", err)
+			}
+			if resp.Locale != tc.locale {
+				t.Errorf("incorrect locale, want %q got %q", tc.locale, resp.Locale)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package userapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// tfaRequest contains the fields used to complete 2FA before
+// changing account settings.
+type tfaRequest struct {
+	// Code is an OTP or TOTP code used to complete 2FA.
+	Code string `json:"code"`
+	// Device is a signed WebAuthn challenge used to complete 2FA.
+	Device json.RawMessage `json:"device"`
+}
+
+type passwordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	Password        string `json:"password"`
+	tfaRequest
+}
+
+type sendCodeRequest struct {
+	DeliveryMethod auth.DeliveryMethod `json:"deliveryMethod"`
+}
+
+type localeRequest struct {
+	Locale string `json:"locale"`
+}
+
+// localeRe matches a BCP 47 language tag such as en, pt-BR or zh-Hant-TW.
+var localeRe = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+func decodePasswordRequest(r *http.Request) (*passwordRequest, error) {
+	var (
+		req passwordRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+
+	if req.Code == "" && len(req.Device) == 0 {
+		return nil, auth.ErrBadRequest("code or device is required")
+	}
+
+	return &req, nil
+}
+
+func decodeTFARequest(r *http.Request) (*tfaRequest, error) {
+	var (
+		req tfaRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+
+	if req.Code == "" && len(req.Device) == 0 {
+		return nil, auth.ErrBadRequest("code or device is required")
+	}
+
+	return &req, nil
+}
+
+func decodeSendCodeRequest(r *http.Request) (*sendCodeRequest, error) {
+	var (
+		req sendCodeRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	if !req.DeliveryMethod.IsPhone() && req.DeliveryMethod != auth.Email {
+		return nil, auth.ErrInvalidField("deliveryMethod must be `phone`, `voice` or `email`")
+	}
+
+	return &req, nil
+}
+
+func decodeLocaleRequest(r *http.Request) (*localeRequest, error) {
+	var (
+		req localeRequest
+		err error
+	)
+
+	if r == nil || r.Body == nil {
+		return nil, auth.ErrBadRequest("no request body received")
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("invalid JSON request"))
+	}
+
+	req.Locale = strings.TrimSpace(req.Locale)
+
+	// An empty locale resets the User to the default language.
+	if req.Locale != "" && (len(req.Locale) > 35 || !localeRe.MatchString(req.Locale)) {
+		return nil, auth.ErrInvalidField("locale must be a valid language tag")
+	}
+
+	return &req, nil
+}
@@ -0,0 +1,54 @@
+package userapi
+
+import (
+	"time"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// profileResponse is a success response for UserAPI.Profile.
+type profileResponse struct {
+	ID         string            `json:"id"`
+	Email      string            `json:"email"`
+	Phone      string            `json:"phone"`
+	TFAOptions []auth.TFAOptions `json:"tfaOptions"`
+	DefaultTFA auth.TFAOptions   `json:"defaultTFA"`
+	Locale     string            `json:"locale"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// Create populates fields in a profileResponse.
+func (r *profileResponse) Create(user *auth.User) {
+	r.ID = user.ID
+	r.Email = user.Email.String
+	r.Phone = user.Phone.String
+	r.DefaultTFA = user.DefaultTFA()
+	r.Locale = user.Locale
+	r.CreatedAt = user.CreatedAt
+
+	r.TFAOptions = []auth.TFAOptions{}
+	if user.IsPhoneOTPAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.OTPPhone)
+	}
+	if user.IsEmailOTPAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.OTPEmail)
+	}
+	if user.IsTOTPAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.TOTP)
+	}
+	if user.IsHOTPAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.HOTP)
+	}
+	if user.IsDeviceAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.FIDODevice)
+	}
+	if user.IsRecoveryCodeAllowed {
+		r.TFAOptions = append(r.TFAOptions, auth.RecoveryCode)
+	}
+}
+
+// recoveryCodesResponse is a success response for UserAPI.RecoveryCodes.
+// Codes are only available in plain text in this response.
+type recoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
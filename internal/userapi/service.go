@@ -0,0 +1,351 @@
+// Package userapi provides an HTTP API for account management.
+package userapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+
+	auth "github.com/fmitra/authenticator"
+	"github.com/fmitra/authenticator/internal/httpapi"
+	"github.com/fmitra/authenticator/internal/otp"
+	tokenLib "github.com/fmitra/authenticator/internal/token"
+)
+
+type service struct {
+	logger   log.Logger
+	token    auth.TokenService
+	repoMngr auth.RepositoryManager
+	otp      auth.OTPService
+	password auth.PasswordService
+	webauthn auth.WebAuthnService
+	message  auth.MessagingService
+}
+
+// UpdatePassword changes a User's password. The User must provide their
+// current password and complete 2FA with an OTP code, TOTP code or a
+// signed device challenge. All other sessions are revoked on success.
+func (s *service) UpdatePassword(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+	token := httpapi.GetToken(r)
+
+	req, err := decodePasswordRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users registered in passwordless mode may set an initial
+	// password with 2FA alone.
+	if user.Password != "" {
+		if err = s.password.Validate(user, req.CurrentPassword); err != nil {
+			return nil, fmt.Errorf("%v: %w", err, auth.ErrBadRequest("current password is invalid"))
+		}
+	}
+
+	if err = s.password.OKForUser(req.Password); err != nil {
+		return nil, err
+	}
+
+	if err = s.password.CheckBreached(ctx, req.Password); err != nil {
+		return nil, err
+	}
+
+	if err = s.verifyTFA(ctx, r, user, token, &req.tfaRequest); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := s.password.Hash(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("cannot hash password: %w", err)
+	}
+
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := client.WithAtomic(func() (interface{}, error) {
+		user, err := client.User().GetForUpdate(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Password = string(passwordHash)
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot update password: %w", err)
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Other sessions may have been established with the old password.
+	if err = s.token.RevokeAll(ctx, userID, token.Id); err != nil {
+		return nil, err
+	}
+
+	// A new token is issued without the OTP code hash to ensure
+	// the code may not be used again.
+	token, err = s.token.Create(
+		ctx,
+		entity.(*auth.User),
+		auth.JWTAuthorized,
+		tokenLib.WithRefreshableToken(token),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signedToken, err := s.token.Sign(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenLib.Response{Token: signedToken}, nil
+}
+
+// Profile returns a User's contact addresses and enabled TFA options.
+func (s *service) Profile(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp profileResponse
+	resp.Create(user)
+
+	return &resp, nil
+}
+
+// SendCode delivers an OTP code to an address enabled for OTP delivery.
+// The code hash is embedded in a new token which must accompany the
+// request to change account settings.
+func (s *service) SendCode(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	req, err := decodeSendCodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var address string
+	if req.DeliveryMethod.IsPhone() && user.IsPhoneOTPAllowed {
+		address = user.Phone.String
+	}
+	if req.DeliveryMethod == auth.Email && user.IsEmailOTPAllowed {
+		address = user.Email.String
+	}
+	if address == "" {
+		return nil, auth.ErrBadRequest("delivery method is not enabled")
+	}
+
+	token := httpapi.GetToken(r)
+	token, err = s.token.Create(
+		ctx,
+		user,
+		auth.JWTAuthorized,
+		tokenLib.WithOTPDeliveryMethod(req.DeliveryMethod),
+		tokenLib.WithOTPMessageType(auth.OTPConfirm),
+		tokenLib.WithOTPAddress(address),
+		tokenLib.WithRefreshableToken(token),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signedToken, err := s.token.Sign(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := otp.FromOTPHash(token.CodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTP created: %w", err)
+	}
+
+	msg := &auth.Message{
+		Type:     auth.OTPConfirm,
+		Delivery: h.DeliveryMethod,
+		Vars:     map[string]string{"code": token.Code},
+		Address:  h.Address,
+		Locale:   user.Locale,
+	}
+	if err = s.message.Send(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return &tokenLib.Response{Token: signedToken}, nil
+}
+
+// DeviceChallenge requests a challenge to be signed by the client.
+// The signed challenge must accompany the request to change account
+// settings.
+func (s *service) DeviceChallenge(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsDeviceAllowed {
+		return nil, auth.ErrBadRequest("device is not enabled")
+	}
+
+	return s.webauthn.BeginLogin(ctx, user)
+}
+
+// RecoveryCodes generates a new set of recovery codes for a User after
+// completing 2FA. Any previously issued codes are invalidated. Codes are
+// only stored as hashes and may not be retrieved again.
+func (s *service) RecoveryCodes(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+	token := httpapi.GetToken(r)
+
+	req, err := decodeTFARequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repoMngr.User().ByIdentity(ctx, "ID", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.verifyTFA(ctx, r, user, token, req); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.otp.RecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.WithAtomic(func() (interface{}, error) {
+		user, err := client.User().GetForUpdate(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = client.RecoveryCode().Replace(ctx, userID, codes); err != nil {
+			return nil, err
+		}
+
+		if user.IsRecoveryCodeAllowed {
+			return user, nil
+		}
+
+		user.IsRecoveryCodeAllowed = true
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot enable recovery codes: %w", err)
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &recoveryCodesResponse{Codes: codes}, nil
+}
+
+// UpdateLocale sets the language a User receives messages in. An empty
+// locale resets the User to the default language.
+func (s *service) UpdateLocale(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	req, err := decodeLocaleRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	userID := httpapi.GetUserID(r)
+
+	client, err := s.repoMngr.NewWithTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := client.WithAtomic(func() (interface{}, error) {
+		user, err := client.User().GetForUpdate(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		user.Locale = req.Locale
+		if err = client.User().Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("cannot update locale: %w", err)
+		}
+
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp profileResponse
+	resp.Create(entity.(*auth.User))
+
+	return &resp, nil
+}
+
+// verifyTFA validates a 2FA attempt. A signed device challenge is preferred
+// if provided. Otherwise the code is validated against the OTP code hash
+// embedded in the token, falling back to the User's TOTP secret. If the
+// User has both an authenticator app and an HOTP token, a code rejected
+// as a TOTP is tried against the HOTP token.
+func (s *service) verifyTFA(ctx context.Context, r *http.Request, user *auth.User, token *auth.Token, req *tfaRequest) error {
+	if len(req.Device) > 0 {
+		if !user.IsDeviceAllowed {
+			return auth.ErrBadRequest("device is not enabled")
+		}
+
+		deviceReq := r.Clone(ctx)
+		deviceReq.Body = ioutil.NopCloser(bytes.NewReader(req.Device))
+		return s.webauthn.FinishLogin(ctx, user, deviceReq)
+	}
+
+	if token.CodeHash != "" {
+		return s.otp.ValidateOTP(ctx, token.Id, req.Code, token.CodeHash)
+	}
+
+	if user.IsTOTPAllowed {
+		err := s.otp.ValidateTOTP(ctx, user, req.Code)
+		if err == nil || !user.IsHOTPAllowed || auth.ErrorCode(err) != auth.EInvalidCode {
+			return err
+		}
+	}
+
+	if user.IsHOTPAllowed {
+		return otp.VerifyHOTP(ctx, s.otp, s.repoMngr.User(), user, req.Code)
+	}
+
+	return auth.ErrBadRequest("no OTP code was requested")
+}
@@ -0,0 +1,49 @@
+package vonage
+
+import (
+	"strings"
+
+	auth "github.com/fmitra/authenticator"
+)
+
+// defaultBaseURL is the default Vonage (formerly Nexmo) SMS API endpoint.
+const defaultBaseURL = "https://rest.nexmo.com"
+
+// Config holds configuration options for Vonage.
+type Config struct {
+	baseURL   string
+	apiKey    string
+	apiSecret string
+	smsSender string
+}
+
+// ConfigOption configures the service.
+type ConfigOption func(*client)
+
+// NewClient returns a Vonage client.
+func NewClient(configuration ConfigOption) auth.SMSer {
+	c := client{}
+	configuration(&c)
+	return &c
+}
+
+// WithConfig configures the service with a Config.
+func WithConfig(config Config) ConfigOption {
+	return func(c *client) {
+		c.baseURL = strings.TrimSuffix(config.baseURL, "/")
+		c.apiKey = config.apiKey
+		c.apiSecret = config.apiSecret
+		c.smsSender = config.smsSender
+	}
+}
+
+// WithDefaults configures a Vonage client with a user's API key and
+// secret and configures all other values to default.
+func WithDefaults(apiKey, apiSecret, smsSender string) ConfigOption {
+	return WithConfig(Config{
+		baseURL:   defaultBaseURL,
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		smsSender: smsSender,
+	})
+}
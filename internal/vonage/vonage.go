@@ -0,0 +1,92 @@
+// Package vonage exposes the Vonage (formerly Nexmo) SMS API.
+package vonage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// client is a consumer of the Vonage SMS API.
+type client struct {
+	baseURL   string
+	apiKey    string
+	apiSecret string
+	smsSender string
+}
+
+// smsResponse is the response of the SMS API. A request may succeed
+// while individual messages fail.
+type smsResponse struct {
+	Messages []struct {
+		Status    string `json:"status"`
+		ErrorText string `json:"error-text"`
+	} `json:"messages"`
+}
+
+// SMS sends an SMS message to a phone number.
+func (c *client) SMS(ctx context.Context, phoneNumber string, message string) error {
+	form := url.Values{
+		"api_key":    {c.apiKey},
+		"api_secret": {c.apiSecret},
+		// Numbers are expected in E.164 format without a leading +.
+		"from": {strings.TrimPrefix(c.smsSender, "+")},
+		"to":   {strings.TrimPrefix(phoneNumber, "+")},
+		"text": {message},
+	}
+	if !isASCII(message) {
+		form.Set("type", "unicode")
+	}
+
+	u := fmt.Sprintf("%s/sms/json", c.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("cannot create HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	rBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status %v, got %v: %s",
+			http.StatusOK, resp.StatusCode, string(rBody))
+	}
+
+	var smsResp smsResponse
+	if err = json.Unmarshal(rBody, &smsResp); err != nil {
+		return fmt.Errorf("cannot decode response: %w", err)
+	}
+
+	if len(smsResp.Messages) == 0 {
+		return fmt.Errorf("no messages sent: %s", string(rBody))
+	}
+
+	for _, m := range smsResp.Messages {
+		if m.Status != "0" {
+			return fmt.Errorf("message failed with status %s: %s", m.Status, m.ErrorText)
+		}
+	}
+
+	return nil
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package vonage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVonage_SMS(t *testing.T) {
+	tt := []struct {
+		name         string
+		message      string
+		msgType      string
+		responseCode int
+		resp         string
+		hasError     bool
+	}{
+		{
+			name:         "Success 200",
+			message:      "Your login code is 111",
+			msgType:      "",
+			responseCode: http.StatusOK,
+			resp:         `{"message-count": "1", "messages": [{"status": "0", "message-id": "id"}]}`,
+			hasError:     false,
+		},
+		{
+			name:         "Success unicode 200",
+			message:      "Seu código é 111",
+			msgType:      "unicode",
+			responseCode: http.StatusOK,
+			resp:         `{"message-count": "1", "messages": [{"status": "0", "message-id": "id"}]}`,
+			hasError:     false,
+		},
+		{
+			name:         "Rejected message 200",
+			message:      "Your login code is 111",
+			msgType:      "",
+			responseCode: http.StatusOK,
+			resp:         `{"message-count": "1", "messages": [{"status": "4", "error-text": "Bad Credentials"}]}`,
+			hasError:     true,
+		},
+		{
+			name:         "Invalid 500",
+			message:      "Your login code is 111",
+			msgType:      "",
+			responseCode: http.StatusInternalServerError,
+			resp:         `{}`,
+			hasError:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/sms/json" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+
+				fields := map[string]string{
+					"api_key":    "api-key",
+					"api_secret": "api-secret",
+					"from":       "15555555555",
+					"to":         "17777777777",
+					"text":       tc.message,
+					"type":       tc.msgType,
+				}
+				for k, v := range fields {
+					if r.FormValue(k) != v {
+						t.Errorf("incorrect %s field, want %q got %q", k, v, r.FormValue(k))
+					}
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.responseCode)
+				w.Write([]byte(tc.resp))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			c := NewClient(WithConfig(Config{
+				baseURL:   srv.URL,
+				apiKey:    "api-key",
+				apiSecret: "api-secret",
+				smsSender: "+15555555555",
+			}))
+
+			err := c.SMS(ctx, "+17777777777", tc.message)
+			if err != nil && !tc.hasError {
+				t.Error("expected nil error", err)
+			}
+			if err == nil && tc.hasError {
+				t.Error("expected error, received nil")
+			}
+		})
+	}
+}